@@ -2,13 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
@@ -16,9 +19,14 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"certificate-monkey/docs"
+	"certificate-monkey/internal/acme"
+	"certificate-monkey/internal/api/handlers"
 	"certificate-monkey/internal/api/routes"
+	"certificate-monkey/internal/audit"
 	appConfig "certificate-monkey/internal/config"
 	"certificate-monkey/internal/crypto"
+	"certificate-monkey/internal/metrics"
+	"certificate-monkey/internal/notify"
 	"certificate-monkey/internal/storage"
 	"certificate-monkey/internal/version"
 )
@@ -63,6 +71,13 @@ func main() {
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to load configuration")
 	}
+	if insecure := cfg.InsecureAPIKeys(); len(insecure) > 0 {
+		// Refused in production by Validate; elsewhere make sure it is noticed
+		logger.WithFields(logrus.Fields{
+			"settings":    insecure,
+			"environment": cfg.Environment,
+		}).Warn("INSECURE: default API keys are in use; anyone who knows them can access the API. Set API_KEY_1 and API_KEY_2")
+	}
 
 	// Update Swagger info with current version
 	docs.SwaggerInfo.Version = version.GetVersion()
@@ -72,6 +87,13 @@ func main() {
 		"build_info": version.Get(),
 	}).Info("Starting 🐒 Certificate Monkey API")
 
+	// Initialize the metrics backend
+	recorder, err := metrics.NewRecorder(cfg.Metrics.Backend, cfg.Metrics.Namespace)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize metrics")
+	}
+	metrics.SetRecorder(recorder)
+
 	// Initialize AWS configuration
 	awsCfg, err := config.LoadDefaultConfig(context.TODO(),
 		config.WithRegion(cfg.AWS.Region),
@@ -82,41 +104,125 @@ func main() {
 
 	// Initialize AWS clients
 	dynamoClient := dynamodb.NewFromConfig(awsCfg)
-	kmsClient := kms.NewFromConfig(awsCfg)
+	// KMS retries are owned by the storage layer, which retries throttled and transient Encrypt and
+	// Decrypt calls KMS_MAX_ATTEMPTS times, so the SDK's own retryer is disabled rather than
+	// multiplying the attempts
+	kmsClient := kms.NewFromConfig(awsCfg, func(o *kms.Options) {
+		o.Retryer = aws.NopRetryer{}
+	})
 
 	// Initialize storage layer
 	dbStorage := storage.NewDynamoDBStorage(dynamoClient, kmsClient, cfg, logger)
 
+	// Initialize the optional event log
+	var events *storage.EventStore
+	if cfg.AWS.EventsTable != "" {
+		events = storage.NewEventStore(dynamoClient, cfg.AWS.EventsTable, logger)
+		logger.WithField("table", cfg.AWS.EventsTable).Info("Event log enabled")
+	}
+
+	// Initialize the audit sink; the DynamoDB sink is the event log and records nothing without one
+	var auditSink audit.Sink
+	if cfg.Audit.Sink == audit.SinkDynamoDB {
+		if events != nil {
+			auditSink = events
+		}
+	} else {
+		auditSink, err = audit.NewSink(cfg.Audit.Sink, cfg.Audit.FilePath, cfg.Audit.FileMaxSize)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to initialize audit sink")
+		}
+		if closer, ok := auditSink.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
+	logger.WithField("sink", cfg.Audit.Sink).Info("Audit sink configured")
+
 	// Initialize crypto service
 	cryptoService := crypto.NewCryptoService()
+	cryptoService.EnableRSAKeyPools(cfg.Certificates.RSAKeyPoolSize, cfg.Certificates.AllowedKeyTypes)
+	cryptoService.SetLowercaseCommonNames(cfg.Certificates.NormalizeCNLowercase)
+	cryptoService.SetMaxSANs(cfg.Certificates.MaxSANs)
+	defer cryptoService.Close()
+
+	// Initialize the optional ACME issuer
+	var issuer handlers.CertificateIssuer
+	if cfg.ACME.Enabled {
+		accountKey, err := acme.LoadAccountKey(cfg.ACME.AccountKeyPath)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to load ACME account key")
+		}
+		dnsProvider := acme.NewRoute53Provider(cfg.ACME.Route53HostedZoneID, awsCfg.Credentials)
+		issuer = acme.NewIssuer(cfg.ACME.DirectoryURL, accountKey, cfg.ACME.Email, dnsProvider, logger)
+		logger.WithField("directory_url", cfg.ACME.DirectoryURL).Info("ACME issuance enabled")
+	}
+
+	// Initialize notifications to the webhook and SNS topic, for the events enabled with NOTIFY_EVENTS
+	var notifiers []notify.Notifier
+	if cfg.Notify.WebhookURL != "" {
+		notifiers = append(notifiers, notify.NewWebhookNotifier(cfg.Notify.WebhookURL, cfg.Notify.Timeout))
+	}
+	if cfg.Notify.SNSTopicARN != "" {
+		snsClient := notify.NewSNSClient(awsCfg.Credentials, cfg.Notify.Timeout)
+		notifiers = append(notifiers, notify.NewSNSNotifier(snsClient, cfg.Notify.SNSTopicARN))
+		logger.WithField("topic_arn", cfg.Notify.SNSTopicARN).Info("SNS notifications enabled")
+	}
+	var notifier notify.Notifier
+	if len(notifiers) > 0 {
+		notifier = notify.Filter(notify.Multi(notifiers...), cfg.Notify.Events)
+	}
 
 	// Set up routes
-	router := routes.SetupRoutes(cfg, dbStorage, cryptoService, logger)
+	router := routes.SetupRoutes(cfg, dbStorage, events, auditSink, notifier, cryptoService, issuer, logger)
 
 	// Add build info endpoint
 	router.GET("/build-info", func(c *gin.Context) {
 		c.JSON(http.StatusOK, version.GetBuildInfo())
 	})
 
+	// Configure TLS, including client certificate verification when mTLS is enabled
+	tlsConfig, err := cfg.TLS.ServerTLSConfig()
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to configure TLS")
+	}
+
 	// Create HTTP server
 	server := &http.Server{
 		Addr:              fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port),
 		Handler:           router,
-		ReadTimeout:       15 * time.Second,
+		ReadTimeout:       cfg.Server.ReadTimeout,
 		ReadHeaderTimeout: 5 * time.Second,
-		WriteTimeout:      15 * time.Second,
-		IdleTimeout:       60 * time.Second,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		TLSConfig:         tlsConfig,
+		// net/http refuses headers well past this with 431 before HeaderGuard sees them
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		logger.WithFields(logrus.Fields{
-			"host":    cfg.Server.Host,
-			"port":    cfg.Server.Port,
-			"version": version.GetVersion(),
-		}).Info("Server starting")
+		entry := logger.WithFields(logrus.Fields{
+			"host":          cfg.Server.Host,
+			"port":          cfg.Server.Port,
+			"read_timeout":  cfg.Server.ReadTimeout.String(),
+			"write_timeout": cfg.Server.WriteTimeout.String(),
+			"idle_timeout":  cfg.Server.IdleTimeout.String(),
+			"tls":           cfg.TLS.Enabled(),
+			"mtls":          cfg.TLS.MTLSEnabled(),
+			"version":       version.GetVersion(),
+		})
+		if cfg.TLS.Enabled() {
+			entry = entry.WithField("tls_min_version", tls.VersionName(tlsConfig.MinVersion))
+		}
+		entry.Info("Server starting")
 
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.TLS.Enabled() {
+			err = server.ListenAndServeTLS(cfg.TLS.CertPath, cfg.TLS.KeyPath)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.WithError(err).Fatal("Server failed to start")
 		}
 	}()
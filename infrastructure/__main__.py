@@ -3,6 +3,7 @@ Certificate Monkey Infrastructure
 
 This Pulumi program creates the AWS infrastructure required for Certificate Monkey:
 - DynamoDB table with proper schema and GSI
+- DynamoDB table for the durable event log
 - KMS key for encrypting private keys
 - IAM policy (optional, for reference)
 """
@@ -14,6 +15,7 @@ import pulumi_aws as aws
 config = pulumi.Config()
 environment = config.get("environment", "dev")
 table_name = config.get("table_name", f"certificate-monkey-{environment}")
+events_table_name = config.get("events_table_name", f"certificate-monkey-{environment}-events")
 
 # Get current AWS account ID and region for the key policy
 current = aws.get_caller_identity()
@@ -138,6 +140,11 @@ dynamodb_table = aws.dynamodb.Table(
     point_in_time_recovery=aws.dynamodb.TablePointInTimeRecoveryArgs(
         enabled=True
     ),
+    # Delete entities created with ttl_days once their expires_ttl has passed
+    ttl=aws.dynamodb.TableTtlArgs(
+        attribute_name="expires_ttl",
+        enabled=True
+    ),
     tags={
         "Name": table_name,
         "Environment": environment,
@@ -147,6 +154,40 @@ dynamodb_table = aws.dynamodb.Table(
     # deletion_protection_enabled=True # TODO: enable in prod
 )
 
+# Create DynamoDB table for the durable event log
+events_table = aws.dynamodb.Table(
+    "certificate-monkey-events-table",
+    name=events_table_name,
+    billing_mode="PAY_PER_REQUEST",  # On-demand pricing
+    hash_key="entity_id",
+    range_key="timestamp",
+    attributes=[
+        aws.dynamodb.TableAttributeArgs(
+            name="entity_id",
+            type="S"  # String
+        ),
+        aws.dynamodb.TableAttributeArgs(
+            name="timestamp",
+            type="S"  # String (fixed-width ISO 8601 timestamp, sorts in time order)
+        )
+    ],
+    # Enable server-side encryption with KMS
+    server_side_encryption=aws.dynamodb.TableServerSideEncryptionArgs(
+        enabled=True,
+        kms_key_arn=kms_key.arn
+    ),
+    # Enable point-in-time recovery
+    point_in_time_recovery=aws.dynamodb.TablePointInTimeRecoveryArgs(
+        enabled=True
+    ),
+    tags={
+        "Name": events_table_name,
+        "Environment": environment,
+        "Application": "certificate-monkey",
+        "Purpose": "certificate-event-log"
+    },
+)
+
 # Create IAM policy for the application (for reference)
 app_policy_document = aws.iam.get_policy_document(
     statements=[
@@ -158,6 +199,7 @@ app_policy_document = aws.iam.get_policy_document(
                 "dynamodb:GetItem",
                 "dynamodb:UpdateItem",
                 "dynamodb:DeleteItem",
+                "dynamodb:BatchWriteItem",
                 "dynamodb:Scan"
             ],
             resources=[
@@ -165,6 +207,16 @@ app_policy_document = aws.iam.get_policy_document(
                 pulumi.Output.concat(dynamodb_table.arn, "/index/*")
             ]
         ),
+        # The event log is append-only
+        aws.iam.GetPolicyDocumentStatementArgs(
+            effect="Allow",
+            actions=[
+                "dynamodb:PutItem",
+                "dynamodb:Query",
+                "dynamodb:DescribeTable"
+            ],
+            resources=[events_table.arn]
+        ),
         # KMS permissions for application use
         aws.iam.GetPolicyDocumentStatementArgs(
             effect="Allow",
@@ -201,6 +253,7 @@ app_policy = aws.iam.Policy(
 # Outputs for easy reference
 pulumi.export("dynamodb_table_name", dynamodb_table.name)
 pulumi.export("dynamodb_table_arn", dynamodb_table.arn)
+pulumi.export("dynamodb_events_table_name", events_table.name)
 pulumi.export("kms_key_id", kms_key.key_id)
 pulumi.export("kms_key_arn", kms_key.arn)
 pulumi.export("kms_alias_name", kms_alias.name)
@@ -209,6 +262,7 @@ pulumi.export("iam_policy_arn", app_policy.arn)
 # Environment variables for the application
 pulumi.export("environment_variables", {
     "DYNAMODB_TABLE": dynamodb_table.name,
+    "DYNAMODB_EVENTS_TABLE": events_table.name,
     "KMS_KEY_ID": kms_alias.name,
     "AWS_REGION": region.name
 })
@@ -0,0 +1,266 @@
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	xacme "golang.org/x/crypto/acme"
+)
+
+// challengeType is the only ACME challenge the issuer solves
+const challengeType = "dns-01"
+
+// DNSProvider publishes the TXT records that answer DNS-01 challenges.
+// A name receives several values when an order covers both a domain and its wildcard.
+type DNSProvider interface {
+	// Present creates the TXT record and returns once the CA can be expected to see it
+	Present(ctx context.Context, fqdn string, values []string) error
+	// CleanUp removes a record created by Present
+	CleanUp(ctx context.Context, fqdn string, values []string) error
+}
+
+// Issuer obtains certificates for existing CSRs from an ACME CA such as Let's Encrypt
+type Issuer struct {
+	client  *xacme.Client
+	contact []string
+	dns     DNSProvider
+	logger  *logrus.Logger
+
+	mu         sync.Mutex
+	registered bool
+}
+
+// NewIssuer creates an issuer for the ACME directory; the account is registered on first use
+func NewIssuer(directoryURL string, accountKey crypto.Signer, email string, dns DNSProvider, logger *logrus.Logger) *Issuer {
+	var contact []string
+	if email != "" {
+		contact = []string{"mailto:" + email}
+	}
+
+	return &Issuer{
+		client: &xacme.Client{
+			Key:          accountKey,
+			DirectoryURL: directoryURL,
+			UserAgent:    "certificate-monkey",
+		},
+		contact: contact,
+		dns:     dns,
+		logger:  logger,
+	}
+}
+
+// LoadAccountKey reads a PEM-encoded EC or RSA private key to use as the ACME account key
+func LoadAccountKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ACME account key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode ACME account key PEM block")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported ACME account key PEM block type: %s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ACME account key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("ACME account key of type %T cannot sign", key)
+	}
+	return signer, nil
+}
+
+// Issue performs an ACME order for the names in a PEM CSR and returns the issued
+// certificate and the chain of issuing certificates, both PEM-encoded
+func (i *Issuer) Issue(ctx context.Context, csrPEM string) (certificate, chain string, err error) {
+	csr, err := parseCSR(csrPEM)
+	if err != nil {
+		return "", "", err
+	}
+
+	names, err := orderNames(csr)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := i.register(ctx); err != nil {
+		return "", "", err
+	}
+
+	order, err := i.client.AuthorizeOrder(ctx, xacme.DomainIDs(names...))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create ACME order: %w", err)
+	}
+
+	if err := i.authorize(ctx, order.AuthzURLs); err != nil {
+		return "", "", err
+	}
+
+	if _, err := i.client.WaitOrder(ctx, order.URI); err != nil {
+		return "", "", fmt.Errorf("ACME order did not become ready: %w", err)
+	}
+
+	der, _, err := i.client.CreateOrderCert(ctx, order.FinalizeURL, csr.Raw, true)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to finalize ACME order: %w", err)
+	}
+
+	certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der[0]}))
+	for _, issuer := range der[1:] {
+		chain += string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issuer}))
+	}
+
+	i.logger.WithFields(logrus.Fields{
+		"operation": "acme_issue",
+		"names":     names,
+		"order":     order.URI,
+	}).Info("Certificate issued by ACME CA")
+
+	return certificate, chain, nil
+}
+
+// register creates the ACME account once; an account that already exists for the key is reused
+func (i *Issuer) register(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.registered {
+		return nil
+	}
+
+	_, err := i.client.Register(ctx, &xacme.Account{Contact: i.contact}, xacme.AcceptTOS)
+	if err != nil && err != xacme.ErrAccountAlreadyExists {
+		return fmt.Errorf("failed to register ACME account: %w", err)
+	}
+
+	i.registered = true
+	return nil
+}
+
+// authorize solves the DNS-01 challenge of every pending authorization of an order.
+// All records are published before any challenge is accepted, and removed once validation ends.
+func (i *Issuer) authorize(ctx context.Context, authzURLs []string) error {
+	records := make(map[string][]string)
+	var challenges []*xacme.Challenge
+	var pending []string
+
+	for _, url := range authzURLs {
+		authz, err := i.client.GetAuthorization(ctx, url)
+		if err != nil {
+			return fmt.Errorf("failed to get ACME authorization: %w", err)
+		}
+		if authz.Status == xacme.StatusValid {
+			continue
+		}
+
+		var challenge *xacme.Challenge
+		for _, c := range authz.Challenges {
+			if c.Type == challengeType {
+				challenge = c
+				break
+			}
+		}
+		if challenge == nil {
+			return fmt.Errorf("ACME CA offered no %s challenge for %s", challengeType, authz.Identifier.Value)
+		}
+
+		value, err := i.client.DNS01ChallengeRecord(challenge.Token)
+		if err != nil {
+			return fmt.Errorf("failed to compute %s record: %w", challengeType, err)
+		}
+
+		// Wildcard authorizations name the base domain, so both share one record name
+		fqdn := "_acme-challenge." + strings.TrimPrefix(authz.Identifier.Value, "*.") + "."
+		records[fqdn] = append(records[fqdn], value)
+		challenges = append(challenges, challenge)
+		pending = append(pending, url)
+	}
+
+	// Records are removed even if the order failed or the request was cancelled
+	defer func() {
+		cleanupCtx := context.WithoutCancel(ctx)
+		for fqdn, values := range records {
+			if err := i.dns.CleanUp(cleanupCtx, fqdn, values); err != nil {
+				i.logger.WithError(err).WithField("fqdn", fqdn).Warn("Failed to remove ACME challenge record")
+			}
+		}
+	}()
+
+	for fqdn, values := range records {
+		if err := i.dns.Present(ctx, fqdn, values); err != nil {
+			return fmt.Errorf("failed to publish %s record %s: %w", challengeType, fqdn, err)
+		}
+	}
+
+	for _, challenge := range challenges {
+		if _, err := i.client.Accept(ctx, challenge); err != nil {
+			return fmt.Errorf("failed to accept ACME challenge: %w", err)
+		}
+	}
+	for _, url := range pending {
+		if _, err := i.client.WaitAuthorization(ctx, url); err != nil {
+			return fmt.Errorf("ACME authorization failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// parseCSR parses a PEM-encoded certificate signing request
+func parseCSR(csrPEM string) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode CSR PEM block")
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSR: %w", err)
+	}
+	return csr, nil
+}
+
+// orderNames returns the DNS names to order for a CSR: its SANs, plus the common name if not among them
+func orderNames(csr *x509.CertificateRequest) ([]string, error) {
+	if len(csr.IPAddresses) > 0 || len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return nil, fmt.Errorf("ACME issuance supports DNS names only")
+	}
+
+	names := csr.DNSNames
+	if cn := csr.Subject.CommonName; cn != "" {
+		found := false
+		for _, name := range names {
+			if strings.EqualFold(name, cn) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names = append([]string{cn}, names...)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("CSR has no DNS names to order")
+	}
+	return names, nil
+}
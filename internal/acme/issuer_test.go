@@ -0,0 +1,387 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	xacme "golang.org/x/crypto/acme"
+)
+
+// fakeCA is a minimal RFC 8555 server: it skips JWS verification, validates DNS-01
+// challenges against a fakeDNS and signs finalized CSRs with its own CA key
+type fakeCA struct {
+	t          *testing.T
+	server     *httptest.Server
+	accountKey *ecdsa.PrivateKey
+	dns        *fakeDNS
+	caKey      *ecdsa.PrivateKey
+	caCert     *x509.Certificate
+
+	mu        sync.Mutex
+	names     []string
+	valid     map[int]bool
+	issued    []byte
+	finalized bool
+}
+
+func newFakeCA(t *testing.T, accountKey *ecdsa.PrivateKey, dns *fakeDNS) *fakeCA {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Fake ACME CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &fakeCA{t: t, accountKey: accountKey, dns: dns, caKey: caKey, caCert: caCert, valid: make(map[int]bool)}
+	ca.server = httptest.NewServer(http.HandlerFunc(ca.serve))
+	t.Cleanup(ca.server.Close)
+	return ca
+}
+
+func (ca *fakeCA) url(path string) string {
+	return ca.server.URL + path
+}
+
+func (ca *fakeCA) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+	if r.URL.Path == "/directory" {
+		ca.writeJSON(w, http.StatusOK, map[string]string{
+			"newNonce":   ca.url("/nonce"),
+			"newAccount": ca.url("/account"),
+			"newOrder":   ca.url("/order"),
+		})
+		return
+	}
+	if r.URL.Path == "/nonce" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	payload := ca.payload(r)
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	var index int
+	switch {
+	case r.URL.Path == "/account":
+		w.Header().Set("Location", ca.url("/account/1"))
+		ca.writeJSON(w, http.StatusCreated, map[string]string{"status": "valid"})
+
+	case r.URL.Path == "/order":
+		var req struct {
+			Identifiers []struct{ Value string } `json:"identifiers"`
+		}
+		require.NoError(ca.t, json.Unmarshal(payload, &req))
+		ca.names = nil
+		for _, id := range req.Identifiers {
+			ca.names = append(ca.names, id.Value)
+		}
+		w.Header().Set("Location", ca.url("/order/1"))
+		ca.writeJSON(w, http.StatusCreated, ca.order())
+
+	case r.URL.Path == "/order/1":
+		w.Header().Set("Location", ca.url("/order/1"))
+		ca.writeJSON(w, http.StatusOK, ca.order())
+
+	case scan(r.URL.Path, "/authz/%d", &index):
+		ca.writeJSON(w, http.StatusOK, ca.authorization(index))
+
+	case scan(r.URL.Path, "/challenge/%d", &index):
+		// Validate against the published record the way a CA resolves _acme-challenge
+		fqdn := "_acme-challenge." + strings.TrimPrefix(ca.names[index], "*.") + "."
+		for _, value := range ca.dns.records(fqdn) {
+			if value == ca.expectedRecord(index) {
+				ca.valid[index] = true
+			}
+		}
+		ca.writeJSON(w, http.StatusOK, ca.challenge(index))
+
+	case r.URL.Path == "/finalize/1":
+		var req struct {
+			CSR string `json:"csr"`
+		}
+		require.NoError(ca.t, json.Unmarshal(payload, &req))
+		der, err := base64.RawURLEncoding.DecodeString(req.CSR)
+		require.NoError(ca.t, err)
+		csr, err := x509.ParseCertificateRequest(der)
+		require.NoError(ca.t, err)
+		leaf := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      csr.Subject,
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Minute),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		}
+		ca.issued, err = x509.CreateCertificate(rand.Reader, leaf, ca.caCert, csr.PublicKey, ca.caKey)
+		require.NoError(ca.t, err)
+		ca.finalized = true
+		w.Header().Set("Location", ca.url("/order/1"))
+		ca.writeJSON(w, http.StatusOK, ca.order())
+
+	case r.URL.Path == "/certificate/1":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.WriteHeader(http.StatusOK)
+		_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.issued})
+		_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.caCert.Raw})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (ca *fakeCA) order() map[string]interface{} {
+	status := "ready"
+	var authorizations []string
+	for i := range ca.names {
+		authorizations = append(authorizations, ca.url(fmt.Sprintf("/authz/%d", i)))
+		if !ca.valid[i] {
+			status = "pending"
+		}
+	}
+
+	order := map[string]interface{}{
+		"status":         status,
+		"authorizations": authorizations,
+		"finalize":       ca.url("/finalize/1"),
+	}
+	if ca.finalized {
+		order["status"] = "valid"
+		order["certificate"] = ca.url("/certificate/1")
+	}
+	return order
+}
+
+func (ca *fakeCA) authorization(index int) map[string]interface{} {
+	challenge := ca.challenge(index)
+	return map[string]interface{}{
+		"status":     challenge["status"],
+		"identifier": map[string]string{"type": "dns", "value": strings.TrimPrefix(ca.names[index], "*.")},
+		"wildcard":   strings.HasPrefix(ca.names[index], "*."),
+		"challenges": []interface{}{
+			map[string]string{"type": "http-01", "url": ca.url("/unused"), "token": "unused", "status": "pending"},
+			challenge,
+		},
+	}
+}
+
+func (ca *fakeCA) challenge(index int) map[string]interface{} {
+	status := "pending"
+	if ca.valid[index] {
+		status = "valid"
+	}
+	return map[string]interface{}{
+		"type":   "dns-01",
+		"url":    ca.url(fmt.Sprintf("/challenge/%d", index)),
+		"token":  fmt.Sprintf("token-%d", index),
+		"status": status,
+	}
+}
+
+// expectedRecord computes the DNS-01 record value for a challenge from the account key
+func (ca *fakeCA) expectedRecord(index int) string {
+	thumbprint, err := xacme.JWKThumbprint(ca.accountKey.Public())
+	require.NoError(ca.t, err)
+	digest := sha256.Sum256([]byte(fmt.Sprintf("token-%d.%s", index, thumbprint)))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+// payload returns the decoded payload of a JWS request body; POST-as-GET requests have none
+func (ca *fakeCA) payload(r *http.Request) []byte {
+	body, err := io.ReadAll(r.Body)
+	require.NoError(ca.t, err)
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	require.NoError(ca.t, json.Unmarshal(body, &jws))
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	require.NoError(ca.t, err)
+	return payload
+}
+
+func (ca *fakeCA) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	require.NoError(ca.t, json.NewEncoder(w).Encode(v))
+}
+
+func scan(path, format string, index *int) bool {
+	_, err := fmt.Sscanf(path, format, index)
+	return err == nil
+}
+
+// fakeDNS records the TXT records the issuer publishes
+type fakeDNS struct {
+	mu         sync.Mutex
+	published  map[string][]string
+	cleanedUp  []string
+	presentErr error
+}
+
+func newFakeDNS() *fakeDNS {
+	return &fakeDNS{published: make(map[string][]string)}
+}
+
+func (d *fakeDNS) Present(ctx context.Context, fqdn string, values []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.presentErr != nil {
+		return d.presentErr
+	}
+	d.published[fqdn] = values
+	return nil
+}
+
+func (d *fakeDNS) CleanUp(ctx context.Context, fqdn string, values []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.published, fqdn)
+	d.cleanedUp = append(d.cleanedUp, fqdn)
+	return nil
+}
+
+func (d *fakeDNS) records(fqdn string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.published[fqdn]
+}
+
+// newTestCSR returns a PEM CSR and its key for the common name and DNS SANs
+func newTestCSR(t *testing.T, commonName string, dnsNames ...string) (string, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: commonName},
+		DNSNames: dnsNames,
+	}, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})), key
+}
+
+func newTestIssuer(t *testing.T) (*Issuer, *fakeCA, *fakeDNS) {
+	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	dns := newFakeDNS()
+	ca := newFakeCA(t, accountKey, dns)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	return NewIssuer(ca.url("/directory"), accountKey, "ops@example.com", dns, logger), ca, dns
+}
+
+// TestIssue tests a full order: DNS-01 records are published, validated and removed, and the chain is returned
+func TestIssue(t *testing.T) {
+	issuer, ca, dns := newTestIssuer(t)
+	csrPEM, key := newTestCSR(t, "example.com", "example.com", "*.example.com")
+
+	certificate, chain, err := issuer.Issue(context.Background(), csrPEM)
+	require.NoError(t, err)
+
+	block, _ := pem.Decode([]byte(certificate))
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", cert.Subject.CommonName)
+	assert.Equal(t, []string{"example.com", "*.example.com"}, cert.DNSNames)
+	assert.True(t, cert.PublicKey.(*ecdsa.PublicKey).Equal(&key.PublicKey), "Certificate is issued for the CSR key")
+
+	block, rest := pem.Decode([]byte(chain))
+	require.NotNil(t, block)
+	assert.Equal(t, ca.caCert.Raw, block.Bytes)
+	assert.Empty(t, strings.TrimSpace(string(rest)))
+
+	// The domain and its wildcard share one record name
+	assert.Equal(t, []string{"_acme-challenge.example.com."}, dns.cleanedUp)
+	assert.Empty(t, dns.published)
+}
+
+// TestIssueDNSFailure tests records are cleaned up and the order fails when a record cannot be published
+func TestIssueDNSFailure(t *testing.T) {
+	issuer, _, dns := newTestIssuer(t)
+	dns.presentErr = fmt.Errorf("zone not found")
+	csrPEM, _ := newTestCSR(t, "www.example.com")
+
+	_, _, err := issuer.Issue(context.Background(), csrPEM)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "zone not found")
+	assert.Equal(t, []string{"_acme-challenge.www.example.com."}, dns.cleanedUp)
+}
+
+// TestOrderNames tests the ordered names come from the CSR's SANs and common name
+func TestOrderNames(t *testing.T) {
+	csr := &x509.CertificateRequest{Subject: pkix.Name{CommonName: "example.com"}, DNSNames: []string{"www.example.com"}}
+	names, err := orderNames(csr)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "www.example.com"}, names)
+
+	csr.DNSNames = []string{"www.example.com", "EXAMPLE.com"}
+	names, err = orderNames(csr)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"www.example.com", "EXAMPLE.com"}, names)
+
+	_, err = orderNames(&x509.CertificateRequest{})
+	assert.Error(t, err)
+
+	_, err = orderNames(&x509.CertificateRequest{DNSNames: []string{"example.com"}, IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DNS names only")
+}
+
+// TestLoadAccountKey tests account keys are read from SEC 1, PKCS#1 and PKCS#8 PEM files
+func TestLoadAccountKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	sec1, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	for blockType, der := range map[string][]byte{"EC PRIVATE KEY": sec1, "PRIVATE KEY": pkcs8} {
+		path := filepath.Join(dir, "account.pem")
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+
+		signer, err := LoadAccountKey(path)
+		require.NoError(t, err, blockType)
+		assert.True(t, key.PublicKey.Equal(signer.Public()), blockType)
+	}
+
+	path := filepath.Join(dir, "invalid.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("x")}), 0600))
+	_, err = LoadAccountKey(path)
+	assert.Error(t, err)
+
+	_, err = LoadAccountKey(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
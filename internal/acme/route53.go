@@ -0,0 +1,176 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	// route53Endpoint is the global Route53 API endpoint; requests to it are signed for us-east-1
+	route53Endpoint = "https://route53.amazonaws.com"
+	route53Region   = "us-east-1"
+	route53Version  = "2013-04-01"
+
+	// challengeTTL keeps resolvers from caching a challenge record past its validation
+	challengeTTL = 60
+)
+
+// Route53Provider answers DNS-01 challenges with TXT records in a Route53 hosted zone.
+// It calls the Route53 REST API directly, signing requests with the service's AWS credentials.
+type Route53Provider struct {
+	hostedZoneID string
+	credentials  aws.CredentialsProvider
+	signer       *v4.Signer
+	httpClient   *http.Client
+	endpoint     string
+	pollInterval time.Duration
+}
+
+// NewRoute53Provider creates a DNS-01 provider for the hosted zone
+func NewRoute53Provider(hostedZoneID string, credentials aws.CredentialsProvider) *Route53Provider {
+	return &Route53Provider{
+		hostedZoneID: strings.TrimPrefix(hostedZoneID, "/hostedzone/"),
+		credentials:  credentials,
+		signer:       v4.NewSigner(),
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		endpoint:     route53Endpoint,
+		pollInterval: 5 * time.Second,
+	}
+}
+
+// Present upserts the TXT record and waits until Route53 reports the change in sync
+func (p *Route53Provider) Present(ctx context.Context, fqdn string, values []string) error {
+	changeID, err := p.changeRecord(ctx, "UPSERT", fqdn, values)
+	if err != nil {
+		return err
+	}
+
+	for {
+		status, err := p.changeStatus(ctx, changeID)
+		if err != nil {
+			return err
+		}
+		if status == "INSYNC" {
+			return nil
+		}
+
+		t := time.NewTimer(p.pollInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// CleanUp deletes the TXT record without waiting for the change to propagate
+func (p *Route53Provider) CleanUp(ctx context.Context, fqdn string, values []string) error {
+	_, err := p.changeRecord(ctx, "DELETE", fqdn, values)
+	return err
+}
+
+// route53ChangeRequest is the ChangeResourceRecordSets request body for a single TXT record
+type route53ChangeRequest struct {
+	XMLName xml.Name `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ChangeResourceRecordSetsRequest"`
+	Comment string   `xml:"ChangeBatch>Comment"`
+	Action  string   `xml:"ChangeBatch>Changes>Change>Action"`
+	Name    string   `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>Name"`
+	Type    string   `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>Type"`
+	TTL     int      `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>TTL"`
+	// Records holds one ResourceRecord per value, as Route53 accepts only one Value in each
+	Records []route53ResourceRecord `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>ResourceRecords>ResourceRecord"`
+}
+
+// route53ResourceRecord is a single value of a record set
+type route53ResourceRecord struct {
+	Value string
+}
+
+// route53ChangeInfo is the ChangeInfo element returned by ChangeResourceRecordSets and GetChange
+type route53ChangeInfo struct {
+	ID     string `xml:"ChangeInfo>Id"`
+	Status string `xml:"ChangeInfo>Status"`
+}
+
+// changeRecord applies a change to the TXT record and returns the Route53 change ID
+func (p *Route53Provider) changeRecord(ctx context.Context, action, fqdn string, values []string) (string, error) {
+	request := route53ChangeRequest{
+		Comment: "certificate-monkey ACME DNS-01 challenge",
+		Action:  action,
+		Name:    fqdn,
+		Type:    "TXT",
+		TTL:     challengeTTL,
+	}
+	for _, value := range values {
+		request.Records = append(request.Records, route53ResourceRecord{Value: `"` + value + `"`})
+	}
+
+	body, err := xml.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal Route53 change: %w", err)
+	}
+
+	var info route53ChangeInfo
+	path := fmt.Sprintf("/%s/hostedzone/%s/rrset", route53Version, p.hostedZoneID)
+	if err := p.do(ctx, http.MethodPost, path, append([]byte(xml.Header), body...), &info); err != nil {
+		return "", fmt.Errorf("failed to %s Route53 record %s: %w", strings.ToLower(action), fqdn, err)
+	}
+	return strings.TrimPrefix(info.ID, "/change/"), nil
+}
+
+// changeStatus returns the status of a Route53 change, PENDING or INSYNC
+func (p *Route53Provider) changeStatus(ctx context.Context, changeID string) (string, error) {
+	var info route53ChangeInfo
+	path := fmt.Sprintf("/%s/change/%s", route53Version, changeID)
+	if err := p.do(ctx, http.MethodGet, path, nil, &info); err != nil {
+		return "", fmt.Errorf("failed to get Route53 change %s: %w", changeID, err)
+	}
+	return info.Status, nil
+}
+
+// do sends a signed request to the Route53 API and decodes the XML response into result
+func (p *Route53Provider) do(ctx context.Context, method, path string, body []byte, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, p.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+
+	credentials, err := p.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	payloadHash := sha256.Sum256(body)
+	if err := p.signer.SignHTTP(ctx, credentials, req, hex.EncodeToString(payloadHash[:]), "route53", route53Region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("route53 returned %s: %s", res.Status, strings.TrimSpace(string(data)))
+	}
+	return xml.Unmarshal(data, result)
+}
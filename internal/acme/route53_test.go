@@ -0,0 +1,91 @@
+package acme
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRoute53Provider(t *testing.T, handler http.HandlerFunc) *Route53Provider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider := NewRoute53Provider("/hostedzone/Z0123456789ABC", aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+	}))
+	provider.endpoint = server.URL
+	provider.pollInterval = time.Millisecond
+	return provider
+}
+
+// TestRoute53Present tests the TXT record is upserted with a signed request and the change is awaited
+func TestRoute53Present(t *testing.T) {
+	var change route53ChangeRequest
+	var rawChange string
+	polls := 0
+	provider := newTestRoute53Provider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/us-east-1/route53/aws4_request")
+
+		switch r.URL.Path {
+		case "/2013-04-01/hostedzone/Z0123456789ABC/rrset":
+			assert.Equal(t, http.MethodPost, r.Method)
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			rawChange = string(body)
+			require.NoError(t, xml.Unmarshal(body, &change))
+			_, _ = io.WriteString(w, `<ChangeResourceRecordSetsResponse><ChangeInfo><Id>/change/C123</Id><Status>PENDING</Status></ChangeInfo></ChangeResourceRecordSetsResponse>`)
+		case "/2013-04-01/change/C123":
+			polls++
+			status := "PENDING"
+			if polls == 2 {
+				status = "INSYNC"
+			}
+			_, _ = io.WriteString(w, `<GetChangeResponse><ChangeInfo><Id>/change/C123</Id><Status>`+status+`</Status></ChangeInfo></GetChangeResponse>`)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	})
+
+	err := provider.Present(context.Background(), "_acme-challenge.example.com.", []string{"value-1", "value-2"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "UPSERT", change.Action)
+	assert.Equal(t, "_acme-challenge.example.com.", change.Name)
+	assert.Equal(t, "TXT", change.Type)
+	assert.Equal(t, challengeTTL, change.TTL)
+	// Route53 rejects a ResourceRecord holding more than one Value
+	assert.Contains(t, rawChange, `<ResourceRecords><ResourceRecord><Value>&#34;value-1&#34;</Value></ResourceRecord><ResourceRecord><Value>&#34;value-2&#34;</Value></ResourceRecord></ResourceRecords>`)
+	assert.Equal(t, 2, polls, "Present waits for the change to be in sync")
+}
+
+// TestRoute53CleanUp tests the record is deleted and Route53 errors are surfaced
+func TestRoute53CleanUp(t *testing.T) {
+	var change route53ChangeRequest
+	provider := newTestRoute53Provider(t, func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, xml.Unmarshal(body, &change))
+		_, _ = io.WriteString(w, `<ChangeResourceRecordSetsResponse><ChangeInfo><Id>/change/C456</Id><Status>PENDING</Status></ChangeInfo></ChangeResourceRecordSetsResponse>`)
+	})
+
+	require.NoError(t, provider.CleanUp(context.Background(), "_acme-challenge.example.com.", []string{"value-1"}))
+	assert.Equal(t, "DELETE", change.Action)
+
+	failing := newTestRoute53Provider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `<ErrorResponse><Error><Code>InvalidChangeBatch</Code></Error></ErrorResponse>`)
+	})
+	err := failing.CleanUp(context.Background(), "_acme-challenge.example.com.", []string{"value-1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "InvalidChangeBatch")
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"certificate-monkey/internal/audit"
+	"certificate-monkey/internal/crypto"
+	"certificate-monkey/internal/models"
+	"certificate-monkey/internal/notify"
+	"certificate-monkey/internal/storage"
+)
+
+// CertificateIssuer obtains a certificate for a PEM CSR from an external CA, returning the
+// certificate and its chain of issuing certificates as PEM
+type CertificateIssuer interface {
+	Issue(ctx context.Context, csrPEM string) (certificate, chain string, err error)
+}
+
+// ACMEHandler handles certificate issuance through an ACME CA
+type ACMEHandler struct {
+	storage       *storage.DynamoDBStorage
+	auditSink     audit.Sink
+	notifier      notify.Notifier
+	cryptoService *crypto.CryptoService
+	issuer        CertificateIssuer
+	timeout       time.Duration
+	logger        *logrus.Logger
+
+	// inFlight holds the IDs of entities with an order in progress
+	inFlight sync.Map
+}
+
+// NewACMEHandler creates a new ACME handler; timeout bounds each order
+func NewACMEHandler(storage *storage.DynamoDBStorage, cryptoService *crypto.CryptoService, issuer CertificateIssuer, timeout time.Duration, logger *logrus.Logger) *ACMEHandler {
+	return &ACMEHandler{
+		storage:       storage,
+		cryptoService: cryptoService,
+		issuer:        issuer,
+		timeout:       timeout,
+		logger:        logger,
+	}
+}
+
+// SetAuditSink sets the sink that issued certificates are recorded in; nil disables it
+func (h *ACMEHandler) SetAuditSink(sink audit.Sink) {
+	h.auditSink = sink
+}
+
+// SetNotifier sets the notifier told when a certificate is issued; nil disables it
+func (h *ACMEHandler) SetNotifier(notifier notify.Notifier) {
+	h.notifier = notifier
+}
+
+// IssueCertificate starts an ACME order for an entity's CSR
+// @Summary Issue a certificate through ACME
+// @Description Starts an ACME order (for example with Let's Encrypt) for the names in the entity's CSR, answering DNS-01 challenges through Route53. Orders take up to ACME_TIMEOUT, so the request returns 202 immediately; once the certificate is issued it is stored with its chain and the status becomes CERT_UPLOADED. The private key is not read. Only available when ACME_ENABLED is set.
+// @Tags Certificate Management
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Success 202 {object} map[string]interface{} "ACME order started"
+// @Failure 400 {object} map[string]interface{} "Bad request - no CSR available"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 409 {object} map[string]interface{} "Conflict - an ACME order is already in progress for the entity"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/acme [post]
+func (h *ACMEHandler) IssueCertificate(c *gin.Context) {
+	entityID := c.Param("id")
+
+	// The common name is only read for the issued notification
+	entity, err := h.storage.GetCertificateEntityFields(c.Request.Context(), entityID, []string{"status", "csr", "common_name"})
+	if err != nil {
+		if errors.Is(err, storage.ErrEntityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not Found",
+				"message": "Certificate entity not found",
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to start ACME order",
+		})
+		return
+	}
+
+	if entity.CSR == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "No CSR available for this certificate entity",
+		})
+		return
+	}
+
+	if _, running := h.inFlight.LoadOrStore(entityID, struct{}{}); running {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Conflict",
+			"message": "An ACME order is already in progress for this certificate entity",
+		})
+		return
+	}
+
+	change := statusChange(c, entity.Status, models.StatusCertUploaded)
+	go h.issue(entity, change)
+
+	h.logger.WithFields(logrus.Fields{
+		"operation":  "acme_order",
+		"entity_id":  entityID,
+		"request_id": c.GetString("request_id"),
+	}).Info("ACME order started")
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"id":      entityID,
+		"message": "ACME order started",
+	})
+}
+
+// issue runs an ACME order in the background and stores the issued certificate and chain
+func (h *ACMEHandler) issue(entity *models.CertificateEntity, change *models.StatusChange) {
+	defer h.inFlight.Delete(entity.ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	logger := h.logger.WithField("entity_id", entity.ID)
+
+	certificate, chain, err := h.issuer.Issue(ctx, entity.CSR)
+	if err != nil {
+		logger.WithError(err).Error("ACME order failed")
+		return
+	}
+
+	// The CA should only ever sign the submitted CSR, but the certificate is stored against its key
+	if err := h.cryptoService.ValidateCertificateWithCSR(certificate, entity.CSR); err != nil {
+		logger.WithError(err).Error("ACME certificate does not match the CSR")
+		return
+	}
+	if err := setCertificateDetails(h.cryptoService, entity, certificate); err != nil {
+		logger.WithError(err).Error("Failed to process ACME certificate")
+		return
+	}
+	entity.CertificateChain = chain
+	entity.Status = models.StatusCertUploaded
+
+	if err := h.storage.UpdateCertificateEntity(ctx, entity, change); err != nil {
+		logger.WithError(err).Error("Failed to store ACME certificate")
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"serial_number": entity.SerialNumber,
+		"valid_to":      entity.ValidTo,
+	}).Info("ACME certificate stored")
+
+	event := &models.Event{EntityID: entity.ID, Type: models.EventCertIssued}
+	if change != nil {
+		event.Actor = change.Actor
+		event.RequestID = change.RequestID
+	}
+	recordEvent(ctx, h.auditSink, h.logger, event)
+	notifyIssued(h.notifier, h.logger, entity)
+}
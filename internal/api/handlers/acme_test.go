@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/crypto"
+	"certificate-monkey/internal/models"
+	"certificate-monkey/internal/notify"
+	"certificate-monkey/internal/storage/storagetest"
+)
+
+// staticIssuer returns the same certificate for every order
+type staticIssuer struct {
+	certificate string
+}
+
+func (i staticIssuer) Issue(context.Context, string) (string, string, error) {
+	return i.certificate, "", nil
+}
+
+// TestIssueCertificateNotifiesIssued tests an ACME issuance sends the issued notification with the
+// entity's common name and the issued certificate's fingerprint and validity
+func TestIssueCertificateNotifiesIssued(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cryptoService := crypto.NewCryptoService()
+	_, csrPEM, err := cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{CommonName: "acme.example.com", KeyType: models.KeyTypeECDSAP256})
+	require.NoError(t, err)
+	certPEM := signCSR(t, csrPEM)
+
+	item, err := attributevalue.MarshalMap(&models.CertificateEntity{
+		ID:         "entity-1",
+		CommonName: "acme.example.com",
+		CSR:        csrPEM,
+		Status:     models.StatusCSRCreated,
+	})
+	require.NoError(t, err)
+	// Serve only the projected attributes, as DynamoDB does
+	table := &storagetest.DynamoDBClient{
+		GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			projected := make(map[string]types.AttributeValue)
+			for _, placeholder := range strings.Split(aws.ToString(params.ProjectionExpression), ", ") {
+				name := params.ExpressionAttributeNames[placeholder]
+				if value, ok := item[name]; ok {
+					projected[name] = value
+				}
+			}
+			return &dynamodb.GetItemOutput{Item: projected}, nil
+		},
+	}
+
+	handler := NewACMEHandler(newTestStorage(table, nil), cryptoService, staticIssuer{certificate: certPEM}, time.Minute, newTestLogger())
+	notifications := make(channelNotifier, 1)
+	handler.SetNotifier(notifications)
+
+	router := gin.New()
+	router.POST("/keys/:id/acme", handler.IssueCertificate)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/keys/entity-1/acme", nil))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	select {
+	case notification := <-notifications:
+		assert.Equal(t, notify.EventIssued, notification.Event)
+		assert.Equal(t, "entity-1", notification.EntityID)
+		assert.Equal(t, "acme.example.com", notification.CommonName)
+		assert.NotEmpty(t, notification.Fingerprint)
+		require.NotNil(t, notification.ValidFrom)
+		require.NotNil(t, notification.ValidTo)
+		assert.True(t, notification.ValidTo.After(*notification.ValidFrom))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no issued notification was sent")
+	}
+}
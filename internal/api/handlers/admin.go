@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"certificate-monkey/internal/api/middleware"
+	"certificate-monkey/internal/backup"
+	"certificate-monkey/internal/crypto"
+	"certificate-monkey/internal/models"
+	"certificate-monkey/internal/storage"
+)
+
+const (
+	// backupPassphraseHeader carries the archive passphrase so it never appears in URLs or access logs
+	backupPassphraseHeader = "X-Backup-Passphrase"
+
+	// maxImportSize bounds the size of an uploaded backup archive
+	maxImportSize = 256 << 20
+
+	// defaultRekeyLimit and maxRekeyLimit bound how many keys a single rekey request re-encrypts
+	defaultRekeyLimit = 100
+	maxRekeyLimit     = 1000
+)
+
+// AdminStore defines the storage operations used by the admin handlers
+type AdminStore interface {
+	ForEachEncryptedEntity(ctx context.Context, fn func(entity *models.CertificateEntity) error) error
+	RestoreCertificateEntity(ctx context.Context, entity *models.CertificateEntity, overwrite, force bool) (bool, error)
+	RekeyEntities(ctx context.Context, limit int) (*models.RekeyResponse, error)
+}
+
+// AdminHandler handles administrative HTTP requests such as backups
+type AdminHandler struct {
+	storage       AdminStore
+	cryptoService *crypto.CryptoService
+	logger        *logrus.Logger
+	policy        middleware.Policy
+	deniedNames   []string
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(storage AdminStore, cryptoService *crypto.CryptoService, logger *logrus.Logger) *AdminHandler {
+	return &AdminHandler{
+		storage:       storage,
+		cryptoService: cryptoService,
+		logger:        logger,
+	}
+}
+
+// SetPolicy sets the validation policy and the denied names and glob patterns imported entities
+// are held to, the same ones key creation applies
+func (h *AdminHandler) SetPolicy(policy middleware.Policy, deniedNames []string) {
+	h.policy = policy
+	h.deniedNames = deniedNames
+}
+
+// ExportBackup streams an encrypted backup archive of all certificate entities
+// @Summary Export all certificate entities (ADMIN)
+// @Description Streams a passphrase-encrypted archive (gzip-compressed tar of per-entity JSON) containing every certificate entity. Private keys remain KMS-encrypted inside the archive and are never decrypted during export. Requires an API key with the admin scope.
+// @Tags Administration
+// @Produce application/octet-stream
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param X-Backup-Passphrase header string true "Passphrase used to encrypt the archive (at least 12 characters)"
+// @Success 200 {file} binary "Encrypted backup archive"
+// @Failure 400 {object} map[string]interface{} "Bad request - missing or weak passphrase"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} map[string]interface{} "Forbidden - API key lacks the admin scope"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /admin/export [get]
+func (h *AdminHandler) ExportBackup(c *gin.Context) {
+	passphrase := models.SecretString(c.GetHeader(backupPassphraseHeader))
+	if err := backup.ValidatePassphrase(passphrase.Reveal()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": fmt.Sprintf("A valid %s header is required", backupPassphraseHeader),
+			"details": err.Error(),
+		})
+		return
+	}
+
+	archive, err := backup.NewWriter(c.Writer, passphrase.Reveal())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to initialize backup archive")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to create backup archive",
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("certificate-monkey-backup-%s.cmbk", time.Now().UTC().Format("20060102T150405Z"))
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	err = h.storage.ForEachEncryptedEntity(c.Request.Context(), archive.AddEntity)
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("exported", archive.Count()).Error("Failed to export backup archive")
+		if !c.Writer.Written() {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Internal Server Error",
+				"message": "Failed to export certificate entities",
+			})
+			return
+		}
+		// The stream already started; leaving it without its final chunk makes
+		// the archive fail verification as truncated on import.
+		c.Abort()
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"operation":    "export_backup",
+		"entity_count": archive.Count(),
+		"filename":     filename,
+		"user_agent":   c.GetHeader("User-Agent"),
+		"remote_addr":  c.ClientIP(),
+		"request_id":   c.GetString("request_id"),
+		"api_key_name": c.GetString("api_key_name"),
+	}).Warn("SENSITIVE: Backup archive exported")
+}
+
+// ImportBackup restores certificate entities from an encrypted backup archive
+// @Summary Import certificate entities from a backup (ADMIN)
+// @Description Restores entities from an archive produced by the export endpoint. The whole archive is decrypted and verified before anything is written. Entity IDs, which must be UUIDs, are preserved and private keys are re-encrypted with the current KMS key. Existing entities are skipped unless mode=overwrite; protected entities are only overwritten with force=true. Requires an API key with the admin scope.
+// @Tags Administration
+// @Accept application/octet-stream
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param X-Backup-Passphrase header string true "Passphrase the archive was encrypted with"
+// @Param mode query string false "How to handle existing entities" Enums(skip_existing, overwrite) default(skip_existing)
+// @Param force query bool false "Also overwrite entities tagged protected=true" default(false)
+// @Param archive body string true "Encrypted backup archive"
+// @Success 200 {object} models.ImportBackupResponse "Per-entity import results"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid mode, force, passphrase or archive"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} map[string]interface{} "Forbidden - API key lacks the admin scope"
+// @Router /admin/import [post]
+func (h *AdminHandler) ImportBackup(c *gin.Context) {
+	mode := models.ImportMode(c.DefaultQuery("mode", string(models.ImportModeSkipExisting)))
+	if mode != models.ImportModeSkipExisting && mode != models.ImportModeOverwrite {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid import mode",
+			"details": fmt.Sprintf("mode must be '%s' or '%s'", models.ImportModeSkipExisting, models.ImportModeOverwrite),
+		})
+		return
+	}
+
+	force, ok := parseForceQuery(c)
+	if !ok {
+		return
+	}
+
+	passphrase := models.SecretString(c.GetHeader(backupPassphraseHeader))
+	if passphrase == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": fmt.Sprintf("The %s header is required", backupPassphraseHeader),
+		})
+		return
+	}
+
+	// Read and verify the complete archive first so a corrupt upload never leaves a partial restore
+	entities, err := readBackupArchive(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize), passphrase.Reveal())
+	if err != nil {
+		h.logger.WithError(err).Warn("Rejected backup archive")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Failed to read backup archive",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response := models.ImportBackupResponse{
+		Mode:    mode,
+		Total:   len(entities),
+		Results: make([]models.ImportItemResult, 0, len(entities)),
+	}
+
+	for _, entity := range entities {
+		result := h.importEntity(c.Request.Context(), entity, mode, force)
+		switch result.Status {
+		case models.ImportItemCreated:
+			response.Created++
+		case models.ImportItemOverwritten:
+			response.Overwritten++
+		case models.ImportItemSkipped:
+			response.Skipped++
+		case models.ImportItemFailed:
+			response.Failed++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"operation":    "import_backup",
+		"mode":         mode,
+		"force":        force,
+		"total":        response.Total,
+		"created":      response.Created,
+		"overwritten":  response.Overwritten,
+		"skipped":      response.Skipped,
+		"failed":       response.Failed,
+		"user_agent":   c.GetHeader("User-Agent"),
+		"remote_addr":  c.ClientIP(),
+		"request_id":   c.GetString("request_id"),
+		"api_key_name": c.GetString("api_key_name"),
+	}).Warn("SENSITIVE: Backup archive imported")
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RekeyEntities re-encrypts stored private keys under the currently configured KMS key
+// @Summary Re-encrypt private keys with the current KMS key (ADMIN)
+// @Description Decrypts each stored private key with the key it was encrypted under and re-encrypts it with the currently configured KMS key, recording the new key per entity. Entities already encrypted under the current key are skipped, so the operation is idempotent: repeat the request until complete is true. KMS calls are rate limited by KMS_REKEY_RATE. Requires an API key with the admin scope.
+// @Tags Administration
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param limit query int false "Maximum number of entities to re-encrypt in this request (default: 100, max: 1000)" minimum(1) maximum(1000)
+// @Success 200 {object} models.RekeyResponse "Rekey progress"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid limit"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} map[string]interface{} "Forbidden - API key lacks the admin scope"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /admin/rekey [post]
+func (h *AdminHandler) RekeyEntities(c *gin.Context) {
+	limit := defaultRekeyLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxRekeyLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad Request",
+				"message": "Invalid limit",
+				"details": fmt.Sprintf("limit must be between 1 and %d", maxRekeyLimit),
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	result, err := h.storage.RekeyEntities(c.Request.Context(), limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to rekey certificate entities")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to re-encrypt private keys",
+		})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"operation":       "rekey",
+		"kms_key_id":      result.KMSKeyID,
+		"rekeyed":         result.Rekeyed,
+		"already_current": result.AlreadyCurrent,
+		"pending":         result.Pending,
+		"failed":          result.Failed,
+		"user_agent":      c.GetHeader("User-Agent"),
+		"remote_addr":     c.ClientIP(),
+		"request_id":      c.GetString("request_id"),
+		"api_key_name":    c.GetString("api_key_name"),
+	}).Warn("SENSITIVE: Private keys re-encrypted")
+
+	c.JSON(http.StatusOK, result)
+}
+
+// importEntity validates and restores a single entity
+func (h *AdminHandler) importEntity(ctx context.Context, entity *models.CertificateEntity, mode models.ImportMode, force bool) models.ImportItemResult {
+	result := models.ImportItemResult{ID: entity.ID}
+
+	if err := h.validateBackupEntity(entity); err != nil {
+		result.Status = models.ImportItemFailed
+		result.Error = err.Error()
+		var mismatch *crypto.KeyTypeMismatchError
+		if errors.As(err, &mismatch) {
+			result.RequestedKeyType = mismatch.Requested
+			result.InferredKeyType = mismatch.Inferred
+		}
+		return result
+	}
+
+	replaced, err := h.storage.RestoreCertificateEntity(ctx, entity, mode == models.ImportModeOverwrite, force)
+	switch {
+	case errors.Is(err, storage.ErrEntityExists):
+		result.Status = models.ImportItemSkipped
+	case errors.Is(err, storage.ErrEntityProtected):
+		result.Status = models.ImportItemSkipped
+		result.Error = "Entity is protected; import with force=true to overwrite it"
+	case err != nil:
+		h.logger.WithError(err).WithField("entity_id", entity.ID).Error("Failed to restore certificate entity")
+		result.Status = models.ImportItemFailed
+		result.Error = "Failed to restore certificate entity"
+	case replaced:
+		result.Status = models.ImportItemOverwritten
+	default:
+		result.Status = models.ImportItemCreated
+	}
+
+	return result
+}
+
+// readBackupArchive decrypts an archive and returns all of its entities once it has been fully verified
+func readBackupArchive(r io.Reader, passphrase string) ([]*models.CertificateEntity, error) {
+	reader, err := backup.NewReader(r, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []*models.CertificateEntity
+	for {
+		entity, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return entities, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+}
+
+// validateBackupEntity checks that an entity read from an archive is complete enough to store
+// and passes the policy applied to new keys, so an archive cannot bring in names, countries or
+// tags the API would refuse. The private key is KMS ciphertext, so its key type is checked against
+// the CSR or certificate, which carry the same public key; material sealed with
+// ENCRYPT_ALL_SENSITIVE is not checked.
+func (h *AdminHandler) validateBackupEntity(entity *models.CertificateEntity) error {
+	if entity.ID == "" {
+		return errors.New("entity ID is missing")
+	}
+	// The service only assigns UUIDs, and the handlers assume entity IDs have that form
+	if len(entity.ID) != 36 || uuid.Validate(entity.ID) != nil {
+		return fmt.Errorf("entity ID %q is not a UUID", entity.ID)
+	}
+	if entity.CommonName == "" {
+		return errors.New("common name is missing")
+	}
+	if entity.EncryptedPrivateKey == "" && !entity.ExternalKey {
+		return errors.New("encrypted private key is missing")
+	}
+	if entity.CreatedAt.IsZero() {
+		return errors.New("creation timestamp is missing")
+	}
+
+	switch entity.KeyType {
+	case models.KeyTypeRSA2048, models.KeyTypeRSA4096, models.KeyTypeECDSAP256, models.KeyTypeECDSAP384:
+	default:
+		return fmt.Errorf("unsupported key type: %q", entity.KeyType)
+	}
+	for _, material := range []string{entity.CSR, entity.Certificate} {
+		if block, _ := pem.Decode([]byte(material)); block == nil {
+			continue
+		}
+		if err := h.cryptoService.CheckKeyType(material, entity.KeyType); err != nil {
+			return err
+		}
+		break
+	}
+
+	switch entity.Status {
+	case models.StatusPendingCSR, models.StatusCSRCreated, models.StatusCertUploaded, models.StatusCompleted, models.StatusExpired:
+	default:
+		return fmt.Errorf("unsupported status: %q", entity.Status)
+	}
+
+	// Apply the same policy as key creation
+	names := append([]string{entity.CommonName}, entity.SubjectAlternativeNames...)
+	for _, name := range names {
+		if err := h.policy.CheckWildcardName(name); err != nil {
+			return fmt.Errorf("name %q is invalid: %w", name, err)
+		}
+	}
+	if name, rule, denied := matchDeniedName(h.deniedNames, names); denied {
+		return fmt.Errorf("name %q is on the denylist (%s)", name, rule)
+	}
+	if err := h.cryptoService.CheckSANCount(len(entity.SubjectAlternativeNames)); err != nil {
+		return err
+	}
+	if entity.Country != "" {
+		if err := h.policy.CheckCountry(entity.Country); err != nil {
+			return err
+		}
+	}
+	if err := h.policy.CheckTags(entity.Tags); err != nil {
+		return fmt.Errorf("tags are invalid: %w", err)
+	}
+
+	return nil
+}
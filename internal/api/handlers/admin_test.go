@@ -0,0 +1,535 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/api/middleware"
+	"certificate-monkey/internal/backup"
+	"certificate-monkey/internal/crypto"
+	"certificate-monkey/internal/models"
+	"certificate-monkey/internal/storage"
+)
+
+const testBackupPassphrase = "backup-passphrase-123"
+
+// mockAdminStore keeps entities in memory in insertion order
+type mockAdminStore struct {
+	entities   []*models.CertificateEntity
+	err        error
+	restoreErr map[string]error
+	rekeyErr   error
+	rekeyLimit int
+}
+
+func (m *mockAdminStore) ForEachEncryptedEntity(ctx context.Context, fn func(entity *models.CertificateEntity) error) error {
+	if m.err != nil {
+		return m.err
+	}
+	for _, entity := range m.entities {
+		if err := fn(entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockAdminStore) RestoreCertificateEntity(ctx context.Context, entity *models.CertificateEntity, overwrite, force bool) (bool, error) {
+	if err := m.restoreErr[entity.ID]; err != nil {
+		return false, err
+	}
+	for i, existing := range m.entities {
+		if existing.ID == entity.ID {
+			if !overwrite {
+				return false, fmt.Errorf("%w: %s", storage.ErrEntityExists, entity.ID)
+			}
+			if !force && existing.Tags[models.ProtectedTag] == "true" {
+				return false, fmt.Errorf("%w: %s", storage.ErrEntityProtected, entity.ID)
+			}
+			m.entities[i] = entity
+			return true, nil
+		}
+	}
+	m.entities = append(m.entities, entity)
+	return false, nil
+}
+
+func (m *mockAdminStore) RekeyEntities(ctx context.Context, limit int) (*models.RekeyResponse, error) {
+	m.rekeyLimit = limit
+	if m.rekeyErr != nil {
+		return nil, m.rekeyErr
+	}
+	rekeyed := min(limit, len(m.entities))
+	return &models.RekeyResponse{
+		KMSKeyID: "test-key",
+		Scanned:  len(m.entities),
+		Rekeyed:  rekeyed,
+		Pending:  len(m.entities) - rekeyed,
+		Complete: rekeyed == len(m.entities),
+	}, nil
+}
+
+// newAdminTestRouter creates a router exposing the admin handler without authentication
+func newAdminTestRouter(store AdminStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewAdminHandler(store, crypto.NewCryptoService(), newTestLogger())
+	router := gin.New()
+	router.GET("/admin/export", handler.ExportBackup)
+	router.POST("/admin/import", handler.ImportBackup)
+	router.POST("/admin/rekey", handler.RekeyEntities)
+	return router
+}
+
+// Test exporting a small dataset produces an archive with every entity
+func TestExportBackup(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	store := &mockAdminStore{
+		entities: []*models.CertificateEntity{
+			{ID: "00000000-0000-4000-8000-000000000001", CommonName: "one.example.com", EncryptedPrivateKey: "kms-ciphertext-1", Status: models.StatusCSRCreated, CreatedAt: now},
+			{ID: "00000000-0000-4000-8000-000000000002", CommonName: "two.example.com", EncryptedPrivateKey: "kms-ciphertext-2", Status: models.StatusCertUploaded, CreatedAt: now},
+		},
+	}
+	router := newAdminTestRouter(store)
+
+	req := httptest.NewRequest("GET", "/admin/export", nil)
+	req.Header.Set("X-Backup-Passphrase", testBackupPassphrase)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "certificate-monkey-backup-")
+
+	reader, err := backup.NewReader(bytes.NewReader(w.Body.Bytes()), testBackupPassphrase)
+	require.NoError(t, err)
+
+	var restored []*models.CertificateEntity
+	for {
+		entity, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		restored = append(restored, entity)
+	}
+
+	require.Len(t, restored, 2)
+	assert.Equal(t, "00000000-0000-4000-8000-000000000001", restored[0].ID)
+	assert.Equal(t, "kms-ciphertext-1", restored[0].EncryptedPrivateKey, "Keys must remain KMS-encrypted")
+	assert.Equal(t, "00000000-0000-4000-8000-000000000002", restored[1].ID)
+	assert.Equal(t, models.StatusCertUploaded, restored[1].Status)
+	assert.Equal(t, 2, reader.Manifest().EntityCount)
+}
+
+// Test the passphrase header is required and must be strong enough
+func TestExportBackupPassphraseValidation(t *testing.T) {
+	router := newAdminTestRouter(&mockAdminStore{})
+
+	for _, passphrase := range []string{"", "short"} {
+		req := httptest.NewRequest("GET", "/admin/export", nil)
+		if passphrase != "" {
+			req.Header.Set("X-Backup-Passphrase", passphrase)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Bad Request", response["error"])
+	}
+}
+
+// Test storage failures before any data was streamed return a JSON error
+func TestExportBackupStorageError(t *testing.T) {
+	router := newAdminTestRouter(&mockAdminStore{err: errors.New("scan failed")})
+
+	req := httptest.NewRequest("GET", "/admin/export", nil)
+	req.Header.Set("X-Backup-Passphrase", testBackupPassphrase)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Internal Server Error", response["error"])
+}
+
+// backupEntity returns a complete entity as it would appear in a backup
+func backupEntity(id, commonName string) *models.CertificateEntity {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &models.CertificateEntity{
+		ID:                  id,
+		CommonName:          commonName,
+		KeyType:             models.KeyTypeRSA2048,
+		EncryptedPrivateKey: "kms-ciphertext-" + id,
+		CSR:                 "csr-" + id,
+		Status:              models.StatusCSRCreated,
+		Tags:                map[string]string{"env": "test"},
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+}
+
+// buildArchive writes the entities into an encrypted archive
+func buildArchive(t *testing.T, entities ...*models.CertificateEntity) []byte {
+	var buf bytes.Buffer
+	writer, err := backup.NewWriter(&buf, testBackupPassphrase)
+	require.NoError(t, err)
+	for _, entity := range entities {
+		require.NoError(t, writer.AddEntity(entity))
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+// postImport sends an archive to the import endpoint
+func postImport(router *gin.Engine, archive []byte, passphrase, mode string) *httptest.ResponseRecorder {
+	url := "/admin/import"
+	if mode != "" {
+		url += "?mode=" + mode
+	}
+	req := httptest.NewRequest("POST", url, bytes.NewReader(archive))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if passphrase != "" {
+		req.Header.Set("X-Backup-Passphrase", passphrase)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Test exporting from one store and importing into an empty one reproduces every entity
+func TestExportImportRoundTrip(t *testing.T) {
+	source := &mockAdminStore{
+		entities: []*models.CertificateEntity{
+			backupEntity("00000000-0000-4000-8000-000000000001", "one.example.com"),
+			backupEntity("00000000-0000-4000-8000-000000000002", "two.example.com"),
+			backupEntity("00000000-0000-4000-8000-000000000003", "three.example.com"),
+		},
+	}
+
+	req := httptest.NewRequest("GET", "/admin/export", nil)
+	req.Header.Set("X-Backup-Passphrase", testBackupPassphrase)
+	w := httptest.NewRecorder()
+	newAdminTestRouter(source).ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	target := &mockAdminStore{}
+	w = postImport(newAdminTestRouter(target), w.Body.Bytes(), testBackupPassphrase, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ImportBackupResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.ImportModeSkipExisting, response.Mode)
+	assert.Equal(t, 3, response.Total)
+	assert.Equal(t, 3, response.Created)
+	assert.Zero(t, response.Failed)
+
+	require.Len(t, target.entities, len(source.entities))
+	for i, original := range source.entities {
+		restored := target.entities[i]
+		assert.Equal(t, original.ID, restored.ID)
+		assert.Equal(t, original.CommonName, restored.CommonName)
+		assert.Equal(t, original.EncryptedPrivateKey, restored.EncryptedPrivateKey)
+		assert.Equal(t, original.CSR, restored.CSR)
+		assert.Equal(t, original.Status, restored.Status)
+		assert.Equal(t, original.Tags, restored.Tags)
+		assert.True(t, original.CreatedAt.Equal(restored.CreatedAt))
+	}
+}
+
+// Test existing entities are skipped or replaced depending on the mode
+func TestImportBackupModes(t *testing.T) {
+	archive := buildArchive(t, backupEntity("00000000-0000-4000-8000-000000000001", "new.example.com"), backupEntity("00000000-0000-4000-8000-000000000002", "two.example.com"))
+
+	tests := []struct {
+		name           string
+		mode           string
+		expectedStatus models.ImportItemStatus
+		expectedCN     string
+	}{
+		{name: "default skips existing", mode: "", expectedStatus: models.ImportItemSkipped, expectedCN: "old.example.com"},
+		{name: "skip existing", mode: "skip_existing", expectedStatus: models.ImportItemSkipped, expectedCN: "old.example.com"},
+		{name: "overwrite", mode: "overwrite", expectedStatus: models.ImportItemOverwritten, expectedCN: "new.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockAdminStore{entities: []*models.CertificateEntity{backupEntity("00000000-0000-4000-8000-000000000001", "old.example.com")}}
+
+			w := postImport(newAdminTestRouter(store), archive, testBackupPassphrase, tt.mode)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response models.ImportBackupResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Len(t, response.Results, 2)
+			assert.Equal(t, "00000000-0000-4000-8000-000000000001", response.Results[0].ID)
+			assert.Equal(t, tt.expectedStatus, response.Results[0].Status)
+			assert.Equal(t, models.ImportItemCreated, response.Results[1].Status)
+			assert.Equal(t, tt.expectedCN, store.entities[0].CommonName)
+		})
+	}
+}
+
+// Test overwriting a protected entity requires force
+func TestImportBackupProtectedEntities(t *testing.T) {
+	archive := buildArchive(t, backupEntity("00000000-0000-4000-8000-000000000001", "new.example.com"))
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus models.ImportItemStatus
+		expectedCN     string
+	}{
+		{name: "overwrite skips protected", query: "?mode=overwrite", expectedStatus: models.ImportItemSkipped, expectedCN: "old.example.com"},
+		{name: "force overwrites protected", query: "?mode=overwrite&force=true", expectedStatus: models.ImportItemOverwritten, expectedCN: "new.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := backupEntity("00000000-0000-4000-8000-000000000001", "old.example.com")
+			existing.Tags = map[string]string{models.ProtectedTag: "true"}
+			store := &mockAdminStore{entities: []*models.CertificateEntity{existing}}
+
+			req := httptest.NewRequest("POST", "/admin/import"+tt.query, bytes.NewReader(archive))
+			req.Header.Set("X-Backup-Passphrase", testBackupPassphrase)
+			w := httptest.NewRecorder()
+			newAdminTestRouter(store).ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response models.ImportBackupResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Len(t, response.Results, 1)
+			assert.Equal(t, tt.expectedStatus, response.Results[0].Status)
+			assert.Equal(t, tt.expectedCN, store.entities[0].CommonName)
+		})
+	}
+
+	t.Run("invalid force value", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/admin/import?mode=overwrite&force=maybe", bytes.NewReader(archive))
+		req.Header.Set("X-Backup-Passphrase", testBackupPassphrase)
+		w := httptest.NewRecorder()
+		newAdminTestRouter(&mockAdminStore{}).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// Test invalid entities and storage failures are reported per item
+func TestImportBackupPerItemFailures(t *testing.T) {
+	invalid := backupEntity("00000000-0000-4000-8000-000000000009", "invalid.example.com")
+	invalid.KeyType = "DSA1024"
+	doubleWildcard := backupEntity("00000000-0000-4000-8000-000000000014", "wildcard.example.com")
+	doubleWildcard.SubjectAlternativeNames = []string{"*.*.example.com"}
+	archive := buildArchive(t,
+		backupEntity("00000000-0000-4000-8000-000000000001", "one.example.com"),
+		invalid,
+		backupEntity("00000000-0000-4000-8000-000000000005", "broken.example.com"),
+		doubleWildcard,
+		backupEntity("abc", "short.example.com"),
+	)
+
+	store := &mockAdminStore{restoreErr: map[string]error{"00000000-0000-4000-8000-000000000005": errors.New("kms unavailable")}}
+	w := postImport(newAdminTestRouter(store), archive, testBackupPassphrase, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ImportBackupResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 5, response.Total)
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 4, response.Failed)
+
+	require.Len(t, response.Results, 5)
+	assert.Equal(t, models.ImportItemFailed, response.Results[1].Status)
+	assert.Contains(t, response.Results[1].Error, "unsupported key type")
+	assert.Equal(t, models.ImportItemFailed, response.Results[2].Status)
+	assert.NotContains(t, response.Results[2].Error, "kms unavailable", "Internal errors must not leak")
+	assert.Equal(t, models.ImportItemFailed, response.Results[3].Status)
+	assert.Contains(t, response.Results[3].Error, "*.*.example.com")
+	assert.Equal(t, models.ImportItemFailed, response.Results[4].Status)
+	assert.Contains(t, response.Results[4].Error, "is not a UUID")
+
+	require.Len(t, store.entities, 1)
+	assert.Equal(t, "00000000-0000-4000-8000-000000000001", store.entities[0].ID)
+}
+
+// Test imported entities are held to the denylist, country, SAN and tag policy of key creation
+func TestImportBackupAppliesPolicy(t *testing.T) {
+	denied := backupEntity("00000000-0000-4000-8000-000000000007", "app.corp.internal")
+	country := backupEntity("00000000-0000-4000-8000-000000000006", "country.example.com")
+	country.Country = "US"
+	sans := backupEntity("00000000-0000-4000-8000-000000000011", "sans.example.com")
+	sans.SubjectAlternativeNames = []string{"a.example.com", "b.example.com", "c.example.com"}
+	tags := backupEntity("00000000-0000-4000-8000-000000000013", "tags.example.com")
+	tags.Tags = map[string]string{"env": "test", "team": "pki", "owner": "ops"}
+	allowed := backupEntity("00000000-0000-4000-8000-000000000004", "allowed.example.com")
+	allowed.Country = "NL"
+	archive := buildArchive(t, denied, country, sans, tags, allowed)
+
+	gin.SetMode(gin.TestMode)
+	cryptoService := crypto.NewCryptoService()
+	cryptoService.SetMaxSANs(2)
+	store := &mockAdminStore{}
+	handler := NewAdminHandler(store, cryptoService, newTestLogger())
+	handler.SetPolicy(middleware.Policy{AllowedCountries: []string{"NL"}, TagLimits: middleware.TagLimits{MaxTags: 2}}, []string{"*.corp.internal"})
+	router := gin.New()
+	router.POST("/admin/import", handler.ImportBackup)
+
+	w := postImport(router, archive, testBackupPassphrase, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ImportBackupResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 4, response.Failed)
+
+	require.Len(t, response.Results, 5)
+	for i, message := range []string{"denylist", `country "US" is not allowed`, "too many", "at most 2 tags"} {
+		assert.Equal(t, models.ImportItemFailed, response.Results[i].Status)
+		assert.Contains(t, response.Results[i].Error, message)
+	}
+
+	require.Len(t, store.entities, 1)
+	assert.Equal(t, "00000000-0000-4000-8000-000000000004", store.entities[0].ID)
+}
+
+// Test entities whose key_type does not match their CSR are not imported
+func TestImportBackupKeyTypeMismatch(t *testing.T) {
+	_, csrPEM, err := crypto.NewCryptoService().GenerateKeyAndCSR(models.CreateKeyRequest{
+		CommonName: "rsa.example.com",
+		KeyType:    models.KeyTypeRSA2048,
+	})
+	require.NoError(t, err)
+
+	entity := func(id string, keyType models.KeyType) *models.CertificateEntity {
+		entity := backupEntity(id, "rsa.example.com")
+		entity.KeyType = keyType
+		entity.CSR = csrPEM
+		return entity
+	}
+	archive := buildArchive(t,
+		entity("00000000-0000-4000-8000-000000000010", models.KeyTypeRSA2048),
+		entity("00000000-0000-4000-8000-000000000008", models.KeyTypeECDSAP256),
+		entity("00000000-0000-4000-8000-000000000012", models.KeyTypeRSA4096),
+	)
+
+	store := &mockAdminStore{}
+	w := postImport(newAdminTestRouter(store), archive, testBackupPassphrase, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response models.ImportBackupResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 2, response.Failed)
+
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, models.ImportItemCreated, response.Results[0].Status)
+	assert.Empty(t, response.Results[0].InferredKeyType)
+	for i, requested := range map[int]models.KeyType{1: models.KeyTypeECDSAP256, 2: models.KeyTypeRSA4096} {
+		assert.Equal(t, models.ImportItemFailed, response.Results[i].Status)
+		assert.Equal(t, requested, response.Results[i].RequestedKeyType)
+		assert.Equal(t, models.KeyTypeRSA2048, response.Results[i].InferredKeyType)
+		assert.Contains(t, response.Results[i].Error, "does not match the RSA2048 key")
+	}
+
+	require.Len(t, store.entities, 1)
+	assert.Equal(t, "00000000-0000-4000-8000-000000000010", store.entities[0].ID)
+}
+
+// Test bad requests are rejected before anything is written
+func TestImportBackupRejectsInvalidRequests(t *testing.T) {
+	archive := buildArchive(t, backupEntity("00000000-0000-4000-8000-000000000001", "one.example.com"))
+
+	tests := []struct {
+		name       string
+		archive    []byte
+		passphrase string
+		mode       string
+	}{
+		{name: "invalid mode", archive: archive, passphrase: testBackupPassphrase, mode: "merge"},
+		{name: "missing passphrase", archive: archive},
+		{name: "wrong passphrase", archive: archive, passphrase: "not-the-right-passphrase"},
+		{name: "truncated archive", archive: archive[:len(archive)-20], passphrase: testBackupPassphrase},
+		{name: "not an archive", archive: []byte("hello world"), passphrase: testBackupPassphrase},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockAdminStore{}
+			w := postImport(newAdminTestRouter(store), tt.archive, tt.passphrase, tt.mode)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Empty(t, store.entities)
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "Bad Request", response["error"])
+		})
+	}
+}
+
+// TestRekeyEntities tests the rekey endpoint's limit handling and progress response
+func TestRekeyEntities(t *testing.T) {
+	store := &mockAdminStore{
+		entities: []*models.CertificateEntity{{ID: "00000000-0000-4000-8000-000000000001"}, {ID: "00000000-0000-4000-8000-000000000002"}, {ID: "00000000-0000-4000-8000-000000000003"}},
+	}
+	router := newAdminTestRouter(store)
+
+	t.Run("default limit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/rekey", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, defaultRekeyLimit, store.rekeyLimit)
+
+		var response models.RekeyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 3, response.Rekeyed)
+		assert.True(t, response.Complete)
+	})
+
+	t.Run("explicit limit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/rekey?limit=2", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 2, store.rekeyLimit)
+
+		var response models.RekeyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 1, response.Pending)
+		assert.False(t, response.Complete)
+	})
+
+	for _, limit := range []string{"0", "-1", "1001", "abc"} {
+		t.Run("invalid limit "+limit, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/rekey?limit="+limit, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+
+	t.Run("storage failure", func(t *testing.T) {
+		failing := &mockAdminStore{rekeyErr: errors.New("describe key: access denied")}
+		w := httptest.NewRecorder()
+		newAdminTestRouter(failing).ServeHTTP(w, httptest.NewRequest("POST", "/admin/rekey", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NotContains(t, w.Body.String(), "access denied")
+	})
+}
@@ -1,27 +1,58 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"net/http"
+	"path"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/sirupsen/logrus"
 
+	"certificate-monkey/internal/api/middleware"
+	"certificate-monkey/internal/audit"
 	"certificate-monkey/internal/crypto"
+	"certificate-monkey/internal/metrics"
 	"certificate-monkey/internal/models"
+	"certificate-monkey/internal/notify"
 	"certificate-monkey/internal/storage"
 )
 
 // CertificateHandler handles certificate-related HTTP requests
 type CertificateHandler struct {
-	storage       *storage.DynamoDBStorage
-	cryptoService *crypto.CryptoService
-	logger        *logrus.Logger
+	storage              *storage.DynamoDBStorage
+	events               *storage.EventStore
+	auditSink            audit.Sink
+	notifier             notify.Notifier
+	cryptoService        *crypto.CryptoService
+	logger               *logrus.Logger
+	requiredExtKeyUsages []string
+	allowedIssuers       []string
+	requireDNSSAN        bool
+	requireCNInSANs      bool
+	enforceSANPolicy     bool
+	maxValidity          time.Duration
+	maxClockSkew         time.Duration
+	enforceValidity      bool
+	allowedKeyTypes      []models.KeyType
+	pfxSlots             chan struct{}
+	deniedNames          []string
 }
 
+// pfxRetryAfter is the Retry-After value, in seconds, sent when every PFX generation slot is taken
+const pfxRetryAfter = "1"
+
 // NewCertificateHandler creates a new certificate handler
 func NewCertificateHandler(storage *storage.DynamoDBStorage, cryptoService *crypto.CryptoService, logger *logrus.Logger) *CertificateHandler {
 	return &CertificateHandler{
@@ -31,6 +62,176 @@ func NewCertificateHandler(storage *storage.DynamoDBStorage, cryptoService *cryp
 	}
 }
 
+// SetRequiredExtKeyUsages sets the extended key usages, such as serverAuth, that uploaded certificates
+// are expected to carry. Missing usages are reported as warnings rather than rejecting the upload.
+func (h *CertificateHandler) SetRequiredExtKeyUsages(usages []string) {
+	h.requiredExtKeyUsages = usages
+}
+
+// SetAllowedIssuers sets the issuer common names or distinguished names that uploaded certificates
+// must come from; certificates from any other issuer are rejected with 422. An empty list accepts any issuer.
+func (h *CertificateHandler) SetAllowedIssuers(issuers []string) {
+	h.allowedIssuers = issuers
+}
+
+// SetSANPolicy sets which subject alternative name checks apply to uploaded certificates: requireDNSSAN
+// flags certificates without a DNS SAN and requireCNInSANs, which implies it, a common name missing from
+// the DNS SANs. Flagged certificates are rejected with 422 when enforce is set and reported as warnings otherwise.
+func (h *CertificateHandler) SetSANPolicy(requireDNSSAN, requireCNInSANs, enforce bool) {
+	h.requireDNSSAN = requireDNSSAN
+	h.requireCNInSANs = requireCNInSANs
+	h.enforceSANPolicy = enforce
+}
+
+// SetEventStore sets the durable event log that GetEvents reads
+func (h *CertificateHandler) SetEventStore(events *storage.EventStore) {
+	h.events = events
+}
+
+// SetAuditSink sets the sink that key creation, certificate uploads, exports and deletions are
+// recorded in; nil disables it
+func (h *CertificateHandler) SetAuditSink(sink audit.Sink) {
+	h.auditSink = sink
+}
+
+// SetNotifier sets the notifier told when a certificate is uploaded; nil disables it
+func (h *CertificateHandler) SetNotifier(notifier notify.Notifier) {
+	h.notifier = notifier
+}
+
+// SetValidityPolicy sets the longest validity period accepted for uploaded certificates, where zero
+// disables the check, and how far in the future their NotBefore may lie. Over-long, expired and not
+// yet valid certificates are rejected with 422 when enforce is set and reported as warnings otherwise.
+func (h *CertificateHandler) SetValidityPolicy(maxValidity, maxClockSkew time.Duration, enforce bool) {
+	h.maxValidity = maxValidity
+	h.maxClockSkew = maxClockSkew
+	h.enforceValidity = enforce
+}
+
+// SetAllowedKeyTypes restricts the key types of new keys and imported CSRs. An empty list allows every supported type.
+func (h *CertificateHandler) SetAllowedKeyTypes(keyTypes []models.KeyType) {
+	h.allowedKeyTypes = keyTypes
+}
+
+// SetDeniedNames sets the lower-case names and glob patterns, such as *.corp.internal, that the
+// common name and SANs of new keys, imported CSRs and regenerated CSRs must not match
+func (h *CertificateHandler) SetDeniedNames(rules []string) {
+	h.deniedNames = rules
+}
+
+// SetMaxConcurrentPFX caps the PFX files generated at once; requests beyond it are rejected with 503.
+// Zero or less removes the limit.
+func (h *CertificateHandler) SetMaxConcurrentPFX(n int) {
+	if n <= 0 {
+		h.pfxSlots = nil
+		return
+	}
+	h.pfxSlots = make(chan struct{}, n)
+}
+
+// acquirePFXSlot takes a PFX generation slot without waiting, returning the function that frees it.
+// When every slot is taken it renders a 503 response with Retry-After and returns false.
+func (h *CertificateHandler) acquirePFXSlot(c *gin.Context) (func(), bool) {
+	if h.pfxSlots == nil {
+		return func() {}, true
+	}
+	select {
+	case h.pfxSlots <- struct{}{}:
+		return func() { <-h.pfxSlots }, true
+	default:
+		c.Header("Retry-After", pfxRetryAfter)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Service Unavailable",
+			"message": "Too many PFX generations in progress",
+		})
+		return nil, false
+	}
+}
+
+// keyTypeAllowed reports whether new keys of the given type may be created. Otherwise it renders
+// a 400 response listing the allowed types and returns false.
+func (h *CertificateHandler) keyTypeAllowed(c *gin.Context, keyType models.KeyType) bool {
+	allowed := h.allowedKeyTypes
+	if len(allowed) == 0 {
+		allowed = models.SupportedKeyTypes
+	}
+	for _, allowedType := range allowed {
+		if keyType == allowedType {
+			return true
+		}
+	}
+
+	message := "Invalid key type"
+	if keyType.IsSupported() {
+		message = "Key type is not allowed"
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":       "Bad Request",
+		"message":     message,
+		"valid_types": allowed,
+	})
+	return false
+}
+
+// signatureAlgorithmAllowed reports whether a requested CSR signature algorithm suits the key type.
+// Otherwise it renders a 400 response listing the algorithms the key type supports and returns false.
+func signatureAlgorithmAllowed(c *gin.Context, keyType models.KeyType, algorithm string) bool {
+	if algorithm == "" || keyType.SupportsSignatureAlgorithm(algorithm) {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":            "Bad Request",
+		"message":          "Signature algorithm is not compatible with the key type",
+		"valid_algorithms": keyType.SignatureAlgorithms(),
+	})
+	return false
+}
+
+// tooManySANs renders the 400 response for a request whose CSR would carry more SANs than MAX_SANS allows
+func tooManySANs(c *gin.Context, count int, err error) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Bad Request",
+		"message": "Too many subject alternative names",
+		"details": err.Error(),
+		"count":   count,
+	})
+}
+
+// namesAllowed reports whether neither the common name nor any SAN matches the denylist.
+// Otherwise it renders a 403 response naming the denied name and the rule it matched and returns false.
+func (h *CertificateHandler) namesAllowed(c *gin.Context, commonName string, sans []string) bool {
+	name, rule, denied := matchDeniedName(h.deniedNames, append([]string{commonName}, sans...))
+	if !denied {
+		return true
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"name": name,
+		"rule": rule,
+	}).Warn("Request for a denied name rejected")
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":   "Forbidden",
+		"message": "Name is on the denylist",
+		"name":    name,
+		"rule":    rule,
+	})
+	return false
+}
+
+// matchDeniedName returns the first name matching a denylist rule, compared case-insensitively,
+// and that rule. Rules are exact names or path.Match glob patterns, where * also spans dots.
+func matchDeniedName(rules, names []string) (string, string, bool) {
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		for _, rule := range rules {
+			if matched, _ := path.Match(rule, lower); matched || rule == lower {
+				return name, rule, true
+			}
+		}
+	}
+	return "", "", false
+}
+
 // CreateKey creates a new private key and CSR
 // @Summary Create a new private key and certificate signing request
 // @Description Generates a new private key pair and creates a certificate signing request (CSR) with the provided details
@@ -41,58 +242,44 @@ func NewCertificateHandler(storage *storage.DynamoDBStorage, cryptoService *cryp
 // @Security BearerAuth
 // @Param request body models.CreateKeyRequest true "Certificate creation request"
 // @Success 201 {object} models.CreateKeyResponse "Successfully created private key and CSR"
-// @Failure 400 {object} map[string]interface{} "Bad request - invalid input parameters"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid input parameters, a key type outside ALLOWED_KEY_TYPES (listed in valid_types), a signature_algorithm the key type does not support (listed in valid_algorithms) or more SANs than MAX_SANS (count given); field violations are listed in errors as {field, rule, message}"
 // @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} map[string]interface{} "Forbidden - the common name or a SAN matches NAME_DENYLIST_FILE; the denied name and matched rule are returned"
+// @Failure 409 {object} map[string]interface{} "Conflict - certificate entity already exists"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /keys [post]
 func (h *CertificateHandler) CreateKey(c *gin.Context) {
 	var req models.CreateKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.WithError(err).Error("Failed to bind JSON request")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Bad Request",
-			"message": "Invalid request format",
-			"details": err.Error(),
-		})
+		// The validation middleware renders the structured error response
+		c.Status(http.StatusBadRequest)
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
+	// Record the common name as the generated CSR carries it
+	req.CommonName = h.cryptoService.NormalizeCommonName(req.CommonName)
+
 	// Validate key type
-	validKeyTypes := []models.KeyType{
-		models.KeyTypeRSA2048,
-		models.KeyTypeRSA4096,
-		models.KeyTypeECDSAP256,
-		models.KeyTypeECDSAP384,
-	}
-	isValidKeyType := false
-	for _, validType := range validKeyTypes {
-		if req.KeyType == validType {
-			isValidKeyType = true
-			break
-		}
+	if !h.keyTypeAllowed(c, req.KeyType) {
+		return
 	}
-	if !isValidKeyType {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Bad Request",
-			"message": "Invalid key type",
-			"valid_types": []string{
-				string(models.KeyTypeRSA2048),
-				string(models.KeyTypeRSA4096),
-				string(models.KeyTypeECDSAP256),
-				string(models.KeyTypeECDSAP384),
-			},
-		})
+	if !signatureAlgorithmAllowed(c, req.KeyType, req.SignatureAlgorithm) {
+		return
+	}
+	if !h.namesAllowed(c, req.CommonName, req.SubjectAlternativeNames) {
 		return
 	}
-
-	// Generate UUID for the certificate entity
-	entityID := uuid.New().String()
 
 	// Generate private key and CSR
 	privateKeyPEM, csrPEM, err := h.cryptoService.GenerateKeyAndCSR(req)
+	if errors.Is(err, crypto.ErrTooManySANs) {
+		tooManySANs(c, len(req.SubjectAlternativeNames), err)
+		return
+	}
 	if err != nil {
 		h.logger.WithError(err).WithFields(logrus.Fields{
-			"entity_id":   entityID,
 			"common_name": req.CommonName,
 			"key_type":    req.KeyType,
 		}).Error("Failed to generate private key and CSR")
@@ -103,12 +290,31 @@ func (h *CertificateHandler) CreateKey(c *gin.Context) {
 		return
 	}
 
-	// Create certificate entity
+	spkiPin, err := h.cryptoService.PublicKeyPin(csrPEM)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to compute SPKI pin")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to generate cryptographic material",
+		})
+		return
+	}
+	subject, sans, err := h.cryptoService.CSRNames(csrPEM)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to read back the generated CSR")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to generate cryptographic material",
+		})
+		return
+	}
+
+	// Create certificate entity; the storage layer assigns its UUID. The SANs are stored as the CSR
+	// carries them, so reads of the entity match the create response.
 	now := time.Now()
 	entity := &models.CertificateEntity{
-		ID:                      entityID,
 		CommonName:              req.CommonName,
-		SubjectAlternativeNames: req.SubjectAlternativeNames,
+		SubjectAlternativeNames: sans,
 		Organization:            req.Organization,
 		OrganizationalUnit:      req.OrganizationalUnit,
 		Country:                 req.Country,
@@ -118,32 +324,53 @@ func (h *CertificateHandler) CreateKey(c *gin.Context) {
 		KeyType:                 req.KeyType,
 		EncryptedPrivateKey:     privateKeyPEM,
 		CSR:                     csrPEM,
+		SPKIPin:                 spkiPin,
 		Status:                  models.StatusCSRCreated,
 		Tags:                    req.Tags,
+		Notes:                   models.SanitizeNotes(req.Notes),
+		ExpiresTTL:              models.EntityTTL(now, req.TTLDays),
 		CreatedAt:               now,
 		UpdatedAt:               now,
 	}
+	created := statusChange(c, "", models.StatusCSRCreated)
+	created.Timestamp = now
+	entity.StatusHistory = []models.StatusChange{*created}
 
 	// Store in DynamoDB
 	err = h.storage.CreateCertificateEntity(c.Request.Context(), entity)
 	if err != nil {
-		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to store certificate entity")
+		h.logger.WithError(err).WithField("entity_id", entity.ID).Error("Failed to store certificate entity")
+		if errors.Is(err, storage.ErrEntityExists) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"message": "Certificate entity already exists",
+				"id":      entity.ID,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Internal Server Error",
 			"message": "Failed to store certificate data",
 		})
 		return
 	}
+	entityID := entity.ID
+	metrics.KeyCreated(string(req.KeyType))
+	recordEvent(c.Request.Context(), h.auditSink, h.logger, newEvent(c, entityID, models.EventKeyCreated))
 
 	// Prepare response
 	response := models.CreateKeyResponse{
-		ID:         entityID,
-		CommonName: req.CommonName,
-		KeyType:    req.KeyType,
-		CSR:        csrPEM,
-		Status:     models.StatusCSRCreated,
-		Tags:       req.Tags,
-		CreatedAt:  now,
+		ID:                      entityID,
+		CommonName:              req.CommonName,
+		Subject:                 subject,
+		SubjectAlternativeNames: sans,
+		KeyType:                 req.KeyType,
+		CSR:                     csrPEM,
+		Status:                  models.StatusCSRCreated,
+		Tags:                    req.Tags,
+		Notes:                   entity.Notes,
+		ExpiresTTL:              entity.ExpiresTTL,
+		CreatedAt:               now,
 	}
 
 	h.logger.WithFields(logrus.Fields{
@@ -155,175 +382,173 @@ func (h *CertificateHandler) CreateKey(c *gin.Context) {
 	c.JSON(http.StatusCreated, response)
 }
 
-// UploadCertificate uploads a certificate for an existing CSR
-// @Summary Upload certificate for existing CSR
-// @Description Uploads and validates a certificate against an existing certificate signing request
+// CreateExternalKey creates a certificate entity from a CSR whose private key is held elsewhere
+// @Summary Create a certificate entity from an external CSR
+// @Description Attaches a CSR generated outside Certificate Monkey, for example on an air-gapped host, so the entity can hold the eventual certificate. The subject, SANs and key type are read from the CSR, which must carry a valid self-signature, and are checked against the same policy as POST /keys. The entity has status CSR_CREATED and external_key set; no private key is stored, so certificates can be uploaded but PFX generation, private key export and CSR regeneration are refused.
 // @Tags Certificate Management
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
 // @Security BearerAuth
-// @Param id path string true "Certificate entity ID (UUID format)"
-// @Param request body models.UploadCertificateRequest true "Certificate upload request containing PEM-encoded certificate"
-// @Success 200 {object} models.UploadCertificateResponse "Certificate uploaded successfully"
-// @Failure 400 {object} map[string]interface{} "Bad request - invalid certificate or ID format"
+// @Param request body models.CreateExternalKeyRequest true "PEM CSR and optional tags"
+// @Success 201 {object} models.CreateKeyResponse "Certificate entity created from the CSR"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid CSR, a subject violating the policy, a key type outside ALLOWED_KEY_TYPES or more SANs than MAX_SANS (count given); field violations are listed in errors as {field, rule, message}"
 // @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
-// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 403 {object} map[string]interface{} "Forbidden - the common name or a SAN matches NAME_DENYLIST_FILE; the denied name and matched rule are returned"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
-// @Router /keys/{id}/certificate [put]
-func (h *CertificateHandler) UploadCertificate(c *gin.Context) {
-	entityID := c.Param("id")
-	if entityID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Bad Request",
-			"message": "Entity ID is required",
-		})
+// @Router /keys/external [post]
+func (h *CertificateHandler) CreateExternalKey(c *gin.Context) {
+	var body models.CreateExternalKeyRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		// The validation middleware renders the structured error response
+		c.Status(http.StatusBadRequest)
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	var req models.UploadCertificateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.WithError(err).Error("Failed to bind JSON request")
+	req, err := h.cryptoService.RequestFromCSR(body.CSR)
+	if errors.Is(err, crypto.ErrTooManySANs) {
+		_, sans, _ := h.cryptoService.CSRNames(body.CSR)
+		tooManySANs(c, len(sans), err)
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Bad Request",
-			"message": "Invalid request format",
+			"message": "Invalid CSR",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	// Retrieve existing entity
-	entity, err := h.storage.GetCertificateEntity(c.Request.Context(), entityID)
-	if err != nil {
-		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Not Found",
-			"message": "Certificate entity not found",
-		})
+	// The CSR's subject must satisfy the same rules as a generated one
+	req.Tags = body.Tags
+	req.Notes = body.Notes
+	req.TTLDays = body.TTLDays
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		c.Status(http.StatusBadRequest)
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	if !h.keyTypeAllowed(c, req.KeyType) {
+		return
+	}
+	if !h.namesAllowed(c, req.CommonName, req.SubjectAlternativeNames) {
 		return
 	}
 
-	// Validate that certificate matches the CSR
-	err = h.cryptoService.ValidateCertificateWithCSR(req.Certificate, entity.CSR)
+	spkiPin, err := h.cryptoService.PublicKeyPin(body.CSR)
 	if err != nil {
-		h.logger.WithError(err).WithField("entity_id", entityID).Error("Certificate validation failed")
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Bad Request",
-			"message": "Certificate does not match the CSR",
+			"message": "Invalid CSR",
 			"details": err.Error(),
 		})
 		return
 	}
-
-	// Parse certificate to extract details
-	cert, err := h.cryptoService.ParseCertificate(req.Certificate)
+	subject, _, err := h.cryptoService.CSRNames(body.CSR)
 	if err != nil {
-		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to parse certificate")
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Bad Request",
-			"message": "Invalid certificate format",
+			"message": "Invalid CSR",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	// Generate certificate fingerprint
-	fingerprint, err := h.cryptoService.GenerateCertificateFingerprint(req.Certificate)
-	if err != nil {
-		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to generate certificate fingerprint")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Internal Server Error",
-			"message": "Failed to process certificate",
-		})
-		return
+	// Create certificate entity without a private key; the storage layer assigns its UUID
+	now := time.Now()
+	entity := &models.CertificateEntity{
+		CommonName:              req.CommonName,
+		SubjectAlternativeNames: req.SubjectAlternativeNames,
+		Organization:            req.Organization,
+		OrganizationalUnit:      req.OrganizationalUnit,
+		Country:                 req.Country,
+		State:                   req.State,
+		City:                    req.City,
+		EmailAddress:            req.EmailAddress,
+		KeyType:                 req.KeyType,
+		ExternalKey:             true,
+		CSR:                     strings.TrimSpace(body.CSR) + "\n",
+		SPKIPin:                 spkiPin,
+		Status:                  models.StatusCSRCreated,
+		Tags:                    req.Tags,
+		Notes:                   models.SanitizeNotes(req.Notes),
+		ExpiresTTL:              models.EntityTTL(now, req.TTLDays),
+		CreatedAt:               now,
+		UpdatedAt:               now,
 	}
+	created := statusChange(c, "", models.StatusCSRCreated)
+	created.Timestamp = now
+	entity.StatusHistory = []models.StatusChange{*created}
 
-	// Update entity with certificate information
-	entity.Certificate = req.Certificate
-	entity.Status = models.StatusCertUploaded
-	entity.ValidFrom = &cert.NotBefore
-	entity.ValidTo = &cert.NotAfter
-	entity.SerialNumber = cert.SerialNumber.String()
-	entity.Fingerprint = fingerprint
-
-	// Update in DynamoDB
-	err = h.storage.UpdateCertificateEntity(c.Request.Context(), entity)
-	if err != nil {
-		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to update certificate entity")
+	if err := h.storage.CreateCertificateEntity(c.Request.Context(), entity); err != nil {
+		h.logger.WithError(err).WithField("entity_id", entity.ID).Error("Failed to store certificate entity")
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Internal Server Error",
-			"message": "Failed to update certificate data",
+			"message": "Failed to store certificate data",
 		})
 		return
 	}
-
-	// Prepare response
-	response := models.UploadCertificateResponse{
-		ID:           entityID,
-		Status:       entity.Status,
-		ValidFrom:    entity.ValidFrom,
-		ValidTo:      entity.ValidTo,
-		SerialNumber: entity.SerialNumber,
-		Fingerprint:  entity.Fingerprint,
-		UpdatedAt:    entity.UpdatedAt,
-	}
+	recordEvent(c.Request.Context(), h.auditSink, h.logger, newEvent(c, entity.ID, models.EventExternalCSRAdded))
 
 	h.logger.WithFields(logrus.Fields{
-		"entity_id":     entityID,
-		"serial_number": entity.SerialNumber,
-		"fingerprint":   entity.Fingerprint,
-	}).Info("Certificate uploaded successfully")
-
-	c.JSON(http.StatusOK, response)
+		"entity_id":   entity.ID,
+		"common_name": entity.CommonName,
+		"key_type":    entity.KeyType,
+	}).Info("Certificate entity created from external CSR")
+
+	c.JSON(http.StatusCreated, models.CreateKeyResponse{
+		ID:                      entity.ID,
+		CommonName:              entity.CommonName,
+		Subject:                 subject,
+		SubjectAlternativeNames: entity.SubjectAlternativeNames,
+		KeyType:                 entity.KeyType,
+		CSR:                     entity.CSR,
+		Status:                  entity.Status,
+		Tags:                    entity.Tags,
+		Notes:                   entity.Notes,
+		ExpiresTTL:              entity.ExpiresTTL,
+		CreatedAt:               now,
+	})
 }
 
-// GeneratePFX generates a PKCS#12 file for a completed certificate
-// @Summary Generate PFX/P12 file
-// @Description Creates a password-protected PKCS#12 file containing the private key and certificate
+// RegenerateCSR issues a new CSR for an existing private key
+// @Summary Regenerate the CSR for an existing key
+// @Description Builds a new certificate signing request from the stored private key with an updated subject and SANs, replacing the stored CSR and resetting the status to CSR_CREATED. No new key material is generated, so the public key stays the same. Any previously uploaded certificate is kept until a new one is uploaded.
 // @Tags Certificate Management
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
 // @Security BearerAuth
 // @Param id path string true "Certificate entity ID (UUID format)"
-// @Param request body models.GeneratePFXRequest true "PFX generation request with password"
-// @Success 200 {object} models.GeneratePFXResponse "PFX file generated successfully (base64 encoded)"
-// @Failure 400 {object} map[string]interface{} "Bad request - certificate not ready or invalid password"
+// @Param request body models.RegenerateCSRRequest true "New subject and SANs for the CSR"
+// @Success 200 {object} models.RegenerateCSRResponse "CSR regenerated successfully"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid input parameters, more SANs than MAX_SANS (count given) or the private key is held externally (code external_private_key); field violations are listed in errors as {field, rule, message}"
 // @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} map[string]interface{} "Forbidden - the common name or a SAN matches NAME_DENYLIST_FILE; the denied name and matched rule are returned"
 // @Failure 404 {object} map[string]interface{} "Certificate entity not found"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
-// @Router /keys/{id}/pfx [post]
-func (h *CertificateHandler) GeneratePFX(c *gin.Context) {
+// @Router /keys/{id}/regenerate-csr [post]
+func (h *CertificateHandler) RegenerateCSR(c *gin.Context) {
 	entityID := c.Param("id")
-	if entityID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Bad Request",
-			"message": "Entity ID is required",
-		})
-		return
-	}
 
-	var req models.GeneratePFXRequest
+	var req models.RegenerateCSRRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.WithError(err).Error("Failed to bind JSON request")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Bad Request",
-			"message": "Invalid request format",
-			"details": err.Error(),
-		})
+		// The validation middleware renders the structured error response
+		c.Status(http.StatusBadRequest)
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
-
-	if req.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Bad Request",
-			"message": "Password is required for PFX generation",
-		})
+	req.CommonName = h.cryptoService.NormalizeCommonName(req.CommonName)
+	if !h.namesAllowed(c, req.CommonName, req.SubjectAlternativeNames) {
 		return
 	}
 
-	// Retrieve entity
-	entity, err := h.storage.GetCertificateEntity(c.Request.Context(), entityID)
+	// Retrieve entity with its decrypted private key
+	entity, err := h.storage.GetCertificateEntity(c.Request.Context(), entityID, true)
 	if err != nil {
 		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
 		c.JSON(http.StatusNotFound, gin.H{
@@ -332,67 +557,98 @@ func (h *CertificateHandler) GeneratePFX(c *gin.Context) {
 		})
 		return
 	}
+	if !requirePrivateKey(c, entity) {
+		return
+	}
 
-	// Validate that both private key and certificate are available
-	if entity.EncryptedPrivateKey == "" || entity.Certificate == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Bad Request",
-			"message": "Both private key and certificate must be available to generate PFX",
+	csrPEM, err := h.cryptoService.GenerateCSRFromKey(entity.EncryptedPrivateKey, models.CreateKeyRequest{
+		CommonName:              req.CommonName,
+		SubjectAlternativeNames: req.SubjectAlternativeNames,
+		Organization:            req.Organization,
+		OrganizationalUnit:      req.OrganizationalUnit,
+		Country:                 req.Country,
+		State:                   req.State,
+		City:                    req.City,
+		EmailAddress:            req.EmailAddress,
+	})
+	if errors.Is(err, crypto.ErrTooManySANs) {
+		tooManySANs(c, len(req.SubjectAlternativeNames), err)
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to regenerate CSR")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to generate certificate signing request",
 		})
 		return
 	}
-
-	// Generate PFX
-	pfxData, err := h.cryptoService.GeneratePFX(entity.EncryptedPrivateKey, entity.Certificate, req.Password)
+	_, sans, err := h.cryptoService.CSRNames(csrPEM)
 	if err != nil {
-		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to generate PFX")
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to read back the regenerated CSR")
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Internal Server Error",
-			"message": "Failed to generate PFX file",
-			"details": err.Error(),
+			"message": "Failed to generate certificate signing request",
 		})
 		return
 	}
 
-	// Encode PFX data as base64
-	pfxBase64 := h.cryptoService.EncodeToBase64(pfxData)
-
-	// Generate filename
-	filename := fmt.Sprintf("%s-%s.pfx", entity.CommonName, entityID[:8])
-
-	// Prepare response
-	response := models.GeneratePFXResponse{
-		ID:       entityID,
-		PFXData:  pfxBase64,
-		Filename: filename,
+	entity.CommonName = req.CommonName
+	entity.SubjectAlternativeNames = sans
+	entity.Organization = req.Organization
+	entity.OrganizationalUnit = req.OrganizationalUnit
+	entity.Country = req.Country
+	entity.State = req.State
+	entity.City = req.City
+	entity.EmailAddress = req.EmailAddress
+	entity.CSR = csrPEM
+
+	change := statusChange(c, entity.Status, models.StatusCSRCreated)
+	if err := h.storage.UpdateCSR(c.Request.Context(), entity, change); err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to store regenerated CSR")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to store certificate signing request",
+		})
+		return
 	}
 
 	h.logger.WithFields(logrus.Fields{
 		"entity_id":   entityID,
 		"common_name": entity.CommonName,
-		"filename":    filename,
-	}).Info("PFX file generated successfully")
-
-	c.JSON(http.StatusOK, response)
+		"key_type":    entity.KeyType,
+	}).Info("CSR regenerated successfully")
+
+	c.JSON(http.StatusOK, models.RegenerateCSRResponse{
+		ID:                      entity.ID,
+		CommonName:              entity.CommonName,
+		SubjectAlternativeNames: entity.SubjectAlternativeNames,
+		KeyType:                 entity.KeyType,
+		CSR:                     entity.CSR,
+		Status:                  entity.Status,
+		UpdatedAt:               entity.UpdatedAt,
+	})
 }
 
-// GetCertificate retrieves a certificate entity by ID
-// @Summary Get certificate by ID
-// @Description Retrieves a specific certificate entity including its private key, CSR, and certificate details
+// UploadCertificate uploads a certificate for an existing CSR
+// @Summary Upload certificate for existing CSR
+// @Description Uploads and validates a certificate against an existing certificate signing request. The certificate may be PEM, optionally followed by its chain, base64 DER of the certificate and its chain, or a PKCS#7 (.p7b) bundle as a PEM PKCS7 block or base64 DER; DER is stored as PEM, with a bundle leaf first. Set encoding to pem or der to require one; without it the encoding is detected from the PEM headers.
 // @Tags Certificate Management
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
 // @Security BearerAuth
-// @Param id path string true "Certificate ID (UUID format)"
-// @Success 200 {object} models.CertificateEntity "Certificate entity details"
-// @Failure 400 {object} map[string]interface{} "Bad request - invalid ID format"
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Param request body models.UploadCertificateRequest true "Certificate upload request containing PEM-encoded certificate"
+// @Success 200 {object} models.UploadCertificateResponse "Certificate uploaded successfully"
+// @Failure 400 {object} map[string]interface{} "Bad request - unparseable certificate or one not in the requested encoding (code invalid_certificate), unknown encoding or ID format"
 // @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
-// @Failure 404 {object} map[string]interface{} "Certificate not found"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 422 {object} map[string]interface{} "Well-formed certificate that does not match the CSR (code certificate_csr_mismatch), is from an issuer outside ALLOWED_ISSUERS (code issuer_not_allowed) or, when enforced, violates the validity policy (codes certificate_expired, certificate_not_yet_valid, certificate_validity_too_long) or the SAN policy (codes certificate_missing_dns_san, certificate_cn_not_in_sans)"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
-// @Router /keys/{id} [get]
-func (h *CertificateHandler) GetCertificate(c *gin.Context) {
-	entityID := c.Param("id")
+// @Router /keys/{id}/certificate [put]
+func (h *CertificateHandler) UploadCertificate(c *gin.Context) {
+	entityID := c.Param("id")
 	if entityID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Bad Request",
@@ -401,8 +657,28 @@ func (h *CertificateHandler) GetCertificate(c *gin.Context) {
 		return
 	}
 
-	// Retrieve entity
-	entity, err := h.storage.GetCertificateEntity(c.Request.Context(), entityID)
+	var req models.UploadCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Base64 DER uploads are converted to the PEM the rest of the upload works with
+	certificatePEM, err := h.cryptoService.DecodeCertificateUpload(req.Certificate, req.Encoding)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to read certificate upload")
+		status, body := certificateValidationResponse(fmt.Errorf("%w: %w", crypto.ErrInvalidCertificate, err))
+		c.JSON(status, body)
+		return
+	}
+
+	// Retrieve existing entity
+	entity, err := h.storage.GetCertificateEntity(c.Request.Context(), entityID, true)
 	if err != nil {
 		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
 		c.JSON(http.StatusNotFound, gin.H{
@@ -412,175 +688,338 @@ func (h *CertificateHandler) GetCertificate(c *gin.Context) {
 		return
 	}
 
-	// Remove sensitive data from response
-	entity.EncryptedPrivateKey = "[REDACTED]"
-
-	h.logger.WithField("entity_id", entityID).Debug("Certificate entity retrieved")
-
-	c.JSON(http.StatusOK, entity)
+	response, failure := h.storeCertificate(c, entity, certificatePEM)
+	if failure != nil {
+		c.JSON(failure.status, failure.body)
+		return
+	}
+	c.JSON(http.StatusOK, response)
 }
 
-// ListCertificates retrieves a list of certificates with optional filtering
-// @Summary List certificates with filtering and sorting
-// @Description Retrieves a paginated list of certificate entities with optional filtering by tags, status, key type, date range, and sorting support
+// BatchUploadCertificates uploads certificates by matching each to the entity whose CSR holds its key
+// @Summary Upload certificates matched by CSR
+// @Description Uploads certificates returned by a CA without naming their entities. Each certificate is matched to the entity whose CSR has the same public key and then uploaded with the same validation as a single upload. When several entities share the key, the one still waiting for a certificate is chosen; otherwise the certificate is reported as ambiguous with the candidate IDs. Results are reported per certificate in request order; certificates that match no entity are reported as no_match.
 // @Tags Certificate Management
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
 // @Security BearerAuth
-// @Param status query string false "Filter by certificate status" Enums(CSR_CREATED, CERT_UPLOADED, EXPIRED, REVOKED)
-// @Param key_type query string false "Filter by key type" Enums(RSA2048, RSA4096, ECDSA-P256, ECDSA-P384)
-// @Param date_from query string false "Filter certificates created after this date (RFC3339 format)"
-// @Param date_to query string false "Filter certificates created before this date (RFC3339 format)"
-// @Param page query int false "Page number for pagination (default: 1)" minimum(1)
-// @Param page_size query int false "Number of items per page (default: 50, max: 100)" minimum(1) maximum(100)
-// @Param sort_by query string false "Sort by field (default: created_at)" Enums(created_at, updated_at, common_name, status, valid_to, valid_from, key_type)
-// @Param sort_order query string false "Sort order (default: desc)" Enums(asc, desc)
-// @Param environment query string false "Filter by environment tag"
-// @Param project query string false "Filter by project tag"
-// @Param team query string false "Filter by team tag"
-// @Success 200 {object} models.ListKeysResponse "List of certificate entities"
+// @Param request body models.BatchUploadRequest true "PEM certificates, at most 100"
+// @Success 200 {object} models.BatchUploadResponse "Per-certificate match and upload results"
+// @Failure 400 {object} map[string]interface{} "Bad request - no certificates or more than 100; field violations are listed in errors as {field, rule, message}"
 // @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
-// @Router /keys [get]
-func (h *CertificateHandler) ListCertificates(c *gin.Context) {
-	// Parse query parameters
-	var filters models.SearchFilters
-
-	// Status filter
-	if status := c.Query("status"); status != "" {
-		filters.Status = models.CertificateStatus(status)
+// @Router /keys/batch-upload [post]
+func (h *CertificateHandler) BatchUploadCertificates(c *gin.Context) {
+	var req models.BatchUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		// The validation middleware renders the structured error response
+		c.Status(http.StatusBadRequest)
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
 	}
 
-	// Key type filter
-	if keyType := c.Query("key_type"); keyType != "" {
-		filters.KeyType = models.KeyType(keyType)
+	entities, entityErrors, err := h.storage.ListCSREntities(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list certificate entities")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to list certificate entities",
+		})
+		return
 	}
 
-	// Date filters
-	if dateFrom := c.Query("date_from"); dateFrom != "" {
-		if parsedDate, err := time.Parse(time.RFC3339, dateFrom); err == nil {
-			filters.DateFrom = &parsedDate
+	response := models.BatchUploadResponse{
+		Total:   len(req.Certificates),
+		Results: matchCertificates(h.cryptoService, entities, req.Certificates),
+		Errors:  entityErrors,
+	}
+	for i := range response.Results {
+		result := &response.Results[i]
+		if result.Status == "" {
+			h.uploadMatchedCertificate(c, result, req.Certificates[i])
+		}
+		switch result.Status {
+		case models.BatchUploadUploaded:
+			response.Uploaded++
+		case models.BatchUploadNoMatch, models.BatchUploadAmbiguous:
+			response.Unmatched++
+		default:
+			response.Failed++
 		}
 	}
 
-	if dateTo := c.Query("date_to"); dateTo != "" {
-		if parsedDate, err := time.Parse(time.RFC3339, dateTo); err == nil {
-			filters.DateTo = &parsedDate
-		}
+	h.logger.WithFields(logrus.Fields{
+		"total":      response.Total,
+		"uploaded":   response.Uploaded,
+		"unmatched":  response.Unmatched,
+		"failed":     response.Failed,
+		"request_id": c.GetString("request_id"),
+	}).Info("Certificate batch upload completed")
+
+	c.JSON(http.StatusOK, response)
+}
+
+// uploadMatchedCertificate uploads a batch certificate to the entity it was matched to and records the outcome
+func (h *CertificateHandler) uploadMatchedCertificate(c *gin.Context, result *models.BatchUploadItemResult, certificatePEM string) {
+	entity, err := h.storage.GetCertificateEntity(c.Request.Context(), result.EntityID, true)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", result.EntityID).Error("Failed to retrieve certificate entity")
+		result.Status = models.BatchUploadFailed
+		result.Error = "failed to retrieve certificate entity"
+		return
 	}
 
-	// Pagination
-	if page := c.Query("page"); page != "" {
-		if p, err := strconv.Atoi(page); err == nil && p > 0 {
-			filters.Page = p
-		}
+	upload, failure := h.storeCertificate(c, entity, certificatePEM)
+	if failure != nil {
+		result.Status = models.BatchUploadFailed
+		result.Code, _ = failure.body["code"].(string)
+		result.Error, _ = failure.body["message"].(string)
+		return
 	}
+	result.Status = models.BatchUploadUploaded
+	result.Upload = upload
+}
 
-	if pageSize := c.Query("page_size"); pageSize != "" {
-		if ps, err := strconv.Atoi(pageSize); err == nil && ps > 0 && ps <= 100 {
-			filters.PageSize = ps
+// matchCertificates finds the entity each certificate belongs to by the SPKI pin of its public key.
+// Matched certificates get their EntityID and an empty Status, meaning they are still to be uploaded;
+// the others get their final status. Among several entities sharing a key the single one still waiting
+// for a certificate is chosen, and an entity is only matched by the first certificate for it.
+func matchCertificates(cryptoService *crypto.CryptoService, entities []models.CertificateEntity, certificates []string) []models.BatchUploadItemResult {
+	byPin := make(map[string][]models.CertificateEntity)
+	for _, entity := range entities {
+		pin := entity.SPKIPin
+		if pin == "" {
+			var err error
+			if pin, err = cryptoService.PublicKeyPin(entity.CSR); err != nil {
+				continue
+			}
 		}
+		byPin[pin] = append(byPin[pin], entity)
 	}
 
-	// Sorting parameters
-	if sortBy := c.Query("sort_by"); sortBy != "" {
-		// Validate sort field
-		validSortFields := []string{"created_at", "updated_at", "common_name", "status", "valid_to", "valid_from", "key_type"}
-		isValid := false
-		for _, validField := range validSortFields {
-			if sortBy == validField {
-				isValid = true
-				break
-			}
+	results := make([]models.BatchUploadItemResult, len(certificates))
+	matchedBy := make(map[string]int)
+	for i, certificatePEM := range certificates {
+		result := &results[i]
+		result.Index = i
+
+		pin, err := certificatePin(cryptoService, certificatePEM)
+		if err != nil {
+			result.Status = models.BatchUploadFailed
+			result.Code = codeInvalidCertificate
+			result.Error = err.Error()
+			continue
 		}
-		if isValid {
-			filters.SortBy = sortBy
+
+		candidates := byPin[pin]
+		if len(candidates) > 1 {
+			var waiting []models.CertificateEntity
+			for _, candidate := range candidates {
+				if candidate.Status == models.StatusCSRCreated {
+					waiting = append(waiting, candidate)
+				}
+			}
+			if len(waiting) == 1 {
+				candidates = waiting
+			}
 		}
-	}
 
-	if sortOrder := c.Query("sort_order"); sortOrder != "" {
-		// Validate sort order
-		if sortOrder == "asc" || sortOrder == "desc" {
-			filters.SortOrder = sortOrder
+		switch len(candidates) {
+		case 0:
+			result.Status = models.BatchUploadNoMatch
+			result.Error = "no match"
+		case 1:
+			result.EntityID = candidates[0].ID
+			if first, ok := matchedBy[result.EntityID]; ok {
+				result.Status = models.BatchUploadFailed
+				result.Error = fmt.Sprintf("entity already matched by certificate %d", first)
+				continue
+			}
+			matchedBy[result.EntityID] = i
+		default:
+			result.Status = models.BatchUploadAmbiguous
+			result.Error = "several entities hold this key"
+			for _, candidate := range candidates {
+				result.CandidateIDs = append(result.CandidateIDs, candidate.ID)
+			}
 		}
 	}
+	return results
+}
 
-	// Set defaults for sorting
-	if filters.SortBy == "" {
-		filters.SortBy = "created_at"
+// certificatePin returns the SPKI pin of a PEM certificate or the leaf of a PKCS#7 bundle;
+// other PEM types are rejected
+func certificatePin(cryptoService *crypto.CryptoService, certificatePEM string) (string, error) {
+	certificatePEM, err := cryptoService.CertificateBundlePEM(certificatePEM)
+	if err != nil {
+		return "", err
 	}
-	if filters.SortOrder == "" {
-		filters.SortOrder = "desc"
+	if _, err := cryptoService.ParseCertificate(certificatePEM); err != nil {
+		return "", err
 	}
+	return cryptoService.PublicKeyPin(certificatePEM)
+}
 
-	// Tag filters - expecting format: tag_key=tag_value
-	filters.Tags = make(map[string]string)
-	for key, values := range c.Request.URL.Query() {
-		if len(values) > 0 && key != "status" && key != "key_type" && key != "date_from" && key != "date_to" && key != "page" && key != "page_size" && key != "sort_by" && key != "sort_order" {
-			filters.Tags[key] = values[0]
-		}
+// uploadFailure is a rejected certificate upload with the status and body that describe it
+type uploadFailure struct {
+	status int
+	body   gin.H
+}
+
+// storeCertificate validates a certificate against an entity's CSR and the validity policy and
+// records it with the details parsed from it. The entity must have been read with its private key.
+func (h *CertificateHandler) storeCertificate(c *gin.Context, entity *models.CertificateEntity, certificatePEM string) (*models.UploadCertificateResponse, *uploadFailure) {
+	entityID := entity.ID
+
+	// PKCS#7 bundles are converted to PEM and stored like any other upload
+	certificatePEM, err := h.cryptoService.CertificateBundlePEM(certificatePEM)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to read certificate upload")
+		status, body := certificateValidationResponse(fmt.Errorf("%w: %w", crypto.ErrInvalidCertificate, err))
+		return nil, &uploadFailure{status: status, body: body}
 	}
 
-	// Retrieve entities
-	entities, err := h.storage.ListCertificateEntities(c.Request.Context(), filters)
+	// Validate that certificate matches the CSR
+	err = h.cryptoService.ValidateCertificateWithCSR(certificatePEM, entity.CSR)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to list certificate entities")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Internal Server Error",
-			"message": "Failed to retrieve certificate list",
-		})
-		return
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Certificate validation failed")
+		status, body := certificateValidationResponse(err)
+		return nil, &uploadFailure{status: status, body: body}
 	}
 
-	// Get total count before pagination (we need to call storage method that returns total count)
-	totalCount, err := h.storage.GetCertificateEntityCount(c.Request.Context(), filters)
+	cert, err := h.cryptoService.ParseCertificate(certificatePEM)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to get certificate entity count")
-		// Continue with current count as fallback
-		totalCount = len(entities)
+		status, body := certificateValidationResponse(fmt.Errorf("%w: %w", crypto.ErrInvalidCertificate, err))
+		return nil, &uploadFailure{status: status, body: body}
 	}
 
-	// Remove sensitive data from response
-	for i := range entities {
-		entities[i].EncryptedPrivateKey = "[REDACTED]"
+	// Only certificates from approved issuers are accepted
+	if len(h.allowedIssuers) > 0 && !issuerAllowed(h.allowedIssuers, cert.Issuer) {
+		h.logger.WithFields(logrus.Fields{
+			"entity_id": entityID,
+			"issuer":    cert.Issuer.String(),
+		}).Warn("Uploaded certificate is from an issuer that is not allowed")
+		return nil, &uploadFailure{status: http.StatusUnprocessableEntity, body: gin.H{
+			"error":   "Unprocessable Entity",
+			"code":    codeIssuerNotAllowed,
+			"message": "Certificate issuer is not allowed",
+			"issuer":  cert.Issuer.String(),
+		}}
+	}
+
+	// Apply the SAN policy; browsers ignore the common name and match only SANs
+	var sanErr error
+	if h.requireDNSSAN || h.requireCNInSANs {
+		sanErr = h.cryptoService.CheckSubjectAlternativeNames(cert, h.requireCNInSANs)
+	}
+	if sanErr != nil && h.enforceSANPolicy {
+		h.logger.WithError(sanErr).WithField("entity_id", entityID).Warn("Uploaded certificate violates the SAN policy")
+		return nil, &uploadFailure{status: http.StatusUnprocessableEntity, body: gin.H{
+			"error":   "Unprocessable Entity",
+			"code":    sanViolationCode(sanErr),
+			"message": sanErr.Error(),
+		}}
+	}
+
+	// Record the certificate and the details parsed from it
+	previousStatus := entity.Status
+	if err := setCertificateDetails(h.cryptoService, entity, certificatePEM); err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to process certificate")
+		return nil, &uploadFailure{status: http.StatusInternalServerError, body: gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to process certificate",
+		}}
+	}
+
+	// Apply the validity policy before anything is stored
+	violations := validityViolations(*entity.ValidFrom, *entity.ValidTo, h.maxValidity, h.maxClockSkew, time.Now())
+	if len(violations) > 0 && h.enforceValidity {
+		h.logger.WithField("entity_id", entityID).Warn("Uploaded certificate violates the validity policy")
+		return nil, &uploadFailure{status: http.StatusUnprocessableEntity, body: gin.H{
+			"error":      "Unprocessable Entity",
+			"code":       violations[0].Code,
+			"message":    "Certificate violates the validity policy",
+			"violations": violations,
+		}}
+	}
+	entity.Status = uploadedStatus(violations)
+	change := statusChange(c, previousStatus, entity.Status)
+
+	// Update in DynamoDB
+	err = h.storage.UpdateCertificateEntity(c.Request.Context(), entity, change)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to update certificate entity")
+		return nil, &uploadFailure{status: http.StatusInternalServerError, body: gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to update certificate data",
+		}}
 	}
 
 	// Prepare response
-	response := models.ListKeysResponse{
-		Keys:       entities,
-		TotalCount: totalCount,
-		Page:       filters.Page,
-		PageSize:   filters.PageSize,
-		SortBy:     filters.SortBy,
-		SortOrder:  filters.SortOrder,
+	response := &models.UploadCertificateResponse{
+		ID:                entityID,
+		Status:            entity.Status,
+		ValidFrom:         entity.ValidFrom,
+		ValidTo:           entity.ValidTo,
+		SerialNumber:      entity.SerialNumber,
+		SerialNumberHex:   entity.SerialNumberHex,
+		Fingerprint:       entity.Fingerprint,
+		FingerprintSHA1:   entity.FingerprintSHA1,
+		FingerprintSHA256: entity.FingerprintSHA256,
+		KeyUsages:         entity.KeyUsages,
+		ExtKeyUsages:      entity.ExtKeyUsages,
+		UpdatedAt:         entity.UpdatedAt,
+	}
+	for _, violation := range violations {
+		response.Warnings = append(response.Warnings, violation.Message)
+	}
+	if sanErr != nil {
+		response.Warnings = append(response.Warnings, sanErr.Error())
+		h.logger.WithError(sanErr).WithField("entity_id", entityID).Warn("Uploaded certificate violates the SAN policy")
+	}
+	if missing := missingExtKeyUsages(h.requiredExtKeyUsages, entity.ExtKeyUsages); len(missing) > 0 {
+		response.Warnings = append(response.Warnings, fmt.Sprintf("certificate is missing required extended key usages: %s", strings.Join(missing, ", ")))
+		h.logger.WithFields(logrus.Fields{
+			"entity_id": entityID,
+			"missing":   missing,
+		}).Warn("Uploaded certificate lacks required extended key usages")
 	}
 
 	h.logger.WithFields(logrus.Fields{
-		"count":     len(entities),
-		"page":      filters.Page,
-		"page_size": filters.PageSize,
-	}).Debug("Certificate entities listed")
+		"entity_id":        entityID,
+		"serial_number":    entity.SerialNumber,
+		"fingerprint":      entity.Fingerprint,
+		"fingerprint_sha1": entity.FingerprintSHA1,
+	}).Info("Certificate uploaded successfully")
+	recordEvent(c.Request.Context(), h.auditSink, h.logger, newEvent(c, entityID, models.EventCertUploaded))
+	if entity.Status == models.StatusCertUploaded {
+		notifyIssued(h.notifier, h.logger, entity)
+	}
 
-	c.JSON(http.StatusOK, response)
+	return response, nil
 }
 
-// ExportPrivateKey exports the private key for a certificate entity
-// @Summary Export private key (SENSITIVE OPERATION)
-// @Description Exports the decrypted private key in PEM format. WARNING: This operation exposes sensitive cryptographic material and should be used with extreme caution. Ensure proper access controls and audit logging.
+// GeneratePFX generates a PKCS#12 file for a completed certificate
+// @Summary Generate PFX/P12 file
+// @Description Creates a password-protected PKCS#12 file containing the private key and certificate
 // @Tags Certificate Management
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
 // @Security BearerAuth
 // @Param id path string true "Certificate entity ID (UUID format)"
-// @Success 200 {object} models.ExportPrivateKeyResponse "Private key exported successfully"
-// @Failure 400 {object} map[string]interface{} "Bad request - invalid ID format"
+// @Param request body models.GeneratePFXRequest true "PFX generation request with password"
+// @Success 200 {object} models.GeneratePFXResponse "PFX file generated successfully (base64 encoded)"
+// @Failure 400 {object} map[string]interface{} "Bad request - certificate not ready, invalid password, iterations outside 2048-600000 or the private key is held externally (code external_private_key)"
 // @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
 // @Failure 404 {object} map[string]interface{} "Certificate entity not found"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
-// @Router /keys/{id}/private-key [get]
-func (h *CertificateHandler) ExportPrivateKey(c *gin.Context) {
+// @Failure 503 {object} map[string]interface{} "Too many PFX generations in progress; retry after the Retry-After seconds"
+// @Header 503 {string} Retry-After "Seconds to wait before retrying"
+// @Router /keys/{id}/pfx [post]
+func (h *CertificateHandler) GeneratePFX(c *gin.Context) {
 	entityID := c.Param("id")
 	if entityID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -590,8 +1029,35 @@ func (h *CertificateHandler) ExportPrivateKey(c *gin.Context) {
 		return
 	}
 
+	var req models.GeneratePFXRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Password is required for PFX generation",
+		})
+		return
+	}
+
+	// The slot is held from the key decryption through the PKCS#12 encoding
+	release, ok := h.acquirePFXSlot(c)
+	if !ok {
+		h.logger.WithField("entity_id", entityID).Warn("PFX generation rejected, all slots in use")
+		return
+	}
+	defer release()
+
 	// Retrieve entity
-	entity, err := h.storage.GetCertificateEntity(c.Request.Context(), entityID)
+	entity, err := h.storage.GetCertificateEntity(c.Request.Context(), entityID, true)
 	if err != nil {
 		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
 		c.JSON(http.StatusNotFound, gin.H{
@@ -601,40 +1067,2221 @@ func (h *CertificateHandler) ExportPrivateKey(c *gin.Context) {
 		return
 	}
 
-	// Validate that private key exists
-	if entity.EncryptedPrivateKey == "" {
+	// Validate that both private key and certificate are available
+	if !requirePrivateKey(c, entity) {
+		return
+	}
+	if entity.EncryptedPrivateKey == "" || entity.Certificate == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Bad Request",
-			"message": "No private key available for this certificate entity",
+			"message": "Both private key and certificate must be available to generate PFX",
 		})
 		return
 	}
 
-	// Log the private key export for audit purposes
-	h.logger.WithFields(logrus.Fields{
-		"entity_id":   entityID,
-		"common_name": entity.CommonName,
-		"key_type":    entity.KeyType,
-		"operation":   "export_private_key",
-		"user_agent":  c.GetHeader("User-Agent"),
-		"remote_addr": c.ClientIP(),
-		"request_id":  c.GetString("request_id"),
-	}).Warn("SENSITIVE: Private key exported")
+	// Generate PFX
+	// The raw password is only ever handed to the PKCS#12 encoder
+	pfxData, err := h.cryptoService.GeneratePFX(entity.EncryptedPrivateKey, entity.Certificate, req.Password.Reveal(), req.Iterations)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to generate PFX")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to generate PFX file",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	metrics.PFXGenerated()
+
+	// Encode PFX data as base64
+	pfxBase64 := h.cryptoService.EncodeToBase64(pfxData)
+
+	// Generate filename
+	filename := fmt.Sprintf("%s-%s.pfx", entity.CommonName, shortID(entityID))
 
 	// Prepare response
-	response := models.ExportPrivateKeyResponse{
-		ID:         entityID,
-		PrivateKey: entity.EncryptedPrivateKey, // Note: This is actually the decrypted private key in PEM format
-		KeyType:    entity.KeyType,
-		CommonName: entity.CommonName,
-		ExportedAt: time.Now().Format(time.RFC3339),
+	response := models.GeneratePFXResponse{
+		ID:       entityID,
+		PFXData:  pfxBase64,
+		Filename: filename,
 	}
 
 	h.logger.WithFields(logrus.Fields{
 		"entity_id":   entityID,
 		"common_name": entity.CommonName,
-		"key_type":    entity.KeyType,
-	}).Info("Private key export completed")
+		"filename":    filename,
+	}).Info("PFX file generated successfully")
+	recordEvent(c.Request.Context(), h.auditSink, h.logger, newEvent(c, entityID, models.EventPFXExported))
 
 	c.JSON(http.StatusOK, response)
 }
+
+// GenerateKeystore generates a Java-compatible keystore for a completed certificate
+// @Summary Generate Java keystore
+// @Description Creates a PKCS#12 keystore, which Java 9 and later load natively as keystore type PKCS12, holding the private key and certificate under the requested alias followed by the stored issuing chain. store_password protects the keystore and key_password, which defaults to store_password, the key entry. Returns JSON with the base64 keystore, or the binary keystore when the Accept header asks for application/x-pkcs12 or application/octet-stream.
+// @Tags Certificate Management
+// @Accept json
+// @Produce json
+// @Produce application/x-pkcs12
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Param request body models.GenerateKeystoreRequest true "Keystore generation request with alias and passwords"
+// @Success 200 {object} models.GenerateKeystoreResponse "Keystore generated successfully (base64 encoded, or binary per the Accept header)"
+// @Failure 400 {object} map[string]interface{} "Bad request - certificate not ready, missing alias or password, iterations outside 2048-600000 or the private key is held externally (code external_private_key)"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Failure 503 {object} map[string]interface{} "Too many PFX generations in progress; retry after the Retry-After seconds"
+// @Header 503 {string} Retry-After "Seconds to wait before retrying"
+// @Router /keys/{id}/keystore [post]
+func (h *CertificateHandler) GenerateKeystore(c *gin.Context) {
+	entityID := c.Param("id")
+
+	var req models.GenerateKeystoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+	keyPassword := req.KeyPassword
+	if keyPassword == "" {
+		keyPassword = req.StorePassword
+	}
+
+	// Keystores are PKCS#12 files, so they share the PFX generation slots
+	release, ok := h.acquirePFXSlot(c)
+	if !ok {
+		h.logger.WithField("entity_id", entityID).Warn("Keystore generation rejected, all slots in use")
+		return
+	}
+	defer release()
+
+	entity, err := h.storage.GetCertificateEntity(c.Request.Context(), entityID, true)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not Found",
+			"message": "Certificate entity not found",
+		})
+		return
+	}
+
+	if !requirePrivateKey(c, entity) {
+		return
+	}
+	if entity.EncryptedPrivateKey == "" || entity.Certificate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Both private key and certificate must be available to generate a keystore",
+		})
+		return
+	}
+
+	bundle, err := certificateBundle(entity)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to read stored certificate")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to read certificate chain",
+		})
+		return
+	}
+
+	// The raw passwords are only ever handed to the keystore encoder
+	keystore, err := h.cryptoService.GenerateKeystore(entity.EncryptedPrivateKey, bundle, req.Alias, req.StorePassword.Reveal(), keyPassword.Reveal(), req.Iterations)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to generate keystore")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to generate keystore",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	metrics.PFXGenerated()
+	filename := fmt.Sprintf("%s-%s.p12", entity.CommonName, shortID(entityID))
+
+	h.logger.WithFields(logrus.Fields{
+		"entity_id":   entityID,
+		"common_name": entity.CommonName,
+		"alias":       req.Alias,
+		"filename":    filename,
+	}).Info("Keystore generated successfully")
+	recordEvent(c.Request.Context(), h.auditSink, h.logger, newEvent(c, entityID, models.EventPFXExported))
+
+	if acceptsBinaryKeystore(c) {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, keystoreMediaType, keystore)
+		return
+	}
+	c.JSON(http.StatusOK, models.GenerateKeystoreResponse{
+		ID:           entityID,
+		Alias:        req.Alias,
+		KeystoreData: h.cryptoService.EncodeToBase64(keystore),
+		Filename:     filename,
+	})
+}
+
+// GetCertificate retrieves a certificate entity by ID
+// @Summary Get certificate by ID
+// @Description Retrieves a specific certificate entity including its CSR and certificate details. encrypted_private_key is always [REDACTED]. When include_private_key=true is sent with an API key carrying the export scope (EXPORT_API_KEYS), the decrypted PEM key is returned in private_key, the response is marked Cache-Control: no-store and the request is audited like a private key export.
+// @Tags Certificate Management
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate ID (UUID format)"
+// @Param fields query string false "Comma-separated entity fields to return, e.g. id,common_name,status,valid_to (default: all)"
+// @Param canonical query bool false "Return canonical JSON with every object key sorted, no whitespace and no HTML escaping, for clients that sign the body; Accept: application/json+canonical does the same" default(false)
+// @Param include_private_key query bool false "Return the decrypted PEM private key in private_key, also with a fields projection; only honoured for API keys with the export scope" default(false)
+// @Success 200 {object} models.CertificateEntityWithPrivateKey "Certificate entity details; private_key is only present when include_private_key is honoured"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid ID format, unknown field or invalid canonical or include_private_key value"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id} [get]
+func (h *CertificateHandler) GetCertificate(c *gin.Context) {
+	entityID := c.Param("id")
+	if entityID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Entity ID is required",
+		})
+		return
+	}
+
+	fields, ok := parseFieldsQuery(c)
+	if !ok {
+		return
+	}
+
+	canonical, ok := parseCanonicalQuery(c)
+	if !ok {
+		return
+	}
+
+	includePrivateKey, ok := parseIncludePrivateKeyQuery(c)
+	if !ok {
+		return
+	}
+	if includePrivateKey && !middleware.HasScope(c, middleware.ScopeExport) {
+		h.logger.WithFields(logrus.Fields{
+			"entity_id":    entityID,
+			"remote_addr":  c.ClientIP(),
+			"api_key_name": c.GetString("api_key_name"),
+		}).Warn("Private key requested without the export scope, returning it redacted")
+		includePrivateKey = false
+	}
+	// Retrieve entity; unless the key is returned it is redacted, so KMS is not needed
+	entity, err := h.storage.GetCertificateEntity(c.Request.Context(), entityID, includePrivateKey)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not Found",
+			"message": "Certificate entity not found",
+		})
+		return
+	}
+
+	// Entities created before pins were stored get one from their CSR
+	if entity.SPKIPin == "" && entity.CSR != "" {
+		if pin, err := h.cryptoService.PublicKeyPin(entity.CSR); err == nil {
+			entity.SPKIPin = pin
+		} else {
+			h.logger.WithError(err).WithField("entity_id", entityID).Warn("Failed to compute SPKI pin")
+		}
+	}
+
+	// Remove sensitive data from response; this applies whichever fields were requested. A key
+	// asked for by an export-scoped API key is returned separately in private_key and audited like
+	// ExportPrivateKey, so encrypted_private_key never carries plaintext
+	var privateKey string
+	if includePrivateKey && !entity.ExternalKey && entity.EncryptedPrivateKey != "" {
+		privateKey = entity.EncryptedPrivateKey
+		h.auditPrivateKeyExport(c, entity)
+		c.Header("Cache-Control", "no-store")
+	}
+	entity.EncryptedPrivateKey = "[REDACTED]"
+
+	h.logger.WithField("entity_id", entityID).Debug("Certificate entity retrieved")
+
+	var response interface{} = entity
+	if privateKey != "" {
+		response = models.CertificateEntityWithPrivateKey{CertificateEntity: entity, PrivateKey: privateKey}
+	}
+	if fields != nil {
+		projected, err := models.ProjectEntity(entity, fields)
+		if err != nil {
+			h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to project certificate entity")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Internal Server Error",
+				"message": "Failed to render certificate entity",
+			})
+			return
+		}
+		if privateKey != "" {
+			// Requesting the key is explicit, so it is returned whichever fields were projected
+			projected["private_key"], _ = json.Marshal(privateKey)
+		}
+		response = projected
+	}
+
+	if !canonical {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	body, err := models.CanonicalJSON(response)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to encode canonical certificate entity")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to render certificate entity",
+		})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// UpdateMetadata changes the notes and tags of a certificate entity
+// @Summary Update certificate metadata
+// @Description Sets free-text notes and replaces the tags of a certificate entity. Omitted fields are left unchanged and an empty value clears them. Notes are limited to 1000 characters; control characters other than newlines and tabs are removed. Changing the tags so that protected=true is dropped from a protected entity requires force=true. Tags bound into the private key's KMS encryption context (KMS_CONTEXT_TAGS) cannot be changed or removed, even with force=true.
+// @Tags Certificate Management
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Param force query bool false "Allow dropping protected=true from the tags" default(false)
+// @Param request body models.UpdateMetadataRequest true "Notes and tags to set"
+// @Success 200 {object} models.CertificateEntity "Updated certificate entity"
+// @Failure 400 {object} map[string]interface{} "Bad request - nothing to update, notes too long or invalid force value; field violations are listed in errors as {field, rule, message}"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 409 {object} map[string]interface{} "Conflict - certificate entity is protected or a tag bound to the private key would change"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id} [patch]
+func (h *CertificateHandler) UpdateMetadata(c *gin.Context) {
+	entityID := c.Param("id")
+
+	force, ok := parseForceQuery(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		// The validation middleware renders the structured error response
+		c.Status(http.StatusBadRequest)
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	if req.Notes == nil && req.Tags == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Nothing to update",
+			"details": "Provide notes, tags or both",
+		})
+		return
+	}
+	if req.Notes != nil {
+		notes := models.SanitizeNotes(*req.Notes)
+		req.Notes = &notes
+	}
+
+	entity, err := h.storage.UpdateMetadata(c.Request.Context(), entityID, req.Notes, req.Tags, force)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrEntityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not Found",
+				"message": "Certificate entity not found",
+			})
+		case errors.Is(err, storage.ErrEntityProtected):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"message": "Certificate entity is protected",
+				"details": "Keep the protected tag or pass force=true to remove it",
+			})
+		case errors.Is(err, storage.ErrContextTagBound):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"message": "Tag is bound to the private key",
+				"details": err.Error() + "; the key only decrypts while the tag keeps its value",
+			})
+		default:
+			h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to update certificate metadata")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Internal Server Error",
+				"message": "Failed to update certificate metadata",
+			})
+		}
+		return
+	}
+
+	// Remove sensitive data from response
+	entity.EncryptedPrivateKey = "[REDACTED]"
+
+	h.logger.WithFields(logrus.Fields{
+		"entity_id":  entityID,
+		"force":      force,
+		"request_id": c.GetString("request_id"),
+	}).Info("Certificate metadata updated")
+
+	c.JSON(http.StatusOK, entity)
+}
+
+// TransferOwnership hands a certificate entity over to a new owner
+// @Summary Transfer certificate ownership
+// @Description Merges new owner tags into the tags of a certificate entity and optionally sets its notes in a single atomic write. Tags not named in owner_tags are kept. The reason, the new owner tags and their previous values are recorded as a TRANSFERRED event. Protected entities cannot be transferred, and owner tags cannot change a tag bound into the private key's KMS encryption context (KMS_CONTEXT_TAGS).
+// @Tags Certificate Management
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Param request body models.TransferRequest true "Owner tags, reason and optional notes"
+// @Success 200 {object} models.CertificateEntity "Transferred certificate entity"
+// @Failure 400 {object} map[string]interface{} "Bad request - missing owner tags or reason, or notes too long; field violations are listed in errors as {field, rule, message}"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 409 {object} map[string]interface{} "Conflict - certificate entity is protected, kept changing during the transfer or a tag bound to the private key would change"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/transfer [post]
+func (h *CertificateHandler) TransferOwnership(c *gin.Context) {
+	entityID := c.Param("id")
+
+	var req models.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		// The validation middleware renders the structured error response
+		c.Status(http.StatusBadRequest)
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	req.Reason = models.SanitizeNotes(req.Reason)
+	if req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Reason is required",
+			"details": "Describe why the certificate entity changes owner",
+		})
+		return
+	}
+	if req.Notes != nil {
+		notes := models.SanitizeNotes(*req.Notes)
+		req.Notes = &notes
+	}
+
+	entity, previousTags, err := h.storage.TransferOwnership(c.Request.Context(), entityID, req.OwnerTags, req.Notes)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrEntityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not Found",
+				"message": "Certificate entity not found",
+			})
+		case errors.Is(err, storage.ErrEntityProtected):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"message": "Certificate entity is protected",
+				"details": "Remove the protected tag before transferring the certificate entity",
+			})
+		case errors.Is(err, storage.ErrEntityModified):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"message": "Certificate entity was modified concurrently",
+				"details": "Retry the transfer",
+			})
+		case errors.Is(err, storage.ErrContextTagBound):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"message": "Tag is bound to the private key",
+				"details": err.Error() + "; the key only decrypts while the tag keeps its value",
+			})
+		default:
+			h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to transfer certificate entity")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Internal Server Error",
+				"message": "Failed to transfer certificate entity",
+			})
+		}
+		return
+	}
+
+	// Remove sensitive data from response
+	entity.EncryptedPrivateKey = "[REDACTED]"
+
+	event := transferEvent(c, entityID, req, previousTags)
+	h.logger.WithFields(logrus.Fields{
+		"entity_id":     entityID,
+		"owner_tags":    event.Tags,
+		"previous_tags": event.PreviousTags,
+		"reason":        event.Reason,
+		"actor":         event.Actor,
+		"request_id":    event.RequestID,
+	}).Info("Certificate ownership transferred")
+	recordEvent(c.Request.Context(), h.auditSink, h.logger, event)
+
+	c.JSON(http.StatusOK, entity)
+}
+
+// transferEvent describes a completed ownership transfer for the event log
+func transferEvent(c *gin.Context, entityID string, req models.TransferRequest, previousTags map[string]string) *models.Event {
+	event := newEvent(c, entityID, models.EventTransferred)
+	event.Reason = req.Reason
+	event.Tags = req.OwnerTags
+	if len(previousTags) > 0 {
+		event.PreviousTags = previousTags
+	}
+	return event
+}
+
+// defaultClaimTTL is how long a claim lasts when the request does not say
+const defaultClaimTTL = 5 * time.Minute
+
+// ClaimCertificate claims a certificate entity so that one of several renewers renews it
+// @Summary Claim a certificate for renewal
+// @Description Claims a certificate entity for the holder until ttl_seconds from now (default 300), so that concurrent renewers agree on which of them renews it. The claim succeeds when the entity is unclaimed, its claim has expired or the holder already holds it, in which case it is extended; otherwise 409 is returned with the current holder. The holder defaults to the caller's API key name or client certificate identity.
+// @Tags Certificate Management
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Param request body models.ClaimRequest false "Holder and claim duration"
+// @Success 200 {object} models.CertificateEntity "Claimed certificate entity"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid holder or ttl_seconds; field violations are listed in errors as {field, rule, message}"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 409 {object} map[string]interface{} "Conflict - certificate entity is claimed by another holder"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/claim [post]
+func (h *CertificateHandler) ClaimCertificate(c *gin.Context) {
+	entityID := c.Param("id")
+
+	var req models.ClaimRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.logger.WithError(err).Error("Failed to bind JSON request")
+			// The validation middleware renders the structured error response
+			c.Status(http.StatusBadRequest)
+			c.Error(err).SetType(gin.ErrorTypeBind)
+			return
+		}
+	}
+
+	holder, ok := claimHolder(c, req.Holder)
+	if !ok {
+		return
+	}
+	ttl := defaultClaimTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+
+	entity, err := h.storage.ClaimEntity(c.Request.Context(), entityID, holder, ttl)
+	if err != nil {
+		h.renderClaimError(c, entityID, err, "Failed to claim certificate entity")
+		return
+	}
+
+	// Remove sensitive data from response
+	entity.EncryptedPrivateKey = "[REDACTED]"
+
+	h.logger.WithFields(logrus.Fields{
+		"entity_id":  entityID,
+		"claimed_by": holder,
+		"ttl":        ttl.String(),
+		"request_id": c.GetString("request_id"),
+	}).Info("Certificate claimed")
+
+	c.JSON(http.StatusOK, entity)
+}
+
+// TouchCertificate records that a certificate is still in use
+// @Summary Mark a certificate as still in use
+// @Description Sets the entity's updated_at and last_seen_at to the current time without changing any other data, so automation can report certificates it still deploys and stale ones can be found by an old last_seen_at.
+// @Tags Certificate Management
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Success 200 {object} models.TouchResponse "Timestamps set on the entity"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/touch [post]
+func (h *CertificateHandler) TouchCertificate(c *gin.Context) {
+	entityID := c.Param("id")
+
+	touchedAt, err := h.storage.TouchCertificateEntity(c.Request.Context(), entityID)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to touch certificate entity")
+		if errors.Is(err, storage.ErrEntityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not Found",
+				"message": "Certificate entity not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to touch certificate entity",
+		})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"entity_id":  entityID,
+		"request_id": c.GetString("request_id"),
+	}).Debug("Certificate entity touched")
+
+	c.JSON(http.StatusOK, models.TouchResponse{
+		ID:         entityID,
+		UpdatedAt:  touchedAt,
+		LastSeenAt: touchedAt,
+	})
+}
+
+// defaultStaleDays and maxStaleDays bound the period of the stale certificate report
+const (
+	defaultStaleDays = 90
+	maxStaleDays     = 3650
+)
+
+// GetStaleCertificates reports certificates that have not been touched or updated for a number of days
+// @Summary List stale certificates
+// @Description Lists the certificate entities whose updated_at and last_seen_at both lie more than days days in the past, oldest first, with their age in whole days. Touching an entity with POST /keys/{id}/touch, or any update, takes it off the report. Use it to find abandoned certificates for cleanup. The whole table is scanned and no key material is read.
+// @Tags Certificate Management
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param days query int false "Days without a touch or update after which a certificate is stale (default: 90, max: 3650)" minimum(1) maximum(3650)
+// @Success 200 {object} models.StaleCertificatesResponse "Stale certificates, oldest first; entities that could not be read are listed in errors"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid days"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/stale [get]
+func (h *CertificateHandler) GetStaleCertificates(c *gin.Context) {
+	days := defaultStaleDays
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxStaleDays {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad Request",
+				"message": "Invalid days",
+				"details": fmt.Sprintf("days must be between 1 and %d", maxStaleDays),
+			})
+			return
+		}
+		days = parsed
+	}
+
+	now := time.Now().UTC()
+	cutoff := now.AddDate(0, 0, -days).Truncate(time.Second)
+	entities, entityErrors, err := h.storage.ListStaleEntities(c.Request.Context(), cutoff)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list stale certificate entities")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to list stale certificates",
+		})
+		return
+	}
+
+	certificates := staleCertificates(entities, now)
+	h.logger.WithFields(logrus.Fields{
+		"days":   days,
+		"stale":  len(certificates),
+		"errors": len(entityErrors),
+	}).Info("Stale certificate report generated")
+
+	c.JSON(http.StatusOK, models.StaleCertificatesResponse{
+		Days:         days,
+		Cutoff:       cutoff,
+		Total:        len(certificates),
+		Certificates: certificates,
+		Errors:       entityErrors,
+	})
+}
+
+// staleCertificates summarizes entities for the stale certificate report, oldest first. An
+// entity's age runs from the later of its updated_at and last_seen_at.
+func staleCertificates(entities []models.CertificateEntity, now time.Time) []models.StaleCertificate {
+	certificates := make([]models.StaleCertificate, 0, len(entities))
+	lastActivity := make(map[string]time.Time, len(entities))
+	for _, entity := range entities {
+		active := entity.UpdatedAt
+		if entity.LastSeenAt != nil && entity.LastSeenAt.After(active) {
+			active = *entity.LastSeenAt
+		}
+		lastActivity[entity.ID] = active
+		certificates = append(certificates, models.StaleCertificate{
+			ID:         entity.ID,
+			CommonName: entity.CommonName,
+			Status:     entity.Status,
+			Tags:       entity.Tags,
+			UpdatedAt:  entity.UpdatedAt,
+			LastSeenAt: entity.LastSeenAt,
+			ValidTo:    entity.ValidTo,
+			AgeDays:    int(now.Sub(active) / (24 * time.Hour)),
+		})
+	}
+
+	sort.SliceStable(certificates, func(i, j int) bool {
+		a, b := lastActivity[certificates[i].ID], lastActivity[certificates[j].ID]
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return certificates[i].ID < certificates[j].ID
+	})
+	return certificates
+}
+
+// ReleaseClaim gives up a claim on a certificate entity
+// @Summary Release a certificate claim
+// @Description Clears the holder's claim on a certificate entity. Releasing an unclaimed entity or an expired claim succeeds; a live claim of another holder is left in place and 409 is returned. The holder defaults to the caller's API key name or client certificate identity.
+// @Tags Certificate Management
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Param holder query string false "Holder releasing the claim (default: the caller's identity)"
+// @Success 200 {object} models.CertificateEntity "Released certificate entity"
+// @Failure 400 {object} map[string]interface{} "Bad request - no holder could be determined or holder too long"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 409 {object} map[string]interface{} "Conflict - certificate entity is claimed by another holder"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/claim [delete]
+func (h *CertificateHandler) ReleaseClaim(c *gin.Context) {
+	entityID := c.Param("id")
+
+	holder, ok := claimHolder(c, c.Query("holder"))
+	if !ok {
+		return
+	}
+
+	entity, err := h.storage.ReleaseClaim(c.Request.Context(), entityID, holder)
+	if err != nil {
+		h.renderClaimError(c, entityID, err, "Failed to release certificate claim")
+		return
+	}
+
+	// Remove sensitive data from response
+	entity.EncryptedPrivateKey = "[REDACTED]"
+
+	h.logger.WithFields(logrus.Fields{
+		"entity_id":   entityID,
+		"released_by": holder,
+		"request_id":  c.GetString("request_id"),
+	}).Info("Certificate claim released")
+
+	c.JSON(http.StatusOK, entity)
+}
+
+// maxClaimHolderLength bounds the holder name stored with a claim
+const maxClaimHolderLength = 200
+
+// claimHolder returns the given holder, or the caller's identity when it is empty.
+// It renders a 400 response and returns false when neither names a holder or the name is too long.
+func claimHolder(c *gin.Context, holder string) (string, bool) {
+	holder = strings.TrimSpace(holder)
+	if holder == "" {
+		holder = requestActor(c)
+	}
+	if holder == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Holder is required",
+			"details": "Name the holder, as no caller identity is available",
+		})
+		return "", false
+	}
+	if len(holder) > maxClaimHolderLength {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid holder",
+			"details": fmt.Sprintf("holder must be at most %d characters", maxClaimHolderLength),
+		})
+		return "", false
+	}
+	return holder, true
+}
+
+// renderClaimError renders the response for a failed claim or release
+func (h *CertificateHandler) renderClaimError(c *gin.Context, entityID string, err error, message string) {
+	var claimErr *storage.ClaimError
+	switch {
+	case errors.Is(err, storage.ErrEntityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not Found",
+			"message": "Certificate entity not found",
+		})
+	case errors.As(err, &claimErr):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":            "Conflict",
+			"message":          "Certificate entity is claimed",
+			"details":          err.Error(),
+			"claimed_by":       claimErr.ClaimedBy,
+			"claim_expires_at": claimErr.ExpiresAt,
+		})
+	default:
+		h.logger.WithError(err).WithField("entity_id", entityID).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": message,
+		})
+	}
+}
+
+// ListCertificates retrieves a list of certificates with optional filtering
+// @Summary List certificates with filtering and sorting
+// @Description Retrieves a paginated list of certificate entities with optional filtering by tags, status, key type, date range, and sorting support
+// @Tags Certificate Management
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param status query string false "Filter by certificate status" Enums(CSR_CREATED, CERT_UPLOADED, EXPIRED, REVOKED)
+// @Param key_type query string false "Filter by key type" Enums(RSA2048, RSA4096, ECDSA-P256, ECDSA-P384)
+// @Param date_from query string false "Filter certificates created after this date (RFC3339 format)"
+// @Param date_to query string false "Filter certificates created before this date (RFC3339 format)"
+// @Param has_certificate query bool false "Only entities with (true) or without (false) a certificate; combine false with date_to for CSRs awaiting a certificate"
+// @Param page query int false "Page number for pagination (default: 1)" minimum(1)
+// @Param page_size query int false "Number of items per page (default: 50, max: 100)" minimum(1) maximum(100)
+// @Param cursor query string false "next_cursor of the previous page; continues after its last key, unaffected by keys created or deleted in between, and replaces page"
+// @Param sort_by query string false "Sort by field (default: created_at)" Enums(created_at, updated_at, common_name, status, valid_to, valid_from, key_type)
+// @Param sort_order query string false "Sort order (default: desc)" Enums(asc, desc)
+// @Param tags[environment] query string false "Filter by environment tag; any tag key can be filtered with tags[key]=value"
+// @Param tags[project] query string false "Filter by project tag"
+// @Param tags[team] query string false "Filter by team tag"
+// @Param fields query string false "Comma-separated entity fields to return for each key, e.g. id,common_name,status,valid_to (default: all but csr and certificate, see summary)"
+// @Param summary query bool false "Leave csr and certificate out of each key (default: true); ignored when fields is given"
+// @Success 200 {object} models.ListKeysResponse "List of certificate entities; entities that could not be decrypted are listed in errors"
+// @Failure 400 {object} map[string]interface{} "Bad request - unknown field, query parameter, summary or has_certificate value, or a cursor that is malformed or was returned for another sort"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys [get]
+func (h *CertificateHandler) ListCertificates(c *gin.Context) {
+	fields, ok := parseFieldsQuery(c)
+	if !ok {
+		return
+	}
+	summary, ok := parseSummaryQuery(c)
+	if !ok {
+		return
+	}
+	tags, ok := parseTagsQuery(c)
+	if !ok {
+		return
+	}
+	hasCertificate, ok := parseHasCertificateQuery(c)
+	if !ok {
+		return
+	}
+
+	// Summary mode leaves out the PEM bodies, which are only needed when fetching a single key
+	if fields == nil && summary {
+		fields = models.SummaryEntityFields()
+	}
+
+	// Parse query parameters
+	filters := models.SearchFilters{HasCertificate: hasCertificate}
+
+	// Status filter
+	if status := c.Query("status"); status != "" {
+		filters.Status = models.CertificateStatus(status)
+	}
+
+	// Key type filter
+	if keyType := c.Query("key_type"); keyType != "" {
+		filters.KeyType = models.KeyType(keyType)
+	}
+
+	// Date filters
+	if dateFrom := c.Query("date_from"); dateFrom != "" {
+		if parsedDate, err := time.Parse(time.RFC3339, dateFrom); err == nil {
+			filters.DateFrom = &parsedDate
+		}
+	}
+
+	if dateTo := c.Query("date_to"); dateTo != "" {
+		if parsedDate, err := time.Parse(time.RFC3339, dateTo); err == nil {
+			filters.DateTo = &parsedDate
+		}
+	}
+
+	// Pagination
+	if page := c.Query("page"); page != "" {
+		if p, err := strconv.Atoi(page); err == nil && p > 0 {
+			filters.Page = p
+		}
+	}
+
+	if pageSize := c.Query("page_size"); pageSize != "" {
+		if ps, err := strconv.Atoi(pageSize); err == nil && ps > 0 && ps <= 100 {
+			filters.PageSize = ps
+		}
+	}
+	filters.Cursor = c.Query("cursor")
+
+	// Sorting parameters
+	if sortBy := c.Query("sort_by"); sortBy != "" {
+		// Validate sort field
+		validSortFields := []string{"created_at", "updated_at", "common_name", "status", "valid_to", "valid_from", "key_type"}
+		isValid := false
+		for _, validField := range validSortFields {
+			if sortBy == validField {
+				isValid = true
+				break
+			}
+		}
+		if isValid {
+			filters.SortBy = sortBy
+		}
+	}
+
+	if sortOrder := c.Query("sort_order"); sortOrder != "" {
+		// Validate sort order
+		if sortOrder == "asc" || sortOrder == "desc" {
+			filters.SortOrder = sortOrder
+		}
+	}
+
+	// Set defaults for sorting
+	if filters.SortBy == "" {
+		filters.SortBy = "created_at"
+	}
+	if filters.SortOrder == "" {
+		filters.SortOrder = "desc"
+	}
+
+	// Tag filters - expecting format: tags[tag_key]=tag_value
+	filters.Tags = tags
+
+	// Only read the requested attributes; the private key is redacted anyway, so it is never read
+	if fields != nil {
+		filters.Projection = []string{"id"}
+		for _, field := range fields {
+			if field != "encrypted_private_key" {
+				filters.Projection = append(filters.Projection, field)
+			}
+		}
+	}
+
+	// Retrieve entities
+	entities, entityErrors, err := h.storage.ListCertificateEntities(c.Request.Context(), filters)
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid cursor",
+			"details": err.Error() + "; a cursor is only valid with the sort_by and sort_order it was returned with",
+		})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list certificate entities")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to retrieve certificate list",
+		})
+		return
+	}
+
+	// Get total count before pagination (we need to call storage method that returns total count)
+	totalCount, err := h.storage.GetCertificateEntityCount(c.Request.Context(), filters)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get certificate entity count")
+		// Continue with current count as fallback
+		totalCount = len(entities)
+	}
+
+	// Remove sensitive data from response
+	for i := range entities {
+		entities[i].EncryptedPrivateKey = "[REDACTED]"
+	}
+
+	// Prepare response
+	response := models.ListKeysResponse{
+		Keys:       entities,
+		TotalCount: totalCount,
+		Page:       filters.Page,
+		PageSize:   filters.PageSize,
+		SortBy:     filters.SortBy,
+		SortOrder:  filters.SortOrder,
+		Errors:     entityErrors,
+	}
+
+	// A full page may have more after it; the cursor picks up after its last key
+	pageSize := filters.PageSize
+	if pageSize == 0 {
+		pageSize = storage.DefaultListPageSize
+	}
+	if len(entities) == pageSize {
+		response.NextCursor = storage.EncodeCursor(entities[len(entities)-1], filters.SortBy, filters.SortOrder)
+	}
+
+	if len(entityErrors) > 0 {
+		h.logger.WithField("failed_count", len(entityErrors)).Warn("Some certificate entities could not be listed")
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"count":     len(entities),
+		"page":      filters.Page,
+		"page_size": filters.PageSize,
+	}).Debug("Certificate entities listed")
+
+	if fields == nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	projected := projectedListKeysResponse{
+		ListKeysResponse: response,
+		Keys:             make([]map[string]json.RawMessage, 0, len(entities)),
+	}
+	for i := range entities {
+		key, err := models.ProjectEntity(&entities[i], fields)
+		if err != nil {
+			h.logger.WithError(err).WithField("entity_id", entities[i].ID).Error("Failed to project certificate entity")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Internal Server Error",
+				"message": "Failed to render certificate list",
+			})
+			return
+		}
+		projected.Keys = append(projected.Keys, key)
+	}
+	c.JSON(http.StatusOK, projected)
+}
+
+// projectedListKeysResponse is a ListKeysResponse whose keys are reduced to the requested fields
+type projectedListKeysResponse struct {
+	models.ListKeysResponse
+	Keys []map[string]json.RawMessage `json:"keys"`
+}
+
+// ExportPrivateKey exports the private key for a certificate entity
+// @Summary Export private key (SENSITIVE OPERATION)
+// @Description Exports the decrypted private key in PEM format. WARNING: This operation exposes sensitive cryptographic material and should be used with extreme caution. Ensure proper access controls and audit logging.
+// @Tags Certificate Management
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Success 200 {object} models.ExportPrivateKeyResponse "Private key exported successfully"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid ID format or the private key is held externally (code external_private_key)"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/private-key [get]
+func (h *CertificateHandler) ExportPrivateKey(c *gin.Context) {
+	entityID := c.Param("id")
+	if entityID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Entity ID is required",
+		})
+		return
+	}
+
+	// Retrieve entity
+	entity, err := h.storage.GetCertificateEntity(c.Request.Context(), entityID, true)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not Found",
+			"message": "Certificate entity not found",
+		})
+		return
+	}
+
+	// Validate that private key exists
+	if !requirePrivateKey(c, entity) {
+		return
+	}
+	if entity.EncryptedPrivateKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "No private key available for this certificate entity",
+		})
+		return
+	}
+
+	// Prepare response
+	response := models.ExportPrivateKeyResponse{
+		ID:         entityID,
+		PrivateKey: entity.EncryptedPrivateKey, // Note: This is actually the decrypted private key in PEM format
+		KeyType:    entity.KeyType,
+		CommonName: entity.CommonName,
+		ExportedAt: time.Now().Format(time.RFC3339),
+	}
+
+	h.auditPrivateKeyExport(c, entity)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// auditPrivateKeyExport logs and records an event for a decrypted private key leaving the service
+func (h *CertificateHandler) auditPrivateKeyExport(c *gin.Context, entity *models.CertificateEntity) {
+	h.logger.WithFields(logrus.Fields{
+		"entity_id":    entity.ID,
+		"common_name":  entity.CommonName,
+		"key_type":     entity.KeyType,
+		"operation":    "export_private_key",
+		"user_agent":   c.GetHeader("User-Agent"),
+		"remote_addr":  c.ClientIP(),
+		"request_id":   c.GetString("request_id"),
+		"api_key_name": c.GetString("api_key_name"),
+	}).Warn("SENSITIVE: Private key exported")
+
+	h.logger.WithFields(logrus.Fields{
+		"entity_id":   entity.ID,
+		"common_name": entity.CommonName,
+		"key_type":    entity.KeyType,
+	}).Info("Private key export completed")
+	recordEvent(c.Request.Context(), h.auditSink, h.logger, newEvent(c, entity.ID, models.EventPrivateKeyExported))
+}
+
+// VerifyPrivateKey checks that an entity's private key can still be decrypted without exporting it
+// @Summary Verify a private key can be decrypted
+// @Description Decrypts the private key with KMS and discards it, confirming the KMS key and grants still work and the ciphertext is intact before a deployment relies on it. A failed decryption is reported with decryptable false and the AWS error code; the key itself is never returned.
+// @Tags Certificate Management
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Success 200 {object} models.VerifyPrivateKeyResponse "Result of the decryption attempt"
+// @Failure 400 {object} map[string]interface{} "Bad request - the private key is held externally (code external_private_key)"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/verify-key [get]
+func (h *CertificateHandler) VerifyPrivateKey(c *gin.Context) {
+	entityID := c.Param("id")
+
+	entity, reason, err := h.storage.VerifyPrivateKey(c.Request.Context(), entityID)
+	if err != nil {
+		if errors.Is(err, storage.ErrEntityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not Found",
+				"message": "Certificate entity not found",
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to verify private key",
+		})
+		return
+	}
+	if !requirePrivateKey(c, entity) {
+		return
+	}
+
+	// The key was decrypted, so the check is audited like an export
+	h.logger.WithFields(logrus.Fields{
+		"entity_id":    entityID,
+		"common_name":  entity.CommonName,
+		"operation":    "verify_private_key",
+		"decryptable":  reason == "",
+		"remote_addr":  c.ClientIP(),
+		"request_id":   c.GetString("request_id"),
+		"api_key_name": c.GetString("api_key_name"),
+	}).Info("Private key decryption verified")
+
+	c.JSON(http.StatusOK, models.VerifyPrivateKeyResponse{
+		ID:          entityID,
+		Decryptable: reason == "",
+		KMSKeyID:    entity.KMSKeyID,
+		Error:       reason,
+		CheckedAt:   time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// GetCSR returns an entity's CSR in PEM or base64 DER form with the metadata needed to submit it
+// @Summary Get the CSR of a certificate entity
+// @Description Returns the CSR ready for submission to a CA. With format=der the CSR is the base64-encoded DER, as EST and ACME expect; the key type and requested SANs are included either way. The private key is not read.
+// @Tags Certificate Management
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Param format query string false "CSR encoding" Enums(pem, der) default(pem)
+// @Success 200 {object} models.CSRResponse "CSR and metadata"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid format or no CSR available"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/csr [get]
+func (h *CertificateHandler) GetCSR(c *gin.Context) {
+	entityID := c.Param("id")
+
+	format := models.CSRFormat(c.DefaultQuery("format", string(models.CSRFormatPEM)))
+	if format != models.CSRFormatPEM && format != models.CSRFormatDER {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid format",
+			"details": fmt.Sprintf("format must be '%s' or '%s'", models.CSRFormatPEM, models.CSRFormatDER),
+		})
+		return
+	}
+
+	entity, err := h.storage.GetCertificateEntityFields(c.Request.Context(), entityID,
+		[]string{"common_name", "subject_alternative_names", "key_type", "csr"})
+	if err != nil {
+		if errors.Is(err, storage.ErrEntityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not Found",
+				"message": "Certificate entity not found",
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to retrieve certificate signing request",
+		})
+		return
+	}
+
+	if entity.CSR == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "No CSR available for this certificate entity",
+		})
+		return
+	}
+
+	csr := entity.CSR
+	if format == models.CSRFormatDER {
+		if csr, err = h.cryptoService.EncodeCSRDER(entity.CSR); err != nil {
+			h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to encode stored CSR")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Internal Server Error",
+				"message": "Failed to encode certificate signing request",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.CSRResponse{
+		ID:                      entityID,
+		CommonName:              entity.CommonName,
+		SubjectAlternativeNames: entity.SubjectAlternativeNames,
+		KeyType:                 entity.KeyType,
+		Format:                  format,
+		CSR:                     csr,
+	})
+}
+
+// DownloadCertificate returns an entity's certificate as a PEM or DER file
+// @Summary Download the certificate of a certificate entity
+// @Description Returns the uploaded or issued leaf certificate as a file. format=pem (default) returns it as a .pem file; format=der returns the raw DER bytes as application/pkix-cert with a .cer filename, as Windows and smartcard tools expect. The issuing chain is not included; see GET /keys/{id}/chain.
+// @Tags Certificate Management
+// @Produce application/x-pem-file
+// @Produce application/pkix-cert
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Param format query string false "Certificate encoding" Enums(pem, der) default(pem)
+// @Success 200 {file} file "Certificate file"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid format or no certificate uploaded"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/certificate [get]
+func (h *CertificateHandler) DownloadCertificate(c *gin.Context) {
+	entityID := c.Param("id")
+
+	format := models.CertificateFormat(c.DefaultQuery("format", string(models.CertificateFormatPEM)))
+	if format != models.CertificateFormatPEM && format != models.CertificateFormatDER {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid format",
+			"details": fmt.Sprintf("format must be '%s' or '%s'", models.CertificateFormatPEM, models.CertificateFormatDER),
+		})
+		return
+	}
+
+	entity, err := h.storage.GetCertificateEntityFields(c.Request.Context(), entityID, []string{"common_name", "certificate"})
+	if err != nil {
+		if errors.Is(err, storage.ErrEntityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not Found",
+				"message": "Certificate entity not found",
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to retrieve certificate",
+		})
+		return
+	}
+
+	if entity.Certificate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "No certificate available for this certificate entity",
+		})
+		return
+	}
+
+	file, err := certificateFile(entity, format)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to encode stored certificate")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to encode certificate",
+		})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"entity_id": entityID,
+		"format":    format,
+		"filename":  file.filename,
+	}).Debug("Certificate downloaded")
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.filename))
+	c.Data(http.StatusOK, file.contentType, file.data)
+}
+
+// GetCertificateChain returns an entity's issuing chain as a PEM file
+// @Summary Download the certificate chain of a certificate entity
+// @Description Returns only the issuing chain, without the leaf, as concatenated PEM for systems that want the chain in a separate file. The chain is the one stored on ACME issuance or else the certificates uploaded after the leaf. Returns 204 when no chain is stored.
+// @Tags Certificate Management
+// @Produce application/x-pem-file
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Success 200 {file} file "Certificate chain PEM"
+// @Success 204 "No chain stored"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/chain [get]
+func (h *CertificateHandler) GetCertificateChain(c *gin.Context) {
+	entityID := c.Param("id")
+
+	entity, err := h.storage.GetCertificateEntityFields(c.Request.Context(), entityID, []string{"common_name", "certificate", "certificate_chain"})
+	if err != nil {
+		if errors.Is(err, storage.ErrEntityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not Found",
+				"message": "Certificate entity not found",
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to retrieve certificate chain",
+		})
+		return
+	}
+
+	file, err := certificateChainFile(entity)
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to read stored certificate")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to read certificate chain",
+		})
+		return
+	}
+	if file == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.filename))
+	c.Data(http.StatusOK, file.contentType, file.data)
+}
+
+// GetCertificateExpiry reports how long an entity's certificate remains valid
+// @Summary Get time until certificate expiry
+// @Description Returns the certificate's validity period with the whole days remaining until it expires and whether it already has, computed on the server's clock so monitoring scripts need neither parse valid_to nor trust their own clock. days_remaining is negative once the certificate has expired. Only the validity dates are read.
+// @Tags Certificate Management
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Success 200 {object} models.ExpiryResponse "Certificate validity and days remaining"
+// @Failure 400 {object} map[string]interface{} "Bad request - no certificate has been uploaded"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/expiry [get]
+func (h *CertificateHandler) GetCertificateExpiry(c *gin.Context) {
+	entityID := c.Param("id")
+
+	entity, err := h.storage.GetCertificateEntityFields(c.Request.Context(), entityID, []string{"valid_from", "valid_to"})
+	if err != nil {
+		if errors.Is(err, storage.ErrEntityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not Found",
+				"message": "Certificate entity not found",
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve certificate entity")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to retrieve certificate expiry",
+		})
+		return
+	}
+	if entity.ValidTo == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "No certificate has been uploaded for this entity",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, certificateExpiry(entityID, entity.ValidFrom, *entity.ValidTo, time.Now()))
+}
+
+// certificateExpiry computes the days remaining until validTo as of now, rounded down so that an
+// expired certificate has negative days remaining
+func certificateExpiry(id string, validFrom *time.Time, validTo, now time.Time) models.ExpiryResponse {
+	remaining := validTo.Sub(now)
+	days := int(remaining / (24 * time.Hour))
+	if remaining < 0 && remaining%(24*time.Hour) != 0 {
+		days--
+	}
+	return models.ExpiryResponse{
+		ID:            id,
+		ValidFrom:     validFrom,
+		ValidTo:       validTo,
+		DaysRemaining: days,
+		IsExpired:     now.After(validTo),
+	}
+}
+
+// downloadFile is a file returned as an attachment
+type downloadFile struct {
+	filename    string
+	contentType string
+	data        []byte
+}
+
+// certificateFile encodes an entity's leaf certificate for download; a chain uploaded along with it is left out
+func certificateFile(entity *models.CertificateEntity, format models.CertificateFormat) (*downloadFile, error) {
+	leaf, _, err := splitCertificateBundle(entity.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	base := fmt.Sprintf("%s-%s", entity.CommonName, shortID(entity.ID))
+	if format == models.CertificateFormatDER {
+		return &downloadFile{filename: base + ".cer", contentType: "application/pkix-cert", data: leaf}, nil
+	}
+	leafPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf})
+	return &downloadFile{filename: base + ".pem", contentType: "application/x-pem-file", data: leafPEM}, nil
+}
+
+// certificateChainFile returns an entity's issuing chain as a PEM file, or nil when none is stored.
+// The chain is the one stored separately on ACME issuance or else the certificates uploaded after the leaf.
+func certificateChainFile(entity *models.CertificateEntity) (*downloadFile, error) {
+	chain := entity.CertificateChain
+	if chain == "" && entity.Certificate != "" {
+		var err error
+		if _, chain, err = splitCertificateBundle(entity.Certificate); err != nil {
+			return nil, err
+		}
+	}
+	if strings.TrimSpace(chain) == "" {
+		return nil, nil
+	}
+
+	return &downloadFile{
+		filename:    fmt.Sprintf("%s-%s-chain.pem", entity.CommonName, shortID(entity.ID)),
+		contentType: "application/x-pem-file",
+		data:        []byte(chain),
+	}, nil
+}
+
+// certificateBundle returns an entity's leaf certificate followed by its issuing chain as PEM. The
+// chain stored separately on ACME issuance takes the place of any certificates uploaded after the leaf.
+func certificateBundle(entity *models.CertificateEntity) (string, error) {
+	if entity.CertificateChain == "" {
+		return entity.Certificate, nil
+	}
+	leaf, _, err := splitCertificateBundle(entity.Certificate)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf})) + entity.CertificateChain, nil
+}
+
+// splitCertificateBundle returns the DER of the first certificate in a PEM bundle, the leaf, and the
+// certificates following it re-encoded as PEM
+func splitCertificateBundle(bundle string) ([]byte, string, error) {
+	block, rest := pem.Decode([]byte(bundle))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, "", fmt.Errorf("stored certificate is not a PEM certificate")
+	}
+
+	var chain []byte
+	for {
+		var next *pem.Block
+		next, rest = pem.Decode(rest)
+		if next == nil {
+			break
+		}
+		if next.Type == "CERTIFICATE" {
+			chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: next.Bytes})...)
+		}
+	}
+	return block.Bytes, string(chain), nil
+}
+
+// shortID returns the first eight characters of an entity ID for use in filenames
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// GetStatusHistory returns the status transitions of a certificate entity
+// @Summary Get the status history of a certificate entity
+// @Description Lists the status transitions of the entity, most recent first unless sort_order=asc, with the time, the API key name or client certificate identity that made it and the request ID. Transitions are returned in pages; pass next_cursor as cursor to read the next one. Entities created before history was recorded only list later transitions.
+// @Tags Certificate Management
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Param page_size query int false "Number of transitions per page (default: 50, max: 100)" minimum(1) maximum(100)
+// @Param sort_order query string false "Sort order by time (default: desc)" Enums(asc, desc)
+// @Param cursor query string false "next_cursor of the previous page; continues after its last transition"
+// @Success 200 {object} models.StatusHistoryResponse "Status history"
+// @Failure 400 {object} map[string]interface{} "Bad request - a cursor that is malformed or was returned for another sort_order"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/history [get]
+func (h *CertificateHandler) GetStatusHistory(c *gin.Context) {
+	entityID := c.Param("id")
+	page := historyPage(c)
+
+	history, nextCursor, err := h.storage.GetStatusHistory(c.Request.Context(), entityID, page)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			invalidHistoryCursor(c, err)
+			return
+		}
+		if errors.Is(err, storage.ErrEntityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not Found",
+				"message": "Certificate entity not found",
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve status history")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to retrieve status history",
+		})
+		return
+	}
+
+	if history == nil {
+		history = []models.StatusChange{}
+	}
+
+	c.JSON(http.StatusOK, models.StatusHistoryResponse{
+		ID:         entityID,
+		History:    history,
+		PageSize:   page.PageSize,
+		SortOrder:  page.SortOrder,
+		NextCursor: nextCursor,
+	})
+}
+
+// GetEvents returns the durable event log of a certificate entity
+// @Summary Get the event log of a certificate entity
+// @Description Lists the logged key creation, certificate upload and issuance, private key and PFX export and deletion events of the entity, most recent first unless sort_order=asc, with the API key name or client certificate identity that caused them and the request ID. Events are returned in pages; pass next_cursor as cursor to read the next one. Events are kept after the entity is deleted, so an unknown ID returns an empty list. Only available when DYNAMODB_EVENTS_TABLE is set.
+// @Tags Certificate Management
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Param page_size query int false "Number of events per page (default: 50, max: 100)" minimum(1) maximum(100)
+// @Param sort_order query string false "Sort order by time (default: desc)" Enums(asc, desc)
+// @Param cursor query string false "next_cursor of the previous page; continues after its last event"
+// @Success 200 {object} models.EventsResponse "Event log"
+// @Failure 400 {object} map[string]interface{} "Bad request - a cursor that is malformed or was returned for another sort_order"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id}/events [get]
+func (h *CertificateHandler) GetEvents(c *gin.Context) {
+	entityID := c.Param("id")
+	page := historyPage(c)
+
+	events, nextCursor, err := h.events.ListEvents(c.Request.Context(), entityID, page)
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		invalidHistoryCursor(c, err)
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to retrieve events")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to retrieve events",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.EventsResponse{
+		ID:         entityID,
+		Events:     events,
+		PageSize:   page.PageSize,
+		SortOrder:  page.SortOrder,
+		NextCursor: nextCursor,
+	})
+}
+
+// historyPage reads the page_size, sort_order and cursor query parameters of the event log and
+// status history. Like in ListCertificates, invalid page sizes and orders fall back to the defaults.
+func historyPage(c *gin.Context) models.HistoryPage {
+	page := models.HistoryPage{
+		PageSize:  storage.DefaultHistoryPageSize,
+		SortOrder: "desc",
+		Cursor:    c.Query("cursor"),
+	}
+	if pageSize, err := strconv.Atoi(c.Query("page_size")); err == nil && pageSize > 0 && pageSize <= 100 {
+		page.PageSize = pageSize
+	}
+	if sortOrder := c.Query("sort_order"); sortOrder == "asc" || sortOrder == "desc" {
+		page.SortOrder = sortOrder
+	}
+	return page
+}
+
+// invalidHistoryCursor renders the 400 response for an event log or status history cursor that
+// cannot be used
+func invalidHistoryCursor(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Bad Request",
+		"message": "Invalid cursor",
+		"details": err.Error() + "; a cursor is only valid with the sort_order it was returned with",
+	})
+}
+
+// DeleteCertificate deletes a certificate entity
+// @Summary Delete a certificate entity
+// @Description Permanently deletes a certificate entity, including its encrypted private key. Entities tagged protected=true are only deleted when force=true is given.
+// @Tags Certificate Management
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param id path string true "Certificate entity ID (UUID format)"
+// @Param force query bool false "Also delete an entity tagged protected=true" default(false)
+// @Success 204 "Certificate entity deleted"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid force value"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} map[string]interface{} "Certificate entity not found"
+// @Failure 409 {object} map[string]interface{} "Conflict - certificate entity is protected"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/{id} [delete]
+func (h *CertificateHandler) DeleteCertificate(c *gin.Context) {
+	entityID := c.Param("id")
+
+	force, ok := parseForceQuery(c)
+	if !ok {
+		return
+	}
+
+	if err := h.storage.DeleteCertificateEntity(c.Request.Context(), entityID, force); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrEntityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Not Found",
+				"message": "Certificate entity not found",
+			})
+		case errors.Is(err, storage.ErrEntityProtected):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Conflict",
+				"message": "Certificate entity is protected",
+				"details": "Remove the protected tag or pass force=true to delete it",
+			})
+		default:
+			h.logger.WithError(err).WithField("entity_id", entityID).Error("Failed to delete certificate entity")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Internal Server Error",
+				"message": "Failed to delete certificate entity",
+			})
+		}
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"entity_id":    entityID,
+		"force":        force,
+		"operation":    "delete_certificate",
+		"user_agent":   c.GetHeader("User-Agent"),
+		"remote_addr":  c.ClientIP(),
+		"request_id":   c.GetString("request_id"),
+		"api_key_name": c.GetString("api_key_name"),
+	}).Warn("SENSITIVE: Certificate entity deleted")
+	recordEvent(c.Request.Context(), h.auditSink, h.logger, newEvent(c, entityID, models.EventDeleted))
+
+	c.Status(http.StatusNoContent)
+}
+
+// bulkDeleteSampleSize is how many matching IDs a bulk delete response lists
+const bulkDeleteSampleSize = 20
+
+// BulkDelete deletes every certificate entity matching a filter
+// @Summary Delete certificate entities matching a filter
+// @Description Deletes the entities matching a filter with the same criteria as listing (tags, status, key_type, date_from, date_to); at least one criterion is required. dry_run is required: a dry run only counts the matches and returns a sample of their IDs with a confirm_token. To delete, repeat the request with dry_run=false and that token as confirm; if the matching entities changed in the meantime the request is refused with 409. Entities tagged protected=true are never deleted and are reported separately.
+// @Tags Certificate Management
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param request body models.BulkDeleteRequest true "Filter, dry_run flag and confirm token"
+// @Success 200 {object} models.BulkDeleteResponse "Matching entities counted, or deleted"
+// @Failure 400 {object} map[string]interface{} "Bad request - missing dry_run, empty filter or missing confirm token"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 409 {object} map[string]interface{} "Conflict - the matching entities changed since the dry run"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /keys/bulk-delete [post]
+func (h *CertificateHandler) BulkDelete(c *gin.Context) {
+	var req models.BulkDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to bind JSON request")
+		// The validation middleware renders the structured error response
+		c.Status(http.StatusBadRequest)
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	if req.Filter.IsEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Filter is required",
+			"details": "Set at least one of tags, status, key_type, date_from or date_to",
+		})
+		return
+	}
+	dryRun := *req.DryRun
+	if !dryRun && req.Confirm == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Confirm token is required",
+			"details": "Run with dry_run=true first and pass its confirm_token as confirm",
+		})
+		return
+	}
+
+	deletable, protected, err := h.storage.FindDeletableEntities(c.Request.Context(), req.Filter.SearchFilters())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to find certificate entities for bulk delete")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Internal Server Error",
+			"message": "Failed to find matching certificate entities",
+		})
+		return
+	}
+
+	token := bulkDeleteToken(deletable)
+	response := models.BulkDeleteResponse{
+		DryRun:             dryRun,
+		MatchedCount:       len(deletable),
+		SampleIDs:          deletable[:min(len(deletable), bulkDeleteSampleSize)],
+		ProtectedCount:     len(protected),
+		ProtectedSampleIDs: protected[:min(len(protected), bulkDeleteSampleSize)],
+	}
+	if response.SampleIDs == nil {
+		response.SampleIDs = []string{}
+	}
+
+	if dryRun {
+		response.ConfirmToken = token
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if req.Confirm != token {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "Conflict",
+			"message":       "The matching certificate entities changed since the dry run",
+			"details":       "Run with dry_run=true again and pass the new confirm_token",
+			"matched_count": len(deletable),
+		})
+		return
+	}
+
+	deleted, err := h.storage.DeleteCertificateEntities(c.Request.Context(), deletable)
+	// Every entity deleted is audited, even when the delete stopped part way
+	for _, id := range deleted {
+		recordEvent(c.Request.Context(), h.auditSink, h.logger, newEvent(c, id, models.EventDeleted))
+	}
+	logger := h.logger.WithFields(logrus.Fields{
+		"operation":       "bulk_delete",
+		"matched_count":   len(deletable),
+		"deleted_count":   len(deleted),
+		"protected_count": len(protected),
+		"user_agent":      c.GetHeader("User-Agent"),
+		"remote_addr":     c.ClientIP(),
+		"request_id":      c.GetString("request_id"),
+		"api_key_name":    c.GetString("api_key_name"),
+	})
+	if err != nil {
+		logger.WithError(err).Error("SENSITIVE: Bulk delete stopped part way")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":         "Internal Server Error",
+			"message":       "Failed to delete all matching certificate entities",
+			"deleted_count": len(deleted),
+		})
+		return
+	}
+	logger.Warn("SENSITIVE: Certificate entities deleted in bulk")
+
+	response.DeletedCount = len(deleted)
+	c.JSON(http.StatusOK, response)
+}
+
+// bulkDeleteToken identifies a set of sorted entity IDs, so a bulk delete only proceeds when it
+// matches exactly the entities its dry run reported
+func bulkDeleteToken(ids []string) string {
+	hash := sha256.New()
+	for _, id := range ids {
+		hash.Write([]byte(id))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// codeExternalKey identifies requests refused because the entity's private key is held elsewhere
+const codeExternalKey = "external_private_key"
+
+// requirePrivateKey renders a 400 response and returns false when the entity was created from an
+// external CSR, so Certificate Monkey holds no private key for it
+func requirePrivateKey(c *gin.Context, entity *models.CertificateEntity) bool {
+	if !entity.ExternalKey {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Bad Request",
+		"code":    codeExternalKey,
+		"message": "The private key of this certificate entity is held outside Certificate Monkey",
+	})
+	return false
+}
+
+// Stable codes identifying why an uploaded certificate was rejected
+const (
+	codeInvalidCertificate      = "invalid_certificate"
+	codeCertificateCSRMismatch  = "certificate_csr_mismatch"
+	codeCertificateExpired      = "certificate_expired"
+	codeCertificateNotYetValid  = "certificate_not_yet_valid"
+	codeCertificateValidityLong = "certificate_validity_too_long"
+	codeIssuerNotAllowed        = "issuer_not_allowed"
+	codeMissingDNSSAN           = "certificate_missing_dns_san"
+	codeCNNotInSANs             = "certificate_cn_not_in_sans"
+)
+
+// validityViolation describes how a certificate's validity breaks the upload policy
+type validityViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validityViolations checks a certificate's validity period against maxValidity, where zero
+// allows any length, and reports a certificate that has already expired at now or only becomes
+// valid more than maxClockSkew after now
+func validityViolations(validFrom, validTo time.Time, maxValidity, maxClockSkew time.Duration, now time.Time) []validityViolation {
+	var violations []validityViolation
+	if !now.Before(validTo) {
+		violations = append(violations, validityViolation{
+			Code:    codeCertificateExpired,
+			Message: fmt.Sprintf("certificate expired at %s", validTo.UTC().Format(time.RFC3339)),
+		})
+	}
+	if validFrom.After(now.Add(maxClockSkew)) {
+		violations = append(violations, validityViolation{
+			Code:    codeCertificateNotYetValid,
+			Message: fmt.Sprintf("certificate is not valid until %s", validFrom.UTC().Format(time.RFC3339)),
+		})
+	}
+	if validity := validTo.Sub(validFrom); maxValidity > 0 && validity > maxValidity {
+		violations = append(violations, validityViolation{
+			Code: codeCertificateValidityLong,
+			Message: fmt.Sprintf("certificate is valid for %d days, more than the maximum of %d days",
+				int(validity.Hours()/24), int(maxValidity.Hours()/24)),
+		})
+	}
+	return violations
+}
+
+// uploadedStatus is the status of an entity whose certificate was accepted despite the given
+// violations: EXPIRED for a certificate that has already expired, CERT_UPLOADED otherwise
+func uploadedStatus(violations []validityViolation) models.CertificateStatus {
+	for _, violation := range violations {
+		if violation.Code == codeCertificateExpired {
+			return models.StatusExpired
+		}
+	}
+	return models.StatusCertUploaded
+}
+
+// certificateValidationResponse maps a ValidateCertificateWithCSR error to a status and body:
+// 400 for a certificate that cannot be parsed, 422 for a well-formed one that does not match the CSR
+func certificateValidationResponse(err error) (int, gin.H) {
+	switch {
+	case errors.Is(err, crypto.ErrInvalidCertificate):
+		return http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"code":    codeInvalidCertificate,
+			"message": "Invalid certificate format",
+			"details": err.Error(),
+		}
+	case errors.Is(err, crypto.ErrCertificateMismatch):
+		return http.StatusUnprocessableEntity, gin.H{
+			"error":   "Unprocessable Entity",
+			"code":    codeCertificateCSRMismatch,
+			"message": "Certificate does not match the CSR",
+			"details": err.Error(),
+		}
+	default:
+		return http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Certificate validation failed",
+			"details": err.Error(),
+		}
+	}
+}
+
+// setCertificateDetails stores a PEM certificate on the entity along with its validity, serial number, fingerprints and SPKI pin.
+// The certificate must already have been validated against the entity's CSR.
+func setCertificateDetails(cryptoService *crypto.CryptoService, entity *models.CertificateEntity, certificatePEM string) error {
+	cert, err := cryptoService.ParseCertificate(certificatePEM)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	fingerprintSHA256, err := cryptoService.GenerateCertificateFingerprint(certificatePEM, crypto.FingerprintSHA256)
+	if err != nil {
+		return fmt.Errorf("failed to generate certificate fingerprint: %w", err)
+	}
+	fingerprintSHA1, err := cryptoService.GenerateCertificateFingerprint(certificatePEM, crypto.FingerprintSHA1)
+	if err != nil {
+		return fmt.Errorf("failed to generate certificate fingerprint: %w", err)
+	}
+	spkiPin, err := cryptoService.PublicKeyPin(certificatePEM)
+	if err != nil {
+		return fmt.Errorf("failed to compute SPKI pin: %w", err)
+	}
+
+	entity.Certificate = certificatePEM
+	entity.ValidFrom = &cert.NotBefore
+	entity.ValidTo = &cert.NotAfter
+	entity.Issuer = cert.Issuer.String()
+	entity.SerialNumber = cert.SerialNumber.String()
+	entity.SerialNumberHex = cryptoService.FormatSerialNumberHex(cert.SerialNumber)
+	entity.Fingerprint = fingerprintSHA256
+	entity.FingerprintSHA1 = fingerprintSHA1
+	entity.FingerprintSHA256 = fingerprintSHA256
+	entity.SPKIPin = spkiPin
+	entity.KeyUsages, entity.ExtKeyUsages = cryptoService.DescribeKeyUsages(cert)
+	entity.AuthorityKeyID, entity.SubjectKeyID = cryptoService.KeyIdentifiers(cert)
+	return nil
+}
+
+// issuerAllowed reports whether the issuer's common name or full distinguished name is in the allowed list
+func issuerAllowed(allowed []string, issuer pkix.Name) bool {
+	dn := issuer.String()
+	for _, entry := range allowed {
+		if entry == dn || (issuer.CommonName != "" && entry == issuer.CommonName) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanViolationCode maps an error from CheckSubjectAlternativeNames to its stable upload code
+func sanViolationCode(err error) string {
+	if errors.Is(err, crypto.ErrCommonNameNotInSANs) {
+		return codeCNNotInSANs
+	}
+	return codeMissingDNSSAN
+}
+
+// missingExtKeyUsages returns the required extended key usages the certificate lacks.
+// A certificate with the "any" usage satisfies every requirement.
+func missingExtKeyUsages(required, present []string) []string {
+	has := make(map[string]bool, len(present))
+	for _, usage := range present {
+		has[usage] = true
+	}
+	if has["any"] {
+		return nil
+	}
+
+	var missing []string
+	for _, usage := range required {
+		if !has[usage] {
+			missing = append(missing, usage)
+		}
+	}
+	return missing
+}
+
+// statusChange describes a status transition made by the current request, or returns nil when
+// the status does not change. The storage layer stamps the time of the write.
+func statusChange(c *gin.Context, from, to models.CertificateStatus) *models.StatusChange {
+	if from == to {
+		return nil
+	}
+
+	return &models.StatusChange{
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      requestActor(c),
+		RequestID:  c.GetString("request_id"),
+	}
+}
+
+// requestActor names who made the current request: the API key name, or the client certificate identity
+func requestActor(c *gin.Context) string {
+	if actor := c.GetString("api_key_name"); actor != "" {
+		return actor
+	}
+	return c.GetString("client_identity")
+}
+
+// newEvent describes an event caused by the current request; the event store stamps the time
+func newEvent(c *gin.Context, entityID string, eventType models.EventType) *models.Event {
+	return &models.Event{
+		EntityID:  entityID,
+		Type:      eventType,
+		Actor:     requestActor(c),
+		RequestID: c.GetString("request_id"),
+	}
+}
+
+// recordEvent writes an event to the audit sink when one is configured. The operation has
+// already taken effect, so a failed write is logged rather than failing the request.
+func recordEvent(ctx context.Context, sink audit.Sink, logger *logrus.Logger, event *models.Event) {
+	if sink == nil {
+		return
+	}
+	if err := sink.RecordEvent(ctx, event); err != nil {
+		logger.WithError(err).WithFields(logrus.Fields{
+			"entity_id":  event.EntityID,
+			"event_type": event.Type,
+		}).Error("Failed to record event")
+	}
+}
+
+// notifyIssued tells the notifier, when set, that a certificate was attached to the entity. It is sent
+// in the background so a slow webhook does not hold up the upload; failures are logged.
+func notifyIssued(notifier notify.Notifier, logger *logrus.Logger, entity *models.CertificateEntity) {
+	if notifier == nil {
+		return
+	}
+	notification := &notify.Notification{
+		Event:       notify.EventIssued,
+		EntityID:    entity.ID,
+		CommonName:  entity.CommonName,
+		Fingerprint: entity.FingerprintSHA256,
+		ValidFrom:   entity.ValidFrom,
+		ValidTo:     entity.ValidTo,
+		Timestamp:   time.Now(),
+	}
+	go func() {
+		if err := notifier.Notify(context.Background(), notification); err != nil {
+			logger.WithError(err).WithFields(logrus.Fields{
+				"entity_id": notification.EntityID,
+				"event":     notification.Event,
+			}).Error("Failed to send notification")
+		}
+	}()
+}
+
+// parseFieldsQuery reads the optional fields query parameter selecting which entity fields to return.
+// It renders a 400 response and returns false when a field is unknown; nil fields select everything.
+func parseFieldsQuery(c *gin.Context) ([]string, bool) {
+	fields, err := models.ParseEntityFields(c.Query("fields"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid fields",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return fields, true
+}
+
+// listQueryParams are the query parameters ListCertificates accepts besides tag filters
+var listQueryParams = map[string]bool{
+	"status": true, "key_type": true, "date_from": true, "date_to": true, "page": true,
+	"page_size": true, "sort_by": true, "sort_order": true, "fields": true, "summary": true,
+	"has_certificate": true, "cursor": true,
+}
+
+// parseTagsQuery reads the tag filters given as tags[key]=value query parameters.
+// It renders a 400 response and returns false for any other parameter ListCertificates does not know,
+// so a misspelt parameter is reported rather than silently filtering on a tag of that name.
+func parseTagsQuery(c *gin.Context) (map[string]string, bool) {
+	tags := make(map[string]string)
+	for param, values := range c.Request.URL.Query() {
+		if listQueryParams[param] {
+			continue
+		}
+		if key, ok := strings.CutPrefix(param, "tags["); ok && strings.HasSuffix(key, "]") && len(key) > 1 {
+			tags[strings.TrimSuffix(key, "]")] = values[0]
+			continue
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Unknown query parameter",
+			"details": fmt.Sprintf("unknown query parameter %q; filter by tag with tags[key]=value", param),
+		})
+		return nil, false
+	}
+	return tags, true
+}
+
+// parseSummaryQuery reads the optional summary query parameter of ListCertificates, which defaults to true.
+// It renders a 400 response and returns false when the value is not a boolean.
+func parseSummaryQuery(c *gin.Context) (bool, bool) {
+	value := c.Query("summary")
+	if value == "" {
+		return true, true
+	}
+
+	summary, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid summary value",
+			"details": "summary must be true or false",
+		})
+		return false, false
+	}
+	return summary, true
+}
+
+// parseHasCertificateQuery reads the optional has_certificate list filter.
+// It renders a 400 response and returns false when the value is not a boolean.
+func parseHasCertificateQuery(c *gin.Context) (*bool, bool) {
+	value := c.Query("has_certificate")
+	if value == "" {
+		return nil, true
+	}
+
+	hasCertificate, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid has_certificate value",
+			"details": "has_certificate must be true or false",
+		})
+		return nil, false
+	}
+	return &hasCertificate, true
+}
+
+// canonicalMediaType is the Accept value that selects the canonical JSON encoding
+const canonicalMediaType = "application/json+canonical"
+
+// parseCanonicalQuery reports whether the client asked for the canonical JSON encoding, either with
+// canonical=true or by accepting application/json+canonical. It renders a 400 response and returns
+// false when the canonical value is not a boolean.
+func parseCanonicalQuery(c *gin.Context) (bool, bool) {
+	if value := c.Query("canonical"); value != "" {
+		canonical, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad Request",
+				"message": "Invalid canonical value",
+				"details": "canonical must be true or false",
+			})
+			return false, false
+		}
+		return canonical, true
+	}
+
+	for _, accept := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), canonicalMediaType) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// parseIncludePrivateKeyQuery reads the optional include_private_key query parameter.
+// It renders a 400 response and returns false when the value is not a boolean.
+func parseIncludePrivateKeyQuery(c *gin.Context) (bool, bool) {
+	value := c.Query("include_private_key")
+	if value == "" {
+		return false, true
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid include_private_key value",
+			"details": "include_private_key must be true or false",
+		})
+		return false, false
+	}
+	return include, true
+}
+
+// keystoreMediaType is the content type of binary keystores
+const keystoreMediaType = "application/x-pkcs12"
+
+// acceptsBinaryKeystore reports whether the client asked for the keystore file itself, by accepting
+// application/x-pkcs12 or application/octet-stream, rather than JSON with the base64 keystore
+func acceptsBinaryKeystore(c *gin.Context) bool {
+	for _, accept := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		mediaType = strings.TrimSpace(mediaType)
+		if strings.EqualFold(mediaType, keystoreMediaType) || strings.EqualFold(mediaType, "application/octet-stream") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseForceQuery reads the optional force query parameter that overrides the protected tag.
+// It renders a 400 response and returns false when the value is not a boolean.
+func parseForceQuery(c *gin.Context) (bool, bool) {
+	value := c.Query("force")
+	if value == "" {
+		return false, true
+	}
+
+	force, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid force value",
+			"details": "force must be true or false",
+		})
+		return false, false
+	}
+	return force, true
+}
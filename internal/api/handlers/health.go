@@ -8,24 +8,39 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 
-	"certificate-monkey/internal/storage"
 	"certificate-monkey/internal/version"
 )
 
+// HealthStore is the storage the health checks probe
+type HealthStore interface {
+	CheckDynamoDBHealth(ctx context.Context) error
+	CheckKMSHealth(ctx context.Context) error
+	DynamoDBTableStatus(ctx context.Context) (string, error)
+	KMSKeyState(ctx context.Context) (string, error)
+	TableName() string
+	KMSKeyID() string
+}
+
 // HealthHandler handles health check HTTP requests
 type HealthHandler struct {
-	storage *storage.DynamoDBStorage
+	storage HealthStore
 	logger  *logrus.Logger
+	region  string
 }
 
 // NewHealthHandler creates a new health handler
-func NewHealthHandler(storage *storage.DynamoDBStorage, logger *logrus.Logger) *HealthHandler {
+func NewHealthHandler(storage HealthStore, logger *logrus.Logger) *HealthHandler {
 	return &HealthHandler{
 		storage: storage,
 		logger:  logger,
 	}
 }
 
+// SetRegion sets the AWS region reported by the readiness endpoint
+func (h *HealthHandler) SetRegion(region string) {
+	h.region = region
+}
+
 // HealthResponse represents the basic health check response
 type HealthResponse struct {
 	Status  string `json:"status"`
@@ -42,6 +57,33 @@ type AWSHealthResponse struct {
 	Checks    map[string]HealthCheck `json:"checks"`
 }
 
+// ReadinessResponse aggregates readiness, dependency state and build information in one document
+// for dashboards. Ready is true only when every dependency is ready.
+type ReadinessResponse struct {
+	Ready        bool                  `json:"ready" example:"true"`
+	Service      string                `json:"service" example:"certificate-monkey"`
+	Region       string                `json:"region" example:"eu-central-1"`
+	Timestamp    string                `json:"timestamp" example:"2024-01-01T10:00:00Z"`
+	Build        version.Info          `json:"build"`
+	Dependencies ReadinessDependencies `json:"dependencies"`
+}
+
+// ReadinessDependencies reports each AWS dependency of the service
+type ReadinessDependencies struct {
+	DynamoDB DependencyStatus `json:"dynamodb"`
+	KMS      DependencyStatus `json:"kms"`
+}
+
+// DependencyStatus describes one dependency. Resource names the table or key, and State is its
+// AWS status, such as ACTIVE for a table or Enabled for a key, when it could be described.
+type DependencyStatus struct {
+	Ready      bool   `json:"ready" example:"true"`
+	Resource   string `json:"resource" example:"certificate-monkey-prod"`
+	State      string `json:"state,omitempty" example:"ACTIVE"`
+	ResponseMs int64  `json:"response_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
 // HealthCheck represents individual service check result
 type HealthCheck struct {
 	Status     string `json:"status"`
@@ -67,7 +109,7 @@ func (h *HealthHandler) BasicHealth(c *gin.Context) {
 
 // AWSHealth checks AWS services connectivity
 // @Summary AWS connectivity health check
-// @Description Verifies connectivity to DynamoDB and KMS services
+// @Description Verifies the configured DynamoDB table exists and is ACTIVE, and the configured KMS key exists and is enabled
 // @Tags Health
 // @Produce json
 // @Success 200 {object} AWSHealthResponse "All AWS services are accessible"
@@ -166,3 +208,60 @@ func (h *HealthHandler) checkKMS(ctx context.Context) HealthCheck {
 		ResponseMs: elapsed,
 	}
 }
+
+// Readiness reports readiness together with dependency state and build information
+// @Summary Readiness with dependency details
+// @Description Returns readiness in one machine-readable document: the DynamoDB table status, the KMS key state, the configured region and the service build. Responds 503 when a dependency is not ready.
+// @Tags Health
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Success 200 {object} ReadinessResponse "All dependencies are ready"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Failure 503 {object} ReadinessResponse "One or more dependencies are not ready"
+// @Router /ready [get]
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	response := ReadinessResponse{
+		Service:   "certificate-monkey",
+		Region:    h.region,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Build:     version.Get(),
+		Dependencies: ReadinessDependencies{
+			DynamoDB: h.dependencyStatus(ctx, h.storage.TableName(), h.storage.DynamoDBTableStatus),
+			KMS:      h.dependencyStatus(ctx, h.storage.KMSKeyID(), h.storage.KMSKeyState),
+		},
+	}
+	response.Ready = response.Dependencies.DynamoDB.Ready && response.Dependencies.KMS.Ready
+
+	h.logger.WithFields(logrus.Fields{
+		"ready":          response.Ready,
+		"dynamodb_state": response.Dependencies.DynamoDB.State,
+		"kms_state":      response.Dependencies.KMS.State,
+	}).Debug("Readiness check completed")
+
+	httpStatus := http.StatusOK
+	if !response.Ready {
+		httpStatus = http.StatusServiceUnavailable
+	}
+	c.JSON(httpStatus, response)
+}
+
+// dependencyStatus runs one dependency probe and times it
+func (h *HealthHandler) dependencyStatus(ctx context.Context, resource string, probe func(context.Context) (string, error)) DependencyStatus {
+	start := time.Now()
+	state, err := probe(ctx)
+	status := DependencyStatus{
+		Ready:      err == nil,
+		Resource:   resource,
+		State:      state,
+		ResponseMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("resource", resource).Error("Readiness dependency check failed")
+		status.Error = err.Error()
+	}
+	return status
+}
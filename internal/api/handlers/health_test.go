@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -9,8 +11,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"certificate-monkey/internal/storage"
+	"certificate-monkey/internal/version"
 )
 
 func TestNewHealthHandler(t *testing.T) {
@@ -112,3 +116,114 @@ func TestHandlerLoggerIsUsed(t *testing.T) {
 	assert.Same(t, logger, handler.logger, "Handler should use the provided logger")
 	assert.Same(t, storage, handler.storage, "Handler should use the provided storage")
 }
+
+// mockHealthStore returns fixed dependency states; an error marks the dependency as not ready
+type mockHealthStore struct {
+	tableStatus string
+	tableErr    error
+	keyState    string
+	keyErr      error
+}
+
+func (m *mockHealthStore) CheckDynamoDBHealth(ctx context.Context) error { return m.tableErr }
+func (m *mockHealthStore) CheckKMSHealth(ctx context.Context) error      { return m.keyErr }
+func (m *mockHealthStore) DynamoDBTableStatus(ctx context.Context) (string, error) {
+	return m.tableStatus, m.tableErr
+}
+func (m *mockHealthStore) KMSKeyState(ctx context.Context) (string, error) {
+	return m.keyState, m.keyErr
+}
+func (m *mockHealthStore) TableName() string { return "certificate-monkey-test" }
+func (m *mockHealthStore) KMSKeyID() string  { return "alias/certificate-monkey-test" }
+
+// TestReadiness tests the readiness aggregate reports every dependency, the region and the build
+func TestReadiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		store     *mockHealthStore
+		wantCode  int
+		wantReady bool
+	}{
+		{
+			name:      "ready",
+			store:     &mockHealthStore{tableStatus: "ACTIVE", keyState: "Enabled"},
+			wantCode:  http.StatusOK,
+			wantReady: true,
+		},
+		{
+			name:     "key pending deletion",
+			store:    &mockHealthStore{tableStatus: "ACTIVE", keyState: "PendingDeletion", keyErr: errors.New("KMS key alias/certificate-monkey-test is PendingDeletion, not Enabled")},
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "table unreachable",
+			store:    &mockHealthStore{tableErr: errors.New("failed to describe DynamoDB table certificate-monkey-test"), keyState: "Enabled"},
+			wantCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := logrus.New()
+			logger.SetLevel(logrus.FatalLevel)
+			handler := NewHealthHandler(tt.store, logger)
+			handler.SetRegion("eu-central-1")
+			router := gin.New()
+			router.GET("/ready", handler.Readiness)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+			require.Equal(t, tt.wantCode, w.Code)
+
+			var response ReadinessResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.wantReady, response.Ready)
+			assert.Equal(t, "certificate-monkey", response.Service)
+			assert.Equal(t, "eu-central-1", response.Region)
+			assert.NotEmpty(t, response.Timestamp)
+			assert.Equal(t, version.Get(), response.Build)
+
+			dynamo := response.Dependencies.DynamoDB
+			assert.Equal(t, tt.store.tableErr == nil, dynamo.Ready)
+			assert.Equal(t, "certificate-monkey-test", dynamo.Resource)
+			assert.Equal(t, tt.store.tableStatus, dynamo.State)
+			if tt.store.tableErr != nil {
+				assert.Equal(t, tt.store.tableErr.Error(), dynamo.Error)
+			} else {
+				assert.Empty(t, dynamo.Error)
+			}
+
+			kms := response.Dependencies.KMS
+			assert.Equal(t, tt.store.keyErr == nil, kms.Ready)
+			assert.Equal(t, "alias/certificate-monkey-test", kms.Resource)
+			assert.Equal(t, tt.store.keyState, kms.State)
+			if tt.store.keyErr != nil {
+				assert.Equal(t, tt.store.keyErr.Error(), kms.Error)
+			} else {
+				assert.Empty(t, kms.Error)
+			}
+		})
+	}
+
+	t.Run("shape", func(t *testing.T) {
+		handler := NewHealthHandler(&mockHealthStore{tableStatus: "ACTIVE", keyState: "Enabled"}, logrus.New())
+		router := gin.New()
+		router.GET("/ready", handler.Readiness)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		for _, field := range []string{"ready", "service", "region", "timestamp", "build", "dependencies"} {
+			assert.Contains(t, response, field)
+		}
+		assert.Contains(t, response["build"], "git_commit")
+		dependencies := response["dependencies"].(map[string]interface{})
+		assert.Contains(t, dependencies, "dynamodb")
+		assert.Contains(t, dependencies, "kms")
+		assert.NotContains(t, dependencies["kms"], "error", "Ready dependencies carry no error")
+	})
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/smithy-go"
+	"github.com/sirupsen/logrus"
+
+	"certificate-monkey/internal/config"
+	"certificate-monkey/internal/crypto"
+	"certificate-monkey/internal/storage"
+	"certificate-monkey/internal/storage/storagetest"
+)
+
+// testKMSKeyID is the KMS key the test storage encrypts under
+const testKMSKeyID = "test-key"
+
+// newTestLogger returns a logger that only reports errors
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	return logger
+}
+
+// newTestStorage returns storage backed by the given mock clients; a nil client is replaced by one
+// whose operations all succeed
+func newTestStorage(client *storagetest.DynamoDBClient, kmsClient *storagetest.KMSClient) *storage.DynamoDBStorage {
+	if client == nil {
+		client = &storagetest.DynamoDBClient{}
+	}
+	if kmsClient == nil {
+		kmsClient = &storagetest.KMSClient{}
+	}
+	cfg := &config.Config{AWS: config.AWSConfig{DynamoDBTable: "test-table", KMSKeyID: testKMSKeyID}}
+	return storage.NewDynamoDBStorage(client, kmsClient, cfg, newTestLogger())
+}
+
+// newTestHandler returns a certificate handler backed by the given mock clients, as newTestStorage
+func newTestHandler(client *storagetest.DynamoDBClient, kmsClient *storagetest.KMSClient) *CertificateHandler {
+	return NewCertificateHandler(newTestStorage(client, kmsClient), crypto.NewCryptoService(), newTestLogger())
+}
+
+// newDeniedKMS returns a KMS client failing every Decrypt as KMS does once the key policy or grant no
+// longer allows it
+func newDeniedKMS() *storagetest.KMSClient {
+	return &storagetest.KMSClient{
+		DecryptFn: func(ctx context.Context, params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+			return nil, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "User is not authorized to use arn:aws:kms:eu-central-1:123456789012:key/old"}
+		},
+	}
+}
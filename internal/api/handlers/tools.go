@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"certificate-monkey/internal/crypto"
+	"certificate-monkey/internal/models"
+)
+
+// ToolsHandler handles stateless helper requests; submitted material is never stored
+type ToolsHandler struct {
+	cryptoService *crypto.CryptoService
+	logger        *logrus.Logger
+}
+
+// NewToolsHandler creates a new tools handler
+func NewToolsHandler(cryptoService *crypto.CryptoService, logger *logrus.Logger) *ToolsHandler {
+	return &ToolsHandler{
+		cryptoService: cryptoService,
+		logger:        logger,
+	}
+}
+
+// InspectCertificate parses a PEM certificate or bundle and describes each certificate
+// @Summary Inspect a PEM certificate
+// @Description Parses a PEM-encoded certificate, or a bundle of several, and returns the subject, issuer, validity, SANs and key details of each. format=text returns a plain-text description laid out like openssl x509 -text instead, one block per certificate separated by a blank line. Nothing is stored.
+// @Tags Tools
+// @Accept json
+// @Produce json
+// @Produce plain
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param request body models.InspectCertificateRequest true "PEM-encoded certificate or bundle"
+// @Param format query string false "Response format" Enums(json, text) default(json)
+// @Success 200 {object} models.InspectCertificateResponse "Parsed certificate details"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid format, missing or unparseable certificate"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Router /tools/inspect-certificate [post]
+func (h *ToolsHandler) InspectCertificate(c *gin.Context) {
+	format := models.InspectFormat(c.DefaultQuery("format", string(models.InspectFormatJSON)))
+	if format != models.InspectFormatJSON && format != models.InspectFormatText {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid format",
+			"details": fmt.Sprintf("format must be '%s' or '%s'", models.InspectFormatJSON, models.InspectFormatText),
+		})
+		return
+	}
+
+	var req models.InspectCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		// The validation middleware renders the structured error response
+		c.Status(http.StatusBadRequest)
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	certs, err := h.cryptoService.ParseCertificates(req.Certificate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid certificate",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"operation":  "inspect_certificate",
+		"count":      len(certs),
+		"format":     format,
+		"request_id": c.GetString("request_id"),
+	}).Info("Certificate inspected")
+
+	if format == models.InspectFormatText {
+		texts := make([]string, 0, len(certs))
+		for _, cert := range certs {
+			texts = append(texts, h.cryptoService.FormatCertificateText(cert))
+		}
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(strings.Join(texts, "\n")))
+		return
+	}
+
+	now := time.Now()
+	response := models.InspectCertificateResponse{
+		Count:        len(certs),
+		Certificates: make([]models.CertificateDetails, 0, len(certs)),
+	}
+	for _, cert := range certs {
+		response.Certificates = append(response.Certificates, h.cryptoService.DescribeCertificate(cert, now))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// InspectCSR parses a PEM certificate signing request and verifies its signature
+// @Summary Inspect a PEM certificate signing request
+// @Description Parses a PEM-encoded CSR and returns its subject, SANs by type, key details, signature algorithm and whether its self-signature verifies. Nothing is stored.
+// @Tags Tools
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param request body models.InspectCSRRequest true "PEM-encoded CSR"
+// @Success 200 {object} models.CSRDetails "Parsed CSR details"
+// @Failure 400 {object} map[string]interface{} "Bad request - missing or unparseable CSR"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Router /tools/inspect-csr [post]
+func (h *ToolsHandler) InspectCSR(c *gin.Context) {
+	var req models.InspectCSRRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		// The validation middleware renders the structured error response
+		c.Status(http.StatusBadRequest)
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	csr, err := h.cryptoService.ParseCSR(req.CSR)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid CSR",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	details := h.cryptoService.DescribeCSR(csr)
+
+	h.logger.WithFields(logrus.Fields{
+		"operation":       "inspect_csr",
+		"signature_valid": details.SignatureValid,
+		"request_id":      c.GetString("request_id"),
+	}).Info("CSR inspected")
+
+	c.JSON(http.StatusOK, details)
+}
+
+// MatchKey checks whether a private key and a certificate form a pair
+// @Summary Check whether a private key matches a certificate
+// @Description Compares the public key of a PEM certificate with the one derived from a PEM private key. Neither input is stored or logged.
+// @Tags Tools
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Param request body models.MatchKeyRequest true "PEM-encoded private key and certificate"
+// @Success 200 {object} models.MatchKeyResponse "Match result"
+// @Failure 400 {object} map[string]interface{} "Bad request - missing or unparseable key or certificate"
+// @Failure 401 {object} map[string]interface{} "Unauthorized - invalid or missing API key"
+// @Router /tools/match [post]
+func (h *ToolsHandler) MatchKey(c *gin.Context) {
+	var req models.MatchKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		// The validation middleware renders the structured error response
+		c.Status(http.StatusBadRequest)
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	cert, err := h.cryptoService.ParseCertificate(req.Certificate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid certificate",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// The raw key is only ever handed to the matcher
+	match, err := h.cryptoService.MatchPrivateKeyAndCertificate(req.PrivateKey.Reveal(), req.Certificate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Invalid private key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"operation":  "match_key",
+		"match":      match,
+		"request_id": c.GetString("request_id"),
+	}).Info("Private key and certificate compared")
+
+	c.JSON(http.StatusOK, models.MatchKeyResponse{
+		Match:              match,
+		CertificateSubject: cert.Subject.String(),
+	})
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/api/middleware"
+	"certificate-monkey/internal/crypto"
+	"certificate-monkey/internal/models"
+)
+
+// newToolsTestRouter creates a router exposing the tools handler without authentication
+func newToolsTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewToolsHandler(crypto.NewCryptoService(), newTestLogger())
+	router := gin.New()
+	router.Use(middleware.ValidationErrors(middleware.Policy{}))
+	router.POST("/tools/inspect-certificate", handler.InspectCertificate)
+	router.POST("/tools/inspect-csr", handler.InspectCSR)
+	router.POST("/tools/match", handler.MatchKey)
+	return router
+}
+
+// postTool sends a JSON body to a tools endpoint
+func postTool(t *testing.T, router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	payload, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", path, strings.NewReader(string(payload)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// newTestCertificatePEM creates a self-signed ECDSA certificate valid between notBefore and notAfter
+func newTestCertificatePEM(t *testing.T, commonName string, notBefore, notAfter time.Time) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Example Corp"}},
+		DNSNames:     []string{commonName, "www." + commonName},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// TestInspectCertificate tests certificate inspection for single certificates and bundles
+func TestInspectCertificate(t *testing.T) {
+	router := newToolsTestRouter()
+	now := time.Now()
+
+	t.Run("valid certificate", func(t *testing.T) {
+		certPEM := newTestCertificatePEM(t, "inspect.example.com", now.Add(-time.Hour), now.Add(24*time.Hour))
+		w := postTool(t, router, "/tools/inspect-certificate", models.InspectCertificateRequest{Certificate: certPEM})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response models.InspectCertificateResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, 1, response.Count)
+
+		details := response.Certificates[0]
+		assert.Equal(t, "CN=inspect.example.com,O=Example Corp", details.Subject)
+		assert.Equal(t, []string{"inspect.example.com", "www.inspect.example.com"}, details.DNSNames)
+		assert.Equal(t, "ECDSA", details.KeyAlgorithm)
+		assert.Equal(t, 256, details.KeySize)
+		assert.Equal(t, "42", details.SerialNumber)
+		assert.False(t, details.Expired)
+	})
+
+	t.Run("bundle with an expired certificate", func(t *testing.T) {
+		bundle := newTestCertificatePEM(t, "current.example.com", now.Add(-time.Hour), now.Add(time.Hour)) +
+			newTestCertificatePEM(t, "expired.example.com", now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+		w := postTool(t, router, "/tools/inspect-certificate", models.InspectCertificateRequest{Certificate: bundle})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response models.InspectCertificateResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, 2, response.Count)
+		assert.False(t, response.Certificates[0].Expired)
+		assert.True(t, response.Certificates[1].Expired)
+		assert.Equal(t, "CN=expired.example.com,O=Example Corp", response.Certificates[1].Subject)
+	})
+
+	t.Run("text format", func(t *testing.T) {
+		bundle := newTestCertificatePEM(t, "first.example.com", now.Add(-time.Hour), now.Add(time.Hour)) +
+			newTestCertificatePEM(t, "second.example.com", now.Add(-time.Hour), now.Add(time.Hour))
+		w := postTool(t, router, "/tools/inspect-certificate?format=text", models.InspectCertificateRequest{Certificate: bundle})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+
+		text := w.Body.String()
+		assert.Equal(t, 2, strings.Count(text, "Certificate:\n"))
+		assert.Contains(t, text, "Subject: CN=first.example.com,O=Example Corp\n")
+		assert.Contains(t, text, "Serial Number: 42 (0x2a)\n")
+		assert.Contains(t, text, "DNS:second.example.com, DNS:www.second.example.com\n")
+		assert.Contains(t, text, "\n\nCertificate:\n", "Certificates are separated by a blank line")
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		certPEM := newTestCertificatePEM(t, "inspect.example.com", now.Add(-time.Hour), now.Add(time.Hour))
+		w := postTool(t, router, "/tools/inspect-certificate?format=yaml", models.InspectCertificateRequest{Certificate: certPEM})
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Invalid format", response["message"])
+	})
+
+	t.Run("garbage input", func(t *testing.T) {
+		w := postTool(t, router, "/tools/inspect-certificate", models.InspectCertificateRequest{Certificate: "definitely not a certificate"})
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Invalid certificate", response["message"])
+	})
+
+	t.Run("corrupt certificate body", func(t *testing.T) {
+		corrupt := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("not DER")}))
+		w := postTool(t, router, "/tools/inspect-certificate", models.InspectCertificateRequest{Certificate: corrupt})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("missing certificate", func(t *testing.T) {
+		w := postTool(t, router, "/tools/inspect-certificate", map[string]string{})
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "certificate is required")
+	})
+}
+
+// TestInspectCSR tests CSR inspection including signature verification
+func TestInspectCSR(t *testing.T) {
+	router := newToolsTestRouter()
+
+	_, csrPEM, err := crypto.NewCryptoService().GenerateKeyAndCSR(models.CreateKeyRequest{
+		CommonName:              "csr.example.com",
+		SubjectAlternativeNames: []string{"csr.example.com", "10.0.0.5"},
+		KeyType:                 models.KeyTypeECDSAP256,
+	})
+	require.NoError(t, err)
+
+	t.Run("valid CSR", func(t *testing.T) {
+		w := postTool(t, router, "/tools/inspect-csr", models.InspectCSRRequest{CSR: csrPEM})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var details models.CSRDetails
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+		assert.Equal(t, "csr.example.com", details.CommonName)
+		assert.Equal(t, []string{"csr.example.com"}, details.DNSNames)
+		assert.Equal(t, []string{"10.0.0.5"}, details.IPAddresses)
+		assert.Equal(t, "ECDSA", details.KeyAlgorithm)
+		assert.Equal(t, "ECDSA-SHA256", details.SignatureAlgorithm)
+		assert.True(t, details.SignatureValid)
+	})
+
+	t.Run("broken signature", func(t *testing.T) {
+		block, _ := pem.Decode([]byte(csrPEM))
+		require.NotNil(t, block)
+		csr, err := x509.ParseCertificateRequest(block.Bytes)
+		require.NoError(t, err)
+
+		// Re-encode the request with a signature over different data
+		signature := append([]byte(nil), csr.Signature...)
+		signature[len(signature)-1] ^= 0xff
+		der := bytes.Replace(block.Bytes, csr.Signature, signature, 1)
+		broken := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+
+		w := postTool(t, router, "/tools/inspect-csr", models.InspectCSRRequest{CSR: broken})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var details models.CSRDetails
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+		assert.False(t, details.SignatureValid)
+		assert.NotEmpty(t, details.SignatureError)
+	})
+
+	t.Run("garbage input", func(t *testing.T) {
+		w := postTool(t, router, "/tools/inspect-csr", models.InspectCSRRequest{CSR: "not a csr"})
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid CSR")
+	})
+}
+
+// TestMatchKey tests key/certificate matching and that inputs never reach the logs
+func TestMatchKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+	logger.SetLevel(logrus.DebugLevel)
+
+	cryptoService := crypto.NewCryptoService()
+	handler := NewToolsHandler(cryptoService, logger)
+	router := gin.New()
+	router.Use(middleware.ValidationErrors(middleware.Policy{}))
+	router.POST("/tools/match", handler.MatchKey)
+
+	privateKeyPEM, _, err := cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{CommonName: "match.example.com", KeyType: models.KeyTypeECDSAP256})
+	require.NoError(t, err)
+	otherCertPEM := newTestCertificatePEM(t, "other.example.com", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+	// Self-sign a certificate for the generated key
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	require.NotNil(t, block)
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(7),
+		Subject:      pkix.Name{CommonName: "match.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+
+	post := func(body interface{}) *httptest.ResponseRecorder {
+		return postTool(t, router, "/tools/match", body)
+	}
+
+	t.Run("matching", func(t *testing.T) {
+		w := post(map[string]string{"private_key": privateKeyPEM, "certificate": certPEM})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response models.MatchKeyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.Match)
+		assert.Equal(t, "CN=match.example.com", response.CertificateSubject)
+	})
+
+	t.Run("not matching", func(t *testing.T) {
+		w := post(map[string]string{"private_key": privateKeyPEM, "certificate": otherCertPEM})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response models.MatchKeyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.False(t, response.Match)
+	})
+
+	t.Run("malformed key", func(t *testing.T) {
+		w := post(map[string]string{"private_key": "not a key", "certificate": certPEM})
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid private key")
+	})
+
+	t.Run("malformed certificate", func(t *testing.T) {
+		w := post(map[string]string{"private_key": privateKeyPEM, "certificate": "not a certificate"})
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid certificate")
+	})
+
+	t.Run("missing fields", func(t *testing.T) {
+		w := post(map[string]string{})
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "private_key is required")
+	})
+
+	// Neither the key nor the certificate may appear in the logs or responses
+	keyBody := strings.Split(privateKeyPEM, "\n")[1]
+	assert.NotEmpty(t, logs.String())
+	assert.NotContains(t, logs.String(), keyBody)
+	assert.NotContains(t, logs.String(), strings.Split(certPEM, "\n")[1])
+}
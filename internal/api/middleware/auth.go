@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -10,19 +11,23 @@ import (
 	"certificate-monkey/internal/config"
 )
 
+// ScopeAdmin grants access to administrative endpoints such as backup and restore
+const ScopeAdmin = "admin"
+
+// ScopeExport allows the decrypted private key to be returned by GET /keys/{id}?include_private_key=true
+const ScopeExport = "export"
+
 // AuthMiddleware creates authentication middleware for API key validation
 func AuthMiddleware(cfg *config.Config, logger *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get API key from header
-		apiKey := c.GetHeader("X-API-Key")
-		if apiKey == "" {
-			// Also check Authorization header with Bearer prefix
-			authHeader := c.GetHeader("Authorization")
-			if strings.HasPrefix(authHeader, "Bearer ") {
-				apiKey = strings.TrimPrefix(authHeader, "Bearer ")
-			}
+		// Requests already authenticated by ClientCertMiddleware only need an API key if configured
+		if c.GetString("client_identity") != "" && !cfg.TLS.RequireAPIKey {
+			c.Next()
+			return
 		}
 
+		apiKey := requestAPIKey(c, cfg.Security)
+
 		if apiKey == "" {
 			logger.WithFields(logrus.Fields{
 				"remote_addr": c.ClientIP(),
@@ -47,6 +52,24 @@ func AuthMiddleware(cfg *config.Config, logger *logrus.Logger) gin.HandlerFunc {
 			}
 		}
 
+		// Admin and export keys are valid for every endpoint and additionally carry their scope.
+		// Scopes granted by a client certificate are kept when both are required.
+		scopes := c.GetStringSlice("auth_scopes")
+		for _, adminKey := range cfg.Security.AdminAPIKeys {
+			if apiKey == adminKey {
+				isValid = true
+				scopes = append(scopes, ScopeAdmin)
+				break
+			}
+		}
+		for _, exportKey := range cfg.Security.ExportAPIKeys {
+			if apiKey == exportKey {
+				isValid = true
+				scopes = append(scopes, ScopeExport)
+				break
+			}
+		}
+
 		if !isValid {
 			logger.WithFields(logrus.Fields{
 				"remote_addr": c.ClientIP(),
@@ -63,18 +86,100 @@ func AuthMiddleware(cfg *config.Config, logger *logrus.Logger) gin.HandlerFunc {
 			return
 		}
 
+		// Keys loaded from API_KEYS_FILE are identified by name; others only by their masked value
+		keyName := cfg.Security.KeyNames[apiKey]
+
 		// Log successful authentication
 		logger.WithFields(logrus.Fields{
-			"remote_addr": c.ClientIP(),
-			"path":        c.Request.URL.Path,
-			"api_key":     maskAPIKey(apiKey),
+			"remote_addr":  c.ClientIP(),
+			"path":         c.Request.URL.Path,
+			"api_key":      maskAPIKey(apiKey),
+			"api_key_name": keyName,
 		}).Debug("Request authenticated successfully")
 
+		c.Set("auth_scopes", scopes)
+		if keyName != "" {
+			c.Set("api_key_name", keyName)
+		}
+
 		// Continue to the next handler
 		c.Next()
 	}
 }
 
+// RequireScope creates middleware that rejects requests whose credentials lack the given scope.
+// It must be registered after AuthMiddleware.
+func RequireScope(scope string, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasScope(c, scope) {
+			logger.WithFields(logrus.Fields{
+				"remote_addr": c.ClientIP(),
+				"path":        c.Request.URL.Path,
+				"scope":       scope,
+			}).Warn("API key lacks required scope")
+
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": fmt.Sprintf("API key does not have the required '%s' scope", scope),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasScope reports whether the credentials that authenticated the request carry the given scope
+func HasScope(c *gin.Context, scope string) bool {
+	value, exists := c.Get("auth_scopes")
+	if !exists {
+		return false
+	}
+
+	scopes, ok := value.([]string)
+	if !ok {
+		return false
+	}
+
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// requestAPIKey reads the API key from X-API-Key, then from the configured API key header,
+// then from an Authorization header using one of the configured schemes. Without configured
+// schemes only Bearer is accepted.
+func requestAPIKey(c *gin.Context, security config.SecurityConfig) string {
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+		return apiKey
+	}
+	if security.APIKeyHeader != "" {
+		if apiKey := c.GetHeader(security.APIKeyHeader); apiKey != "" {
+			return apiKey
+		}
+	}
+
+	schemes := security.AuthSchemes
+	if len(schemes) == 0 {
+		schemes = []string{config.AuthSchemeBearer}
+	}
+	scheme, credentials, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found {
+		return ""
+	}
+	// Authorization schemes are case-insensitive (RFC 9110)
+	for _, accepted := range schemes {
+		if strings.EqualFold(scheme, accepted) {
+			return strings.TrimSpace(credentials)
+		}
+	}
+	return ""
+}
+
 // maskAPIKey masks an API key for logging purposes
 func maskAPIKey(apiKey string) string {
 	if len(apiKey) < 8 {
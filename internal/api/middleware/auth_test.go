@@ -161,6 +161,109 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 }
 
+// TestAuthMiddlewareSchemes tests the configured API key header and Authorization schemes, and that X-API-Key takes precedence
+func TestAuthMiddlewareSchemes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	newRouter := func(security config.SecurityConfig) *gin.Engine {
+		security.APIKeys = []string{"valid_key_1", "valid_key_2"}
+		router := gin.New()
+		router.Use(AuthMiddleware(&config.Config{Security: security}, logger))
+		router.GET("/test", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"message": "success"})
+		})
+		return router
+	}
+
+	tests := []struct {
+		name           string
+		security       config.SecurityConfig
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{
+			name:           "ApiKey scheme is rejected by default",
+			headers:        map[string]string{"Authorization": "ApiKey valid_key_1"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "ApiKey scheme when configured",
+			security:       config.SecurityConfig{AuthSchemes: []string{config.AuthSchemeAPIKey}},
+			headers:        map[string]string{"Authorization": "ApiKey valid_key_1"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Scheme names are case-insensitive",
+			security:       config.SecurityConfig{AuthSchemes: []string{config.AuthSchemeAPIKey}},
+			headers:        map[string]string{"Authorization": "apikey valid_key_1"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Bearer scheme is rejected when only ApiKey is configured",
+			security:       config.SecurityConfig{AuthSchemes: []string{config.AuthSchemeAPIKey}},
+			headers:        map[string]string{"Authorization": "Bearer valid_key_1"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Both schemes when configured",
+			security:       config.SecurityConfig{AuthSchemes: []string{config.AuthSchemeBearer, config.AuthSchemeAPIKey}},
+			headers:        map[string]string{"Authorization": "Bearer valid_key_2"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Configured API key header",
+			security:       config.SecurityConfig{APIKeyHeader: "X-Client-Token"},
+			headers:        map[string]string{"X-Client-Token": "valid_key_1"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Custom header is ignored unless configured",
+			headers:        map[string]string{"X-Client-Token": "valid_key_1"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "X-API-Key is still accepted with a configured header",
+			security:       config.SecurityConfig{APIKeyHeader: "X-Client-Token"},
+			headers:        map[string]string{"X-API-Key": "valid_key_2"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "X-API-Key takes precedence over the configured header",
+			security: config.SecurityConfig{APIKeyHeader: "X-Client-Token"},
+			headers: map[string]string{
+				"X-API-Key":      "invalid_key",
+				"X-Client-Token": "valid_key_1",
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:     "Configured header takes precedence over Authorization",
+			security: config.SecurityConfig{APIKeyHeader: "X-Client-Token", AuthSchemes: []string{config.AuthSchemeAPIKey}},
+			headers: map[string]string{
+				"X-Client-Token": "invalid_key",
+				"Authorization":  "ApiKey valid_key_1",
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			newRouter(tt.security).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
 // Test AuthMiddleware with empty API keys configuration
 func TestAuthMiddlewareEmptyConfig(t *testing.T) {
 	gin.SetMode(gin.TestMode)
@@ -219,6 +322,123 @@ func TestAuthMiddlewareNilConfig(t *testing.T) {
 	})
 }
 
+// Test RequireScope only admits API keys carrying the scope
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Security: config.SecurityConfig{
+			APIKeys:      []string{"regular_key_123"},
+			AdminAPIKeys: []string{"admin_key_456"},
+		},
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	router := gin.New()
+	router.Use(AuthMiddleware(cfg, logger))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"admin": HasScope(c, ScopeAdmin)})
+	})
+	admin := router.Group("/admin")
+	admin.Use(RequireScope(ScopeAdmin, logger))
+	admin.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		apiKey         string
+		expectedStatus int
+	}{
+		{"Regular key on regular endpoint", "/test", "regular_key_123", http.StatusOK},
+		{"Admin key on regular endpoint", "/test", "admin_key_456", http.StatusOK},
+		{"Regular key on admin endpoint", "/admin/test", "regular_key_123", http.StatusForbidden},
+		{"Admin key on admin endpoint", "/admin/test", "admin_key_456", http.StatusOK},
+		{"Invalid key on admin endpoint", "/admin/test", "invalid_key", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("X-API-Key", tt.apiKey)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusForbidden {
+				var response map[string]interface{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, "Forbidden", response["error"])
+			}
+		})
+	}
+
+	t.Run("Scope is exposed to handlers", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-API-Key", "admin_key_456")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, true, response["admin"])
+	})
+}
+
+// Test export keys authenticate and carry only the export scope
+func TestExportScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Security: config.SecurityConfig{
+			APIKeys:       []string{"regular_key_123"},
+			AdminAPIKeys:  []string{"admin_key_456"},
+			ExportAPIKeys: []string{"export_key_789"},
+		},
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	router := gin.New()
+	router.Use(AuthMiddleware(cfg, logger))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"admin": HasScope(c, ScopeAdmin), "export": HasScope(c, ScopeExport)})
+	})
+
+	tests := []struct {
+		name   string
+		apiKey string
+		admin  bool
+		export bool
+	}{
+		{"Regular key", "regular_key_123", false, false},
+		{"Admin key", "admin_key_456", true, false},
+		{"Export key", "export_key_789", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("X-API-Key", tt.apiKey)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.admin, response["admin"])
+			assert.Equal(t, tt.export, response["export"])
+		})
+	}
+}
+
 // Test maskAPIKey function
 func TestMaskAPIKey(t *testing.T) {
 	tests := []struct {
@@ -445,6 +665,69 @@ func TestAuthMiddlewareLogging(t *testing.T) {
 	})
 }
 
+// TestAuthMiddlewareKeyName tests that named keys expose their name to handlers and logs
+func TestAuthMiddlewareKeyName(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Security: config.SecurityConfig{
+			APIKeys:      []string{"unnamed_key", "named_key_123"},
+			AdminAPIKeys: []string{"named_admin_456"},
+			KeyNames: map[string]string{
+				"named_key_123":   "ci-pipeline",
+				"named_admin_456": "ops-admin",
+			},
+		},
+	}
+
+	logger := logrus.New()
+	var logBuffer bytes.Buffer
+	logger.SetOutput(&logBuffer)
+	logger.SetLevel(logrus.DebugLevel)
+
+	router := gin.New()
+	router.Use(AuthMiddleware(cfg, logger))
+	router.GET("/test", func(c *gin.Context) {
+		_, exists := c.Get("api_key_name")
+		c.JSON(http.StatusOK, gin.H{
+			"api_key_name": c.GetString("api_key_name"),
+			"set":          exists,
+		})
+	})
+
+	tests := []struct {
+		name         string
+		apiKey       string
+		expectedName string
+	}{
+		{"named key", "named_key_123", "ci-pipeline"},
+		{"named admin key", "named_admin_456", "ops-admin"},
+		{"unnamed key", "unnamed_key", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logBuffer.Reset()
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("X-API-Key", tt.apiKey)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedName, response["api_key_name"])
+			assert.Equal(t, tt.expectedName != "", response["set"])
+
+			if tt.expectedName != "" {
+				assert.Contains(t, logBuffer.String(), "api_key_name="+tt.expectedName)
+			}
+		})
+	}
+}
+
 // Benchmark the auth middleware
 func BenchmarkAuthMiddleware(b *testing.B) {
 	gin.SetMode(gin.TestMode)
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireContentType rejects POST, PUT and PATCH requests whose body is not one of the given
+// media types with 415 Unsupported Media Type. Parameters such as charset are ignored and
+// requests without a body are let through.
+func RequireContentType(mediaTypes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength == 0 {
+			c.Next()
+			return
+		}
+
+		contentType := c.GetHeader("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err == nil {
+			for _, allowed := range mediaTypes {
+				if mediaType == allowed {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":   "Unsupported Media Type",
+			"message": fmt.Sprintf("Content-Type must be %s", strings.Join(mediaTypes, " or ")),
+			"details": fmt.Sprintf("received Content-Type %q", contentType),
+		})
+		c.Abort()
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireContentType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequireContentType("application/json"))
+	handler := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	}
+	router.GET("/test", handler)
+	router.POST("/test", handler)
+	router.PUT("/test", handler)
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		contentType    string
+		expectedStatus int
+	}{
+		{"JSON body", "POST", `{"common_name":"example.com"}`, "application/json", http.StatusOK},
+		{"JSON body with charset", "PUT", `{"common_name":"example.com"}`, "application/json; charset=utf-8", http.StatusOK},
+		{"form-encoded body", "POST", "common_name=example.com", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"missing content type", "PUT", `{"common_name":"example.com"}`, "", http.StatusUnsupportedMediaType},
+		{"malformed content type", "POST", `{}`, "application/", http.StatusUnsupportedMediaType},
+		{"POST without body", "POST", "", "", http.StatusOK},
+		{"GET is not checked", "GET", "", "text/plain", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/test", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusUnsupportedMediaType {
+				assert.Contains(t, w.Body.String(), "Content-Type must be application/json")
+			}
+		})
+	}
+}
+
+func TestRequireContentTypeAllowsAlternatives(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/import", RequireContentType("application/octet-stream"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("POST", "/import", strings.NewReader("archive"))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("POST", "/import", strings.NewReader("archive"))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxCredentialHeaderLength is the longest API key or Authorization header value accepted;
+// real credentials are far shorter, so anything longer is abuse rather than a key
+const MaxCredentialHeaderLength = 1024
+
+// HeaderGuard rejects requests whose headers, names and values together, exceed maxHeaderBytes,
+// or whose credential headers are longer than MaxCredentialHeaderLength or contain control
+// characters, with 400 Bad Request. It runs before authentication so such values never reach the
+// key comparison or the logs. A maxHeaderBytes of zero or less only checks the credential headers.
+func HeaderGuard(maxHeaderBytes int, credentialHeaders ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxHeaderBytes > 0 {
+			size := 0
+			for name, values := range c.Request.Header {
+				for _, value := range values {
+					size += len(name) + len(value)
+				}
+			}
+			if size > maxHeaderBytes {
+				rejectHeaders(c, "Request headers are too large", fmt.Sprintf("request headers must not exceed %d bytes", maxHeaderBytes))
+				return
+			}
+		}
+
+		for _, name := range credentialHeaders {
+			for _, value := range c.Request.Header.Values(name) {
+				if len(value) > MaxCredentialHeaderLength {
+					rejectHeaders(c, "Credential header is too long", fmt.Sprintf("%s must not exceed %d bytes", name, MaxCredentialHeaderLength))
+					return
+				}
+				if hasControlCharacter(value) {
+					rejectHeaders(c, "Credential header is malformed", fmt.Sprintf("%s must not contain control characters", name))
+					return
+				}
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// rejectHeaders aborts the request with 400; the offending value is never echoed
+func rejectHeaders(c *gin.Context, message, details string) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Bad Request",
+		"message": message,
+		"details": details,
+	})
+	c.Abort()
+}
+
+// hasControlCharacter reports whether value contains an ASCII control character, tab and DEL included
+func hasControlCharacter(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < 0x20 || value[i] == 0x7f {
+			return true
+		}
+	}
+	return false
+}
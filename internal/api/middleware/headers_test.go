@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHeaderGuard tests oversized headers and over-long or control-character credentials are rejected
+// with 400 before the handler runs, without echoing the offending value
+func TestHeaderGuard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reached := false
+	router := gin.New()
+	router.Use(HeaderGuard(8192, "X-API-Key", "Authorization", "X-Custom-Key"))
+	router.GET("/keys", func(c *gin.Context) {
+		reached = true
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		message string
+	}{
+		{"valid API key", map[string]string{"X-API-Key": "cm_live_a1b2c3"}, http.StatusOK, ""},
+		{"valid bearer token", map[string]string{"Authorization": "Bearer cm_live_a1b2c3"}, http.StatusOK, ""},
+		{"oversized Authorization", map[string]string{"Authorization": "Bearer " + strings.Repeat("A", 16*1024)}, http.StatusBadRequest, "Request headers are too large"},
+		{"over-long API key", map[string]string{"X-API-Key": strings.Repeat("k", MaxCredentialHeaderLength+1)}, http.StatusBadRequest, "Credential header is too long"},
+		{"control character in API key", map[string]string{"X-API-Key": "cm_live_a1b2c3\x00admin"}, http.StatusBadRequest, "Credential header is malformed"},
+		{"escape sequence in custom header", map[string]string{"X-Custom-Key": "cm_live\x1b[2J"}, http.StatusBadRequest, "Credential header is malformed"},
+		{"long non-credential header", map[string]string{"X-Trace": strings.Repeat("t", 4096)}, http.StatusOK, ""},
+		{"oversized non-credential headers", map[string]string{"X-Trace": strings.Repeat("t", 9000)}, http.StatusBadRequest, "Request headers are too large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = false
+			req := httptest.NewRequest("GET", "/keys", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.status == http.StatusOK, reached)
+			if tt.message != "" {
+				assert.Contains(t, w.Body.String(), tt.message)
+				assert.NotContains(t, w.Body.String(), "cm_live")
+			}
+		})
+	}
+}
+
+// TestHeaderGuardWithoutSizeLimit tests a zero limit still checks credential headers
+func TestHeaderGuardWithoutSizeLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(HeaderGuard(0, "X-API-Key"))
+	router.GET("/keys", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/keys", nil)
+	req.Header.Set("X-Trace", strings.Repeat("t", 64*1024))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/keys", nil)
+	req.Header.Set("X-API-Key", "key\twith tab")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
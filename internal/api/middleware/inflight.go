@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inflightRetryAfter is the Retry-After value, in seconds, sent when the in-flight limit is reached
+const inflightRetryAfter = "1"
+
+// MaxInflightRequests caps the requests handled at once to limit memory use under load spikes.
+// Requests beyond the limit are rejected straight away with 503 Service Unavailable and a
+// Retry-After header rather than queued. Requests for the exempt paths, such as health checks,
+// are neither counted nor rejected. A limit of zero or less disables the middleware.
+func MaxInflightRequests(limit int, exempt ...string) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+	slots := make(chan struct{}, limit)
+
+	return func(c *gin.Context) {
+		if exemptPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			c.Next()
+		default:
+			c.Header("Retry-After", inflightRetryAfter)
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "Service Unavailable",
+				"message": "Too many requests in progress",
+			})
+			c.Abort()
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMaxInflightRequests tests requests beyond the cap get 503 with Retry-After while those in
+// flight complete, and that exempt health checks are served throughout
+func TestMaxInflightRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const limit, requests = 2, 6
+	arrived := make(chan struct{}, requests)
+	proceed := make(chan struct{})
+
+	router := gin.New()
+	router.Use(MaxInflightRequests(limit, "/health"))
+	router.GET("/slow", func(c *gin.Context) {
+		arrived <- struct{}{}
+		<-proceed
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	results := make(chan *httptest.ResponseRecorder, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			results <- get("/slow")
+		}()
+	}
+
+	// Once the in-flight requests hold every slot, the rest are turned away
+	for i := 0; i < limit; i++ {
+		<-arrived
+	}
+	for i := 0; i < requests-limit; i++ {
+		w := <-results
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, inflightRetryAfter, w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), "Too many requests in progress")
+	}
+
+	assert.Equal(t, http.StatusOK, get("/health").Code, "Health checks are exempt")
+
+	close(proceed)
+	for i := 0; i < limit; i++ {
+		assert.Equal(t, http.StatusOK, (<-results).Code)
+	}
+
+	// Finished requests free their slots
+	assert.Equal(t, http.StatusOK, get("/slow").Code)
+}
+
+// TestMaxInflightRequestsDisabled tests a limit of zero lets every request through
+func TestMaxInflightRequestsDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(MaxInflightRequests(0))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	}
+}
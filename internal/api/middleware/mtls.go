@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"certificate-monkey/internal/config"
+)
+
+// ClientCertMiddleware authenticates requests by their verified TLS client certificate.
+// It maps the certificate to an identity and scopes for the handlers. Unless the
+// configuration also requires an API key, AuthMiddleware then accepts the request
+// without one. The TLS handshake only verifies a certificate a client chooses to present,
+// so that health probes can connect without one; this middleware is what requires it.
+func ClientCertMiddleware(cfg *config.Config, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasVerifiedClientCert(c) {
+			logger.WithFields(logrus.Fields{
+				"remote_addr": c.ClientIP(),
+				"path":        c.Request.URL.Path,
+			}).Warn("Request without verified client certificate")
+
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": "A verified client certificate is required",
+			})
+			c.Abort()
+			return
+		}
+		authenticateClientCert(c, cfg, logger)
+	}
+}
+
+// OptionalClientCertMiddleware authenticates requests that present a verified client certificate
+// like ClientCertMiddleware and leaves the others to AuthMiddleware, for endpoints such as the
+// readiness check that probes call with an API key but no certificate
+func OptionalClientCertMiddleware(cfg *config.Config, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasVerifiedClientCert(c) {
+			c.Next()
+			return
+		}
+		authenticateClientCert(c, cfg, logger)
+	}
+}
+
+// hasVerifiedClientCert reports whether the TLS handshake verified a client certificate chain;
+// VerifiedChains is empty for a connection without one
+func hasVerifiedClientCert(c *gin.Context) bool {
+	tlsState := c.Request.TLS
+	return tlsState != nil && len(tlsState.VerifiedChains) > 0 && len(tlsState.VerifiedChains[0]) > 0
+}
+
+// authenticateClientCert sets the identity and scopes of the verified client certificate, or
+// rejects the request when the certificate names no identity
+func authenticateClientCert(c *gin.Context, cfg *config.Config, logger *logrus.Logger) {
+	cert := c.Request.TLS.VerifiedChains[0][0]
+	identity := ClientCertIdentity(cert)
+	if identity == "" {
+		logger.WithFields(logrus.Fields{
+			"remote_addr":   c.ClientIP(),
+			"path":          c.Request.URL.Path,
+			"serial_number": cert.SerialNumber.String(),
+		}).Warn("Client certificate has no usable identity")
+
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "Client certificate does not contain a subject common name or SAN",
+		})
+		c.Abort()
+		return
+	}
+
+	scopes := []string{}
+	for _, adminIdentity := range cfg.TLS.AdminIdentities {
+		if identity == adminIdentity {
+			scopes = append(scopes, ScopeAdmin)
+			break
+		}
+	}
+
+	logger.WithFields(logrus.Fields{
+		"remote_addr":     c.ClientIP(),
+		"path":            c.Request.URL.Path,
+		"client_identity": identity,
+	}).Debug("Client certificate authenticated successfully")
+
+	c.Set("client_identity", identity)
+	c.Set("auth_scopes", scopes)
+	c.Next()
+}
+
+// ClientCertIdentity returns the identity of a client certificate: its subject common name,
+// falling back to the first DNS, URI or email SAN for certificates without one
+func ClientCertIdentity(cert *x509.Certificate) string {
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,246 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/config"
+)
+
+// withVerifiedClientCert attaches a TLS state as if the handshake had verified the certificate
+func withVerifiedClientCert(req *http.Request, cert *x509.Certificate) *http.Request {
+	req.TLS = &tls.ConnectionState{
+		HandshakeComplete: true,
+		VerifiedChains:    [][]*x509.Certificate{{cert}},
+	}
+	return req
+}
+
+// newMTLSTestRouter creates a router with the client certificate and API key middleware
+func newMTLSTestRouter(cfg *config.Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	router := gin.New()
+	router.Use(ClientCertMiddleware(cfg, logger))
+	router.Use(AuthMiddleware(cfg, logger))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"identity": c.GetString("client_identity"),
+			"admin":    HasScope(c, ScopeAdmin),
+		})
+	})
+	return router
+}
+
+func TestClientCertIdentity(t *testing.T) {
+	spiffe, err := url.Parse("spiffe://example.org/service/billing")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		expected string
+	}{
+		{
+			name:     "Subject common name wins",
+			cert:     &x509.Certificate{Subject: pkix.Name{CommonName: "billing-service"}, DNSNames: []string{"billing.internal"}},
+			expected: "billing-service",
+		},
+		{
+			name:     "Falls back to DNS SAN",
+			cert:     &x509.Certificate{DNSNames: []string{"billing.internal", "billing.example.com"}},
+			expected: "billing.internal",
+		},
+		{
+			name:     "Falls back to URI SAN",
+			cert:     &x509.Certificate{URIs: []*url.URL{spiffe}},
+			expected: "spiffe://example.org/service/billing",
+		},
+		{
+			name:     "Falls back to email SAN",
+			cert:     &x509.Certificate{EmailAddresses: []string{"ops@example.com"}},
+			expected: "ops@example.com",
+		},
+		{
+			name:     "No identity",
+			cert:     &x509.Certificate{},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ClientCertIdentity(tt.cert))
+		})
+	}
+}
+
+func TestClientCertMiddleware(t *testing.T) {
+	cfg := &config.Config{
+		Security: config.SecurityConfig{APIKeys: []string{"valid_key"}},
+		TLS: config.TLSConfig{
+			ClientCAPath:    "/etc/certificate-monkey/client-ca.pem",
+			AdminIdentities: []string{"ops-automation"},
+		},
+	}
+	router := newMTLSTestRouter(cfg)
+
+	tests := []struct {
+		name             string
+		cert             *x509.Certificate
+		expectedStatus   int
+		expectedIdentity string
+		expectedAdmin    bool
+	}{
+		{
+			name:             "Verified certificate without API key",
+			cert:             &x509.Certificate{Subject: pkix.Name{CommonName: "billing-service"}, SerialNumber: big.NewInt(1)},
+			expectedStatus:   http.StatusOK,
+			expectedIdentity: "billing-service",
+		},
+		{
+			name:             "Admin identity gets admin scope",
+			cert:             &x509.Certificate{Subject: pkix.Name{CommonName: "ops-automation"}, SerialNumber: big.NewInt(2)},
+			expectedStatus:   http.StatusOK,
+			expectedIdentity: "ops-automation",
+			expectedAdmin:    true,
+		},
+		{
+			name:           "Certificate without identity",
+			cert:           &x509.Certificate{SerialNumber: big.NewInt(3)},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "No verified certificate",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			if tt.cert != nil {
+				req = withVerifiedClientCert(req, tt.cert)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedIdentity, response["identity"])
+			assert.Equal(t, tt.expectedAdmin, response["admin"])
+		})
+	}
+
+	t.Run("Unverified peer certificates are not trusted", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "ops-automation"}}},
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestClientCertMiddlewareRequireAPIKey(t *testing.T) {
+	cfg := &config.Config{
+		Security: config.SecurityConfig{APIKeys: []string{"valid_key"}},
+		TLS: config.TLSConfig{
+			ClientCAPath:    "/etc/certificate-monkey/client-ca.pem",
+			RequireAPIKey:   true,
+			AdminIdentities: []string{"ops-automation"},
+		},
+	}
+	router := newMTLSTestRouter(cfg)
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "ops-automation"}, SerialNumber: big.NewInt(1)}
+
+	t.Run("Certificate alone is rejected", func(t *testing.T) {
+		req := withVerifiedClientCert(httptest.NewRequest("GET", "/test", nil), cert)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Certificate and API key are accepted", func(t *testing.T) {
+		req := withVerifiedClientCert(httptest.NewRequest("GET", "/test", nil), cert)
+		req.Header.Set("X-API-Key", "valid_key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "ops-automation", response["identity"])
+		assert.Equal(t, true, response["admin"], "Certificate scopes are kept when an API key is also required")
+	})
+
+	t.Run("API key alone is rejected", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-API-Key", "valid_key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestOptionalClientCertMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	cfg := &config.Config{
+		Security: config.SecurityConfig{APIKeys: []string{"valid_key"}},
+		TLS: config.TLSConfig{
+			ClientCAPath:    "/etc/certificate-monkey/client-ca.pem",
+			AdminIdentities: []string{"ops-automation"},
+		},
+	}
+	router := gin.New()
+	router.Use(OptionalClientCertMiddleware(cfg, logger))
+	router.Use(AuthMiddleware(cfg, logger))
+	router.GET("/ready", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"identity": c.GetString("client_identity")})
+	})
+
+	t.Run("Verified certificate authenticates", func(t *testing.T) {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "ops-automation"}, SerialNumber: big.NewInt(1)}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, withVerifiedClientCert(httptest.NewRequest("GET", "/ready", nil), cert))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "ops-automation")
+	})
+
+	t.Run("API key without certificate is accepted", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/ready", nil)
+		req.Header.Set("X-API-Key", "valid_key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Neither is rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "API key is required")
+	})
+}
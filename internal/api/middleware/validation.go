@@ -0,0 +1,295 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"certificate-monkey/internal/config"
+	"certificate-monkey/internal/models"
+)
+
+// ValidationError describes a single request field that failed validation
+type ValidationError struct {
+	Field   string `json:"field" example:"common_name"`
+	Rule    string `json:"rule" example:"required"`
+	Message string `json:"message" example:"common_name is required"`
+}
+
+// Policy holds the configured rules the custom validation rules apply to request fields. The
+// zero value allows any country and well-formed wildcards and enforces no tag limits.
+type Policy struct {
+	// AllowedCountries restricts fields validated with allowed_country to these ISO 3166-1
+	// alpha-2 codes; empty allows any country
+	AllowedCountries []string
+	// ForbidWildcards rejects every name validated with the wildcard rule that contains a wildcard
+	ForbidWildcards bool
+	// TagLimits bounds the tags validated with the tags rule
+	TagLimits TagLimits
+}
+
+// NewPolicy returns the validation policy of the certificate configuration
+func NewPolicy(cfg config.CertificateConfig) Policy {
+	return Policy{
+		AllowedCountries: slices.Clone(cfg.AllowedCountries),
+		ForbidWildcards:  !cfg.AllowWildcards,
+		TagLimits: TagLimits{
+			MaxTags:        cfg.MaxTags,
+			MaxKeyLength:   cfg.MaxTagKeyLength,
+			MaxValueLength: cfg.MaxTagValueLength,
+		},
+	}
+}
+
+// TagLimits bounds the tags a client may set on an entity. Lengths are counted in characters;
+// a zero limit is not enforced.
+type TagLimits struct {
+	MaxTags        int
+	MaxKeyLength   int
+	MaxValueLength int
+}
+
+// CheckTags reports the first tag limit the tags violate, or nil if they are acceptable.
+// The protected tag is reserved for entity protection and only takes the values "true" and "false",
+// so a typo such as "yes" cannot leave an entity unprotected unnoticed.
+func (p Policy) CheckTags(tags map[string]string) error {
+	limits := p.TagLimits
+	if limits.MaxTags > 0 && len(tags) > limits.MaxTags {
+		return fmt.Errorf("at most %d tags are allowed, got %d", limits.MaxTags, len(tags))
+	}
+
+	// Check keys in order so the reported violation does not depend on map iteration
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if limits.MaxKeyLength > 0 && utf8.RuneCountInString(key) > limits.MaxKeyLength {
+			return fmt.Errorf("tag key '%s' exceeds the maximum length of %d characters", key, limits.MaxKeyLength)
+		}
+		if limits.MaxValueLength > 0 && utf8.RuneCountInString(tags[key]) > limits.MaxValueLength {
+			return fmt.Errorf("value of tag '%s' exceeds the maximum length of %d characters", key, limits.MaxValueLength)
+		}
+		if key == models.ProtectedTag && tags[key] != "true" && tags[key] != "false" {
+			return fmt.Errorf("tag '%s' is reserved and must be \"true\" or \"false\"", key)
+		}
+	}
+	return nil
+}
+
+// CheckCountry reports whether a subject country is outside the allow-list, or nil if it is allowed
+func (p Policy) CheckCountry(country string) error {
+	if len(p.AllowedCountries) == 0 || slices.Contains(p.AllowedCountries, country) {
+		return nil
+	}
+	return fmt.Errorf("country %q is not allowed", country)
+}
+
+// CheckWildcardName reports why a certificate name's wildcard is unacceptable, or nil if it is fine.
+// Only a single leftmost "*" label is accepted, and none at all when wildcards are forbidden.
+func (p Policy) CheckWildcardName(name string) error {
+	if !strings.Contains(name, "*") {
+		return nil
+	}
+	if p.ForbidWildcards {
+		return errors.New("wildcard names are not allowed")
+	}
+	if name == "*" || name == "*." {
+		return errors.New("a bare wildcard is not allowed")
+	}
+	if rest, found := strings.CutPrefix(name, "*."); !found || strings.Contains(rest, "*") {
+		return errors.New("only a single leftmost '*' label is allowed")
+	}
+	return nil
+}
+
+// ValidationErrors installs a request validator applying the policy and renders request binding
+// failures as structured JSON. Handlers record a failed bind with
+// c.Error(err).SetType(gin.ErrorTypeBind) and return without writing a body; field-level failures
+// are then reported as an errors array of {field, rule, message} and any other bind failure (e.g.
+// malformed JSON) as details. Gin binds with one validator per process, so the rules follow the
+// policy of the latest call, made once when the routes are set up.
+func ValidationErrors(policy Policy) gin.HandlerFunc {
+	binding.Validator = newPolicyValidator(policy)
+
+	return func(c *gin.Context) {
+		c.Next()
+
+		bindErr := c.Errors.ByType(gin.ErrorTypeBind).Last()
+		if bindErr == nil || c.Writer.Written() {
+			return
+		}
+
+		var validationErrs validator.ValidationErrors
+		if !errors.As(bindErr.Err, &validationErrs) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Bad Request",
+				"message": "Invalid request format",
+				"details": bindErr.Err.Error(),
+			})
+			return
+		}
+
+		fieldErrors := make([]ValidationError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fieldErrors = append(fieldErrors, newValidationError(fe, policy))
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Bad Request",
+			"message": "Request validation failed",
+			"errors":  fieldErrors,
+		})
+	}
+}
+
+// policyValidator is gin's default struct validator with the custom rules of a policy. Its own
+// validator engine is needed because an engine caches each struct's rules, custom rule functions
+// included, the first time it validates that struct, so rules registered later would not apply.
+type policyValidator struct {
+	validate *validator.Validate
+}
+
+// newPolicyValidator returns a validator reading rules from binding tags, reporting JSON field
+// names in validation errors and applying the policy's custom rules
+func newPolicyValidator(policy Policy) *policyValidator {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	// Registration only fails for empty tags or nil functions
+	_ = v.RegisterValidation("cert_hostname", validateCertHostname)
+	_ = v.RegisterValidation("allowed_country", func(fl validator.FieldLevel) bool {
+		return policy.CheckCountry(fl.Field().String()) == nil
+	})
+	_ = v.RegisterValidation("wildcard", func(fl validator.FieldLevel) bool {
+		return policy.CheckWildcardName(fl.Field().String()) == nil
+	})
+	_ = v.RegisterValidation("tags", func(fl validator.FieldLevel) bool {
+		tags, ok := fl.Field().Interface().(map[string]string)
+		return ok && policy.CheckTags(tags) == nil
+	})
+	return &policyValidator{validate: v}
+}
+
+// ValidateStruct validates a struct, a pointer to one or each element of a slice or array, as
+// gin's default validator does; other values are not validated
+func (v *policyValidator) ValidateStruct(obj any) error {
+	if obj == nil {
+		return nil
+	}
+
+	value := reflect.ValueOf(obj)
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.Elem().Kind() != reflect.Struct {
+			return v.ValidateStruct(value.Elem().Interface())
+		}
+		return v.validate.Struct(obj)
+	case reflect.Struct:
+		return v.validate.Struct(obj)
+	case reflect.Slice, reflect.Array:
+		var errs binding.SliceValidationError
+		for i := 0; i < value.Len(); i++ {
+			if err := v.ValidateStruct(value.Index(i).Interface()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+		return errs
+	default:
+		return nil
+	}
+}
+
+// Engine returns the underlying validator engine
+func (v *policyValidator) Engine() any {
+	return v.validate
+}
+
+// validateCertHostname accepts RFC 1123 hostnames with an optional leading wildcard label
+func validateCertHostname(fl validator.FieldLevel) bool {
+	return models.IsHostname(fl.Field().String())
+}
+
+// newValidationError converts a validator field error into its API representation
+func newValidationError(fe validator.FieldError, policy Policy) ValidationError {
+	// Drop the request struct name so nested fields read like JSON paths, e.g. tags[env]
+	field := fe.Namespace()
+	if _, rest, found := strings.Cut(field, "."); found {
+		field = rest
+	}
+
+	return ValidationError{
+		Field:   field,
+		Rule:    fe.Tag(),
+		Message: validationMessage(field, fe, policy),
+	}
+}
+
+// validationMessage returns a human readable description of a failed rule
+func validationMessage(field string, fe validator.FieldError, policy Policy) string {
+	unit := "characters"
+	if kind := fe.Kind(); kind == reflect.Slice || kind == reflect.Map || kind == reflect.Array {
+		unit = "items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "min":
+		return fmt.Sprintf("%s must be at least %s %s", field, fe.Param(), unit)
+	case "max":
+		return fmt.Sprintf("%s must be at most %s %s", field, fe.Param(), unit)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "iso3166_1_alpha2":
+		return fmt.Sprintf("%s must be an uppercase ISO 3166-1 alpha-2 country code, e.g. US", field)
+	case "allowed_country":
+		if len(policy.AllowedCountries) > 0 {
+			return fmt.Sprintf("%s must be one of the allowed countries: %s", field, strings.Join(policy.AllowedCountries, ", "))
+		}
+		return fmt.Sprintf("%s is not an allowed country", field)
+	case "cert_hostname":
+		return fmt.Sprintf("%s must be a valid hostname", field)
+	case "wildcard":
+		value := fmt.Sprint(fe.Value())
+		if err := policy.CheckWildcardName(value); err != nil {
+			return fmt.Sprintf("%s '%s' is invalid: %s", field, value, err)
+		}
+		return fmt.Sprintf("%s '%s' is not an acceptable wildcard name", field, value)
+	case "tags":
+		if tags, ok := fe.Value().(map[string]string); ok {
+			if err := policy.CheckTags(tags); err != nil {
+				return fmt.Sprintf("%s are invalid: %s", field, err)
+			}
+		}
+		return fmt.Sprintf("%s are invalid", field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, fe.Param())
+	default:
+		return fmt.Sprintf("%s failed the '%s' validation", field, fe.Tag())
+	}
+}
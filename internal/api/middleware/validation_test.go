@@ -0,0 +1,353 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/config"
+	"certificate-monkey/internal/models"
+)
+
+// validationResponse mirrors the structured validation error body
+type validationResponse struct {
+	Error   string            `json:"error"`
+	Message string            `json:"message"`
+	Details string            `json:"details"`
+	Errors  []ValidationError `json:"errors"`
+}
+
+// newValidationTestRouter binds CreateKeyRequest the same way the handlers do, under the policy
+func newValidationTestRouter(policy Policy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(ValidationErrors(policy))
+	router.POST("/test", func(c *gin.Context) {
+		var req models.CreateKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Status(http.StatusBadRequest)
+			c.Error(err).SetType(gin.ErrorTypeBind)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+	return router
+}
+
+func postValidation(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestValidationErrorsReportsAllFieldViolations(t *testing.T) {
+	router := newValidationTestRouter(Policy{})
+
+	body := `{
+		"common_name": "not a hostname!",
+		"country": "USA",
+		"email_address": "not-an-email",
+		"organization": "` + strings.Repeat("o", 65) + `",
+		"subject_alternative_names": ["www.example.com", ""]
+	}`
+	w := postValidation(router, body)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response validationResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Bad Request", response.Error)
+	assert.Equal(t, "Request validation failed", response.Message)
+
+	rules := make(map[string]string)
+	for _, fieldErr := range response.Errors {
+		rules[fieldErr.Field] = fieldErr.Rule
+		assert.NotEmpty(t, fieldErr.Message)
+		assert.Contains(t, fieldErr.Message, fieldErr.Field)
+	}
+
+	assert.Equal(t, map[string]string{
+		"common_name":                  "cert_hostname",
+		"country":                      "iso3166_1_alpha2",
+		"email_address":                "email",
+		"organization":                 "max",
+		"subject_alternative_names[1]": "required",
+		"key_type":                     "required",
+	}, rules)
+}
+
+func TestValidationErrorsMessages(t *testing.T) {
+	router := newValidationTestRouter(Policy{})
+
+	w := postValidation(router, `{"key_type": "RSA2048", "common_name": "`+strings.Repeat("a", 65)+`"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response validationResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Errors, 1)
+	assert.Equal(t, ValidationError{
+		Field:   "common_name",
+		Rule:    "max",
+		Message: "common_name must be at most 64 characters",
+	}, response.Errors[0])
+}
+
+func TestValidationErrorsValidRequests(t *testing.T) {
+	router := newValidationTestRouter(Policy{})
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "minimal", body: `{"common_name": "example.com", "key_type": "RSA2048"}`},
+		{name: "wildcard", body: `{"common_name": "*.example.com", "key_type": "RSA2048"}`},
+		{name: "full subject", body: `{"common_name": "api.example.com", "key_type": "ECDSA-P256", "country": "DE", "email_address": "admin@example.com", "subject_alternative_names": ["api.example.com", "10.0.0.1"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postValidation(router, tt.body)
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestValidationErrorsMalformedJSON(t *testing.T) {
+	router := newValidationTestRouter(Policy{})
+
+	w := postValidation(router, `{"common_name": `)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response validationResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Invalid request format", response.Message)
+	assert.NotEmpty(t, response.Details)
+	assert.Empty(t, response.Errors)
+}
+
+func TestValidateCertHostname(t *testing.T) {
+	router := newValidationTestRouter(Policy{})
+
+	tests := []struct {
+		commonName string
+		valid      bool
+	}{
+		{"example.com", true},
+		{"*.example.com", true},
+		{"localhost", true},
+		{"a-b.example.com", true},
+		{"-bad.example.com", false},
+		{"bad-.example.com", false},
+		{"double..dot.com", false},
+		{"*.", false},
+		{"foo.*.example.com", false},
+		{"under_score.example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.commonName, func(t *testing.T) {
+			w := postValidation(router, `{"key_type": "RSA2048", "common_name": "`+tt.commonName+`"}`)
+			if tt.valid {
+				assert.Equal(t, http.StatusOK, w.Code)
+			} else {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestValidateCountry(t *testing.T) {
+	router := newValidationTestRouter(Policy{})
+
+	post := func(country string) (int, validationResponse) {
+		w := postValidation(router, `{"key_type": "RSA2048", "common_name": "example.com", "country": "`+country+`"}`)
+		var response validationResponse
+		if w.Code != http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w.Code, response
+	}
+
+	t.Run("any ISO code without allow-list", func(t *testing.T) {
+		for _, country := range []string{"US", "DE", "JP"} {
+			code, _ := post(country)
+			assert.Equal(t, http.StatusOK, code, country)
+		}
+	})
+
+	for _, country := range []string{"USA", "us", "XX", "U"} {
+		t.Run("invalid "+country, func(t *testing.T) {
+			code, response := post(country)
+			require.Equal(t, http.StatusBadRequest, code)
+			require.Len(t, response.Errors, 1)
+			assert.Equal(t, "country", response.Errors[0].Field)
+			assert.Equal(t, "iso3166_1_alpha2", response.Errors[0].Rule)
+		})
+	}
+
+	t.Run("allow-list", func(t *testing.T) {
+		router = newValidationTestRouter(Policy{AllowedCountries: []string{"US", "DE"}})
+
+		code, _ := post("DE")
+		assert.Equal(t, http.StatusOK, code)
+
+		code, response := post("FR")
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Len(t, response.Errors, 1)
+		assert.Equal(t, ValidationError{
+			Field:   "country",
+			Rule:    "allowed_country",
+			Message: "country must be one of the allowed countries: US, DE",
+		}, response.Errors[0])
+
+		// Invalid codes still report the format rule first
+		_, response = post("USA")
+		assert.Equal(t, "iso3166_1_alpha2", response.Errors[0].Rule)
+	})
+}
+
+func TestValidateWildcard(t *testing.T) {
+	router := newValidationTestRouter(Policy{})
+
+	post := func(body string) (int, validationResponse) {
+		w := postValidation(router, body)
+		var response validationResponse
+		if w.Code != http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w.Code, response
+	}
+	withSAN := func(san string) string {
+		return `{"key_type": "RSA2048", "common_name": "example.com", "subject_alternative_names": ["example.com", "` + san + `"]}`
+	}
+
+	t.Run("valid wildcard", func(t *testing.T) {
+		code, _ := post(withSAN("*.example.com"))
+		assert.Equal(t, http.StatusOK, code)
+
+		code, _ = post(`{"key_type": "RSA2048", "common_name": "*.example.com"}`)
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	invalid := []struct {
+		name    string
+		san     string
+		message string
+	}{
+		{"double wildcard", "*.*.example.com", "subject_alternative_names[1] '*.*.example.com' is invalid: only a single leftmost '*' label is allowed"},
+		{"bare wildcard", "*", "subject_alternative_names[1] '*' is invalid: a bare wildcard is not allowed"},
+		{"partial label wildcard", "web*.example.com", "subject_alternative_names[1] 'web*.example.com' is invalid: only a single leftmost '*' label is allowed"},
+		{"inner wildcard", "api.*.example.com", "subject_alternative_names[1] 'api.*.example.com' is invalid: only a single leftmost '*' label is allowed"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			code, response := post(withSAN(tt.san))
+			require.Equal(t, http.StatusBadRequest, code)
+			require.Len(t, response.Errors, 1)
+			assert.Equal(t, ValidationError{
+				Field:   "subject_alternative_names[1]",
+				Rule:    "wildcard",
+				Message: tt.message,
+			}, response.Errors[0])
+		})
+	}
+
+	t.Run("policy forbids wildcards", func(t *testing.T) {
+		router = newValidationTestRouter(Policy{ForbidWildcards: true})
+
+		code, response := post(withSAN("*.example.com"))
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Len(t, response.Errors, 1)
+		assert.Equal(t, "subject_alternative_names[1] '*.example.com' is invalid: wildcard names are not allowed", response.Errors[0].Message)
+
+		code, response = post(`{"key_type": "RSA2048", "common_name": "*.example.com"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Len(t, response.Errors, 1)
+		assert.Equal(t, "common_name", response.Errors[0].Field)
+		assert.Equal(t, "wildcard", response.Errors[0].Rule)
+
+		code, _ = post(withSAN("www.example.com"))
+		assert.Equal(t, http.StatusOK, code, "Plain names are unaffected by the policy")
+	})
+}
+
+func TestValidateTags(t *testing.T) {
+	router := newValidationTestRouter(Policy{TagLimits: TagLimits{MaxTags: 3, MaxKeyLength: 9, MaxValueLength: 10}})
+
+	withTags := func(tags map[string]string) string {
+		data, err := json.Marshal(map[string]interface{}{"key_type": "RSA2048", "common_name": "example.com", "tags": tags})
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	valid := []map[string]string{
+		nil,
+		{"env": "prod", "team": "platform", "owner": "jdoe"},
+		{"protected": "true"},
+		{"protected": "false"},
+		{"région": "ääääääääää"}, // lengths count characters, not bytes
+	}
+	for _, tags := range valid {
+		w := postValidation(router, withTags(tags))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	invalid := []struct {
+		name    string
+		tags    map[string]string
+		message string
+	}{
+		{"too many tags", map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}, "tags are invalid: at most 3 tags are allowed, got 4"},
+		{"key too long", map[string]string{"environment": "prod"}, "tags are invalid: tag key 'environment' exceeds the maximum length of 9 characters"},
+		{"value too long", map[string]string{"team": "platform-eng"}, "tags are invalid: value of tag 'team' exceeds the maximum length of 10 characters"},
+		{"reserved key", map[string]string{"protected": "yes"}, `tags are invalid: tag 'protected' is reserved and must be "true" or "false"`},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postValidation(router, withTags(tt.tags))
+			require.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response validationResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Len(t, response.Errors, 1)
+			assert.Equal(t, ValidationError{Field: "tags", Rule: "tags", Message: tt.message}, response.Errors[0])
+		})
+	}
+
+	t.Run("unset limits", func(t *testing.T) {
+		tags := make(map[string]string)
+		for i := 0; i < 100; i++ {
+			tags[strings.Repeat("k", i+1)] = strings.Repeat("v", 300)
+		}
+		assert.NoError(t, Policy{}.CheckTags(tags))
+		assert.Error(t, Policy{}.CheckTags(map[string]string{"protected": "TRUE"}), "The reserved key is checked without limits")
+	})
+}
+
+func TestNewPolicy(t *testing.T) {
+	policy := NewPolicy(config.CertificateConfig{
+		AllowedCountries:  []string{"NL", "BE"},
+		AllowWildcards:    false,
+		MaxTags:           5,
+		MaxTagKeyLength:   16,
+		MaxTagValueLength: 32,
+	})
+	assert.Equal(t, Policy{
+		AllowedCountries: []string{"NL", "BE"},
+		ForbidWildcards:  true,
+		TagLimits:        TagLimits{MaxTags: 5, MaxKeyLength: 16, MaxValueLength: 32},
+	}, policy)
+
+	assert.Error(t, policy.CheckCountry("DE"))
+	assert.NoError(t, policy.CheckCountry("BE"))
+	assert.Error(t, policy.CheckWildcardName("*.example.com"))
+	assert.NoError(t, NewPolicy(config.CertificateConfig{AllowWildcards: true}).CheckWildcardName("*.example.com"))
+}
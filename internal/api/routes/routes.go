@@ -2,9 +2,14 @@ package routes
 
 import (
 	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	mathrand "math/rand/v2"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -13,16 +18,24 @@ import (
 
 	"certificate-monkey/internal/api/handlers"
 	"certificate-monkey/internal/api/middleware"
+	"certificate-monkey/internal/audit"
 	"certificate-monkey/internal/config"
 	"certificate-monkey/internal/crypto"
+	"certificate-monkey/internal/notify"
 	"certificate-monkey/internal/storage"
 )
 
-// SetupRoutes configures all API routes
+// SetupRoutes configures all API routes; ACME issuance is only routed when issuer is non-nil
+// and the event log only when events is non-nil. Audit events go to auditSink and certificate
+// notifications to notifier, when non-nil.
 func SetupRoutes(
 	cfg *config.Config,
 	storage *storage.DynamoDBStorage,
+	events *storage.EventStore,
+	auditSink audit.Sink,
+	notifier notify.Notifier,
 	cryptoService *crypto.CryptoService,
+	issuer handlers.CertificateIssuer,
 	logger *logrus.Logger,
 ) *gin.Engine {
 	// Set Gin mode
@@ -32,17 +45,30 @@ func SetupRoutes(
 		gin.SetMode(gin.DebugMode)
 	}
 
-	// Create Gin router
+	// Create Gin router; a known path with an unsupported method gets a 405 rather than a 404
 	router := gin.New()
+	router.HandleMethodNotAllowed = true
+
+	// Only trusted proxies may name the client in X-Forwarded-For; gin trusts every proxy by
+	// default, which would let any caller spoof the IP recorded in audit logs
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.WithError(err).Error("Invalid trusted proxies; client IPs are taken from the connection")
+		_ = router.SetTrustedProxies(nil)
+	}
 
 	// Add middleware
-	router.Use(gin.Logger())
+	router.Use(gin.LoggerWithFormatter(accessLogFormatter))
 	router.Use(gin.Recovery())
-	router.Use(corsMiddleware())
+	router.Use(corsMiddleware(cfg.CORS, cfg.Security.APIKeyHeader))
 	router.Use(requestIDMiddleware())
+	// Oversized headers and malformed credentials are turned away before authentication
+	router.Use(middleware.HeaderGuard(cfg.Server.MaxHeaderBytes, credentialHeaders(cfg.Security)...))
+	// Probes must keep answering while the service sheds load
+	router.Use(middleware.MaxInflightRequests(cfg.Server.MaxInflightRequests, "/health", "/health/aws", "/api/v1/ready"))
 
 	// Create health handler
 	healthHandler := handlers.NewHealthHandler(storage, logger)
+	healthHandler.SetRegion(cfg.AWS.Region)
 
 	// Health check endpoints (no auth required)
 	router.GET("/health", healthHandler.BasicHealth)
@@ -54,21 +80,102 @@ func SetupRoutes(
 	// API version 1 routes
 	v1 := router.Group("/api/v1")
 
-	// Apply authentication middleware to all v1 routes
+	// Readiness with dependency details; authenticated since it names the table and KMS key. It is
+	// registered ahead of the client certificate requirement so probes can use an API key alone.
+	readiness := []gin.HandlerFunc{middleware.AuthMiddleware(cfg, logger), healthHandler.Readiness}
+	if cfg.TLS.MTLSEnabled() {
+		readiness = append([]gin.HandlerFunc{middleware.OptionalClientCertMiddleware(cfg, logger)}, readiness...)
+	}
+	v1.GET("/ready", readiness...) // GET /api/v1/ready
+
+	// Apply authentication middleware to all other v1 routes; with mTLS the TLS handshake accepts
+	// clients without a certificate, so it is required here
+	if cfg.TLS.MTLSEnabled() {
+		v1.Use(middleware.ClientCertMiddleware(cfg, logger))
+	}
 	v1.Use(middleware.AuthMiddleware(cfg, logger))
+	policy := middleware.NewPolicy(cfg.Certificates)
+	v1.Use(middleware.ValidationErrors(policy))
+	v1.Use(decryptCacheMiddleware())
 
 	// Create handlers
 	certHandler := handlers.NewCertificateHandler(storage, cryptoService, logger)
+	certHandler.SetRequiredExtKeyUsages(cfg.Certificates.RequiredExtKeyUsages)
+	certHandler.SetAllowedIssuers(cfg.Certificates.AllowedIssuers)
+	certHandler.SetSANPolicy(cfg.Certificates.RequireDNSSAN, cfg.Certificates.RequireCNInSANs, cfg.Certificates.EnforceSANPolicy)
+	certHandler.SetAllowedKeyTypes(cfg.Certificates.AllowedKeyTypes)
+	certHandler.SetValidityPolicy(cfg.Certificates.MaxValidity, cfg.Certificates.MaxClockSkew, cfg.Certificates.EnforceValidity)
+	certHandler.SetEventStore(events)
+	certHandler.SetAuditSink(auditSink)
+	certHandler.SetMaxConcurrentPFX(cfg.Certificates.MaxConcurrentPFX)
+	certHandler.SetDeniedNames(cfg.Certificates.DeniedNames)
+
+	certHandler.SetNotifier(notifier)
 
 	// Certificate management endpoints
 	keys := v1.Group("/keys")
+	keys.Use(middleware.RequireContentType("application/json"))
+	{
+		keys.POST("", certHandler.CreateKey)                            // POST /api/v1/keys
+		keys.POST("/external", certHandler.CreateExternalKey)           // POST /api/v1/keys/external
+		keys.POST("/bulk-delete", certHandler.BulkDelete)               // POST /api/v1/keys/bulk-delete
+		keys.POST("/batch-upload", certHandler.BatchUploadCertificates) // POST /api/v1/keys/batch-upload
+		keys.GET("", certHandler.ListCertificates)                      // GET /api/v1/keys
+		keys.GET("/stale", certHandler.GetStaleCertificates)            // GET /api/v1/keys/stale
+		keys.GET("/:id", certHandler.GetCertificate)                    // GET /api/v1/keys/{id}
+		keys.PATCH("/:id", certHandler.UpdateMetadata)                  // PATCH /api/v1/keys/{id}
+		keys.DELETE("/:id", certHandler.DeleteCertificate)              // DELETE /api/v1/keys/{id}
+		keys.GET("/:id/private-key", certHandler.ExportPrivateKey)      // GET /api/v1/keys/{id}/private-key
+		keys.GET("/:id/verify-key", certHandler.VerifyPrivateKey)       // GET /api/v1/keys/{id}/verify-key
+		keys.GET("/:id/csr", certHandler.GetCSR)                        // GET /api/v1/keys/{id}/csr
+		keys.GET("/:id/history", certHandler.GetStatusHistory)          // GET /api/v1/keys/{id}/history
+		keys.PUT("/:id/certificate", certHandler.UploadCertificate)     // PUT /api/v1/keys/{id}/certificate
+		keys.GET("/:id/certificate", certHandler.DownloadCertificate)   // GET /api/v1/keys/{id}/certificate
+		keys.GET("/:id/chain", certHandler.GetCertificateChain)         // GET /api/v1/keys/{id}/chain
+		keys.GET("/:id/expiry", certHandler.GetCertificateExpiry)       // GET /api/v1/keys/{id}/expiry
+		keys.POST("/:id/pfx", certHandler.GeneratePFX)                  // POST /api/v1/keys/{id}/pfx
+		keys.POST("/:id/keystore", certHandler.GenerateKeystore)        // POST /api/v1/keys/{id}/keystore
+		keys.POST("/:id/regenerate-csr", certHandler.RegenerateCSR)     // POST /api/v1/keys/{id}/regenerate-csr
+		keys.POST("/:id/transfer", certHandler.TransferOwnership)       // POST /api/v1/keys/{id}/transfer
+		keys.POST("/:id/claim", certHandler.ClaimCertificate)           // POST /api/v1/keys/{id}/claim
+		keys.DELETE("/:id/claim", certHandler.ReleaseClaim)             // DELETE /api/v1/keys/{id}/claim
+		keys.POST("/:id/touch", certHandler.TouchCertificate)           // POST /api/v1/keys/{id}/touch
+	}
+
+	// Optional ACME issuance
+	if issuer != nil {
+		acmeHandler := handlers.NewACMEHandler(storage, cryptoService, issuer, cfg.ACME.Timeout, logger)
+		acmeHandler.SetAuditSink(auditSink)
+		acmeHandler.SetNotifier(notifier)
+		keys.POST("/:id/acme", acmeHandler.IssueCertificate) // POST /api/v1/keys/{id}/acme
+	}
+
+	// Optional durable event log
+	if events != nil {
+		keys.GET("/:id/events", certHandler.GetEvents) // GET /api/v1/keys/{id}/events
+	}
+
+	// Stateless tools; submitted material is never stored
+	toolsHandler := handlers.NewToolsHandler(cryptoService, logger)
+	tools := v1.Group("/tools")
+	tools.Use(middleware.RequireContentType("application/json"))
+	{
+		tools.POST("/inspect-certificate", toolsHandler.InspectCertificate) // POST /api/v1/tools/inspect-certificate
+		tools.POST("/inspect-csr", toolsHandler.InspectCSR)                 // POST /api/v1/tools/inspect-csr
+		tools.POST("/match", toolsHandler.MatchKey)                         // POST /api/v1/tools/match
+	}
+
+	// Administrative endpoints (admin scope required)
+	adminHandler := handlers.NewAdminHandler(storage, cryptoService, logger)
+	adminHandler.SetPolicy(policy, cfg.Certificates.DeniedNames)
+	admin := v1.Group("/admin")
+	admin.Use(middleware.RequireScope(middleware.ScopeAdmin, logger))
+	// Backup archives are uploaded as raw bytes rather than JSON
+	octetStream := middleware.RequireContentType("application/octet-stream")
 	{
-		keys.POST("", certHandler.CreateKey)                        // POST /api/v1/keys
-		keys.GET("", certHandler.ListCertificates)                  // GET /api/v1/keys
-		keys.GET("/:id", certHandler.GetCertificate)                // GET /api/v1/keys/{id}
-		keys.GET("/:id/private-key", certHandler.ExportPrivateKey)  // GET /api/v1/keys/{id}/private-key
-		keys.PUT("/:id/certificate", certHandler.UploadCertificate) // PUT /api/v1/keys/{id}/certificate
-		keys.POST("/:id/pfx", certHandler.GeneratePFX)              // POST /api/v1/keys/{id}/pfx
+		admin.GET("/export", adminHandler.ExportBackup)               // GET /api/v1/admin/export
+		admin.POST("/import", octetStream, adminHandler.ImportBackup) // POST /api/v1/admin/import
+		admin.POST("/rekey", adminHandler.RekeyEntities)              // POST /api/v1/admin/rekey
 	}
 
 	// Add a catch-all route for undefined endpoints
@@ -80,16 +187,78 @@ func SetupRoutes(
 		})
 	})
 
+	// Gin sets the Allow header to the methods the path supports before calling this handler
+	router.NoMethod(func(c *gin.Context) {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{
+			"error":           "Method Not Allowed",
+			"message":         fmt.Sprintf("The %s method is not supported for this endpoint", c.Request.Method),
+			"path":            c.Request.URL.Path,
+			"allowed_methods": c.Writer.Header().Get("Allow"),
+		})
+	})
+
 	return router
 }
 
-// corsMiddleware adds CORS headers
-func corsMiddleware() gin.HandlerFunc {
+// accessLogFormatter renders gin's default access log line, followed by the API key name when the key has one
+func accessLogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+
+	keyName := ""
+	if name, ok := param.Keys["api_key_name"].(string); ok && name != "" {
+		keyName = " | key=" + name
+	}
+
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v%s\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		param.Path,
+		keyName,
+		param.ErrorMessage,
+	)
+}
+
+// corsMiddleware adds CORS headers and answers preflight requests. With explicit origins only an
+// allowed request origin is echoed back, so credentials are never granted to other sites.
+// A configured API key header is allowed alongside X-API-Key.
+func corsMiddleware(cors config.CORSConfig, apiKeyHeader string) gin.HandlerFunc {
+	allowedOrigins := make(map[string]bool, len(cors.AllowedOrigins))
+	for _, origin := range cors.AllowedOrigins {
+		allowedOrigins[origin] = true
+	}
+	allowHeaders := "Content-Type, Authorization, X-API-Key"
+	if apiKeyHeader != "" {
+		allowHeaders += ", " + apiKeyHeader
+	}
+
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
-		c.Header("Access-Control-Max-Age", "3600")
+		if allowedOrigins["*"] {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			// The response depends on the origin, so caches must not share it between origins
+			c.Writer.Header().Add("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); allowedOrigins[origin] {
+				c.Header("Access-Control-Allow-Origin", origin)
+				if cors.AllowCredentials {
+					c.Header("Access-Control-Allow-Credentials", "true")
+				}
+			}
+		}
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", allowHeaders)
+		c.Header("Access-Control-Max-Age", strconv.Itoa(int(preflightMaxAge(cors, c.Request.URL.Path).Seconds())))
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -100,6 +269,27 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// credentialHeaders lists the headers an API key may be sent in
+func credentialHeaders(security config.SecurityConfig) []string {
+	headers := []string{"X-API-Key", "Authorization"}
+	if security.APIKeyHeader != "" {
+		headers = append(headers, security.APIKeyHeader)
+	}
+	return headers
+}
+
+// preflightMaxAge returns how long a preflight for path may be cached: the override of the longest
+// matching RouteMaxAge prefix, or MaxAge
+func preflightMaxAge(cors config.CORSConfig, path string) time.Duration {
+	maxAge, longest := cors.MaxAge, -1
+	for prefix, routeMaxAge := range cors.RouteMaxAge {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			maxAge, longest = routeMaxAge, len(prefix)
+		}
+	}
+	return maxAge
+}
+
 // requestIDMiddleware adds a unique request ID to each request
 func requestIDMiddleware() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
@@ -113,13 +303,28 @@ func requestIDMiddleware() gin.HandlerFunc {
 	})
 }
 
-// generateRequestID generates a simple request ID
+// decryptCacheMiddleware lets a request decrypt each KMS ciphertext once, however often it reads
+// the same entity; the plaintexts are dropped as soon as the request has been handled
+func decryptCacheMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, clearCache := storage.WithDecryptCache(c.Request.Context())
+		defer clearCache()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// readRandom fills request ID bytes; tests replace it to exercise the fallback
+var readRandom = rand.Read
+
+// generateRequestID returns "req_" followed by 16 random bytes in hex. With 128 bits of entropy
+// collisions are negligible at any request volume.
 func generateRequestID() string {
-	// Simple implementation - in production you might want to use UUID
-	b := make([]byte, 4)
-	if _, err := rand.Read(b); err != nil {
-		// Fallback to timestamp-based ID if crypto/rand fails
-		return fmt.Sprintf("req_%d", 12345678)
+	b := make([]byte, 16)
+	if _, err := readRandom(b); err != nil {
+		// crypto/rand practically never fails; fall back to the runtime's generator rather than a fixed ID
+		binary.BigEndian.PutUint64(b[:8], mathrand.Uint64())
+		binary.BigEndian.PutUint64(b[8:], mathrand.Uint64())
 	}
-	return fmt.Sprintf("req_%x", b)
+	return "req_" + hex.EncodeToString(b)
 }
@@ -1,12 +1,16 @@
 package routes
 
 import (
+	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -34,8 +38,8 @@ func (m *MockStorage) UpdateCertificateEntity(ctx interface{}, entity interface{
 	return nil
 }
 
-func (m *MockStorage) ListCertificateEntities(ctx interface{}, filters interface{}) (interface{}, error) {
-	return nil, nil
+func (m *MockStorage) ListCertificateEntities(ctx interface{}, filters interface{}) (interface{}, interface{}, error) {
+	return nil, nil, nil
 }
 
 type MockCrypto struct{}
@@ -48,7 +52,7 @@ func (m *MockCrypto) ParseCertificate(certPEM string) (interface{}, error) {
 	return nil, nil
 }
 
-func (m *MockCrypto) GenerateCertificateFingerprint(certPEM string) (string, error) {
+func (m *MockCrypto) GenerateCertificateFingerprint(certPEM string, algorithm string) (string, error) {
 	return "", nil
 }
 
@@ -56,7 +60,7 @@ func (m *MockCrypto) ValidateCertificateWithCSR(certPEM, csrPEM string) error {
 	return nil
 }
 
-func (m *MockCrypto) GeneratePFX(privateKeyPEM, certificatePEM, password string) ([]byte, error) {
+func (m *MockCrypto) GeneratePFX(privateKeyPEM, certificatePEM, password string, iterations int) ([]byte, error) {
 	return nil, nil
 }
 
@@ -88,7 +92,7 @@ func TestSetupRoutes(t *testing.T) {
 
 	// This should not panic
 	assert.NotPanics(t, func() {
-		router := SetupRoutes(cfg, storage, cryptoService, logger)
+		router := SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, nil, logger)
 		assert.NotNil(t, router)
 	})
 }
@@ -112,7 +116,7 @@ func TestHealthEndpoint(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
 
-	router := SetupRoutes(cfg, storage, cryptoService, logger)
+	router := SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, nil, logger)
 
 	// Test health endpoint
 	req := httptest.NewRequest("GET", "/health", nil)
@@ -132,12 +136,46 @@ func TestHealthEndpoint(t *testing.T) {
 	assert.Equal(t, expectedVersion, response["version"])
 }
 
+// Test with mTLS enabled the API requires a client certificate while probes work without one
+func TestMTLSRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Security: config.SecurityConfig{APIKeys: []string{"test_key"}},
+		TLS: config.TLSConfig{
+			CertPath:     "/tls/server.pem",
+			KeyPath:      "/tls/server-key.pem",
+			ClientCAPath: "/tls/client-ca.pem",
+		},
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	router := SetupRoutes(cfg, &storage.DynamoDBStorage{}, nil, nil, nil, crypto.NewCryptoService(), nil, logger)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/health").Code)
+
+	w := serve("/api/v1/keys")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "client certificate is required")
+
+	// Readiness falls through to API key authentication instead of requiring a certificate
+	w = serve("/api/v1/ready")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "API key is required")
+}
+
 // Test CORS middleware
 func TestCorsMiddleware(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	router := gin.New()
-	router.Use(corsMiddleware())
+	router.Use(corsMiddleware(config.CORSConfig{AllowedOrigins: []string{"*"}, MaxAge: time.Hour}, ""))
 	router.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "test"})
 	})
@@ -169,13 +207,109 @@ func TestCorsMiddleware(t *testing.T) {
 
 			// Check CORS headers
 			assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
-			assert.Equal(t, "GET, POST, PUT, DELETE, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+			assert.Equal(t, "GET, POST, PUT, PATCH, DELETE, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
 			assert.Equal(t, "Content-Type, Authorization, X-API-Key", w.Header().Get("Access-Control-Allow-Headers"))
 			assert.Equal(t, "3600", w.Header().Get("Access-Control-Max-Age"))
 		})
 	}
 }
 
+// TestCorsMiddlewareCredentials tests credentials are only granted to allowed origins and preflight caching follows the route
+func TestCorsMiddlewareCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(corsMiddleware(config.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+		RouteMaxAge: map[string]time.Duration{
+			"/api/v1/keys":       10 * time.Minute,
+			"/api/v1/keys/batch": 0,
+		},
+	}, "X-Client-Token"))
+	router.GET("/api/v1/keys", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "test"})
+	})
+
+	t.Run("allowed origin", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/keys", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+		assert.Equal(t, "Content-Type, Authorization, X-API-Key, X-Client-Token", w.Header().Get("Access-Control-Allow-Headers"))
+	})
+
+	t.Run("other origin", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/api/v1/keys", nil)
+		req.Header.Set("Origin", "https://evil.example.net")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	for path, maxAge := range map[string]string{
+		"/api/v1/keys/some-id":     "600",
+		"/api/v1/keys/batch/items": "0",
+		"/api/v1/tools/match":      "3600",
+	} {
+		t.Run("preflight "+path, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", path, nil)
+			req.Header.Set("Origin", "https://app.example.com")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, maxAge, w.Header().Get("Access-Control-Max-Age"))
+		})
+	}
+}
+
+// TestTrustedProxies tests X-Forwarded-For names the client only when the connection comes from a trusted proxy
+func TestTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	clientIP := func(trustedProxies []string, remoteAddr string) string {
+		cfg := &config.Config{
+			Server: config.ServerConfig{
+				Host:           "localhost",
+				Port:           "8080",
+				TrustedProxies: trustedProxies,
+			},
+			Security: config.SecurityConfig{
+				APIKeys: []string{"test_key"},
+			},
+		}
+		router := SetupRoutes(cfg, &storage.DynamoDBStorage{}, nil, nil, nil, crypto.NewCryptoService(), nil, logger)
+		router.GET("/client-ip", func(c *gin.Context) {
+			c.String(http.StatusOK, c.ClientIP())
+		})
+
+		req := httptest.NewRequest("GET", "/client-ip", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	assert.Equal(t, "203.0.113.7", clientIP([]string{"10.0.0.0/8"}, "10.1.2.3:41000"), "A trusted load balancer forwards the client")
+	assert.Equal(t, "198.51.100.9", clientIP([]string{"10.0.0.0/8"}, "198.51.100.9:41000"), "Other callers cannot spoof the client")
+	assert.Equal(t, "10.1.2.3", clientIP(nil, "10.1.2.3:41000"), "No proxy is trusted by default")
+}
+
 // Test request ID middleware
 func TestRequestIDMiddleware(t *testing.T) {
 	gin.SetMode(gin.TestMode)
@@ -226,27 +360,62 @@ func TestRequestIDMiddleware(t *testing.T) {
 	})
 }
 
+// Test that the access log names the API key when it has a name
+func TestAccessLogFormatter(t *testing.T) {
+	param := gin.LogFormatterParams{
+		TimeStamp:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		StatusCode: http.StatusOK,
+		ClientIP:   "127.0.0.1",
+		Method:     "GET",
+		Path:       "/api/v1/keys",
+	}
+
+	line := accessLogFormatter(param)
+	assert.Contains(t, line, `"/api/v1/keys"`)
+	assert.NotContains(t, line, "key=")
+
+	param.Keys = map[string]any{"api_key_name": "ci-pipeline"}
+	line = accessLogFormatter(param)
+	assert.Contains(t, line, `"/api/v1/keys" | key=ci-pipeline`)
+	assert.True(t, strings.HasSuffix(line, "\n"))
+}
+
 // Test that generateRequestID produces valid IDs
 func TestGenerateRequestID(t *testing.T) {
 	// Pre-compile the regex for better performance
-	requestIDPattern := regexp.MustCompile(`^req_[a-f0-9]{8}$`)
+	requestIDPattern := regexp.MustCompile(`^req_[a-f0-9]{32}$`)
 
-	requestIDs := make(map[string]bool)
+	// A large sample would very likely collide with the former 32-bit IDs
+	const samples = 200000
+	requestIDs := make(map[string]bool, samples)
 
-	// Generate multiple IDs to test uniqueness and format
-	for i := 0; i < 100; i++ {
+	for i := 0; i < samples; i++ {
 		id := generateRequestID()
 
-		// Basic format checks
-		assert.True(t, strings.HasPrefix(id, "req_"))
-		assert.False(t, requestIDs[id], "Request ID should be unique: %s", id)
+		if requestIDs[id] {
+			t.Fatalf("Request ID should be unique: %s", id)
+		}
 		requestIDs[id] = true
 
-		// Check format: req_ followed by 8 hex characters
-		assert.True(t, requestIDPattern.MatchString(id), "Request ID format should be req_[8hexchars]: %s", id)
+		// Check format: req_ followed by 32 hex characters
+		if !requestIDPattern.MatchString(id) {
+			t.Fatalf("Request ID format should be req_[32hexchars]: %s", id)
+		}
 	}
 }
 
+// TestGenerateRequestIDFallback tests IDs stay random when crypto/rand fails
+func TestGenerateRequestIDFallback(t *testing.T) {
+	readRandom = func([]byte) (int, error) {
+		return 0, errors.New("entropy unavailable")
+	}
+	defer func() { readRandom = rand.Read }()
+
+	first, second := generateRequestID(), generateRequestID()
+	assert.Regexp(t, `^req_[a-f0-9]{32}$`, first)
+	assert.NotEqual(t, first, second)
+}
+
 // Test protected routes require authentication
 func TestProtectedRoutes(t *testing.T) {
 	gin.SetMode(gin.TestMode)
@@ -266,7 +435,7 @@ func TestProtectedRoutes(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
 
-	router := SetupRoutes(cfg, storage, cryptoService, logger)
+	router := SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, nil, logger)
 
 	protectedEndpoints := []struct {
 		method string
@@ -276,8 +445,10 @@ func TestProtectedRoutes(t *testing.T) {
 		{"POST", "/api/v1/keys"},
 		{"GET", "/api/v1/keys/test-id"},
 		{"GET", "/api/v1/keys/test-id/private-key"},
+		{"GET", "/api/v1/keys/test-id/verify-key"},
 		{"PUT", "/api/v1/keys/test-id/certificate"},
 		{"POST", "/api/v1/keys/test-id/pfx"},
+		{"POST", "/api/v1/keys/test-id/keystore"},
 	}
 
 	for _, endpoint := range protectedEndpoints {
@@ -319,7 +490,7 @@ func TestNoRouteHandler(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
 
-	router := SetupRoutes(cfg, storage, cryptoService, logger)
+	router := SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, nil, logger)
 
 	testPaths := []string{
 		"/nonexistent",
@@ -347,6 +518,67 @@ func TestNoRouteHandler(t *testing.T) {
 	}
 }
 
+// Test NoMethod handler returns 405 for defined paths with an undefined method
+func TestNoMethodHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Host: "localhost",
+			Port: "8080",
+		},
+		Security: config.SecurityConfig{
+			APIKeys: []string{"test_key"},
+		},
+	}
+
+	storage := &storage.DynamoDBStorage{}
+	cryptoService := crypto.NewCryptoService()
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	router := SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, nil, logger)
+
+	testCases := []struct {
+		method  string
+		path    string
+		allowed []string
+	}{
+		{"DELETE", "/api/v1/keys", []string{"GET", "POST"}},
+		{"PATCH", "/api/v1/keys/some-id/certificate", []string{"GET", "PUT"}},
+		{"GET", "/api/v1/keys/some-id/pfx", []string{"POST"}},
+		{"POST", "/health", []string{"GET"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.method+"_"+tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+			allowHeader := w.Header().Get("Allow")
+			allowed := strings.Split(allowHeader, ", ")
+			assert.ElementsMatch(t, tc.allowed, allowed)
+
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			require.NoError(t, err)
+
+			assert.Equal(t, "Method Not Allowed", response["error"])
+			assert.Equal(t, tc.path, response["path"])
+			assert.Equal(t, allowHeader, response["allowed_methods"])
+		})
+	}
+
+	// Unknown paths are still reported as not found
+	req := httptest.NewRequest("DELETE", "/api/v1/nonexistent", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
 // Test Gin mode setting based on configuration
 func TestGinModeConfiguration(t *testing.T) {
 	originalMode := gin.Mode()
@@ -391,7 +623,7 @@ func TestGinModeConfiguration(t *testing.T) {
 			logger := logrus.New()
 			logger.SetLevel(logrus.ErrorLevel)
 
-			SetupRoutes(cfg, storage, cryptoService, logger)
+			SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, nil, logger)
 			assert.Equal(t, tt.expectedMode, gin.Mode())
 		})
 	}
@@ -416,7 +648,7 @@ func TestRouteGrouping(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
 
-	router := SetupRoutes(cfg, storage, cryptoService, logger)
+	router := SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, nil, logger)
 
 	// Test that all expected routes are properly grouped under /api/v1/keys
 	keyRoutes := []struct {
@@ -427,8 +659,10 @@ func TestRouteGrouping(t *testing.T) {
 		{"GET", "/api/v1/keys"},
 		{"GET", "/api/v1/keys/test-id"},
 		{"GET", "/api/v1/keys/test-id/private-key"},
+		{"GET", "/api/v1/keys/test-id/verify-key"},
 		{"PUT", "/api/v1/keys/test-id/certificate"},
 		{"POST", "/api/v1/keys/test-id/pfx"},
+		{"POST", "/api/v1/keys/test-id/keystore"},
 	}
 
 	for _, route := range keyRoutes {
@@ -445,6 +679,78 @@ func TestRouteGrouping(t *testing.T) {
 	}
 }
 
+// stubIssuer satisfies handlers.CertificateIssuer without contacting a CA
+type stubIssuer struct{}
+
+func (stubIssuer) Issue(ctx context.Context, csrPEM string) (string, string, error) {
+	return "", "", nil
+}
+
+// TestACMERouteRequiresIssuer tests the ACME endpoint only exists when an issuer is configured
+func TestACMERouteRequiresIssuer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Host: "localhost",
+			Port: "8080",
+		},
+		Security: config.SecurityConfig{
+			APIKeys: []string{"valid_key"},
+		},
+	}
+
+	storage := &storage.DynamoDBStorage{}
+	cryptoService := crypto.NewCryptoService()
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	// Authenticated, so a missing route is a 404 rather than a 401
+	req := httptest.NewRequest("POST", "/api/v1/keys/test-id/acme", nil)
+	req.Header.Set("X-API-Key", "valid_key")
+	w := httptest.NewRecorder()
+	SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, nil, logger).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest("POST", "/api/v1/keys/test-id/acme", nil)
+	w = httptest.NewRecorder()
+	SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, stubIssuer{}, logger).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// TestEventsRouteRequiresEventStore tests the event log endpoint only exists when an events table is configured
+func TestEventsRouteRequiresEventStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Host: "localhost",
+			Port: "8080",
+		},
+		Security: config.SecurityConfig{
+			APIKeys: []string{"valid_key"},
+		},
+	}
+
+	events := storage.NewEventStore(nil, "test-events", logrus.New())
+	storage := &storage.DynamoDBStorage{}
+	cryptoService := crypto.NewCryptoService()
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	// Authenticated, so a missing route is a 404 rather than a 401
+	req := httptest.NewRequest("GET", "/api/v1/keys/test-id/events", nil)
+	req.Header.Set("X-API-Key", "valid_key")
+	w := httptest.NewRecorder()
+	SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, nil, logger).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest("GET", "/api/v1/keys/test-id/events", nil)
+	w = httptest.NewRecorder()
+	SetupRoutes(cfg, storage, events, nil, nil, cryptoService, nil, logger).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
 // Benchmark route setup
 func BenchmarkSetupRoutes(b *testing.B) {
 	cfg := &config.Config{
@@ -464,7 +770,7 @@ func BenchmarkSetupRoutes(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		router := SetupRoutes(cfg, storage, cryptoService, logger)
+		router := SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, nil, logger)
 		_ = router // Avoid unused variable
 	}
 }
@@ -488,7 +794,7 @@ func BenchmarkHealthEndpoint(b *testing.B) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
 
-	router := SetupRoutes(cfg, storage, cryptoService, logger)
+	router := SetupRoutes(cfg, storage, nil, nil, nil, cryptoService, nil, logger)
 
 	req := httptest.NewRequest("GET", "/health", nil)
 
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"certificate-monkey/internal/models"
+)
+
+// Supported audit sinks, selected with AUDIT_SINK
+const (
+	SinkStdout   = "stdout"
+	SinkFile     = "file"
+	SinkDynamoDB = "dynamodb"
+)
+
+// Sink receives the audit events of certificate entities: key creation, certificate uploads,
+// exports, transfers and deletions. storage.EventStore is the DynamoDB sink.
+type Sink interface {
+	RecordEvent(ctx context.Context, event *models.Event) error
+}
+
+// NewSink creates the stdout or file sink; file events go to filePath, which is rotated once it
+// would exceed maxFileSize bytes. The DynamoDB sink is the event store and is not created here.
+func NewSink(kind, filePath string, maxFileSize int64) (Sink, error) {
+	switch kind {
+	case SinkStdout:
+		return NewWriterSink(os.Stdout), nil
+	case SinkFile:
+		return NewFileSink(filePath, maxFileSize)
+	default:
+		return nil, fmt.Errorf("unsupported audit sink %q", kind)
+	}
+}
+
+// WriterSink writes each event as a JSON line to a writer such as stdout
+type WriterSink struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewWriterSink creates a sink writing JSON lines to out
+func NewWriterSink(out io.Writer) *WriterSink {
+	return &WriterSink{out: out}
+}
+
+// RecordEvent writes the event, stamping it with the current time when it has none
+func (s *WriterSink) RecordEvent(_ context.Context, event *models.Event) error {
+	line, err := marshalEvent(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.out.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit event: %w", err)
+	}
+	return nil
+}
+
+// marshalEvent stamps an event like the event store does and encodes it as one JSON line
+func marshalEvent(event *models.Event) ([]byte, error) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	event.Timestamp = event.Timestamp.UTC()
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit event: %w", err)
+	}
+	return append(line, '\n'), nil
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/models"
+)
+
+// sampleEvent returns a private key export event as the handlers record it
+func sampleEvent() *models.Event {
+	return &models.Event{
+		EntityID:  "550e8400-e29b-41d4-a716-446655440000",
+		Type:      models.EventPrivateKeyExported,
+		Actor:     "ci-pipeline",
+		RequestID: "req_1",
+	}
+}
+
+// TestNewSink tests sinks are selected by name and unknown sinks are rejected
+func TestNewSink(t *testing.T) {
+	sink, err := NewSink(SinkStdout, "", 0)
+	require.NoError(t, err)
+	assert.IsType(t, &WriterSink{}, sink)
+
+	sink, err = NewSink(SinkFile, filepath.Join(t.TempDir(), "audit.log"), 1024)
+	require.NoError(t, err)
+	assert.IsType(t, &FileSink{}, sink)
+	require.NoError(t, sink.(*FileSink).Close())
+
+	_, err = NewSink(SinkFile, "", 0)
+	assert.Error(t, err)
+	_, err = NewSink(SinkDynamoDB, "", 0)
+	assert.Error(t, err)
+	_, err = NewSink("syslog", "", 0)
+	assert.Error(t, err)
+}
+
+// TestWriterSink tests each event is written as one JSON line stamped in UTC
+func TestWriterSink(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewWriterSink(&out)
+
+	event := sampleEvent()
+	event.Timestamp = time.Date(2024, 1, 15, 11, 30, 0, 0, time.FixedZone("CET", 3600))
+	require.NoError(t, sink.RecordEvent(context.Background(), event))
+	require.NoError(t, sink.RecordEvent(context.Background(), sampleEvent()))
+
+	lines := bytes.Split(bytes.TrimSuffix(out.Bytes(), []byte("\n")), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{
+		"entity_id": "550e8400-e29b-41d4-a716-446655440000",
+		"timestamp": "2024-01-15T10:30:00Z",
+		"type": "PRIVATE_KEY_EXPORTED",
+		"actor": "ci-pipeline",
+		"request_id": "req_1"
+	}`, string(lines[0]))
+	assert.Contains(t, string(lines[1]), `"type":"PRIVATE_KEY_EXPORTED"`)
+	assert.NotContains(t, string(lines[1]), `"timestamp":"0001-01-01T00:00:00Z"`, "Events without a time are stamped")
+}
@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"certificate-monkey/internal/models"
+)
+
+// rotatedSuffixFormat names rotated files so they sort in the order they were written
+const rotatedSuffixFormat = "20060102T150405.000000000Z"
+
+// FileSink appends events as JSON lines to a file. Once a write would take the file past
+// maxSize bytes it is renamed with a timestamp suffix and a new file is started; rotated files
+// are kept, so removing old audit logs is left to the operator.
+type FileSink struct {
+	path    string
+	maxSize int64
+	now     func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileSink opens path for appending, creating it when missing; a maxSize of zero never rotates
+func NewFileSink(path string, maxSize int64) (*FileSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit file sink requires a file path")
+	}
+	if maxSize < 0 {
+		return nil, fmt.Errorf("audit file max size must not be negative")
+	}
+
+	s := &FileSink{
+		path:    path,
+		maxSize: maxSize,
+		now:     time.Now,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// RecordEvent appends the event, rotating the file first when it would grow past the max size
+func (s *FileSink) RecordEvent(_ context.Context, event *models.Event) error {
+	line, err := marshalEvent(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("audit file %s is closed", s.path)
+	}
+	// A single event larger than the limit still gets a file of its own
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit event: %w", err)
+	}
+	return nil
+}
+
+// Close closes the audit file; later events fail
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// open opens the audit file for appending and records its current size
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit file: %w", err)
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// rotate renames the full audit file aside and starts a new one. When the rename fails the
+// file is reopened as it is, so the next event tries again.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit file: %w", err)
+	}
+	s.file = nil
+	rotated := s.path + "." + s.now().UTC().Format(rotatedSuffixFormat)
+	renameErr := os.Rename(s.path, rotated)
+	if err := s.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("failed to rotate audit file: %w", renameErr)
+	}
+	return nil
+}
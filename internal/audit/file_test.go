@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readLines returns the lines of a file
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// TestFileSink tests events are appended to an existing file and survive reopening
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileSink(path, 0)
+	require.NoError(t, err)
+	require.NoError(t, sink.RecordEvent(context.Background(), sampleEvent()))
+	require.NoError(t, sink.Close())
+
+	sink, err = NewFileSink(path, 0)
+	require.NoError(t, err)
+	require.NoError(t, sink.RecordEvent(context.Background(), sampleEvent()))
+	require.NoError(t, sink.Close())
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		assert.Contains(t, line, `"entity_id":"550e8400-e29b-41d4-a716-446655440000"`)
+	}
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	assert.Error(t, sink.RecordEvent(context.Background(), sampleEvent()), "A closed sink refuses events")
+}
+
+// TestFileSinkRotation tests the file is renamed aside once the next event would exceed the max size
+func TestFileSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	line, err := marshalEvent(sampleEvent())
+	require.NoError(t, err)
+	// Two events fit, the third starts a new file
+	sink, err := NewFileSink(path, int64(2*len(line)+1))
+	require.NoError(t, err)
+	defer sink.Close()
+	sink.now = func() time.Time { return time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC) }
+
+	for i := 0; i < 3; i++ {
+		event := sampleEvent()
+		event.Timestamp = time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+		require.NoError(t, sink.RecordEvent(context.Background(), event))
+	}
+
+	assert.Len(t, readLines(t, path), 1)
+	rotated := path + ".20240115T103000.000000000Z"
+	assert.Len(t, readLines(t, rotated), 2)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+// TestNewFileSinkValidation tests a path is required and the max size cannot be negative
+func TestNewFileSinkValidation(t *testing.T) {
+	_, err := NewFileSink("", 0)
+	assert.Error(t, err)
+	_, err = NewFileSink(filepath.Join(t.TempDir(), "audit.log"), -1)
+	assert.Error(t, err)
+	_, err = NewFileSink(filepath.Join(t.TempDir(), "missing", "audit.log"), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,223 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"certificate-monkey/internal/models"
+)
+
+const (
+	// FormatVersion is the version of the archive layout written by Writer
+	FormatVersion = 1
+
+	entitiesDir  = "entities/"
+	manifestName = "manifest.json"
+
+	// maxEntrySize bounds a single archive entry to guard against decompression bombs
+	maxEntrySize = 1 << 20
+)
+
+// Manifest describes the contents of a backup archive
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	EntityCount   int       `json:"entity_count"`
+}
+
+// Writer streams certificate entities into a passphrase-encrypted archive.
+// The archive is a gzip-compressed tar with one JSON document per entity
+// followed by a manifest. Private keys must be passed in KMS-encrypted form.
+type Writer struct {
+	enc       *encryptWriter
+	gz        *gzip.Writer
+	tw        *tar.Writer
+	createdAt time.Time
+	count     int
+}
+
+// NewWriter creates an archive writer that encrypts its output with the passphrase
+func NewWriter(w io.Writer, passphrase string) (*Writer, error) {
+	if err := ValidatePassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	enc, err := newEncryptWriter(w, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(enc)
+	return &Writer{
+		enc:       enc,
+		gz:        gz,
+		tw:        tar.NewWriter(gz),
+		createdAt: time.Now().UTC(),
+	}, nil
+}
+
+// AddEntity appends a certificate entity to the archive
+func (w *Writer) AddEntity(entity *models.CertificateEntity) error {
+	if entity.ID == "" || strings.ContainsAny(entity.ID, "/\\") {
+		return fmt.Errorf("invalid entity ID for backup: %q", entity.ID)
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return fmt.Errorf("failed to marshal entity %s: %w", entity.ID, err)
+	}
+
+	if err := w.writeFile(entitiesDir+entity.ID+".json", data); err != nil {
+		return fmt.Errorf("failed to write entity %s: %w", entity.ID, err)
+	}
+
+	w.count++
+	return nil
+}
+
+// Count returns the number of entities written so far
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Close writes the manifest and flushes and seals the archive
+func (w *Writer) Close() error {
+	manifest, err := json.Marshal(Manifest{
+		FormatVersion: FormatVersion,
+		CreatedAt:     w.createdAt,
+		EntityCount:   w.count,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	if err := w.writeFile(manifestName, manifest); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := w.tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar stream: %w", err)
+	}
+	if err := w.gz.Close(); err != nil {
+		return fmt.Errorf("failed to close gzip stream: %w", err)
+	}
+	return w.enc.Close()
+}
+
+// writeFile adds a single regular file to the tar stream
+func (w *Writer) writeFile(name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(data)),
+		ModTime: w.createdAt,
+	}
+	if err := w.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := w.tw.Write(data)
+	return err
+}
+
+// Reader reads certificate entities back out of an encrypted archive
+type Reader struct {
+	tr       *tar.Reader
+	manifest *Manifest
+	count    int
+}
+
+// NewReader opens an archive produced by Writer using the passphrase
+func NewReader(r io.Reader, passphrase string) (*Reader, error) {
+	dec, err := newDecryptReader(r, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(dec)
+	if err != nil {
+		if errors.Is(err, ErrDecryptionFailed) || errors.Is(err, ErrTruncated) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+
+	return &Reader{tr: tar.NewReader(gz)}, nil
+}
+
+// Next returns the next entity in the archive, or io.EOF once all entities were read.
+// Reaching io.EOF also verifies that the manifest was present and matches the content.
+func (r *Reader) Next() (*models.CertificateEntity, error) {
+	for {
+		header, err := r.tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, r.finish()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := readEntry(r.tr, header)
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(header.Name)
+		switch {
+		case name == manifestName:
+			var manifest Manifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return nil, fmt.Errorf("%w: invalid manifest: %v", ErrInvalidFormat, err)
+			}
+			r.manifest = &manifest
+		case strings.HasPrefix(name, entitiesDir) && strings.HasSuffix(name, ".json"):
+			var entity models.CertificateEntity
+			if err := json.Unmarshal(data, &entity); err != nil {
+				return nil, fmt.Errorf("invalid entity document %s: %w", name, err)
+			}
+			r.count++
+			return &entity, nil
+		}
+	}
+}
+
+// Manifest returns the archive manifest. It is only available after Next returned io.EOF.
+func (r *Reader) Manifest() *Manifest {
+	return r.manifest
+}
+
+// finish validates the manifest once the end of the archive is reached
+func (r *Reader) finish() error {
+	if r.manifest == nil {
+		return fmt.Errorf("%w: manifest missing", ErrInvalidFormat)
+	}
+	if r.manifest.FormatVersion != FormatVersion {
+		return fmt.Errorf("unsupported archive format version: %d", r.manifest.FormatVersion)
+	}
+	if r.manifest.EntityCount != r.count {
+		return fmt.Errorf("%w: manifest lists %d entities but archive contains %d", ErrInvalidFormat, r.manifest.EntityCount, r.count)
+	}
+	return io.EOF
+}
+
+// readEntry reads a bounded tar entry into memory
+func readEntry(r io.Reader, header *tar.Header) ([]byte, error) {
+	if header.Size < 0 || header.Size > maxEntrySize {
+		return nil, fmt.Errorf("%w: entry %s is too large", ErrInvalidFormat, header.Name)
+	}
+
+	data := make([]byte, header.Size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,212 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/models"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+func init() {
+	// Keep key derivation cheap in tests; the count is read back from the header
+	kdfIterations = 1000
+}
+
+// testEntities returns a small dataset of stored (KMS-encrypted) entities
+func testEntities() []*models.CertificateEntity {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	return []*models.CertificateEntity{
+		{
+			ID:                  "11111111-1111-1111-1111-111111111111",
+			CommonName:          "one.example.com",
+			KeyType:             models.KeyTypeRSA2048,
+			EncryptedPrivateKey: "0a0b0c0d-kms-ciphertext-one",
+			CSR:                 "-----BEGIN CERTIFICATE REQUEST-----\nONE\n-----END CERTIFICATE REQUEST-----\n",
+			Status:              models.StatusCSRCreated,
+			Tags:                map[string]string{"env": "prod"},
+			CreatedAt:           now,
+			UpdatedAt:           now,
+		},
+		{
+			ID:                  "22222222-2222-2222-2222-222222222222",
+			CommonName:          "two.example.com",
+			KeyType:             models.KeyTypeECDSAP256,
+			EncryptedPrivateKey: "0e0f1011-kms-ciphertext-two",
+			Certificate:         "-----BEGIN CERTIFICATE-----\nTWO\n-----END CERTIFICATE-----\n",
+			Status:              models.StatusCertUploaded,
+			CreatedAt:           now,
+			UpdatedAt:           now,
+		},
+	}
+}
+
+// writeArchive writes the entities into an archive and returns its bytes
+func writeArchive(t *testing.T, entities []*models.CertificateEntity) []byte {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, testPassphrase)
+	require.NoError(t, err)
+
+	for _, entity := range entities {
+		require.NoError(t, writer.AddEntity(entity))
+	}
+	require.NoError(t, writer.Close())
+	assert.Equal(t, len(entities), writer.Count())
+
+	return buf.Bytes()
+}
+
+// readArchive reads all entities from an archive
+func readArchive(t *testing.T, data []byte, passphrase string) ([]*models.CertificateEntity, *Manifest, error) {
+	reader, err := NewReader(bytes.NewReader(data), passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entities []*models.CertificateEntity
+	for {
+		entity, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return entities, reader.Manifest(), nil
+		}
+		if err != nil {
+			return entities, nil, err
+		}
+		entities = append(entities, entity)
+	}
+}
+
+// Test an archive round trip preserves every entity unchanged
+func TestArchiveRoundTrip(t *testing.T) {
+	entities := testEntities()
+	data := writeArchive(t, entities)
+
+	// Nothing from the entities may be readable in the encrypted archive
+	assert.NotContains(t, string(data), "one.example.com")
+	assert.NotContains(t, string(data), "kms-ciphertext")
+
+	restored, manifest, err := readArchive(t, data, testPassphrase)
+	require.NoError(t, err)
+	require.Len(t, restored, len(entities))
+
+	for i, entity := range entities {
+		assert.Equal(t, entity.ID, restored[i].ID)
+		assert.Equal(t, entity.CommonName, restored[i].CommonName)
+		assert.Equal(t, entity.EncryptedPrivateKey, restored[i].EncryptedPrivateKey, "Keys must stay KMS-encrypted")
+		assert.Equal(t, entity.CSR, restored[i].CSR)
+		assert.Equal(t, entity.Certificate, restored[i].Certificate)
+		assert.Equal(t, entity.Status, restored[i].Status)
+		assert.Equal(t, entity.Tags, restored[i].Tags)
+		assert.True(t, entity.CreatedAt.Equal(restored[i].CreatedAt))
+	}
+
+	require.NotNil(t, manifest)
+	assert.Equal(t, FormatVersion, manifest.FormatVersion)
+	assert.Equal(t, 2, manifest.EntityCount)
+}
+
+// Test an empty archive is still valid
+func TestArchiveEmpty(t *testing.T) {
+	data := writeArchive(t, nil)
+
+	restored, manifest, err := readArchive(t, data, testPassphrase)
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+	assert.Equal(t, 0, manifest.EntityCount)
+}
+
+// Test archives spanning many encrypted chunks
+func TestArchiveMultipleChunks(t *testing.T) {
+	var entities []*models.CertificateEntity
+	for i := 0; i < 20; i++ {
+		// Random payloads do not compress, forcing several chunks
+		payload := make([]byte, 16*1024)
+		_, err := rand.Read(payload)
+		require.NoError(t, err)
+
+		entities = append(entities, &models.CertificateEntity{
+			ID:                  hex.EncodeToString(payload[:16]),
+			CommonName:          "bulk.example.com",
+			EncryptedPrivateKey: hex.EncodeToString(payload),
+		})
+	}
+
+	data := writeArchive(t, entities)
+	assert.Greater(t, len(data), 3*chunkSize)
+
+	restored, _, err := readArchive(t, data, testPassphrase)
+	require.NoError(t, err)
+	require.Len(t, restored, len(entities))
+	for i := range entities {
+		assert.Equal(t, entities[i].EncryptedPrivateKey, restored[i].EncryptedPrivateKey)
+	}
+}
+
+// Test a wrong passphrase is rejected
+func TestArchiveWrongPassphrase(t *testing.T) {
+	data := writeArchive(t, testEntities())
+
+	_, _, err := readArchive(t, data, "a different passphrase")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+}
+
+// Test truncated and tampered archives are rejected
+func TestArchiveIntegrity(t *testing.T) {
+	data := writeArchive(t, testEntities())
+
+	t.Run("truncated", func(t *testing.T) {
+		_, _, err := readArchive(t, data[:len(data)-10], testPassphrase)
+		require.Error(t, err)
+	})
+
+	t.Run("final chunk removed", func(t *testing.T) {
+		_, _, err := readArchive(t, data[:headerSize], testPassphrase)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrTruncated)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := append([]byte(nil), data...)
+		tampered[len(tampered)-1] ^= 0xff
+		_, _, err := readArchive(t, tampered, testPassphrase)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrDecryptionFailed)
+	})
+
+	t.Run("excessive key derivation cost", func(t *testing.T) {
+		tampered := append([]byte(nil), data...)
+		binary.BigEndian.PutUint32(tampered[len(streamMagic)+1:], maxKDFIterations+1)
+		_, _, err := readArchive(t, tampered, testPassphrase)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrInvalidFormat)
+	})
+
+	t.Run("not an archive", func(t *testing.T) {
+		_, _, err := readArchive(t, []byte("definitely not a backup archive at all"), testPassphrase)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrInvalidFormat)
+	})
+}
+
+// Test passphrase and entity validation on the writer
+func TestWriterValidation(t *testing.T) {
+	_, err := NewWriter(io.Discard, "short")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "at least")
+
+	writer, err := NewWriter(io.Discard, testPassphrase)
+	require.NoError(t, err)
+	assert.Error(t, writer.AddEntity(&models.CertificateEntity{}))
+	assert.Error(t, writer.AddEntity(&models.CertificateEntity{ID: "../escape"}))
+}
@@ -0,0 +1,273 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// streamMagic identifies an encrypted Certificate Monkey backup stream
+	streamMagic = "CMBACKUP"
+	// streamVersion is the current version of the encrypted stream format
+	streamVersion byte = 1
+
+	saltSize        = 16
+	noncePrefixSize = 4
+	keySize         = 32
+	headerSize      = len(streamMagic) + 1 + 4 + saltSize + noncePrefixSize
+
+	// chunkSize is the maximum amount of plaintext sealed in a single chunk
+	chunkSize = 64 * 1024
+	// finalChunkFlag marks the last chunk of a stream so truncation can be detected
+	finalChunkFlag = uint32(1) << 31
+
+	defaultKDFIterations = 600_000
+	// maxKDFIterations bounds the iteration count read from an archive header, so a crafted archive
+	// cannot make a restore spend minutes of CPU deriving a key before its first chunk is checked
+	maxKDFIterations = 2 * defaultKDFIterations
+
+	// MinPassphraseLength is the shortest passphrase accepted for backup archives
+	MinPassphraseLength = 12
+)
+
+// kdfIterations is the PBKDF2-SHA256 iteration count used for new archives.
+// It is stored in the stream header so archives stay readable if it changes.
+var kdfIterations = defaultKDFIterations
+
+var (
+	// ErrInvalidFormat is returned when the input is not a backup archive
+	ErrInvalidFormat = errors.New("not a certificate monkey backup archive")
+	// ErrDecryptionFailed is returned when the passphrase is wrong or the archive was modified
+	ErrDecryptionFailed = errors.New("failed to decrypt backup archive: wrong passphrase or corrupted data")
+	// ErrTruncated is returned when the archive ends before its final chunk
+	ErrTruncated = errors.New("backup archive is truncated")
+)
+
+// ValidatePassphrase checks that a passphrase is acceptable for encrypting an archive
+func ValidatePassphrase(passphrase string) error {
+	if len(passphrase) < MinPassphraseLength {
+		return fmt.Errorf("passphrase must be at least %d characters long", MinPassphraseLength)
+	}
+	return nil
+}
+
+// deriveAEAD derives the AES-256-GCM cipher for a passphrase and salt
+func deriveAEAD(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key := pbkdf2.Key([]byte(passphrase), salt, iterations, keySize, sha256.New)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce builds the nonce for a chunk from the stream prefix and the chunk counter
+func chunkNonce(prefix []byte, counter uint64) []byte {
+	nonce := make([]byte, noncePrefixSize+8)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint64(nonce[noncePrefixSize:], counter)
+	return nonce
+}
+
+// encryptWriter seals everything written to it into authenticated chunks.
+// The stream header is only emitted with the first chunk, so nothing reaches
+// the underlying writer until at least one chunk is complete or Close is called.
+type encryptWriter struct {
+	w           io.Writer
+	aead        cipher.AEAD
+	header      []byte
+	noncePrefix []byte
+	counter     uint64
+	buf         []byte
+	started     bool
+	closed      bool
+}
+
+// newEncryptWriter creates a writer encrypting its input with a key derived from the passphrase
+func newEncryptWriter(w io.Writer, passphrase string) (*encryptWriter, error) {
+	salt := make([]byte, saltSize)
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce prefix: %w", err)
+	}
+
+	aead, err := deriveAEAD(passphrase, salt, kdfIterations)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, streamMagic...)
+	header = append(header, streamVersion)
+	header = binary.BigEndian.AppendUint32(header, uint32(kdfIterations)) // #nosec G115 -- bounded constant
+	header = append(header, salt...)
+	header = append(header, noncePrefix...)
+
+	return &encryptWriter{
+		w:           w,
+		aead:        aead,
+		header:      header,
+		noncePrefix: noncePrefix,
+	}, nil
+}
+
+// Write buffers plaintext and seals every full chunk
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed backup stream")
+	}
+
+	e.buf = append(e.buf, p...)
+	for len(e.buf) > chunkSize {
+		if err := e.writeChunk(e.buf[:chunkSize], false); err != nil {
+			return 0, err
+		}
+		e.buf = append(e.buf[:0], e.buf[chunkSize:]...)
+	}
+
+	return len(p), nil
+}
+
+// Close seals the remaining plaintext as the final chunk
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.writeChunk(e.buf, true)
+}
+
+// writeChunk seals one chunk. Its length prefix, including the final flag, is authenticated.
+func (e *encryptWriter) writeChunk(plaintext []byte, final bool) error {
+	if !e.started {
+		if _, err := e.w.Write(e.header); err != nil {
+			return err
+		}
+		e.started = true
+	}
+
+	prefix := uint32(len(plaintext) + e.aead.Overhead()) // #nosec G115 -- at most chunkSize plus overhead
+	if final {
+		prefix |= finalChunkFlag
+	}
+	lengthPrefix := binary.BigEndian.AppendUint32(nil, prefix)
+
+	sealed := e.aead.Seal(lengthPrefix, chunkNonce(e.noncePrefix, e.counter), plaintext, lengthPrefix)
+	e.counter++
+
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// decryptReader authenticates and decrypts a stream produced by encryptWriter
+type decryptReader struct {
+	r           io.Reader
+	aead        cipher.AEAD
+	noncePrefix []byte
+	counter     uint64
+	buf         []byte
+	done        bool
+}
+
+// newDecryptReader reads the stream header and prepares decryption with the passphrase
+func newDecryptReader(r io.Reader, passphrase string) (*decryptReader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrInvalidFormat
+	}
+
+	if string(header[:len(streamMagic)]) != streamMagic {
+		return nil, ErrInvalidFormat
+	}
+	offset := len(streamMagic)
+
+	if header[offset] != streamVersion {
+		return nil, fmt.Errorf("unsupported backup format version: %d", header[offset])
+	}
+	offset++
+
+	iterations := int(binary.BigEndian.Uint32(header[offset:]))
+	if iterations < 1 || iterations > maxKDFIterations {
+		return nil, fmt.Errorf("%w: invalid key derivation parameters", ErrInvalidFormat)
+	}
+	offset += 4
+
+	salt := header[offset : offset+saltSize]
+	offset += saltSize
+
+	aead, err := deriveAEAD(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		r:           r,
+		aead:        aead,
+		noncePrefix: header[offset : offset+noncePrefixSize],
+	}, nil
+}
+
+// Read returns decrypted plaintext, failing if any chunk was tampered with or is missing
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// readChunk reads and opens the next chunk of the stream
+func (d *decryptReader) readChunk() error {
+	lengthPrefix := make([]byte, 4)
+	if _, err := io.ReadFull(d.r, lengthPrefix); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrTruncated
+		}
+		return err
+	}
+
+	prefix := binary.BigEndian.Uint32(lengthPrefix)
+	final := prefix&finalChunkFlag != 0
+	length := int(prefix &^ finalChunkFlag)
+	if length < d.aead.Overhead() || length > chunkSize+d.aead.Overhead() {
+		return ErrDecryptionFailed
+	}
+
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrTruncated
+		}
+		return err
+	}
+
+	plaintext, err := d.aead.Open(nil, chunkNonce(d.noncePrefix, d.counter), sealed, lengthPrefix)
+	if err != nil {
+		return ErrDecryptionFailed
+	}
+	d.counter++
+
+	d.buf = plaintext
+	d.done = final
+	return nil
+}
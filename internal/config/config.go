@@ -1,67 +1,501 @@
 package config
 
 import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"path"
 	"strconv"
+	"strings"
+	"time"
+
+	"certificate-monkey/internal/models"
+	"certificate-monkey/internal/notify"
 )
 
+// Config is the service configuration
 type Config struct {
-	Server   ServerConfig
-	AWS      AWSConfig
-	Security SecurityConfig
+	// Environment names the deployment, such as "production", defaulting to production when
+	// GIN_MODE=release; production deployments are held to stricter validation
+	Environment  string
+	Server       ServerConfig
+	AWS          AWSConfig
+	Security     SecurityConfig
+	TLS          TLSConfig
+	Certificates CertificateConfig
+	ACME         ACMEConfig
+	Metrics      MetricsConfig
+	Audit        AuditConfig
+	Notify       NotifyConfig
+	CORS         CORSConfig
 }
 
+// ServerConfig holds the HTTP server settings
 type ServerConfig struct {
-	Port string
-	Host string
+	Port         string
+	Host         string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// TrustedProxies lists the IPs and CIDRs whose X-Forwarded-For header is trusted to name the
+	// client; when empty the connection's peer address is the client
+	TrustedProxies []string
+	// MaxInflightRequests caps the requests handled at once, health checks aside; zero means no limit
+	MaxInflightRequests int
+	// MaxHeaderBytes bounds the total size of a request's header names and values
+	MaxHeaderBytes int
 }
 
+// AWSConfig holds the AWS resources used for storage and encryption
 type AWSConfig struct {
-	Region        string
+	Region string
+	// TablePrefix isolates environments sharing an AWS account; it is already applied to the table names
+	TablePrefix   string
 	DynamoDBTable string
-	KMSKeyID      string
+	// EventsTable holds the durable event log; when empty no events are recorded
+	EventsTable string
+	KMSKeyID    string
+	// KMSRekeyRate caps the private keys re-encrypted per second during a rekey
+	KMSRekeyRate int
+	// KMSMaxAttempts is how many times a throttled or transiently failing KMS call is tried
+	KMSMaxAttempts int
+	// EncryptAllSensitive also encrypts CSRs and certificates at rest, not only private keys
+	EncryptAllSensitive bool
+	// KMSContextTags names entity tags, such as data_class, bound into the KMS encryption context of
+	// private keys, so a key only decrypts while those tags keep their values
+	KMSContextTags []string
 }
 
+// Authorization header schemes that can carry an API key, selected with API_KEY_AUTH_SCHEMES
+const (
+	AuthSchemeBearer = "Bearer"
+	AuthSchemeAPIKey = "ApiKey"
+)
+
+// SecurityConfig holds the accepted API keys
 type SecurityConfig struct {
 	APIKeys []string
+	// AdminAPIKeys carry the admin scope; they are accepted on every endpoint
+	AdminAPIKeys []string
+	// ExportAPIKeys carry the export scope; they are accepted on every endpoint
+	ExportAPIKeys []string
+	// KeyNames maps keys loaded from API_KEYS_FILE to their name; keys from the environment are unnamed
+	KeyNames map[string]string
+	// APIKeyHeader names a header read after X-API-Key, for clients that cannot set it; empty disables it
+	APIKeyHeader string
+	// AuthSchemes lists the Authorization header schemes, such as "ApiKey", that carry an API key
+	AuthSchemes []string
+}
+
+// NamedAPIKey is an entry of the API_KEYS_FILE JSON array
+type NamedAPIKey struct {
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	Admin  bool   `json:"admin"`
+	Export bool   `json:"export"`
+}
+
+// CertificateConfig holds the policy applied to keys, CSRs and uploaded certificates
+type CertificateConfig struct {
+	// AllowedCountries restricts the subject country to these ISO 3166-1 alpha-2 codes; empty allows any
+	AllowedCountries []string
+	// AllowedKeyTypes restricts the key types of new keys and imported CSRs
+	AllowedKeyTypes []models.KeyType
+	// AllowWildcards permits wildcard common names and DNS SANs such as *.example.com
+	AllowWildcards bool
+	// RequiredExtKeyUsages lists extended key usages, such as serverAuth, uploaded certificates are warned about lacking
+	RequiredExtKeyUsages []string
+	// AllowedIssuers lists the issuer common or distinguished names uploads must come from; empty accepts any
+	AllowedIssuers []string
+	// RequireDNSSAN flags uploaded certificates without a DNS SAN
+	RequireDNSSAN bool
+	// RequireCNInSANs flags uploaded certificates whose common name is missing from the DNS SANs
+	RequireCNInSANs bool
+	// EnforceSANPolicy rejects certificates failing the SAN checks instead of warning
+	EnforceSANPolicy bool
+	// MaxValidity is the longest validity period accepted on upload; zero means no limit
+	MaxValidity time.Duration
+	// MaxClockSkew is how far in the future an uploaded certificate's NotBefore may lie
+	MaxClockSkew time.Duration
+	// EnforceValidity rejects over-long, expired and not yet valid certificates instead of warning
+	EnforceValidity bool
+	// MaxTags bounds the tags a client may set on an entity
+	MaxTags int
+	// MaxTagKeyLength bounds a tag key, in characters
+	MaxTagKeyLength int
+	// MaxTagValueLength bounds a tag value, in characters
+	MaxTagValueLength int
+	// MaxConcurrentPFX caps the PFX files generated at once, as PKCS#12 key derivation is CPU-intensive
+	MaxConcurrentPFX int
+	// RSAKeyPoolSize is how many RSA keys of each size are generated ahead of requests; zero disables the pools
+	RSAKeyPoolSize int
+	// DeniedNames lists lower-case names and glob patterns, such as *.corp.internal, no common name or SAN may match
+	DeniedNames []string
+	// NormalizeCNLowercase lowercases hostname common names in generated CSRs
+	NormalizeCNLowercase bool
+	// MaxSANs caps the subject alternative names of generated, regenerated and imported CSRs
+	MaxSANs int
+}
+
+// ACMEConfig configures optional certificate issuance through an ACME CA such as Let's Encrypt
+type ACMEConfig struct {
+	Enabled      bool
+	DirectoryURL string
+	// AccountKeyPath is a PEM EC or RSA key identifying the ACME account, registered on first use
+	AccountKeyPath string
+	Email          string
+	// Route53HostedZoneID is the hosted zone DNS-01 challenge TXT records are written to
+	Route53HostedZoneID string
+	// Timeout bounds a whole order, including DNS propagation and challenge validation
+	Timeout time.Duration
+}
+
+// MetricsConfig selects where operational metrics are sent
+type MetricsConfig struct {
+	// Backend is "noop" or "emf", EMF lines written to stdout for CloudWatch to extract
+	Backend   string
+	Namespace string
+}
+
+// AuditConfig selects where audit events are written
+type AuditConfig struct {
+	// Sink is "dynamodb", the event log in EventsTable; "stdout", JSON lines on stdout; or "file",
+	// JSON lines appended to FilePath
+	Sink     string
+	FilePath string
+	// FileMaxSize is the size in bytes at which the audit file is rotated; zero never rotates
+	FileMaxSize int64
+}
+
+// NotifyConfig configures notifications to downstream automation
+type NotifyConfig struct {
+	// WebhookURL receives each notification as a JSON POST
+	WebhookURL string
+	// SNSTopicARN receives each notification as a JSON message; with neither it nor WebhookURL set
+	// no notifications are sent
+	SNSTopicARN string
+	// Events lists the notification events sent, such as "issued"; it defaults to every event the service sends
+	Events []string
+	// Timeout bounds the delivery of one notification
+	Timeout time.Duration
+}
+
+// CORSConfig controls which browser origins may call the API
+type CORSConfig struct {
+	// AllowedOrigins lists the permitted origins; "*" allows any but cannot be combined with AllowCredentials
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies and is only valid with explicit origins
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+	// RouteMaxAge overrides MaxAge for paths starting with one of its keys, the longest prefix winning
+	RouteMaxAge map[string]time.Duration
+}
+
+// TLSConfig configures HTTPS and optional mutual-TLS client authentication
+type TLSConfig struct {
+	CertPath string
+	KeyPath  string
+	// ClientCAPath enables mTLS: clients must present a certificate signed by this CA bundle
+	ClientCAPath string
+	// RequireAPIKey additionally requires a valid API key from mTLS-authenticated clients
+	RequireAPIKey bool
+	// AdminIdentities lists client certificate identities granted the admin scope
+	AdminIdentities []string
+	// MinVersion is the lowest TLS version accepted, a tls.VersionTLS* constant
+	MinVersion uint16
+	// CipherSuites are the TLS 1.2 cipher suites offered; empty means DefaultCipherSuites
+	CipherSuites []uint16
+}
+
+// Enabled reports whether the server should serve HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertPath != ""
+}
+
+// MTLSEnabled reports whether clients must authenticate with a certificate
+func (t TLSConfig) MTLSEnabled() bool {
+	return t.ClientCAPath != ""
 }
 
 func Load() (*Config, error) {
+	// A setting that cannot be read keeps its default and is reported together with the problems
+	// Validate finds, so a deployment can be fixed in one go
+	var problems []error
+	report := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+	envInt := func(key string, defaultValue int) int {
+		value, err := getEnvAsInt(key, defaultValue)
+		report(err)
+		return value
+	}
+	envBool := func(key string, defaultValue bool) bool {
+		value, err := getEnvAsBool(key, defaultValue)
+		report(err)
+		return value
+	}
+	envDuration := func(key string, defaultValue time.Duration) time.Duration {
+		value, err := getEnvAsDuration(key, defaultValue)
+		report(err)
+		return value
+	}
+
 	cfg := &Config{
+		Environment: getEnvWithDefault("ENV", defaultEnvironment()),
 		Server: ServerConfig{
-			Port: getEnvWithDefault("SERVER_PORT", "8080"),
-			Host: getEnvWithDefault("SERVER_HOST", "0.0.0.0"),
+			Port:                getEnvWithDefault("SERVER_PORT", "8080"),
+			Host:                getEnvWithDefault("SERVER_HOST", "0.0.0.0"),
+			ReadTimeout:         envDuration("SERVER_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:        envDuration("SERVER_WRITE_TIMEOUT", 15*time.Second),
+			IdleTimeout:         envDuration("SERVER_IDLE_TIMEOUT", 60*time.Second),
+			TrustedProxies:      getEnvAsSlice("TRUSTED_PROXIES"),
+			MaxInflightRequests: envInt("MAX_INFLIGHT_REQUESTS", 0),
+			MaxHeaderBytes:      envInt("MAX_HEADER_BYTES", 16384),
 		},
 		AWS: AWSConfig{
-			Region:        getEnvWithDefault("AWS_REGION", "eu-central-1"),
-			DynamoDBTable: getEnvWithDefault("DYNAMODB_TABLE", "certificate-monkey-dev"),
-			KMSKeyID:      getEnvWithDefault("KMS_KEY_ID", "alias/certificate-monkey-dev"),
+			Region:              getEnvWithDefault("AWS_REGION", "eu-central-1"),
+			TablePrefix:         os.Getenv("TABLE_PREFIX"),
+			DynamoDBTable:       getEnvWithDefault("DYNAMODB_TABLE", "certificate-monkey-dev"),
+			EventsTable:         os.Getenv("DYNAMODB_EVENTS_TABLE"),
+			KMSKeyID:            getEnvWithDefault("KMS_KEY_ID", "alias/certificate-monkey-dev"),
+			KMSRekeyRate:        envInt("KMS_REKEY_RATE", 10),
+			KMSMaxAttempts:      envInt("KMS_MAX_ATTEMPTS", 3),
+			EncryptAllSensitive: envBool("ENCRYPT_ALL_SENSITIVE", false),
+			KMSContextTags:      getEnvAsSlice("KMS_CONTEXT_TAGS"),
 		},
 		Security: SecurityConfig{
 			APIKeys: []string{
 				getEnvWithDefault("API_KEY_1", "cm_dev_12345"),  // TODO: remove this default value for production ready version
 				getEnvWithDefault("API_KEY_2", "cm_prod_67890"), // TODO: remove this default value for production ready version
 			},
+			AdminAPIKeys:  getEnvAsSlice("ADMIN_API_KEYS"),
+			ExportAPIKeys: getEnvAsSlice("EXPORT_API_KEYS"),
+			APIKeyHeader:  http.CanonicalHeaderKey(os.Getenv("API_KEY_HEADER")),
+		},
+		TLS: TLSConfig{
+			CertPath:        os.Getenv("TLS_CERT_PATH"),
+			KeyPath:         os.Getenv("TLS_KEY_PATH"),
+			ClientCAPath:    os.Getenv("CLIENT_CA_PATH"),
+			RequireAPIKey:   envBool("MTLS_REQUIRE_API_KEY", false),
+			AdminIdentities: getEnvAsSlice("MTLS_ADMIN_IDENTITIES"),
+		},
+		Metrics: MetricsConfig{
+			Backend:   getEnvWithDefault("METRICS_BACKEND", "noop"),
+			Namespace: getEnvWithDefault("METRICS_NAMESPACE", "CertificateMonkey"),
+		},
+		Audit: AuditConfig{
+			Sink:        getEnvWithDefault("AUDIT_SINK", "dynamodb"),
+			FilePath:    os.Getenv("AUDIT_FILE_PATH"),
+			FileMaxSize: int64(envInt("AUDIT_FILE_MAX_SIZE_MB", 100)) << 20,
 		},
+		Notify: NotifyConfig{
+			WebhookURL:  os.Getenv("NOTIFY_WEBHOOK_URL"),
+			SNSTopicARN: os.Getenv("SNS_TOPIC_ARN"),
+			Events:      getEnvAsSlice("NOTIFY_EVENTS"),
+			Timeout:     envDuration("NOTIFY_TIMEOUT", 5*time.Second),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   getEnvAsSlice("CORS_ALLOWED_ORIGINS"),
+			AllowCredentials: envBool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           envDuration("CORS_MAX_AGE", time.Hour),
+		},
+		Certificates: CertificateConfig{
+			AllowWildcards:       envBool("ALLOW_WILDCARDS", true),
+			MaxValidity:          time.Duration(envInt("MAX_CERT_VALIDITY_DAYS", 0)) * 24 * time.Hour,
+			MaxClockSkew:         envDuration("MAX_CERT_CLOCK_SKEW", time.Hour),
+			EnforceValidity:      envBool("ENFORCE_CERT_VALIDITY", false),
+			RequiredExtKeyUsages: getEnvAsSlice("REQUIRED_EXT_KEY_USAGES"),
+			RequireDNSSAN:        envBool("REQUIRE_DNS_SAN", false),
+			RequireCNInSANs:      envBool("REQUIRE_CN_IN_SANS", false),
+			EnforceSANPolicy:     envBool("ENFORCE_SAN_POLICY", false),
+			MaxTags:              envInt("MAX_TAGS", 50),
+			MaxTagKeyLength:      envInt("MAX_TAG_KEY_LEN", 128),
+			MaxTagValueLength:    envInt("MAX_TAG_VALUE_LEN", 256),
+			MaxConcurrentPFX:     envInt("MAX_CONCURRENT_PFX", 4),
+			RSAKeyPoolSize:       envInt("RSA_KEY_POOL_SIZE", 0),
+			NormalizeCNLowercase: envBool("NORMALIZE_CN_LOWERCASE", false),
+			MaxSANs:              envInt("MAX_SANS", 100),
+		},
+		ACME: ACMEConfig{
+			Enabled:             envBool("ACME_ENABLED", false),
+			DirectoryURL:        getEnvWithDefault("ACME_DIRECTORY_URL", "https://acme-v02.api.letsencrypt.org/directory"),
+			AccountKeyPath:      os.Getenv("ACME_ACCOUNT_KEY_PATH"),
+			Email:               os.Getenv("ACME_EMAIL"),
+			Route53HostedZoneID: os.Getenv("ACME_ROUTE53_HOSTED_ZONE_ID"),
+			Timeout:             envDuration("ACME_TIMEOUT", 5*time.Minute),
+		},
+	}
+
+	// Prefix the table names so several environments can share one AWS account
+	cfg.AWS.DynamoDBTable = cfg.AWS.TablePrefix + cfg.AWS.DynamoDBTable
+	if cfg.AWS.EventsTable != "" {
+		cfg.AWS.EventsTable = cfg.AWS.TablePrefix + cfg.AWS.EventsTable
+	}
+
+	var err error
+	if cfg.TLS.MinVersion, err = parseTLSVersion(getEnvWithDefault("TLS_MIN_VERSION", "1.2")); err != nil {
+		report(err)
+		cfg.TLS.MinVersion = tls.VersionTLS12
+	}
+	cfg.TLS.CipherSuites, err = parseCipherSuites(getEnvAsSlice("TLS_CIPHER_SUITES"))
+	report(err)
+
+	if len(cfg.CORS.AllowedOrigins) == 0 {
+		cfg.CORS.AllowedOrigins = []string{"*"}
+	}
+	for _, entry := range getEnvAsSlice("CORS_ROUTE_MAX_AGE") {
+		prefix, value, found := strings.Cut(entry, "=")
+		if !found || !strings.HasPrefix(prefix, "/") {
+			report(fmt.Errorf("CORS_ROUTE_MAX_AGE entry %q must have the form /path=duration", entry))
+			continue
+		}
+		maxAge, err := time.ParseDuration(value)
+		if err != nil || maxAge < 0 {
+			report(fmt.Errorf("CORS_ROUTE_MAX_AGE entry %q must have a non-negative duration such as \"10m\"", entry))
+			continue
+		}
+		if cfg.CORS.RouteMaxAge == nil {
+			cfg.CORS.RouteMaxAge = make(map[string]time.Duration)
+		}
+		cfg.CORS.RouteMaxAge[prefix] = maxAge
+	}
+
+	for _, country := range getEnvAsSlice("ALLOWED_COUNTRIES") {
+		cfg.Certificates.AllowedCountries = append(cfg.Certificates.AllowedCountries, strings.ToUpper(country))
+	}
+
+	if cfg.Notify.Events == nil {
+		cfg.Notify.Events = notify.Events
+	}
+
+	cfg.Certificates.AllowedKeyTypes = append([]models.KeyType(nil), models.SupportedKeyTypes...)
+	if keyTypes := getEnvAsSlice("ALLOWED_KEY_TYPES"); len(keyTypes) > 0 {
+		cfg.Certificates.AllowedKeyTypes = nil
+		for _, keyType := range keyTypes {
+			cfg.Certificates.AllowedKeyTypes = append(cfg.Certificates.AllowedKeyTypes, models.KeyType(keyType))
+		}
 	}
 
-	// Validate API keys are not empty
-	if cfg.Security.APIKeys[0] == "" {
-		return nil, fmt.Errorf("API_KEY_1 is required")
+	// Load the approved issuers; distinguished names contain commas, so entries are separated by semicolons
+	for _, issuer := range strings.Split(os.Getenv("ALLOWED_ISSUERS"), ";") {
+		if trimmed := strings.TrimSpace(issuer); trimmed != "" {
+			cfg.Certificates.AllowedIssuers = append(cfg.Certificates.AllowedIssuers, trimmed)
+		}
+	}
+
+	// Load the name denylist
+	if path := os.Getenv("NAME_DENYLIST_FILE"); path != "" {
+		cfg.Certificates.DeniedNames, err = loadNameDenylist(path)
+		report(err)
+	}
+
+	// Accept the Authorization schemes in any case; unknown schemes are reported by Validate
+	schemes := getEnvAsSlice("API_KEY_AUTH_SCHEMES")
+	if schemes == nil {
+		schemes = []string{AuthSchemeBearer}
+	}
+	for _, scheme := range schemes {
+		switch {
+		case strings.EqualFold(scheme, AuthSchemeBearer):
+			scheme = AuthSchemeBearer
+		case strings.EqualFold(scheme, AuthSchemeAPIKey):
+			scheme = AuthSchemeAPIKey
+		}
+		cfg.Security.AuthSchemes = append(cfg.Security.AuthSchemes, scheme)
 	}
-	if cfg.Security.APIKeys[1] == "" {
-		return nil, fmt.Errorf("API_KEY_2 is required")
+
+	// Load named API keys
+	if path := os.Getenv("API_KEYS_FILE"); path != "" {
+		report(loadAPIKeysFile(path, &cfg.Security))
 	}
 
-	// Validate KMS key ID is set
-	if cfg.AWS.KMSKeyID == "" {
-		return nil, fmt.Errorf("KMS_KEY_ID is required")
+	var validationErr *ValidationError
+	if errors.As(cfg.Validate(), &validationErr) {
+		problems = append(problems, validationErr.Problems...)
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
 	}
 
 	return cfg, nil
 }
 
+// loadAPIKeysFile adds the named keys listed in a JSON file to the security config
+func loadAPIKeysFile(path string, security *SecurityConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read API_KEYS_FILE: %w", err)
+	}
+
+	var keys []NamedAPIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("failed to parse API_KEYS_FILE: %w", err)
+	}
+
+	security.KeyNames = make(map[string]string, len(keys))
+	names := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		if key.Name == "" || key.Key == "" {
+			return fmt.Errorf("API_KEYS_FILE entry %d must have a name and a key", i+1)
+		}
+		if names[key.Name] {
+			return fmt.Errorf("API_KEYS_FILE entry %d reuses the name %q", i+1, key.Name)
+		}
+		if _, exists := security.KeyNames[key.Key]; exists {
+			return fmt.Errorf("API_KEYS_FILE entry %d (%q) reuses the key of another entry", i+1, key.Name)
+		}
+		names[key.Name] = true
+		security.KeyNames[key.Key] = key.Name
+
+		if key.Admin {
+			security.AdminAPIKeys = append(security.AdminAPIKeys, key.Key)
+		} else {
+			security.APIKeys = append(security.APIKeys, key.Key)
+		}
+		if key.Export {
+			security.ExportAPIKeys = append(security.ExportAPIKeys, key.Key)
+		}
+	}
+
+	return nil
+}
+
+// loadNameDenylist reads the names and glob patterns listed one per line in a file. Blank lines and
+// lines starting with # are skipped; names are matched case-insensitively and stored lower-case.
+func loadNameDenylist(filename string) ([]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NAME_DENYLIST_FILE: %w", err)
+	}
+
+	var rules []string
+	for i, line := range strings.Split(string(data), "\n") {
+		rule := strings.ToLower(strings.TrimSpace(line))
+		if rule == "" || strings.HasPrefix(rule, "#") {
+			continue
+		}
+		if _, err := path.Match(rule, ""); err != nil {
+			return nil, fmt.Errorf("NAME_DENYLIST_FILE line %d: invalid pattern %q", i+1, rule)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// defaultEnvironment treats a service running gin in release mode as production unless ENV says otherwise
+func defaultEnvironment() string {
+	if os.Getenv("GIN_MODE") == "release" {
+		return "production"
+	}
+	return "development"
+}
+
 func getEnvWithDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -69,11 +503,69 @@ func getEnvWithDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+// getEnvAsInt parses an integer from an environment variable; a value that is not an integer is
+// an error and yields the default
+func getEnvAsInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue, fmt.Errorf("%s must be an integer, got %q", key, value)
+	}
+
+	return intValue, nil
+}
+
+// getEnvAsSlice splits a comma-separated environment variable into its non-empty, trimmed values
+func getEnvAsSlice(key string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			values = append(values, trimmed)
 		}
 	}
-	return defaultValue
+	return values
+}
+
+// getEnvAsDuration parses a positive duration such as "30s" or "2m" from an environment variable;
+// an invalid value is an error and yields the default
+func getEnvAsDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return defaultValue, fmt.Errorf("%s must be a duration such as \"30s\" or \"2m\": %w", key, err)
+	}
+	if duration <= 0 {
+		return defaultValue, fmt.Errorf("%s must be positive, got %s", key, value)
+	}
+
+	return duration, nil
+}
+
+// getEnvAsBool parses a boolean such as "true" or "0" from an environment variable; an invalid
+// value is an error and yields the default
+func getEnvAsBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue, fmt.Errorf("%s must be a boolean: %w", key, err)
+	}
+
+	return boolValue, nil
 }
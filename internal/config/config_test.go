@@ -3,10 +3,14 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/models"
 )
 
 // Test Load with default values
@@ -385,32 +389,864 @@ func TestGetEnvAsInt(t *testing.T) {
 
 	t.Run("uses default when env var not set", func(t *testing.T) {
 		os.Unsetenv(testKey)
-		result := getEnvAsInt(testKey, testDefault)
+		result, err := getEnvAsInt(testKey, testDefault)
+		require.NoError(t, err)
 		assert.Equal(t, testDefault, result)
 	})
 
 	t.Run("uses env var when set to valid int", func(t *testing.T) {
 		os.Setenv(testKey, "123")
-		result := getEnvAsInt(testKey, testDefault)
+		result, err := getEnvAsInt(testKey, testDefault)
+		require.NoError(t, err)
 		assert.Equal(t, 123, result)
 		os.Unsetenv(testKey)
 	})
 
-	t.Run("uses default when env var is invalid int", func(t *testing.T) {
+	t.Run("reports an invalid int", func(t *testing.T) {
 		os.Setenv(testKey, "not_a_number")
-		result := getEnvAsInt(testKey, testDefault)
+		result, err := getEnvAsInt(testKey, testDefault)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), testKey)
 		assert.Equal(t, testDefault, result)
 		os.Unsetenv(testKey)
 	})
 
 	t.Run("uses default when env var is empty", func(t *testing.T) {
 		os.Setenv(testKey, "")
-		result := getEnvAsInt(testKey, testDefault)
+		result, err := getEnvAsInt(testKey, testDefault)
+		require.NoError(t, err)
 		assert.Equal(t, testDefault, result)
 		os.Unsetenv(testKey)
 	})
 }
 
+// Test getEnvAsSlice helper
+func TestGetEnvAsSlice(t *testing.T) {
+	testKey := "TEST_SLICE_VAR"
+
+	t.Run("returns nil when env var not set", func(t *testing.T) {
+		os.Unsetenv(testKey)
+		assert.Nil(t, getEnvAsSlice(testKey))
+	})
+
+	t.Run("splits and trims comma-separated values", func(t *testing.T) {
+		os.Setenv(testKey, " key_a, key_b ,,key_c ")
+		assert.Equal(t, []string{"key_a", "key_b", "key_c"}, getEnvAsSlice(testKey))
+		os.Unsetenv(testKey)
+	})
+}
+
+// Test admin API keys are loaded from the environment
+func TestLoadAdminAPIKeys(t *testing.T) {
+	os.Unsetenv("ADMIN_API_KEYS")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Security.AdminAPIKeys, "No admin keys should be configured by default")
+
+	os.Setenv("ADMIN_API_KEYS", "admin_key_1,admin_key_2")
+	defer os.Unsetenv("ADMIN_API_KEYS")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin_key_1", "admin_key_2"}, cfg.Security.AdminAPIKeys)
+}
+
+// Test export API keys are loaded from the environment
+func TestLoadExportAPIKeys(t *testing.T) {
+	os.Unsetenv("EXPORT_API_KEYS")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Security.ExportAPIKeys, "No export keys should be configured by default")
+
+	os.Setenv("EXPORT_API_KEYS", "export_key_1,export_key_2")
+	defer os.Unsetenv("EXPORT_API_KEYS")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"export_key_1", "export_key_2"}, cfg.Security.ExportAPIKeys)
+}
+
+// Test getEnvAsDuration helper
+func TestGetEnvAsDuration(t *testing.T) {
+	testKey := "TEST_DURATION_VAR"
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    time.Duration
+		expectError bool
+	}{
+		{name: "uses default when not set", value: "", expected: 15 * time.Second},
+		{name: "parses seconds", value: "30s", expected: 30 * time.Second},
+		{name: "parses minutes", value: "2m", expected: 2 * time.Minute},
+		{name: "parses compound durations", value: "1m30s", expected: 90 * time.Second},
+		{name: "rejects plain numbers", value: "30", expectError: true},
+		{name: "rejects garbage", value: "soon", expectError: true},
+		{name: "rejects zero", value: "0s", expectError: true},
+		{name: "rejects negative", value: "-5s", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(testKey, tt.value)
+			defer os.Unsetenv(testKey)
+
+			result, err := getEnvAsDuration(testKey, 15*time.Second)
+			if tt.expectError {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testKey)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+// Test server timeouts are loaded from the environment
+func TestLoadServerTimeouts(t *testing.T) {
+	os.Unsetenv("SERVER_READ_TIMEOUT")
+	os.Unsetenv("SERVER_WRITE_TIMEOUT")
+	os.Unsetenv("SERVER_IDLE_TIMEOUT")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, 15*time.Second, cfg.Server.WriteTimeout)
+	assert.Equal(t, 60*time.Second, cfg.Server.IdleTimeout)
+
+	os.Setenv("SERVER_READ_TIMEOUT", "2m")
+	os.Setenv("SERVER_WRITE_TIMEOUT", "90s")
+	os.Setenv("SERVER_IDLE_TIMEOUT", "5m")
+	defer func() {
+		os.Unsetenv("SERVER_READ_TIMEOUT")
+		os.Unsetenv("SERVER_WRITE_TIMEOUT")
+		os.Unsetenv("SERVER_IDLE_TIMEOUT")
+	}()
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, cfg.Server.ReadTimeout)
+	assert.Equal(t, 90*time.Second, cfg.Server.WriteTimeout)
+	assert.Equal(t, 5*time.Minute, cfg.Server.IdleTimeout)
+
+	os.Setenv("SERVER_WRITE_TIMEOUT", "fast")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SERVER_WRITE_TIMEOUT")
+}
+
+// TestLoadKMSRekeyRate tests the rekey rate default and validation
+func TestLoadKMSRekeyRate(t *testing.T) {
+	os.Unsetenv("KMS_REKEY_RATE")
+	defer os.Unsetenv("KMS_REKEY_RATE")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 10, cfg.AWS.KMSRekeyRate)
+
+	os.Setenv("KMS_REKEY_RATE", "25")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 25, cfg.AWS.KMSRekeyRate)
+
+	os.Setenv("KMS_REKEY_RATE", "0")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "KMS_REKEY_RATE")
+}
+
+// TestLoadTablePrefix tests that TABLE_PREFIX is prepended to the table name
+func TestLoadTablePrefix(t *testing.T) {
+	os.Unsetenv("TABLE_PREFIX")
+	os.Setenv("DYNAMODB_TABLE", "certificate-monkey")
+	defer func() {
+		os.Unsetenv("TABLE_PREFIX")
+		os.Unsetenv("DYNAMODB_TABLE")
+	}()
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.AWS.TablePrefix)
+	assert.Equal(t, "certificate-monkey", cfg.AWS.DynamoDBTable)
+
+	os.Setenv("TABLE_PREFIX", "staging-")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "staging-", cfg.AWS.TablePrefix)
+	assert.Equal(t, "staging-certificate-monkey", cfg.AWS.DynamoDBTable)
+
+	os.Unsetenv("DYNAMODB_TABLE")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "staging-certificate-monkey-dev", cfg.AWS.DynamoDBTable, "The prefix also applies to the default table name")
+	assert.Empty(t, cfg.AWS.EventsTable, "The event log stays disabled without an events table")
+
+	os.Setenv("DYNAMODB_EVENTS_TABLE", "certificate-monkey-events")
+	defer os.Unsetenv("DYNAMODB_EVENTS_TABLE")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "staging-certificate-monkey-events", cfg.AWS.EventsTable)
+}
+
+// TestLoadAllowedCountries tests parsing and validation of the country allow-list
+func TestLoadAllowedCountries(t *testing.T) {
+	os.Unsetenv("ALLOWED_COUNTRIES")
+	defer os.Unsetenv("ALLOWED_COUNTRIES")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Certificates.AllowedCountries)
+
+	os.Setenv("ALLOWED_COUNTRIES", "US, de,GB")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"US", "DE", "GB"}, cfg.Certificates.AllowedCountries)
+
+	os.Setenv("ALLOWED_COUNTRIES", "US,USA")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ALLOWED_COUNTRIES")
+}
+
+// TestLoadAllowWildcards tests the wildcard policy switch
+func TestLoadAllowWildcards(t *testing.T) {
+	os.Unsetenv("ALLOW_WILDCARDS")
+	defer os.Unsetenv("ALLOW_WILDCARDS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.Certificates.AllowWildcards, "Wildcards are allowed by default")
+
+	os.Setenv("ALLOW_WILDCARDS", "false")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Certificates.AllowWildcards)
+
+	os.Setenv("ALLOW_WILDCARDS", "sometimes")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ALLOW_WILDCARDS")
+}
+
+// TestLoadNormalizeCNLowercase tests common name lowercasing is opt-in
+func TestLoadNormalizeCNLowercase(t *testing.T) {
+	os.Unsetenv("NORMALIZE_CN_LOWERCASE")
+	defer os.Unsetenv("NORMALIZE_CN_LOWERCASE")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Certificates.NormalizeCNLowercase)
+
+	os.Setenv("NORMALIZE_CN_LOWERCASE", "true")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.Certificates.NormalizeCNLowercase)
+
+	os.Setenv("NORMALIZE_CN_LOWERCASE", "yes please")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NORMALIZE_CN_LOWERCASE")
+}
+
+// TestLoadMaxSANs tests the SAN cap defaults to the request limit and can only be lowered
+func TestLoadMaxSANs(t *testing.T) {
+	os.Unsetenv("MAX_SANS")
+	defer os.Unsetenv("MAX_SANS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 100, cfg.Certificates.MaxSANs)
+
+	os.Setenv("MAX_SANS", "10")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 10, cfg.Certificates.MaxSANs)
+
+	for _, value := range []string{"0", "101"} {
+		os.Setenv("MAX_SANS", value)
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "MAX_SANS")
+	}
+}
+
+// TestLoadNotify tests the notification webhook and event selection
+func TestLoadNotify(t *testing.T) {
+	for _, key := range []string{"NOTIFY_WEBHOOK_URL", "NOTIFY_EVENTS", "NOTIFY_TIMEOUT"} {
+		os.Unsetenv(key)
+		defer os.Unsetenv(key)
+	}
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Notify.WebhookURL)
+	assert.Equal(t, []string{"issued"}, cfg.Notify.Events, "Every event the service sends is selected by default")
+	assert.Equal(t, 5*time.Second, cfg.Notify.Timeout)
+
+	os.Setenv("NOTIFY_WEBHOOK_URL", "https://deploy.example.com/hooks/certificates")
+	os.Setenv("NOTIFY_EVENTS", "issued")
+	os.Setenv("NOTIFY_TIMEOUT", "2s")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "https://deploy.example.com/hooks/certificates", cfg.Notify.WebhookURL)
+	assert.Equal(t, []string{"issued"}, cfg.Notify.Events)
+	assert.Equal(t, 2*time.Second, cfg.Notify.Timeout)
+
+	os.Setenv("NOTIFY_EVENTS", "issued,deleted")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NOTIFY_EVENTS")
+
+	os.Setenv("NOTIFY_EVENTS", "issued")
+	os.Setenv("NOTIFY_WEBHOOK_URL", "ftp://deploy.example.com/hooks")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NOTIFY_WEBHOOK_URL")
+}
+
+// TestLoadKMSMaxAttempts tests the KMS retry bound defaults to three attempts and stays within 1-10
+func TestLoadKMSMaxAttempts(t *testing.T) {
+	os.Unsetenv("KMS_MAX_ATTEMPTS")
+	defer os.Unsetenv("KMS_MAX_ATTEMPTS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.AWS.KMSMaxAttempts)
+
+	os.Setenv("KMS_MAX_ATTEMPTS", "1")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 1, cfg.AWS.KMSMaxAttempts, "One attempt disables retries")
+
+	for _, value := range []string{"0", "11"} {
+		os.Setenv("KMS_MAX_ATTEMPTS", value)
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "KMS_MAX_ATTEMPTS")
+	}
+}
+
+// TestLoadEncryptAllSensitive tests CSR and certificate encryption is opt-in
+func TestLoadEncryptAllSensitive(t *testing.T) {
+	os.Unsetenv("ENCRYPT_ALL_SENSITIVE")
+	defer os.Unsetenv("ENCRYPT_ALL_SENSITIVE")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.AWS.EncryptAllSensitive, "Only private keys are encrypted by default")
+
+	os.Setenv("ENCRYPT_ALL_SENSITIVE", "true")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.AWS.EncryptAllSensitive)
+
+	os.Setenv("ENCRYPT_ALL_SENSITIVE", "always")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ENCRYPT_ALL_SENSITIVE")
+}
+
+// TestLoadKMSContextTags tests the encryption context tags are opt-in and listed at most once
+func TestLoadKMSContextTags(t *testing.T) {
+	os.Unsetenv("KMS_CONTEXT_TAGS")
+	defer os.Unsetenv("KMS_CONTEXT_TAGS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.AWS.KMSContextTags)
+
+	os.Setenv("KMS_CONTEXT_TAGS", "data_class, tenant")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data_class", "tenant"}, cfg.AWS.KMSContextTags)
+
+	os.Setenv("KMS_CONTEXT_TAGS", "data_class,data_class")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "KMS_CONTEXT_TAGS")
+}
+
+// TestLoadAllowedKeyTypes tests the key type allow-list defaults to every supported type and rejects unknown types
+func TestLoadAllowedKeyTypes(t *testing.T) {
+	os.Unsetenv("ALLOWED_KEY_TYPES")
+	defer os.Unsetenv("ALLOWED_KEY_TYPES")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, models.SupportedKeyTypes, cfg.Certificates.AllowedKeyTypes)
+
+	os.Setenv("ALLOWED_KEY_TYPES", "RSA4096, ECDSA-P384")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []models.KeyType{models.KeyTypeRSA4096, models.KeyTypeECDSAP384}, cfg.Certificates.AllowedKeyTypes)
+
+	os.Setenv("ALLOWED_KEY_TYPES", "RSA4096,RSA1024")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `ALLOWED_KEY_TYPES entry "RSA1024" is not a supported key type`)
+}
+
+// TestLoadTagLimits tests the tag limits default to DynamoDB-friendly sizes and can be overridden
+func TestLoadTagLimits(t *testing.T) {
+	for _, name := range []string{"MAX_TAGS", "MAX_TAG_KEY_LEN", "MAX_TAG_VALUE_LEN"} {
+		os.Unsetenv(name)
+		defer os.Unsetenv(name)
+	}
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 50, cfg.Certificates.MaxTags)
+	assert.Equal(t, 128, cfg.Certificates.MaxTagKeyLength)
+	assert.Equal(t, 256, cfg.Certificates.MaxTagValueLength)
+
+	os.Setenv("MAX_TAGS", "10")
+	os.Setenv("MAX_TAG_KEY_LEN", "32")
+	os.Setenv("MAX_TAG_VALUE_LEN", "64")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 10, cfg.Certificates.MaxTags)
+	assert.Equal(t, 32, cfg.Certificates.MaxTagKeyLength)
+	assert.Equal(t, 64, cfg.Certificates.MaxTagValueLength)
+
+	os.Setenv("MAX_TAGS", "0")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MAX_TAGS must be positive")
+}
+
+// TestLoadMaxInflightRequests tests the in-flight request cap is off by default and validated
+func TestLoadMaxInflightRequests(t *testing.T) {
+	os.Unsetenv("MAX_INFLIGHT_REQUESTS")
+	defer os.Unsetenv("MAX_INFLIGHT_REQUESTS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Server.MaxInflightRequests)
+
+	os.Setenv("MAX_INFLIGHT_REQUESTS", "500")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 500, cfg.Server.MaxInflightRequests)
+
+	os.Setenv("MAX_INFLIGHT_REQUESTS", "-1")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MAX_INFLIGHT_REQUESTS must not be negative")
+}
+
+// TestLoadRSAKeyPoolSize tests the RSA key pool size default and validation
+func TestLoadRSAKeyPoolSize(t *testing.T) {
+	os.Unsetenv("RSA_KEY_POOL_SIZE")
+	defer os.Unsetenv("RSA_KEY_POOL_SIZE")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Certificates.RSAKeyPoolSize, "Keys are generated on demand by default")
+
+	os.Setenv("RSA_KEY_POOL_SIZE", "2")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 2, cfg.Certificates.RSAKeyPoolSize)
+
+	os.Setenv("RSA_KEY_POOL_SIZE", "-2")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "RSA_KEY_POOL_SIZE must not be negative")
+}
+
+// TestLoadMaxConcurrentPFX tests the PFX concurrency limit default and validation
+func TestLoadMaxConcurrentPFX(t *testing.T) {
+	os.Unsetenv("MAX_CONCURRENT_PFX")
+	defer os.Unsetenv("MAX_CONCURRENT_PFX")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 4, cfg.Certificates.MaxConcurrentPFX)
+
+	os.Setenv("MAX_CONCURRENT_PFX", "16")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 16, cfg.Certificates.MaxConcurrentPFX)
+
+	os.Setenv("MAX_CONCURRENT_PFX", "0")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MAX_CONCURRENT_PFX must be positive")
+}
+
+// TestLoadRequiredExtKeyUsages tests the required extended key usages are parsed and validated
+func TestLoadRequiredExtKeyUsages(t *testing.T) {
+	os.Unsetenv("REQUIRED_EXT_KEY_USAGES")
+	defer os.Unsetenv("REQUIRED_EXT_KEY_USAGES")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Certificates.RequiredExtKeyUsages)
+
+	os.Setenv("REQUIRED_EXT_KEY_USAGES", "serverAuth, clientAuth")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"serverAuth", "clientAuth"}, cfg.Certificates.RequiredExtKeyUsages)
+
+	os.Setenv("REQUIRED_EXT_KEY_USAGES", "serverAuth,webAuth")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "webAuth")
+}
+
+// TestLoadAllowedIssuers tests approved issuers are split on semicolons so DNs keep their commas
+func TestLoadAllowedIssuers(t *testing.T) {
+	os.Unsetenv("ALLOWED_ISSUERS")
+	defer os.Unsetenv("ALLOWED_ISSUERS")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Certificates.AllowedIssuers)
+
+	os.Setenv("ALLOWED_ISSUERS", "Corp Issuing CA; CN=R11,O=Let's Encrypt,C=US;")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Corp Issuing CA", "CN=R11,O=Let's Encrypt,C=US"}, cfg.Certificates.AllowedIssuers)
+}
+
+// TestLoadSANPolicy tests the SAN checks are off by default and each can be switched on
+func TestLoadSANPolicy(t *testing.T) {
+	for _, key := range []string{"REQUIRE_DNS_SAN", "REQUIRE_CN_IN_SANS", "ENFORCE_SAN_POLICY"} {
+		os.Unsetenv(key)
+		defer os.Unsetenv(key)
+	}
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Certificates.RequireDNSSAN)
+	assert.False(t, cfg.Certificates.RequireCNInSANs)
+	assert.False(t, cfg.Certificates.EnforceSANPolicy)
+
+	os.Setenv("REQUIRE_DNS_SAN", "true")
+	os.Setenv("REQUIRE_CN_IN_SANS", "true")
+	os.Setenv("ENFORCE_SAN_POLICY", "true")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.Certificates.RequireDNSSAN)
+	assert.True(t, cfg.Certificates.RequireCNInSANs)
+	assert.True(t, cfg.Certificates.EnforceSANPolicy)
+
+	os.Setenv("ENFORCE_SAN_POLICY", "sometimes")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ENFORCE_SAN_POLICY")
+}
+
+// TestLoadValidityPolicy tests the maximum certificate validity is read in days, negative values are rejected and the clock skew is a duration
+func TestLoadValidityPolicy(t *testing.T) {
+	os.Unsetenv("MAX_CERT_VALIDITY_DAYS")
+	os.Unsetenv("MAX_CERT_CLOCK_SKEW")
+	os.Unsetenv("ENFORCE_CERT_VALIDITY")
+	defer os.Unsetenv("MAX_CERT_VALIDITY_DAYS")
+	defer os.Unsetenv("MAX_CERT_CLOCK_SKEW")
+	defer os.Unsetenv("ENFORCE_CERT_VALIDITY")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Certificates.MaxValidity)
+	assert.Equal(t, time.Hour, cfg.Certificates.MaxClockSkew)
+	assert.False(t, cfg.Certificates.EnforceValidity)
+
+	os.Setenv("MAX_CERT_VALIDITY_DAYS", "398")
+	os.Setenv("MAX_CERT_CLOCK_SKEW", "10m")
+	os.Setenv("ENFORCE_CERT_VALIDITY", "true")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 398*24*time.Hour, cfg.Certificates.MaxValidity)
+	assert.Equal(t, 10*time.Minute, cfg.Certificates.MaxClockSkew)
+	assert.True(t, cfg.Certificates.EnforceValidity)
+
+	os.Setenv("MAX_CERT_CLOCK_SKEW", "soon")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MAX_CERT_CLOCK_SKEW")
+	os.Unsetenv("MAX_CERT_CLOCK_SKEW")
+
+	os.Setenv("MAX_CERT_VALIDITY_DAYS", "-1")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MAX_CERT_VALIDITY_DAYS")
+}
+
+// TestLoadCORS tests the CORS defaults, route overrides and that credentials are refused with a wildcard origin
+func TestLoadCORS(t *testing.T) {
+	for _, key := range []string{"CORS_ALLOWED_ORIGINS", "CORS_ALLOW_CREDENTIALS", "CORS_MAX_AGE", "CORS_ROUTE_MAX_AGE"} {
+		os.Unsetenv(key)
+		defer os.Unsetenv(key)
+	}
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"*"}, cfg.CORS.AllowedOrigins)
+	assert.False(t, cfg.CORS.AllowCredentials)
+	assert.Equal(t, time.Hour, cfg.CORS.MaxAge)
+	assert.Empty(t, cfg.CORS.RouteMaxAge)
+
+	os.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	_, err = Load()
+	require.Error(t, err, "Credentials must not be allowed for every origin")
+	assert.Contains(t, err.Error(), "CORS_ALLOW_CREDENTIALS")
+
+	os.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com, *")
+	_, err = Load()
+	require.Error(t, err, "A wildcard among explicit origins is still a wildcard")
+
+	os.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com,https://admin.example.com")
+	os.Setenv("CORS_MAX_AGE", "2h")
+	os.Setenv("CORS_ROUTE_MAX_AGE", "/api/v1/keys=10m,/api/v1/admin=0s")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://app.example.com", "https://admin.example.com"}, cfg.CORS.AllowedOrigins)
+	assert.True(t, cfg.CORS.AllowCredentials)
+	assert.Equal(t, 2*time.Hour, cfg.CORS.MaxAge)
+	assert.Equal(t, map[string]time.Duration{"/api/v1/keys": 10 * time.Minute, "/api/v1/admin": 0}, cfg.CORS.RouteMaxAge)
+
+	os.Setenv("CORS_ROUTE_MAX_AGE", "api/v1/keys=10m")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CORS_ROUTE_MAX_AGE")
+}
+
+// TestLoadAuthSchemes tests the Authorization schemes default to Bearer and the API key header is optional
+func TestLoadAuthSchemes(t *testing.T) {
+	for _, key := range []string{"API_KEY_HEADER", "API_KEY_AUTH_SCHEMES"} {
+		os.Unsetenv(key)
+		defer os.Unsetenv(key)
+	}
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Security.APIKeyHeader)
+	assert.Equal(t, []string{AuthSchemeBearer}, cfg.Security.AuthSchemes)
+
+	os.Setenv("API_KEY_HEADER", "x-client-token")
+	os.Setenv("API_KEY_AUTH_SCHEMES", "apikey, Bearer")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "X-Client-Token", cfg.Security.APIKeyHeader)
+	assert.Equal(t, []string{AuthSchemeAPIKey, AuthSchemeBearer}, cfg.Security.AuthSchemes)
+
+	os.Setenv("API_KEY_AUTH_SCHEMES", "Basic")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "API_KEY_AUTH_SCHEMES")
+
+	os.Setenv("API_KEY_AUTH_SCHEMES", "ApiKey")
+	for _, header := range []string{"Authorization", "x-api-key", "X Client"} {
+		os.Setenv("API_KEY_HEADER", header)
+		_, err = Load()
+		require.Error(t, err, header)
+		assert.Contains(t, err.Error(), "API_KEY_HEADER")
+	}
+}
+
+// TestLoadTrustedProxies tests trusted proxies accept IPs and CIDRs and default to none
+func TestLoadTrustedProxies(t *testing.T) {
+	os.Unsetenv("TRUSTED_PROXIES")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Server.TrustedProxies)
+
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/16, 192.0.2.10,2001:db8::/32")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/16", "192.0.2.10", "2001:db8::/32"}, cfg.Server.TrustedProxies)
+
+	os.Setenv("TRUSTED_PROXIES", "alb.internal")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TRUSTED_PROXIES")
+}
+
+// TestLoadMetricsBackend tests the metrics backend defaults to noop and rejects unknown backends
+func TestLoadMetricsBackend(t *testing.T) {
+	os.Unsetenv("METRICS_BACKEND")
+	defer os.Unsetenv("METRICS_BACKEND")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "noop", cfg.Metrics.Backend)
+	assert.Equal(t, "CertificateMonkey", cfg.Metrics.Namespace)
+
+	os.Setenv("METRICS_BACKEND", "emf")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "emf", cfg.Metrics.Backend)
+
+	os.Setenv("METRICS_BACKEND", "statsd")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "METRICS_BACKEND")
+}
+
+// TestLoadAuditSink tests the audit sink defaults to DynamoDB, requires a path for the file sink
+// and rejects unknown sinks
+func TestLoadAuditSink(t *testing.T) {
+	for _, key := range []string{"AUDIT_SINK", "AUDIT_FILE_PATH", "AUDIT_FILE_MAX_SIZE_MB"} {
+		os.Unsetenv(key)
+		defer os.Unsetenv(key)
+	}
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "dynamodb", cfg.Audit.Sink)
+	assert.Equal(t, int64(100<<20), cfg.Audit.FileMaxSize)
+
+	os.Setenv("AUDIT_SINK", "stdout")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "stdout", cfg.Audit.Sink)
+
+	os.Setenv("AUDIT_SINK", "file")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AUDIT_FILE_PATH")
+
+	os.Setenv("AUDIT_FILE_PATH", "/var/log/certificate-monkey/audit.log")
+	os.Setenv("AUDIT_FILE_MAX_SIZE_MB", "10")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "/var/log/certificate-monkey/audit.log", cfg.Audit.FilePath)
+	assert.Equal(t, int64(10<<20), cfg.Audit.FileMaxSize)
+
+	os.Setenv("AUDIT_FILE_MAX_SIZE_MB", "-1")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AUDIT_FILE_MAX_SIZE_MB")
+
+	os.Setenv("AUDIT_SINK", "syslog")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AUDIT_SINK")
+}
+
+// TestLoadACME tests ACME is opt-in and requires an account key and hosted zone when enabled
+func TestLoadACME(t *testing.T) {
+	for _, key := range []string{"ACME_ENABLED", "ACME_ACCOUNT_KEY_PATH", "ACME_ROUTE53_HOSTED_ZONE_ID", "ACME_TIMEOUT"} {
+		os.Unsetenv(key)
+		defer os.Unsetenv(key)
+	}
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.ACME.Enabled, "ACME is disabled by default")
+	assert.Equal(t, "https://acme-v02.api.letsencrypt.org/directory", cfg.ACME.DirectoryURL)
+	assert.Equal(t, 5*time.Minute, cfg.ACME.Timeout)
+
+	os.Setenv("ACME_ENABLED", "true")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ACME_ACCOUNT_KEY_PATH")
+
+	os.Setenv("ACME_ACCOUNT_KEY_PATH", "/etc/certificate-monkey/acme-account.pem")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ACME_ROUTE53_HOSTED_ZONE_ID")
+
+	os.Setenv("ACME_ROUTE53_HOSTED_ZONE_ID", "Z0123456789ABC")
+	os.Setenv("ACME_TIMEOUT", "10m")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.ACME.Enabled)
+	assert.Equal(t, 10*time.Minute, cfg.ACME.Timeout)
+}
+
+// TestLoadAPIKeysFile tests loading named API keys from a JSON file
+func TestLoadAPIKeysFile(t *testing.T) {
+	os.Unsetenv("ADMIN_API_KEYS")
+	defer os.Unsetenv("API_KEYS_FILE")
+
+	writeKeysFile := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "api-keys.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	t.Run("named keys are added", func(t *testing.T) {
+		os.Setenv("API_KEYS_FILE", writeKeysFile(t, `[
+			{"name": "ci-pipeline", "key": "cm_ci_key_123"},
+			{"name": "ops-admin", "key": "cm_ops_admin_456", "admin": true},
+			{"name": "deployer", "key": "cm_deployer_789", "export": true}
+		]`))
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Contains(t, cfg.Security.APIKeys, "cm_ci_key_123")
+		assert.Equal(t, []string{"cm_ops_admin_456"}, cfg.Security.AdminAPIKeys)
+		assert.Equal(t, []string{"cm_deployer_789"}, cfg.Security.ExportAPIKeys)
+		assert.Equal(t, map[string]string{
+			"cm_ci_key_123":    "ci-pipeline",
+			"cm_ops_admin_456": "ops-admin",
+			"cm_deployer_789":  "deployer",
+		}, cfg.Security.KeyNames)
+	})
+
+	tests := []struct {
+		name     string
+		content  string
+		contains string
+	}{
+		{"invalid JSON", `{"name":`, "failed to parse API_KEYS_FILE"},
+		{"missing key", `[{"name": "ci"}]`, "must have a name and a key"},
+		{"duplicate name", `[{"name": "ci", "key": "a"}, {"name": "ci", "key": "b"}]`, "reuses the name"},
+		{"duplicate key", `[{"name": "ci", "key": "a"}, {"name": "ops", "key": "a"}]`, "reuses the key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("API_KEYS_FILE", writeKeysFile(t, tt.content))
+			_, err := Load()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.contains)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		os.Setenv("API_KEYS_FILE", filepath.Join(t.TempDir(), "missing.json"))
+		_, err := Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read API_KEYS_FILE")
+	})
+}
+
+// TestLoadNameDenylist tests denylist rules are read one per line, skipping blanks and comments
+func TestLoadNameDenylist(t *testing.T) {
+	defer os.Unsetenv("NAME_DENYLIST_FILE")
+
+	writeDenylist := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "denylist.txt")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	os.Unsetenv("NAME_DENYLIST_FILE")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Certificates.DeniedNames)
+
+	os.Setenv("NAME_DENYLIST_FILE", writeDenylist(t, "# Reserved internal names\nLocalhost.Example.com\n\n  *.corp.internal  \n"))
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost.example.com", "*.corp.internal"}, cfg.Certificates.DeniedNames)
+
+	os.Setenv("NAME_DENYLIST_FILE", writeDenylist(t, "ok.example.com\n[bad.example.com\n"))
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NAME_DENYLIST_FILE line 2")
+
+	os.Setenv("NAME_DENYLIST_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read NAME_DENYLIST_FILE")
+}
+
 // Benchmark config loading
 func BenchmarkLoad(b *testing.B) {
 	// Set up environment for consistent benchmarking
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// DefaultCipherSuites are the TLS 1.2 cipher suites offered unless TLS_CIPHER_SUITES is set:
+// forward-secret ECDHE key exchange with AEAD ciphers only. TLS 1.3 suites are not configurable
+// in Go and are always the safe defaults.
+var DefaultCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// parseTLSVersion parses a TLS_MIN_VERSION value, "1.2" or "1.3"
+func parseTLSVersion(value string) (uint16, error) {
+	switch value {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("TLS_MIN_VERSION must be \"1.2\" or \"1.3\", got %q", value)
+}
+
+// parseCipherSuites looks up TLS_CIPHER_SUITES entries by their IANA names, such as
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Suites Go considers insecure are rejected, as are TLS 1.3
+// suites, which Go does not let be configured and would otherwise be accepted and silently ignored.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	var suites []uint16
+	for _, name := range names {
+		found := false
+		for _, suite := range tls.CipherSuites() {
+			if suite.Name != name {
+				continue
+			}
+			if !slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+				return nil, fmt.Errorf("TLS_CIPHER_SUITES entry %q is a TLS 1.3 cipher suite, which cannot be configured; list TLS 1.2 suites only", name)
+			}
+			suites = append(suites, suite.ID)
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("TLS_CIPHER_SUITES entry %q is not a supported secure cipher suite", name)
+		}
+	}
+	return suites, nil
+}
+
+// ServerTLSConfig builds the TLS settings for the HTTP server. With mTLS enabled a client
+// certificate, when presented, must verify against the client CA bundle; connections without one
+// are accepted so health probes keep working, and the API routes require the certificate.
+// The server certificate itself is loaded from CertPath and KeyPath when serving. Connections
+// below MinVersion (default TLS 1.2) are refused and TLS 1.2 is limited to CipherSuites,
+// DefaultCipherSuites when none are configured.
+func (t TLSConfig) ServerTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:   t.MinVersion,
+		CipherSuites: t.CipherSuites,
+	}
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+	if len(tlsConfig.CipherSuites) == 0 {
+		tlsConfig.CipherSuites = DefaultCipherSuites
+	}
+
+	if !t.MTLSEnabled() {
+		return tlsConfig, nil
+	}
+
+	caPEM, err := os.ReadFile(t.ClientCAPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("client CA bundle %s contains no PEM certificates", t.ClientCAPath)
+	}
+
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCA writes a self-signed CA certificate to a temporary PEM file
+func writeTestCA(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Client CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "client-ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return path
+}
+
+// Test the server TLS configuration with and without mTLS
+func TestServerTLSConfig(t *testing.T) {
+	t.Run("TLS without client authentication", func(t *testing.T) {
+		tlsConfig, err := TLSConfig{CertPath: "server.pem", KeyPath: "server-key.pem"}.ServerTLSConfig()
+		require.NoError(t, err)
+		assert.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+		assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+		assert.Equal(t, DefaultCipherSuites, tlsConfig.CipherSuites)
+	})
+
+	t.Run("Configured minimum version and cipher suites", func(t *testing.T) {
+		suites := []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}
+		tlsConfig, err := TLSConfig{CertPath: "server.pem", KeyPath: "server-key.pem", MinVersion: tls.VersionTLS13, CipherSuites: suites}.ServerTLSConfig()
+		require.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+		assert.Equal(t, suites, tlsConfig.CipherSuites)
+	})
+
+	t.Run("Default cipher suites are forward secret AEAD suites", func(t *testing.T) {
+		secure := map[uint16]bool{}
+		for _, suite := range tls.CipherSuites() {
+			secure[suite.ID] = true
+		}
+		for _, id := range DefaultCipherSuites {
+			name := tls.CipherSuiteName(id)
+			assert.True(t, secure[id], name)
+			assert.Contains(t, name, "TLS_ECDHE_")
+			assert.NotContains(t, name, "_CBC_")
+		}
+	})
+
+	t.Run("mTLS verifies client certificates when given", func(t *testing.T) {
+		tlsConfig, err := TLSConfig{CertPath: "server.pem", KeyPath: "server-key.pem", ClientCAPath: writeTestCA(t)}.ServerTLSConfig()
+		require.NoError(t, err)
+		assert.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth, "Probes connect without a certificate")
+		require.NotNil(t, tlsConfig.ClientCAs)
+	})
+
+	t.Run("Missing CA bundle", func(t *testing.T) {
+		_, err := TLSConfig{ClientCAPath: filepath.Join(t.TempDir(), "missing.pem")}.ServerTLSConfig()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read client CA bundle")
+	})
+
+	t.Run("CA bundle without certificates", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "empty.pem")
+		require.NoError(t, os.WriteFile(path, []byte("not a certificate"), 0o600))
+
+		_, err := TLSConfig{ClientCAPath: path}.ServerTLSConfig()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "contains no PEM certificates")
+	})
+}
+
+// Test TLS settings are loaded and validated
+func TestLoadTLSConfig(t *testing.T) {
+	keys := []string{"TLS_CERT_PATH", "TLS_KEY_PATH", "CLIENT_CA_PATH", "MTLS_REQUIRE_API_KEY", "MTLS_ADMIN_IDENTITIES", "TLS_MIN_VERSION", "TLS_CIPHER_SUITES"}
+	unsetTLSEnv := func() {
+		for _, key := range keys {
+			os.Unsetenv(key)
+		}
+	}
+	unsetTLSEnv()
+	defer unsetTLSEnv()
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.TLS.Enabled())
+	assert.False(t, cfg.TLS.MTLSEnabled())
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.TLS.MinVersion)
+	assert.Empty(t, cfg.TLS.CipherSuites)
+
+	os.Setenv("TLS_MIN_VERSION", "1.3")
+	os.Setenv("TLS_CIPHER_SUITES", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.TLS.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}, cfg.TLS.CipherSuites)
+
+	os.Setenv("TLS_MIN_VERSION", "1.1")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TLS_MIN_VERSION")
+	os.Unsetenv("TLS_MIN_VERSION")
+
+	// Insecure suites are refused, not just unknown names
+	os.Setenv("TLS_CIPHER_SUITES", "TLS_RSA_WITH_RC4_128_SHA")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TLS_RSA_WITH_RC4_128_SHA")
+
+	// TLS 1.3 suites cannot be configured, so listing one is an error rather than a no-op
+	for _, name := range []string{"TLS_AES_128_GCM_SHA256", "TLS_AES_256_GCM_SHA384", "TLS_CHACHA20_POLY1305_SHA256"} {
+		os.Setenv("TLS_CIPHER_SUITES", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,"+name)
+		_, err = Load()
+		require.Error(t, err, name)
+		assert.Contains(t, err.Error(), name+`" is a TLS 1.3 cipher suite`)
+	}
+	os.Unsetenv("TLS_CIPHER_SUITES")
+
+	os.Setenv("TLS_CERT_PATH", "/tls/server.pem")
+	os.Setenv("TLS_KEY_PATH", "/tls/server-key.pem")
+	os.Setenv("CLIENT_CA_PATH", "/tls/client-ca.pem")
+	os.Setenv("MTLS_REQUIRE_API_KEY", "true")
+	os.Setenv("MTLS_ADMIN_IDENTITIES", "ops-automation, backup-job")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.TLS.Enabled())
+	assert.True(t, cfg.TLS.MTLSEnabled())
+	assert.True(t, cfg.TLS.RequireAPIKey)
+	assert.Equal(t, []string{"ops-automation", "backup-job"}, cfg.TLS.AdminIdentities)
+
+	os.Setenv("MTLS_REQUIRE_API_KEY", "sometimes")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MTLS_REQUIRE_API_KEY")
+	os.Unsetenv("MTLS_REQUIRE_API_KEY")
+
+	os.Unsetenv("TLS_KEY_PATH")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be set together")
+
+	os.Unsetenv("TLS_CERT_PATH")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CLIENT_CA_PATH requires")
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"certificate-monkey/internal/crypto"
+	"certificate-monkey/internal/notify"
+)
+
+// insecureDefaultAPIKeys are the published development keys API_KEY_1 and API_KEY_2 fall back to
+var insecureDefaultAPIKeys = map[string]bool{
+	"cm_dev_12345":  true,
+	"cm_prod_67890": true,
+}
+
+var (
+	// regionPattern matches AWS region names such as eu-central-1 and us-gov-west-1
+	regionPattern = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]*)?-[a-z]+-[0-9]+$`)
+	// tableNamePattern matches valid DynamoDB table names
+	tableNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,255}$`)
+	// kmsKeyIDPattern matches a KMS key ID, key ARN, alias name or alias ARN
+	kmsKeyIDPattern = regexp.MustCompile(`^(` +
+		`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|mrk-[0-9a-f]{32}|` +
+		`alias/[a-zA-Z0-9/_-]+|` +
+		`arn:aws[a-z-]*:kms:[a-z0-9-]+:[0-9]{12}:(key/([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|mrk-[0-9a-f]{32})|alias/[a-zA-Z0-9/_-]+)` +
+		`)$`)
+)
+
+// ValidationError lists every problem Validate found in a configuration
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		messages[i] = problem.Error()
+	}
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(messages, "; "))
+}
+
+// Unwrap exposes the individual problems to errors.Is and errors.As
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// IsProduction reports whether the configuration is for a production deployment
+func (c *Config) IsProduction() bool {
+	return strings.EqualFold(c.Environment, "production")
+}
+
+// InsecureAPIKeys names the settings, such as API_KEY_1, whose key is one of the published
+// development defaults. Any client knowing the defaults can use such a key.
+func (c *Config) InsecureAPIKeys() []string {
+	var names []string
+	for i, key := range c.Security.APIKeys {
+		if !insecureDefaultAPIKeys[key] {
+			continue
+		}
+		// API_KEY_1 and API_KEY_2 come first; later keys are from API_KEYS_FILE
+		if i < 2 {
+			names = append(names, fmt.Sprintf("API_KEY_%d", i+1))
+		} else {
+			names = append(names, "API_KEYS_FILE")
+		}
+	}
+	for _, key := range c.Security.AdminAPIKeys {
+		if insecureDefaultAPIKeys[key] {
+			names = append(names, "ADMIN_API_KEYS")
+		}
+	}
+	return names
+}
+
+// Validate checks the settings that would otherwise only fail on the first request that uses
+// them. Every problem found is reported in one *ValidationError, so a deployment can be fixed in one go.
+func (c *Config) Validate() error {
+	var problems []error
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			problems = append(problems, fmt.Errorf("TRUSTED_PROXIES entry %q is not an IP address or CIDR", proxy))
+		}
+	}
+
+	if c.AWS.Region == "" {
+		problems = append(problems, errors.New("AWS_REGION is required"))
+	} else if !regionPattern.MatchString(c.AWS.Region) {
+		problems = append(problems, fmt.Errorf("AWS_REGION %q is not a region name such as eu-central-1", c.AWS.Region))
+	}
+
+	if c.AWS.DynamoDBTable == "" {
+		problems = append(problems, errors.New("DYNAMODB_TABLE is required"))
+	} else if !tableNamePattern.MatchString(c.AWS.DynamoDBTable) {
+		problems = append(problems, fmt.Errorf("DYNAMODB_TABLE %q is not a valid table name", c.AWS.DynamoDBTable))
+	}
+	if c.AWS.EventsTable != "" && !tableNamePattern.MatchString(c.AWS.EventsTable) {
+		problems = append(problems, fmt.Errorf("DYNAMODB_EVENTS_TABLE %q is not a valid table name", c.AWS.EventsTable))
+	}
+
+	if c.AWS.KMSKeyID == "" {
+		problems = append(problems, errors.New("KMS_KEY_ID is required"))
+	} else if !kmsKeyIDPattern.MatchString(c.AWS.KMSKeyID) {
+		problems = append(problems, fmt.Errorf("KMS_KEY_ID %q is not a key ID, key ARN, alias or alias ARN", c.AWS.KMSKeyID))
+	}
+	if c.AWS.KMSRekeyRate <= 0 {
+		problems = append(problems, errors.New("KMS_REKEY_RATE must be positive"))
+	}
+	if c.AWS.KMSMaxAttempts < 1 || c.AWS.KMSMaxAttempts > 10 {
+		problems = append(problems, fmt.Errorf("KMS_MAX_ATTEMPTS must be between 1 and 10, got %d", c.AWS.KMSMaxAttempts))
+	}
+	seenContextTags := make(map[string]bool, len(c.AWS.KMSContextTags))
+	for _, tag := range c.AWS.KMSContextTags {
+		if seenContextTags[tag] {
+			problems = append(problems, fmt.Errorf("KMS_CONTEXT_TAGS lists %q more than once", tag))
+		}
+		seenContextTags[tag] = true
+	}
+
+	if (c.TLS.CertPath == "") != (c.TLS.KeyPath == "") {
+		problems = append(problems, errors.New("TLS_CERT_PATH and TLS_KEY_PATH must be set together"))
+	}
+	if c.TLS.MTLSEnabled() && !c.TLS.Enabled() {
+		problems = append(problems, errors.New("CLIENT_CA_PATH requires TLS_CERT_PATH and TLS_KEY_PATH"))
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" && c.CORS.AllowCredentials {
+			problems = append(problems, errors.New("CORS_ALLOW_CREDENTIALS requires explicit CORS_ALLOWED_ORIGINS, not \"*\""))
+		}
+	}
+
+	if c.Metrics.Backend != "noop" && c.Metrics.Backend != "emf" {
+		problems = append(problems, fmt.Errorf("METRICS_BACKEND must be \"noop\" or \"emf\", got %q", c.Metrics.Backend))
+	}
+	switch c.Audit.Sink {
+	case "dynamodb", "stdout":
+	case "file":
+		if c.Audit.FilePath == "" {
+			problems = append(problems, errors.New("AUDIT_SINK=file requires AUDIT_FILE_PATH"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("AUDIT_SINK must be \"dynamodb\", \"stdout\" or \"file\", got %q", c.Audit.Sink))
+	}
+	if c.Audit.FileMaxSize < 0 {
+		problems = append(problems, errors.New("AUDIT_FILE_MAX_SIZE_MB must not be negative"))
+	}
+
+	if c.Notify.WebhookURL != "" {
+		webhookURL, err := url.Parse(c.Notify.WebhookURL)
+		if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+			problems = append(problems, errors.New("NOTIFY_WEBHOOK_URL must be an http or https URL"))
+		}
+	}
+	if c.Notify.SNSTopicARN != "" && !notify.IsSNSTopicARN(c.Notify.SNSTopicARN) {
+		problems = append(problems, fmt.Errorf("SNS_TOPIC_ARN %q must be an SNS topic ARN, arn:aws:sns:<region>:<account>:<topic>", c.Notify.SNSTopicARN))
+	}
+	for _, event := range c.Notify.Events {
+		if event == notify.EventExpiring {
+			problems = append(problems, fmt.Errorf("NOTIFY_EVENTS entry %q is not supported yet: expiry warnings are not sent", event))
+		} else if !notify.IsEvent(event) {
+			problems = append(problems, fmt.Errorf("NOTIFY_EVENTS entry %q must be one of %s", event, strings.Join(notify.Events, ", ")))
+		}
+	}
+
+	if c.ACME.Enabled {
+		if c.ACME.AccountKeyPath == "" {
+			problems = append(problems, errors.New("ACME_ENABLED requires ACME_ACCOUNT_KEY_PATH"))
+		}
+		if c.ACME.Route53HostedZoneID == "" {
+			problems = append(problems, errors.New("ACME_ENABLED requires ACME_ROUTE53_HOSTED_ZONE_ID"))
+		}
+	}
+
+	if c.Certificates.MaxTags <= 0 {
+		problems = append(problems, errors.New("MAX_TAGS must be positive"))
+	}
+	if c.Certificates.MaxTagKeyLength <= 0 {
+		problems = append(problems, errors.New("MAX_TAG_KEY_LEN must be positive"))
+	}
+	if c.Certificates.MaxTagValueLength <= 0 {
+		problems = append(problems, errors.New("MAX_TAG_VALUE_LEN must be positive"))
+	}
+	if c.Server.MaxInflightRequests < 0 {
+		problems = append(problems, errors.New("MAX_INFLIGHT_REQUESTS must not be negative"))
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		problems = append(problems, errors.New("MAX_HEADER_BYTES must be positive"))
+	}
+	if c.Certificates.RSAKeyPoolSize < 0 {
+		problems = append(problems, errors.New("RSA_KEY_POOL_SIZE must not be negative"))
+	}
+	if c.Certificates.MaxConcurrentPFX <= 0 {
+		problems = append(problems, errors.New("MAX_CONCURRENT_PFX must be positive"))
+	}
+	// Requests are limited to 100 SANs, so a higher cap would never be reached
+	if c.Certificates.MaxSANs < 1 || c.Certificates.MaxSANs > 100 {
+		problems = append(problems, fmt.Errorf("MAX_SANS must be between 1 and 100, got %d", c.Certificates.MaxSANs))
+	}
+	if c.Certificates.MaxValidity < 0 {
+		problems = append(problems, errors.New("MAX_CERT_VALIDITY_DAYS must not be negative"))
+	}
+	for i, country := range c.Certificates.AllowedCountries {
+		if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+			problems = append(problems, fmt.Errorf("ALLOWED_COUNTRIES entry %d (%q) is not a two-letter country code", i+1, country))
+		}
+	}
+	for _, keyType := range c.Certificates.AllowedKeyTypes {
+		if !keyType.IsSupported() {
+			problems = append(problems, fmt.Errorf("ALLOWED_KEY_TYPES entry %q is not a supported key type", keyType))
+		}
+	}
+	for _, usage := range c.Certificates.RequiredExtKeyUsages {
+		if !crypto.IsExtKeyUsageName(usage) {
+			problems = append(problems, fmt.Errorf("REQUIRED_EXT_KEY_USAGES entry %q is not a known extended key usage", usage))
+		}
+	}
+
+	switch c.Security.APIKeyHeader {
+	case "X-Api-Key", "Authorization":
+		problems = append(problems, fmt.Errorf("API_KEY_HEADER must not be %s, which is always read", c.Security.APIKeyHeader))
+	}
+	if strings.ContainsAny(c.Security.APIKeyHeader, " \t:") {
+		problems = append(problems, fmt.Errorf("API_KEY_HEADER %q is not a valid header name", c.Security.APIKeyHeader))
+	}
+	for _, scheme := range c.Security.AuthSchemes {
+		if scheme != AuthSchemeBearer && scheme != AuthSchemeAPIKey {
+			problems = append(problems, fmt.Errorf("API_KEY_AUTH_SCHEMES entry %q must be %q or %q", scheme, AuthSchemeBearer, AuthSchemeAPIKey))
+		}
+	}
+
+	for i, key := range c.Security.APIKeys {
+		if key == "" && i < 2 {
+			problems = append(problems, fmt.Errorf("API_KEY_%d is required", i+1))
+		}
+	}
+	if c.IsProduction() {
+		for _, name := range c.InsecureAPIKeys() {
+			problems = append(problems, fmt.Errorf("%s must not use the insecure default key in production", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
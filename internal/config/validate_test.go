@@ -0,0 +1,230 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/models"
+)
+
+// validConfig returns a configuration that passes Validate
+func validConfig() *Config {
+	return &Config{
+		Environment: "production",
+		Server:      ServerConfig{MaxHeaderBytes: 16384},
+		AWS: AWSConfig{
+			Region:         "eu-central-1",
+			DynamoDBTable:  "certificate-monkey",
+			KMSKeyID:       "alias/certificate-monkey",
+			KMSRekeyRate:   10,
+			KMSMaxAttempts: 3,
+		},
+		Security: SecurityConfig{
+			APIKeys: []string{"cm_live_a1b2c3", "cm_live_d4e5f6"},
+		},
+		Certificates: CertificateConfig{
+			MaxTags:           50,
+			MaxTagKeyLength:   128,
+			MaxTagValueLength: 256,
+			MaxConcurrentPFX:  4,
+			MaxSANs:           100,
+		},
+		Metrics: MetricsConfig{Backend: "noop"},
+		Audit:   AuditConfig{Sink: "dynamodb"},
+	}
+}
+
+// TestValidate tests each invalid setting is reported and a valid configuration passes
+func TestValidate(t *testing.T) {
+	require.NoError(t, validConfig().Validate())
+
+	for _, keyID := range []string{
+		"1234abcd-12ab-34cd-56ef-1234567890ab",
+		"mrk-1234abcd12ab34cd56ef1234567890ab",
+		"arn:aws:kms:eu-central-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab",
+		"arn:aws-us-gov:kms:us-gov-west-1:123456789012:alias/certificate-monkey",
+	} {
+		cfg := validConfig()
+		cfg.AWS.KMSKeyID = keyID
+		assert.NoError(t, cfg.Validate(), keyID)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		problem string
+	}{
+		{"empty region", func(cfg *Config) { cfg.AWS.Region = "" }, "AWS_REGION is required"},
+		{"malformed region", func(cfg *Config) { cfg.AWS.Region = "Frankfurt" }, `AWS_REGION "Frankfurt" is not a region name`},
+		{"empty table", func(cfg *Config) { cfg.AWS.DynamoDBTable = "" }, "DYNAMODB_TABLE is required"},
+		{"invalid table", func(cfg *Config) { cfg.AWS.DynamoDBTable = "certificate monkey" }, "is not a valid table name"},
+		{"invalid events table", func(cfg *Config) { cfg.AWS.EventsTable = "ev" }, "DYNAMODB_EVENTS_TABLE"},
+		{"empty KMS key", func(cfg *Config) { cfg.AWS.KMSKeyID = "" }, "KMS_KEY_ID is required"},
+		{"malformed KMS key", func(cfg *Config) { cfg.AWS.KMSKeyID = "certificate-monkey" }, "is not a key ID, key ARN, alias or alias ARN"},
+		{"rekey rate", func(cfg *Config) { cfg.AWS.KMSRekeyRate = 0 }, "KMS_REKEY_RATE must be positive"},
+		{"tag count", func(cfg *Config) { cfg.Certificates.MaxTags = 0 }, "MAX_TAGS must be positive"},
+		{"tag key length", func(cfg *Config) { cfg.Certificates.MaxTagKeyLength = -1 }, "MAX_TAG_KEY_LEN must be positive"},
+		{"tag value length", func(cfg *Config) { cfg.Certificates.MaxTagValueLength = 0 }, "MAX_TAG_VALUE_LEN must be positive"},
+		{"in-flight requests", func(cfg *Config) { cfg.Server.MaxInflightRequests = -1 }, "MAX_INFLIGHT_REQUESTS must not be negative"},
+		{"header bytes", func(cfg *Config) { cfg.Server.MaxHeaderBytes = 0 }, "MAX_HEADER_BYTES must be positive"},
+		{"RSA key pool", func(cfg *Config) { cfg.Certificates.RSAKeyPoolSize = -1 }, "RSA_KEY_POOL_SIZE must not be negative"},
+		{"concurrent PFX", func(cfg *Config) { cfg.Certificates.MaxConcurrentPFX = 0 }, "MAX_CONCURRENT_PFX must be positive"},
+		{"trusted proxy", func(cfg *Config) { cfg.Server.TrustedProxies = []string{"lb.internal"} }, `TRUSTED_PROXIES entry "lb.internal"`},
+		{"KMS attempts", func(cfg *Config) { cfg.AWS.KMSMaxAttempts = 11 }, "KMS_MAX_ATTEMPTS must be between 1 and 10"},
+		{"KMS context tags", func(cfg *Config) { cfg.AWS.KMSContextTags = []string{"team", "team"} }, `KMS_CONTEXT_TAGS lists "team" more than once`},
+		{"TLS key without certificate", func(cfg *Config) { cfg.TLS.KeyPath = "server.key" }, "TLS_CERT_PATH and TLS_KEY_PATH must be set together"},
+		{"mTLS without TLS", func(cfg *Config) { cfg.TLS.ClientCAPath = "ca.pem" }, "CLIENT_CA_PATH requires TLS_CERT_PATH and TLS_KEY_PATH"},
+		{"credentialed wildcard origin", func(cfg *Config) {
+			cfg.CORS = CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+		}, "CORS_ALLOW_CREDENTIALS requires explicit CORS_ALLOWED_ORIGINS"},
+		{"metrics backend", func(cfg *Config) { cfg.Metrics.Backend = "statsd" }, "METRICS_BACKEND"},
+		{"audit sink", func(cfg *Config) { cfg.Audit.Sink = "syslog" }, "AUDIT_SINK must be"},
+		{"audit file path", func(cfg *Config) { cfg.Audit.Sink = "file" }, "AUDIT_SINK=file requires AUDIT_FILE_PATH"},
+		{"webhook URL", func(cfg *Config) { cfg.Notify.WebhookURL = "ftp://hooks.example.com" }, "NOTIFY_WEBHOOK_URL must be an http or https URL"},
+		{"SNS topic", func(cfg *Config) { cfg.Notify.SNSTopicARN = "arn:aws:sqs:eu-west-1:123456789012:certificates" }, "SNS_TOPIC_ARN"},
+		{"notify event", func(cfg *Config) { cfg.Notify.Events = []string{"revoked"} }, `NOTIFY_EVENTS entry "revoked"`},
+		{"expiring event", func(cfg *Config) { cfg.Notify.Events = []string{"issued", "expiring"} }, `NOTIFY_EVENTS entry "expiring" is not supported yet`},
+		{"ACME account key", func(cfg *Config) { cfg.ACME = ACMEConfig{Enabled: true, Route53HostedZoneID: "Z1"} }, "ACME_ENABLED requires ACME_ACCOUNT_KEY_PATH"},
+		{"SAN cap", func(cfg *Config) { cfg.Certificates.MaxSANs = 101 }, "MAX_SANS must be between 1 and 100"},
+		{"validity", func(cfg *Config) { cfg.Certificates.MaxValidity = -time.Hour }, "MAX_CERT_VALIDITY_DAYS must not be negative"},
+		{"country", func(cfg *Config) { cfg.Certificates.AllowedCountries = []string{"NLD"} }, `ALLOWED_COUNTRIES entry 1 ("NLD")`},
+		{"key type", func(cfg *Config) { cfg.Certificates.AllowedKeyTypes = []models.KeyType{"DSA1024"} }, `ALLOWED_KEY_TYPES entry "DSA1024"`},
+		{"extended key usage", func(cfg *Config) { cfg.Certificates.RequiredExtKeyUsages = []string{"teleport"} }, `REQUIRED_EXT_KEY_USAGES entry "teleport"`},
+		{"API key header", func(cfg *Config) { cfg.Security.APIKeyHeader = "Authorization" }, "API_KEY_HEADER must not be Authorization"},
+		{"auth scheme", func(cfg *Config) { cfg.Security.AuthSchemes = []string{"Basic"} }, `API_KEY_AUTH_SCHEMES entry "Basic"`},
+		{"empty API key", func(cfg *Config) { cfg.Security.APIKeys[1] = "" }, "API_KEY_2 is required"},
+		{"default API key in production", func(cfg *Config) { cfg.Security.APIKeys[0] = "cm_dev_12345" }, "API_KEY_1 must not use the insecure default key in production"},
+		{"default API key in file", func(cfg *Config) {
+			cfg.Security.APIKeys = append(cfg.Security.APIKeys, "cm_prod_67890")
+		}, "API_KEYS_FILE must not use the insecure default key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.problem)
+		})
+	}
+
+	t.Run("default API keys outside production", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.Environment = "development"
+		cfg.Security.APIKeys = []string{"cm_dev_12345", "cm_prod_67890"}
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("problems are aggregated", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.AWS.Region = "nowhere"
+		cfg.AWS.KMSKeyID = ""
+		cfg.Security.APIKeys[0] = "cm_dev_12345"
+
+		err := cfg.Validate()
+		var validationErr *ValidationError
+		require.True(t, errors.As(err, &validationErr))
+		assert.Len(t, validationErr.Problems, 3)
+		assert.Contains(t, err.Error(), "invalid configuration: ")
+		assert.Contains(t, err.Error(), "AWS_REGION")
+		assert.Contains(t, err.Error(), "KMS_KEY_ID")
+		assert.Contains(t, err.Error(), "API_KEY_1")
+	})
+}
+
+// TestLoadValidates tests Load fails on an invalid configuration
+func TestLoadValidates(t *testing.T) {
+	os.Setenv("AWS_REGION", "eu_central_1")
+	os.Setenv("ENV", "production")
+	defer os.Unsetenv("AWS_REGION")
+	defer os.Unsetenv("ENV")
+
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AWS_REGION")
+	assert.Contains(t, err.Error(), "API_KEY_1 must not use the insecure default key in production")
+	assert.Contains(t, err.Error(), "API_KEY_2 must not use the insecure default key in production")
+}
+
+// TestLoadReportsEveryProblem tests Load reports unreadable settings and invalid values together
+// instead of stopping at the first one
+func TestLoadReportsEveryProblem(t *testing.T) {
+	settings := map[string]string{
+		"MAX_TAGS":          "fifty",
+		"KMS_MAX_ATTEMPTS":  "0",
+		"MAX_SANS":          "500",
+		"TLS_KEY_PATH":      "server.key",
+		"ALLOW_WILDCARDS":   "sometimes",
+		"NOTIFY_EVENTS":     "revoked",
+		"AUDIT_SINK":        "syslog",
+		"ALLOWED_COUNTRIES": "NL,Netherlands",
+	}
+	for key, value := range settings {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+
+	_, err := Load()
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, len(settings))
+	for key := range settings {
+		assert.Contains(t, err.Error(), key)
+	}
+}
+
+// TestInsecureAPIKeys tests the default keys are refused in production and only reported for a warning elsewhere
+func TestInsecureAPIKeys(t *testing.T) {
+	cfg := validConfig()
+	assert.Empty(t, cfg.InsecureAPIKeys())
+
+	cfg.Security.APIKeys = []string{"cm_dev_12345", "cm_live_d4e5f6", "cm_prod_67890"}
+	cfg.Security.AdminAPIKeys = []string{"cm_dev_12345"}
+	assert.Equal(t, []string{"API_KEY_1", "API_KEYS_FILE", "ADMIN_API_KEYS"}, cfg.InsecureAPIKeys())
+
+	for _, env := range []string{"ENV", "GIN_MODE", "API_KEY_1", "API_KEY_2"} {
+		os.Unsetenv(env)
+		defer os.Unsetenv(env)
+	}
+
+	t.Run("warning in development", func(t *testing.T) {
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.False(t, cfg.IsProduction())
+		assert.Equal(t, []string{"API_KEY_1", "API_KEY_2"}, cfg.InsecureAPIKeys())
+	})
+
+	t.Run("refused in release mode", func(t *testing.T) {
+		os.Setenv("GIN_MODE", "release")
+		defer os.Unsetenv("GIN_MODE")
+
+		_, err := Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "API_KEY_1 must not use the insecure default key in production")
+
+		// An explicit environment takes precedence over the gin mode
+		os.Setenv("ENV", "staging")
+		defer os.Unsetenv("ENV")
+		_, err = Load()
+		assert.NoError(t, err)
+	})
+
+	t.Run("accepted in production with real keys", func(t *testing.T) {
+		os.Setenv("ENV", "production")
+		os.Setenv("API_KEY_1", "cm_live_a1b2c3")
+		os.Setenv("API_KEY_2", "cm_live_d4e5f6")
+		defer os.Unsetenv("ENV")
+		defer os.Unsetenv("API_KEY_1")
+		defer os.Unsetenv("API_KEY_2")
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.True(t, cfg.IsProduction())
+		assert.Empty(t, cfg.InsecureAPIKeys())
+	})
+}
@@ -0,0 +1,417 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"certificate-monkey/internal/models"
+)
+
+// ParseCertificates parses every certificate in a PEM bundle, in order
+func (cs *CryptoService) ParseCertificates(pemData string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(pemData)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("invalid certificate PEM block type: %s", block.Type)
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d: %w", len(certs)+1, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+	return certs, nil
+}
+
+// DescribeCertificate summarizes a parsed certificate; validity is evaluated at now
+func (cs *CryptoService) DescribeCertificate(cert *x509.Certificate, now time.Time) models.CertificateDetails {
+	keyAlgorithm, keySize := describePublicKey(cert.PublicKey)
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	details := models.CertificateDetails{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       cert.SerialNumber.String(),
+		SerialNumberHex:    cs.FormatSerialNumberHex(cert.SerialNumber),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		Expired:            now.After(cert.NotAfter),
+		NotYetValid:        now.Before(cert.NotBefore),
+		DNSNames:           cert.DNSNames,
+		EmailAddresses:     cert.EmailAddresses,
+		KeyAlgorithm:       keyAlgorithm,
+		KeySize:            keySize,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		IsCA:               cert.IsCA,
+		SelfSigned:         isSelfSigned(cert),
+		FingerprintSHA256:  formatHexBytes(fingerprint[:]),
+	}
+
+	for _, ip := range cert.IPAddresses {
+		details.IPAddresses = append(details.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		details.URIs = append(details.URIs, uri.String())
+	}
+	details.KeyUsages, details.ExtKeyUsages = cs.DescribeKeyUsages(cert)
+	details.AuthorityKeyID, details.SubjectKeyID = cs.KeyIdentifiers(cert)
+
+	return details
+}
+
+// certificateTextTime is the time layout of openssl x509 -text
+const certificateTextTime = "Jan _2 15:04:05 2006 MST"
+
+// FormatCertificateText renders a parsed certificate as a human-readable description laid out like
+// openssl x509 -text: subject, issuer, validity, serial number, signature algorithm, key, key usages,
+// basic constraints, key identifiers and SANs. Names use the same notation as DescribeCertificate.
+func (cs *CryptoService) FormatCertificateText(cert *x509.Certificate) string {
+	var b strings.Builder
+	line := func(indent int, format string, args ...interface{}) {
+		b.WriteString(strings.Repeat(" ", indent))
+		fmt.Fprintf(&b, format, args...)
+		b.WriteByte('\n')
+	}
+
+	line(0, "Certificate:")
+	line(4, "Data:")
+	line(8, "Version: %d (0x%x)", cert.Version, cert.Version-1)
+	if cert.SerialNumber.IsInt64() && cert.SerialNumber.Sign() >= 0 {
+		line(8, "Serial Number: %d (0x%x)", cert.SerialNumber.Int64(), cert.SerialNumber.Int64())
+	} else {
+		line(8, "Serial Number:")
+		line(12, "%s", cs.FormatSerialNumberHex(cert.SerialNumber))
+	}
+	line(8, "Signature Algorithm: %s", cert.SignatureAlgorithm)
+	line(8, "Issuer: %s", cert.Issuer)
+	line(8, "Validity")
+	line(12, "Not Before: %s", cert.NotBefore.UTC().Format(certificateTextTime))
+	line(12, "Not After : %s", cert.NotAfter.UTC().Format(certificateTextTime))
+	line(8, "Subject: %s", cert.Subject)
+	keyAlgorithm, keySize := describePublicKey(cert.PublicKey)
+	line(8, "Subject Public Key Info:")
+	line(12, "Public Key Algorithm: %s", keyAlgorithm)
+	line(16, "Public-Key: (%d bit)", keySize)
+
+	// Extensions in the order openssl prints them, each with its value on the following line
+	var extensions [][2]string
+	keyUsages, extKeyUsages := cs.DescribeKeyUsages(cert)
+	if len(keyUsages) > 0 {
+		extensions = append(extensions, [2]string{"Key Usage", strings.Join(keyUsages, ", ")})
+	}
+	if len(extKeyUsages) > 0 {
+		extensions = append(extensions, [2]string{"Extended Key Usage", strings.Join(extKeyUsages, ", ")})
+	}
+	if cert.BasicConstraintsValid {
+		constraints := "CA:" + strings.ToUpper(strconv.FormatBool(cert.IsCA))
+		if cert.IsCA && (cert.MaxPathLen > 0 || cert.MaxPathLenZero) {
+			constraints += fmt.Sprintf(", pathlen:%d", cert.MaxPathLen)
+		}
+		extensions = append(extensions, [2]string{"Basic Constraints", constraints})
+	}
+	authorityKeyID, subjectKeyID := cs.KeyIdentifiers(cert)
+	if subjectKeyID != "" {
+		extensions = append(extensions, [2]string{"Subject Key Identifier", subjectKeyID})
+	}
+	if authorityKeyID != "" {
+		extensions = append(extensions, [2]string{"Authority Key Identifier", authorityKeyID})
+	}
+	var sans []string
+	for _, name := range cert.DNSNames {
+		sans = append(sans, "DNS:"+name)
+	}
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, "IP Address:"+ip.String())
+	}
+	for _, email := range cert.EmailAddresses {
+		sans = append(sans, "email:"+email)
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, "URI:"+uri.String())
+	}
+	if len(sans) > 0 {
+		extensions = append(extensions, [2]string{"Subject Alternative Name", strings.Join(sans, ", ")})
+	}
+	if len(extensions) > 0 {
+		line(8, "X509v3 extensions:")
+		for _, extension := range extensions {
+			line(12, "X509v3 %s:", extension[0])
+			line(16, "%s", extension[1])
+		}
+	}
+
+	fingerprint := sha256.Sum256(cert.Raw)
+	line(4, "SHA256 Fingerprint=%s", formatHexBytes(fingerprint[:]))
+	return b.String()
+}
+
+// ParseCSR parses a PEM-encoded certificate signing request
+func (cs *CryptoService) ParseCSR(csrPEM string) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode CSR PEM block")
+	}
+
+	if block.Type != "CERTIFICATE REQUEST" && block.Type != "NEW CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("invalid CSR PEM block type: %s", block.Type)
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSR: %w", err)
+	}
+
+	return csr, nil
+}
+
+// EncodeCSRDER returns the DER encoding of a PEM CSR in standard base64, as EST and ACME clients submit it
+func (cs *CryptoService) EncodeCSRDER(csrPEM string) (string, error) {
+	csr, err := cs.ParseCSR(csrPEM)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(csr.Raw), nil
+}
+
+// DescribeCSR summarizes a parsed CSR, including whether its self-signature verifies
+func (cs *CryptoService) DescribeCSR(csr *x509.CertificateRequest) models.CSRDetails {
+	keyAlgorithm, keySize := describePublicKey(csr.PublicKey)
+
+	details := models.CSRDetails{
+		Subject:            csr.Subject.String(),
+		CommonName:         csr.Subject.CommonName,
+		DNSNames:           csr.DNSNames,
+		EmailAddresses:     csr.EmailAddresses,
+		KeyAlgorithm:       keyAlgorithm,
+		KeySize:            keySize,
+		SignatureAlgorithm: csr.SignatureAlgorithm.String(),
+		SignatureValid:     true,
+	}
+
+	for _, ip := range csr.IPAddresses {
+		details.IPAddresses = append(details.IPAddresses, ip.String())
+	}
+	for _, uri := range csr.URIs {
+		details.URIs = append(details.URIs, uri.String())
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		details.SignatureValid = false
+		details.SignatureError = err.Error()
+	}
+
+	return details
+}
+
+// RequestFromCSR extracts the subject, SANs and key type of a PEM CSR generated elsewhere, so it can
+// be validated like a CreateKey request. The CSR's self-signature must verify, and only the key types
+// and SAN kinds Certificate Monkey itself generates are accepted, up to the SAN limit.
+func (cs *CryptoService) RequestFromCSR(csrPEM string) (models.CreateKeyRequest, error) {
+	csr, err := cs.ParseCSR(csrPEM)
+	if err != nil {
+		return models.CreateKeyRequest{}, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return models.CreateKeyRequest{}, fmt.Errorf("CSR signature is invalid: %w", err)
+	}
+
+	keyType, err := keyTypeOf(csr.PublicKey)
+	if err != nil {
+		return models.CreateKeyRequest{}, err
+	}
+	if len(csr.URIs) > 0 {
+		return models.CreateKeyRequest{}, fmt.Errorf("URI SANs are not supported")
+	}
+	if len(csr.EmailAddresses) > 1 {
+		return models.CreateKeyRequest{}, fmt.Errorf("at most one email address is supported, got %d", len(csr.EmailAddresses))
+	}
+
+	req := models.CreateKeyRequest{
+		CommonName:         csr.Subject.CommonName,
+		Organization:       first(csr.Subject.Organization),
+		OrganizationalUnit: first(csr.Subject.OrganizationalUnit),
+		Country:            first(csr.Subject.Country),
+		State:              first(csr.Subject.Province),
+		City:               first(csr.Subject.Locality),
+		EmailAddress:       first(csr.EmailAddresses),
+		KeyType:            keyType,
+	}
+	req.SubjectAlternativeNames = subjectAlternativeNames(csr)
+	if err := cs.CheckSANCount(len(req.SubjectAlternativeNames)); err != nil {
+		return models.CreateKeyRequest{}, err
+	}
+
+	return req, nil
+}
+
+// CSRNames returns the subject and the SANs a PEM CSR actually carries, DNS names first and then
+// IP addresses in their canonical form, so callers can see what was encoded rather than what was asked for
+func (cs *CryptoService) CSRNames(csrPEM string) (subject string, sans []string, err error) {
+	csr, err := cs.ParseCSR(csrPEM)
+	if err != nil {
+		return "", nil, err
+	}
+	return csr.Subject.String(), subjectAlternativeNames(csr), nil
+}
+
+// subjectAlternativeNames lists a CSR's DNS and IP SANs as strings
+func subjectAlternativeNames(csr *x509.CertificateRequest) []string {
+	var sans []string
+	sans = append(sans, csr.DNSNames...)
+	for _, ip := range csr.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
+// keyTypeOf maps a public key to the key type Certificate Monkey would have generated for it
+func keyTypeOf(publicKey interface{}) (models.KeyType, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		switch key.N.BitLen() {
+		case 2048:
+			return models.KeyTypeRSA2048, nil
+		case 4096:
+			return models.KeyTypeRSA4096, nil
+		}
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return models.KeyTypeECDSAP256, nil
+		case elliptic.P384():
+			return models.KeyTypeECDSAP384, nil
+		}
+	}
+
+	algorithm, size := describePublicKey(publicKey)
+	return "", fmt.Errorf("unsupported %s %d-bit key; supported key types are RSA2048, RSA4096, ECDSA-P256 and ECDSA-P384", algorithm, size)
+}
+
+// first returns the first value of a subject attribute, or "" when it is absent
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// keyUsageNames names the key usage bits in RFC 5280 order
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+// extKeyUsageNames names the extended key usages known to crypto/x509, using the RFC 5280 identifiers where defined
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "any",
+	x509.ExtKeyUsageServerAuth:                     "serverAuth",
+	x509.ExtKeyUsageClientAuth:                     "clientAuth",
+	x509.ExtKeyUsageCodeSigning:                    "codeSigning",
+	x509.ExtKeyUsageEmailProtection:                "emailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "ipsecEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                    "ipsecTunnel",
+	x509.ExtKeyUsageIPSECUser:                      "ipsecUser",
+	x509.ExtKeyUsageTimeStamping:                   "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSPSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "msSGC",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "nsSGC",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "msCodeCom",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "msKernelCodeSigning",
+}
+
+// IsExtKeyUsageName reports whether name is an extended key usage reported by DescribeKeyUsages
+func IsExtKeyUsageName(name string) bool {
+	for _, known := range extKeyUsageNames {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyIdentifiers returns a certificate's authority and subject key identifiers as colon-separated hex,
+// e.g. 3A:1F:..., or "" for an identifier the certificate does not carry. A leaf's authority key
+// identifier equals the subject key identifier of the intermediate that issued it.
+func (cs *CryptoService) KeyIdentifiers(cert *x509.Certificate) (authorityKeyID, subjectKeyID string) {
+	if len(cert.AuthorityKeyId) > 0 {
+		authorityKeyID = formatHexBytes(cert.AuthorityKeyId)
+	}
+	if len(cert.SubjectKeyId) > 0 {
+		subjectKeyID = formatHexBytes(cert.SubjectKeyId)
+	}
+	return authorityKeyID, subjectKeyID
+}
+
+// DescribeKeyUsages returns the names of a certificate's key usages and extended key usages.
+// Extended key usages unknown to crypto/x509 are reported by their dotted OID.
+func (cs *CryptoService) DescribeKeyUsages(cert *x509.Certificate) (keyUsages, extKeyUsages []string) {
+	for _, ku := range keyUsageNames {
+		if cert.KeyUsage&ku.usage != 0 {
+			keyUsages = append(keyUsages, ku.name)
+		}
+	}
+	for _, eku := range cert.ExtKeyUsage {
+		if name, ok := extKeyUsageNames[eku]; ok {
+			extKeyUsages = append(extKeyUsages, name)
+		}
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		extKeyUsages = append(extKeyUsages, oid.String())
+	}
+	return keyUsages, extKeyUsages
+}
+
+// isSelfSigned reports whether a certificate is its own issuer and signed by its own key.
+// CheckSignatureFrom is not used since it also requires the issuer to be a CA.
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// describePublicKey returns the algorithm name and size in bits of a public key
+func describePublicKey(publicKey interface{}) (string, int) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	default:
+		return "unknown", 0
+	}
+}
@@ -0,0 +1,380 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/models"
+)
+
+// Test ParseCertificates with single certificates, bundles and invalid input
+func (suite *CryptoTestSuite) TestParseCertificates() {
+	first := suite.createTestCertificate()
+	second := suite.createTestCertificate()
+
+	certs, err := suite.cryptoService.ParseCertificates(first)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), certs, 1)
+
+	certs, err = suite.cryptoService.ParseCertificates(first + second)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), certs, 2)
+
+	_, err = suite.cryptoService.ParseCertificates("not a certificate")
+	assert.Error(suite.T(), err)
+
+	privateKeyPEM, _, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{CommonName: "key.example.com", KeyType: models.KeyTypeECDSAP256})
+	require.NoError(suite.T(), err)
+	_, err = suite.cryptoService.ParseCertificates(first + privateKeyPEM)
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "EC PRIVATE KEY")
+}
+
+// Test DescribeCertificate reports subject, SANs, key details and validity
+func (suite *CryptoTestSuite) TestDescribeCertificate() {
+	privateKeyPEM, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+		CommonName:              "describe.example.com",
+		SubjectAlternativeNames: []string{"describe.example.com", "10.1.2.3"},
+		KeyType:                 models.KeyTypeECDSAP384,
+	})
+	require.NoError(suite.T(), err)
+
+	cert, err := suite.cryptoService.ParseCertificate(suite.createMatchingCertificate(privateKeyPEM, csrPEM))
+	require.NoError(suite.T(), err)
+
+	details := suite.cryptoService.DescribeCertificate(cert, time.Now())
+	assert.Equal(suite.T(), "CN=describe.example.com", details.Subject)
+	assert.Equal(suite.T(), "CN=describe.example.com", details.Issuer)
+	assert.Equal(suite.T(), []string{"describe.example.com"}, details.DNSNames)
+	assert.Equal(suite.T(), []string{"10.1.2.3"}, details.IPAddresses)
+	assert.Equal(suite.T(), "ECDSA", details.KeyAlgorithm)
+	assert.Equal(suite.T(), 384, details.KeySize)
+	assert.Equal(suite.T(), "1", details.SerialNumber)
+	assert.Equal(suite.T(), "01", details.SerialNumberHex)
+	assert.True(suite.T(), details.SelfSigned)
+	assert.False(suite.T(), details.Expired)
+	assert.False(suite.T(), details.NotYetValid)
+
+	later := suite.cryptoService.DescribeCertificate(cert, cert.NotAfter.Add(time.Second))
+	assert.True(suite.T(), later.Expired)
+
+	earlier := suite.cryptoService.DescribeCertificate(cert, cert.NotBefore.Add(-time.Second))
+	assert.True(suite.T(), earlier.NotYetValid)
+}
+
+// Test FormatCertificateText renders the key fields of a known certificate in openssl x509 -text layout
+func (suite *CryptoTestSuite) TestFormatCertificateText() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(suite.T(), err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: "text.example.com", Organization: []string{"Example Corp"}},
+		NotBefore:             time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		NotAfter:              time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC),
+		DNSNames:              []string{"text.example.com", "www.text.example.com"},
+		IPAddresses:           []net.IP{net.ParseIP("10.1.2.3")},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		SubjectKeyId:          []byte{0x3a, 0x1f, 0x9d},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(suite.T(), err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(suite.T(), err)
+
+	text := suite.cryptoService.FormatCertificateText(cert)
+	for _, expected := range []string{
+		"Certificate:\n    Data:\n        Version: 3 (0x2)\n",
+		"        Serial Number: 42 (0x2a)\n",
+		"        Signature Algorithm: ECDSA-SHA256\n",
+		"        Issuer: CN=text.example.com,O=Example Corp\n",
+		"            Not Before: Jan 15 10:30:00 2024 UTC\n",
+		"            Not After : Jan 15 10:30:00 2025 UTC\n",
+		"        Subject: CN=text.example.com,O=Example Corp\n",
+		"            Public Key Algorithm: ECDSA\n                Public-Key: (256 bit)\n",
+		"            X509v3 Key Usage:\n                digitalSignature\n",
+		"            X509v3 Extended Key Usage:\n                serverAuth, clientAuth\n",
+		"            X509v3 Basic Constraints:\n                CA:FALSE\n",
+		"            X509v3 Subject Key Identifier:\n                3A:1F:9D\n",
+		"            X509v3 Subject Alternative Name:\n                DNS:text.example.com, DNS:www.text.example.com, IP Address:10.1.2.3\n",
+	} {
+		assert.Contains(suite.T(), text, expected)
+	}
+	details := suite.cryptoService.DescribeCertificate(cert, time.Now())
+	assert.True(suite.T(), strings.HasSuffix(text, "    SHA256 Fingerprint="+details.FingerprintSHA256+"\n"))
+
+	// Serial numbers too large for an int64 are printed as hex
+	template.SerialNumber = new(big.Int).Lsh(big.NewInt(1), 100)
+	der, err = x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(suite.T(), err)
+	cert, err = x509.ParseCertificate(der)
+	require.NoError(suite.T(), err)
+	assert.Contains(suite.T(), suite.cryptoService.FormatCertificateText(cert),
+		"        Serial Number:\n            10:00:00:00:00:00:00:00:00:00:00:00:00\n")
+}
+
+// Test DescribeCSR reports SANs by type and verifies the self-signature
+func (suite *CryptoTestSuite) TestDescribeCSR() {
+	_, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+		CommonName:              "csr.example.com",
+		SubjectAlternativeNames: []string{"csr.example.com", "192.168.1.10"},
+		EmailAddress:            "pki@example.com",
+		KeyType:                 models.KeyTypeRSA2048,
+	})
+	require.NoError(suite.T(), err)
+
+	csr, err := suite.cryptoService.ParseCSR(csrPEM)
+	require.NoError(suite.T(), err)
+
+	details := suite.cryptoService.DescribeCSR(csr)
+	assert.Equal(suite.T(), "csr.example.com", details.CommonName)
+	assert.Equal(suite.T(), []string{"csr.example.com"}, details.DNSNames)
+	assert.Equal(suite.T(), []string{"192.168.1.10"}, details.IPAddresses)
+	assert.Equal(suite.T(), []string{"pki@example.com"}, details.EmailAddresses)
+	assert.Equal(suite.T(), "RSA", details.KeyAlgorithm)
+	assert.Equal(suite.T(), 2048, details.KeySize)
+	assert.Equal(suite.T(), "SHA256-RSA", details.SignatureAlgorithm)
+	assert.True(suite.T(), details.SignatureValid)
+	assert.Empty(suite.T(), details.SignatureError)
+
+	broken, err := suite.cryptoService.ParseCSR(tamperCSRSignature(suite.T(), csrPEM))
+	require.NoError(suite.T(), err, "A tampered signature must still parse")
+
+	details = suite.cryptoService.DescribeCSR(broken)
+	assert.False(suite.T(), details.SignatureValid)
+	assert.NotEmpty(suite.T(), details.SignatureError)
+
+	_, err = suite.cryptoService.ParseCSR(suite.createTestCertificate())
+	assert.Error(suite.T(), err)
+}
+
+// tamperCSRSignature flips the last signature byte of a PEM-encoded CSR
+func tamperCSRSignature(t *testing.T, csrPEM string) string {
+	block, _ := pem.Decode([]byte(csrPEM))
+	require.NotNil(t, block)
+
+	der := append([]byte(nil), block.Bytes...)
+	der[len(der)-1] ^= 0xff
+	return string(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}))
+}
+
+// Test MatchPrivateKeyAndCertificate for matching, non-matching and malformed inputs
+func (suite *CryptoTestSuite) TestMatchPrivateKeyAndCertificate() {
+	privateKeyPEM, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{CommonName: "match.example.com", KeyType: models.KeyTypeECDSAP256})
+	require.NoError(suite.T(), err)
+	certPEM := suite.createMatchingCertificate(privateKeyPEM, csrPEM)
+
+	match, err := suite.cryptoService.MatchPrivateKeyAndCertificate(privateKeyPEM, certPEM)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), match)
+
+	match, err = suite.cryptoService.MatchPrivateKeyAndCertificate(privateKeyPEM, suite.createTestCertificate())
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), match)
+
+	_, err = suite.cryptoService.MatchPrivateKeyAndCertificate("not a key", certPEM)
+	assert.Error(suite.T(), err)
+
+	_, err = suite.cryptoService.MatchPrivateKeyAndCertificate(privateKeyPEM, "not a certificate")
+	assert.Error(suite.T(), err)
+}
+
+// Test EncodeCSRDER returns base64 DER that decodes back to the same CSR as the PEM
+func (suite *CryptoTestSuite) TestEncodeCSRDER() {
+	_, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+		CommonName:              "der.example.com",
+		SubjectAlternativeNames: []string{"der.example.com", "www.der.example.com"},
+		KeyType:                 models.KeyTypeECDSAP256,
+	})
+	require.NoError(suite.T(), err)
+
+	encoded, err := suite.cryptoService.EncodeCSRDER(csrPEM)
+	require.NoError(suite.T(), err)
+
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(suite.T(), err)
+
+	block, _ := pem.Decode([]byte(csrPEM))
+	require.NotNil(suite.T(), block)
+	assert.Equal(suite.T(), block.Bytes, der)
+
+	fromDER, err := x509.ParseCertificateRequest(der)
+	require.NoError(suite.T(), err)
+	fromPEM, err := suite.cryptoService.ParseCSR(csrPEM)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), fromPEM.Subject.String(), fromDER.Subject.String())
+	assert.Equal(suite.T(), fromPEM.DNSNames, fromDER.DNSNames)
+	assert.NoError(suite.T(), fromDER.CheckSignature())
+
+	_, err = suite.cryptoService.EncodeCSRDER("not a csr")
+	assert.Error(suite.T(), err)
+}
+
+// Test RequestFromCSR recovers the subject, SANs and key type of a CSR and rejects CSRs an entity cannot hold
+func (suite *CryptoTestSuite) TestRequestFromCSR() {
+	original := models.CreateKeyRequest{
+		CommonName:              "airgap.example.com",
+		SubjectAlternativeNames: []string{"airgap.example.com", "www.airgap.example.com", "10.0.0.5"},
+		Organization:            "Example Corp",
+		OrganizationalUnit:      "PKI",
+		Country:                 "NL",
+		State:                   "Noord-Holland",
+		City:                    "Amsterdam",
+		EmailAddress:            "pki@example.com",
+		KeyType:                 models.KeyTypeECDSAP384,
+	}
+	_, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(original)
+	require.NoError(suite.T(), err)
+
+	req, err := suite.cryptoService.RequestFromCSR(csrPEM)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), original, req)
+
+	_, rsaCSR, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{CommonName: "rsa.example.com", KeyType: models.KeyTypeRSA2048})
+	require.NoError(suite.T(), err)
+	req, err = suite.cryptoService.RequestFromCSR(rsaCSR)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.KeyTypeRSA2048, req.KeyType)
+	assert.Empty(suite.T(), req.SubjectAlternativeNames)
+
+	_, err = suite.cryptoService.RequestFromCSR(tamperCSRSignature(suite.T(), csrPEM))
+	assert.ErrorContains(suite.T(), err, "signature")
+
+	p521Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(suite.T(), err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "p521.example.com"}}, p521Key)
+	require.NoError(suite.T(), err)
+	_, err = suite.cryptoService.RequestFromCSR(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})))
+	assert.ErrorContains(suite.T(), err, "unsupported ECDSA 521-bit key")
+
+	_, err = suite.cryptoService.RequestFromCSR("not a csr")
+	assert.Error(suite.T(), err)
+}
+
+// Test CSRNames reads back the subject and SANs as encoded, with IP addresses in canonical form after the DNS names
+func (suite *CryptoTestSuite) TestCSRNames() {
+	_, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+		CommonName:              "names.example.com",
+		SubjectAlternativeNames: []string{"2001:0DB8::1", "names.example.com", "10.0.0.5"},
+		Organization:            "Example Corp",
+		Country:                 "NL",
+		KeyType:                 models.KeyTypeECDSAP256,
+	})
+	require.NoError(suite.T(), err)
+
+	subject, sans, err := suite.cryptoService.CSRNames(csrPEM)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "CN=names.example.com,O=Example Corp,C=NL", subject)
+	assert.Equal(suite.T(), []string{"names.example.com", "2001:db8::1", "10.0.0.5"}, sans)
+
+	_, _, err = suite.cryptoService.CSRNames("not a csr")
+	assert.Error(suite.T(), err)
+}
+
+// Test KeyIdentifiers links a leaf to its issuer through the AKI and reports nothing for a certificate without identifiers
+func (suite *CryptoTestSuite) TestKeyIdentifiers() {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(suite.T(), err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Example Intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		SubjectKeyId:          []byte{0x3a, 0x1f, 0x9d, 0x04},
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(suite.T(), err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(suite.T(), err)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(suite.T(), err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "leaf.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		SubjectKeyId: []byte{0xb4, 0x2c, 0x5e},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCert, &leafKey.PublicKey, caKey)
+	require.NoError(suite.T(), err)
+	leafCert, err := x509.ParseCertificate(leafDER)
+	require.NoError(suite.T(), err)
+
+	authorityKeyID, subjectKeyID := suite.cryptoService.KeyIdentifiers(leafCert)
+	assert.Equal(suite.T(), "3A:1F:9D:04", authorityKeyID)
+	assert.Equal(suite.T(), "B4:2C:5E", subjectKeyID)
+
+	_, caSubjectKeyID := suite.cryptoService.KeyIdentifiers(caCert)
+	assert.Equal(suite.T(), authorityKeyID, caSubjectKeyID, "The leaf's AKI must match the issuer's SKI")
+
+	details := suite.cryptoService.DescribeCertificate(leafCert, time.Now())
+	assert.Equal(suite.T(), "3A:1F:9D:04", details.AuthorityKeyID)
+	assert.Equal(suite.T(), "B4:2C:5E", details.SubjectKeyID)
+
+	// A self-signed leaf gets no generated identifiers
+	plainTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "plain.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	plainDER, err := x509.CreateCertificate(rand.Reader, plainTemplate, plainTemplate, &leafKey.PublicKey, leafKey)
+	require.NoError(suite.T(), err)
+	plainCert, err := x509.ParseCertificate(plainDER)
+	require.NoError(suite.T(), err)
+
+	authorityKeyID, subjectKeyID = suite.cryptoService.KeyIdentifiers(plainCert)
+	assert.Empty(suite.T(), authorityKeyID)
+	assert.Empty(suite.T(), subjectKeyID)
+}
+
+// Test DescribeKeyUsages names key usages and EKUs, falling back to the OID for unknown EKUs
+func (suite *CryptoTestSuite) TestDescribeKeyUsages() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(suite.T(), err)
+	smartcardLogon := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 2}
+	template := &x509.Certificate{
+		SerialNumber:       big.NewInt(42),
+		Subject:            pkix.Name{CommonName: "usage.example.com"},
+		NotBefore:          time.Now().Add(-time.Hour),
+		NotAfter:           time.Now().Add(time.Hour),
+		KeyUsage:           x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement,
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		UnknownExtKeyUsage: []asn1.ObjectIdentifier{smartcardLogon},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(suite.T(), err)
+	certs, err := suite.cryptoService.ParseCertificates(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	require.NoError(suite.T(), err)
+
+	keyUsages, extKeyUsages := suite.cryptoService.DescribeKeyUsages(certs[0])
+	assert.Equal(suite.T(), []string{"digitalSignature", "keyAgreement"}, keyUsages)
+	assert.Equal(suite.T(), []string{"serverAuth", "clientAuth", "1.3.6.1.4.1.311.20.2.2"}, extKeyUsages)
+
+	details := suite.cryptoService.DescribeCertificate(certs[0], time.Now())
+	assert.Equal(suite.T(), keyUsages, details.KeyUsages)
+	assert.Equal(suite.T(), extKeyUsages, details.ExtKeyUsages)
+
+	assert.True(suite.T(), IsExtKeyUsageName("serverAuth"))
+	assert.True(suite.T(), IsExtKeyUsageName("OCSPSigning"))
+	assert.False(suite.T(), IsExtKeyUsageName("serverauth"))
+}
@@ -0,0 +1,83 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"sync"
+)
+
+// RSAKeyPool keeps up to a fixed number of RSA keys of one size generated ahead of time, so a
+// request for a key does not wait hundreds of milliseconds for an RSA-4096 key. A background
+// goroutine refills the pool as keys are taken. Each key is handed out exactly once.
+type RSAKeyPool struct {
+	bits int
+	keys chan *rsa.PrivateKey
+	stop chan struct{}
+	done sync.WaitGroup
+	once sync.Once
+}
+
+// NewRSAKeyPool starts filling a pool of up to size keys of the given bit length
+func NewRSAKeyPool(bits, size int) *RSAKeyPool {
+	p := &RSAKeyPool{
+		bits: bits,
+		keys: make(chan *rsa.PrivateKey, size),
+		stop: make(chan struct{}),
+	}
+	p.done.Add(1)
+	go p.fill()
+	return p
+}
+
+// fill generates keys until the pool is closed, waiting whenever the pool is full
+func (p *RSAKeyPool) fill() {
+	defer p.done.Done()
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		key, err := rsa.GenerateKey(rand.Reader, p.bits)
+		if err != nil {
+			// Get falls back to generating on demand, which reports the error to the caller
+			return
+		}
+		select {
+		case p.keys <- key:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Get takes a pre-generated key from the pool, or generates one on demand when the pool is empty
+func (p *RSAKeyPool) Get() (*rsa.PrivateKey, error) {
+	select {
+	case key := <-p.keys:
+		return key, nil
+	default:
+		return rsa.GenerateKey(rand.Reader, p.bits)
+	}
+}
+
+// Len returns the number of keys ready in the pool
+func (p *RSAKeyPool) Len() int {
+	return len(p.keys)
+}
+
+// Close stops refilling the pool and discards the keys it holds
+func (p *RSAKeyPool) Close() {
+	p.once.Do(func() {
+		close(p.stop)
+		p.done.Wait()
+		for {
+			select {
+			case <-p.keys:
+			default:
+				return
+			}
+		}
+	})
+}
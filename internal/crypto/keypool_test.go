@@ -0,0 +1,109 @@
+package crypto
+
+import (
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/models"
+)
+
+// waitForKeys waits until the pool holds n keys
+func (suite *CryptoTestSuite) waitForKeys(pool *RSAKeyPool, n int) {
+	require.Eventually(suite.T(), func() bool { return pool.Len() == n }, 10*time.Second, 10*time.Millisecond)
+}
+
+// Test the pool fills up to its size and hands out each key only once
+func (suite *CryptoTestSuite) TestRSAKeyPool() {
+	pool := NewRSAKeyPool(2048, 3)
+	defer pool.Close()
+
+	suite.waitForKeys(pool, 3)
+	// A full pool stops generating
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(suite.T(), 3, pool.Len())
+
+	// Draining the pool and beyond, every key is distinct
+	seen := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		key, err := pool.Get()
+		require.NoError(suite.T(), err)
+		assert.Equal(suite.T(), 2048, key.N.BitLen())
+		assert.False(suite.T(), seen[key.N.String()], "A key is never handed out twice")
+		seen[key.N.String()] = true
+	}
+
+	// Taken keys are replaced in the background
+	suite.waitForKeys(pool, 3)
+
+	pool.Close()
+	assert.Zero(suite.T(), pool.Len(), "Close discards the pooled keys")
+	key, err := pool.Get()
+	require.NoError(suite.T(), err, "A closed pool still generates on demand")
+	assert.Equal(suite.T(), 2048, key.N.BitLen())
+	pool.Close()
+}
+
+// Test RSA keys for new CSRs come from the pools when enabled
+func (suite *CryptoTestSuite) TestGenerateKeyAndCSRWithRSAKeyPool() {
+	suite.cryptoService.EnableRSAKeyPools(1, models.SupportedKeyTypes)
+	defer suite.cryptoService.Close()
+	pool := suite.cryptoService.rsaPools[2048]
+	suite.waitForKeys(pool, 1)
+
+	privateKeyPEM, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{CommonName: "pooled.example.com", KeyType: models.KeyTypeRSA2048})
+	require.NoError(suite.T(), err)
+
+	privateKey, err := suite.cryptoService.parsePrivateKeyFromPEM(privateKeyPEM)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2048, privateKey.(*rsa.PrivateKey).N.BitLen())
+	csr, err := suite.cryptoService.ParseCSR(csrPEM)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), privateKey.(*rsa.PrivateKey).PublicKey.Equal(csr.PublicKey))
+}
+
+// Test pools are only kept for the RSA key sizes that are allowed
+func (suite *CryptoTestSuite) TestRSAKeyPoolsFollowAllowedKeyTypes() {
+	cs := NewCryptoService()
+	cs.EnableRSAKeyPools(1, []models.KeyType{models.KeyTypeECDSAP256, models.KeyTypeRSA2048})
+	defer cs.Close()
+	assert.Len(suite.T(), cs.rsaPools, 1)
+	assert.Contains(suite.T(), cs.rsaPools, 2048)
+
+	ecdsaOnly := NewCryptoService()
+	ecdsaOnly.EnableRSAKeyPools(1, []models.KeyType{models.KeyTypeECDSAP256, models.KeyTypeECDSAP384})
+	assert.Empty(suite.T(), ecdsaOnly.rsaPools)
+}
+
+// BenchmarkRSA4096KeyGeneration compares the latency of an RSA-4096 key taken from a pool
+// that has had time to refill with generating the key on demand
+func BenchmarkRSA4096KeyGeneration(b *testing.B) {
+	b.Run("on demand", func(b *testing.B) {
+		cs := NewCryptoService()
+		for i := 0; i < b.N; i++ {
+			if _, err := cs.generateRSAKey(4096); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		cs := NewCryptoService()
+		cs.EnableRSAKeyPools(1, []models.KeyType{models.KeyTypeRSA4096})
+		defer cs.Close()
+		pool := cs.rsaPools[4096]
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			for pool.Len() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			b.StartTimer()
+			if _, err := cs.generateRSAKey(4096); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
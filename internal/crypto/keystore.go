@@ -0,0 +1,371 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PKCS#12 (RFC 7292) and PBES2 (RFC 8018) object identifiers used to build keystores
+var (
+	oidData                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBES2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA256              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+// pkcs12PFX is the outer PFX structure; AuthSafe holds the DER AuthenticatedSafe as data content
+type pkcs12PFX struct {
+	Version  int
+	AuthSafe pkcs7ContentInfo
+	MacData  pkcs12MacData
+}
+
+// pkcs12MacData is the HMAC over the AuthenticatedSafe that proves the store password
+type pkcs12MacData struct {
+	Mac        pkcs12DigestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type pkcs12DigestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+// pkcs12SafeBag holds one key or certificate; Value is [0] EXPLICIT and must be built with explicitContent
+type pkcs12SafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+// pkcs12Attribute is a bag attribute; Value is the SET holding its single value
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type pkcs12CertBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type pkcs12EncryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pkcs12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo pkcs12EncryptedContentInfo
+}
+
+type pkcs12EncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	PRF        pkix.AlgorithmIdentifier
+}
+
+// GenerateKeystore creates a PKCS#12 keystore for Java, which reads the format natively from Java 9 on.
+// Unlike GeneratePFX the key entry is named alias and may have its own password: certificates and the
+// integrity MAC are protected by storePassword and the private key by keyPassword. certificatePEM is
+// the leaf followed by any issuing chain, which is stored with it. Keys and certificates are encrypted
+// with PBES2 (PBKDF2-HMAC-SHA256, AES-256-CBC) and the MAC is HMAC-SHA256, which Java 11.0.12 and
+// later support. An iterations value of 0 uses PFXMinIterations.
+func (cs *CryptoService) GenerateKeystore(privateKeyPEM, certificatePEM, alias, storePassword, keyPassword string, iterations int) ([]byte, error) {
+	if iterations == 0 {
+		iterations = PFXMinIterations
+	}
+	if iterations < PFXMinIterations || iterations > PFXMaxIterations {
+		return nil, fmt.Errorf("iterations must be between %d and %d", PFXMinIterations, PFXMaxIterations)
+	}
+	if alias == "" {
+		return nil, fmt.Errorf("alias is required")
+	}
+	if storePassword == "" || keyPassword == "" {
+		return nil, fmt.Errorf("store and key passwords are required")
+	}
+
+	privateKey, err := cs.parsePrivateKeyFromPEM(privateKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	certs, err := cs.ParseCertificates(certificatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	leaf := certs[0]
+
+	// The key and its certificate are paired by a shared local key ID and carry the alias
+	localKeyID := sha256.Sum256(leaf.Raw)
+	attributes, err := keystoreEntryAttributes(alias, localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// Certificates are encrypted under the store password
+	var certBags []pkcs12SafeBag
+	for i, cert := range certs {
+		bag, err := keystoreCertBag(cert)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			bag.Attributes = attributes
+		}
+		certBags = append(certBags, bag)
+	}
+	certSafe, err := keystoreEncryptedSafe(certBags, storePassword, iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	// The private key is encrypted under the key password
+	keyBag, err := keystoreKeyBag(privateKey, keyPassword, iterations)
+	if err != nil {
+		return nil, err
+	}
+	keyBag.Attributes = attributes
+	keySafe, err := keystoreDataSafe([]pkcs12SafeBag{keyBag})
+	if err != nil {
+		return nil, err
+	}
+
+	authSafe, err := asn1.Marshal([]pkcs7ContentInfo{certSafe, keySafe})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode keystore contents: %w", err)
+	}
+	authSafeContent, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode keystore contents: %w", err)
+	}
+
+	// The MAC over the contents lets readers verify the store password
+	macSalt, err := randomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, pkcs12MACKey(storePassword, macSalt, iterations))
+	mac.Write(authSafe)
+
+	pfx := pkcs12PFX{
+		Version:  3,
+		AuthSafe: pkcs7ContentInfo{ContentType: oidData, Content: explicitContent(authSafeContent)},
+		MacData: pkcs12MacData{
+			Mac: pkcs12DigestInfo{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+				Digest:    mac.Sum(nil),
+			},
+			MacSalt:    macSalt,
+			Iterations: iterations,
+		},
+	}
+	keystore, err := asn1.Marshal(pfx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode keystore: %w", err)
+	}
+	return keystore, nil
+}
+
+// keystoreEntryAttributes returns the friendlyName, which Java reads as the alias, and localKeyId attributes
+func keystoreEntryAttributes(alias string, localKeyID []byte) ([]pkcs12Attribute, error) {
+	friendlyName, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagBMPString, Bytes: bmpString(alias)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode alias: %w", err)
+	}
+	keyID, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode local key ID: %w", err)
+	}
+	return []pkcs12Attribute{
+		{ID: oidFriendlyName, Value: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: friendlyName}},
+		{ID: oidLocalKeyID, Value: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: keyID}},
+	}, nil
+}
+
+// keystoreCertBag wraps a certificate in a certificate bag
+func keystoreCertBag(cert *x509.Certificate) (pkcs12SafeBag, error) {
+	certBag, err := asn1.Marshal(pkcs12CertBag{ID: oidX509Certificate, Data: cert.Raw})
+	if err != nil {
+		return pkcs12SafeBag{}, fmt.Errorf("failed to encode certificate bag: %w", err)
+	}
+	return pkcs12SafeBag{ID: oidCertBag, Value: explicitContent(certBag)}, nil
+}
+
+// keystoreKeyBag encrypts a private key into a PKCS#8 shrouded key bag
+func keystoreKeyBag(privateKey interface{}, password string, iterations int) (pkcs12SafeBag, error) {
+	pkcs8Key, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return pkcs12SafeBag{}, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	algorithm, encrypted, err := pbes2Encrypt(pkcs8Key, password, iterations)
+	if err != nil {
+		return pkcs12SafeBag{}, err
+	}
+	keyInfo, err := asn1.Marshal(pkcs12EncryptedPrivateKeyInfo{Algorithm: algorithm, EncryptedData: encrypted})
+	if err != nil {
+		return pkcs12SafeBag{}, fmt.Errorf("failed to encode private key bag: %w", err)
+	}
+	return pkcs12SafeBag{ID: oidPKCS8ShroudedKeyBag, Value: explicitContent(keyInfo)}, nil
+}
+
+// keystoreDataSafe stores safe bags as plain data content
+func keystoreDataSafe(bags []pkcs12SafeBag) (pkcs7ContentInfo, error) {
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return pkcs7ContentInfo{}, fmt.Errorf("failed to encode safe contents: %w", err)
+	}
+	content, err := asn1.Marshal(safeContents)
+	if err != nil {
+		return pkcs7ContentInfo{}, fmt.Errorf("failed to encode safe contents: %w", err)
+	}
+	return pkcs7ContentInfo{ContentType: oidData, Content: explicitContent(content)}, nil
+}
+
+// keystoreEncryptedSafe stores safe bags as encrypted data content
+func keystoreEncryptedSafe(bags []pkcs12SafeBag, password string, iterations int) (pkcs7ContentInfo, error) {
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return pkcs7ContentInfo{}, fmt.Errorf("failed to encode safe contents: %w", err)
+	}
+	algorithm, encrypted, err := pbes2Encrypt(safeContents, password, iterations)
+	if err != nil {
+		return pkcs7ContentInfo{}, err
+	}
+	content, err := asn1.Marshal(pkcs12EncryptedData{
+		EncryptedContentInfo: pkcs12EncryptedContentInfo{
+			ContentType:                oidData,
+			ContentEncryptionAlgorithm: algorithm,
+			EncryptedContent:           encrypted,
+		},
+	})
+	if err != nil {
+		return pkcs7ContentInfo{}, fmt.Errorf("failed to encode encrypted data: %w", err)
+	}
+	return pkcs7ContentInfo{ContentType: oidEncryptedData, Content: explicitContent(content)}, nil
+}
+
+// pbes2Encrypt encrypts data with AES-256-CBC under a key derived from the UTF-8 password by
+// PBKDF2-HMAC-SHA256, returning the algorithm identifier that describes the salt, iterations and IV
+func pbes2Encrypt(data []byte, password string, iterations int) (pkix.AlgorithmIdentifier, []byte, error) {
+	salt, err := randomBytes(16)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	iv, err := randomBytes(aes.BlockSize)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, fmt.Errorf("failed to encode PBKDF2 parameters: %w", err)
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, fmt.Errorf("failed to encode IV: %w", err)
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, fmt.Errorf("failed to encode PBES2 parameters: %w", err)
+	}
+
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(password), salt, iterations, 32, sha256.New))
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	encrypted := append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	return pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}}, encrypted, nil
+}
+
+// pkcs12MACKey derives the HMAC-SHA256 key of a keystore MAC from the password with the PKCS#12 key
+// derivation function (RFC 7292 appendix B.2). The key is a single SHA-256 output, so one block is derived.
+func pkcs12MACKey(password string, salt []byte, iterations int) []byte {
+	// blockSize is the SHA-256 input block size, v in RFC 7292; the diversifier 3 selects a MAC key
+	const blockSize = 64
+	input := bytes.Repeat([]byte{3}, blockSize)
+	input = append(input, fillBlocks(salt, blockSize)...)
+	input = append(input, fillBlocks(append(bmpString(password), 0, 0), blockSize)...)
+
+	sum := sha256.Sum256(input)
+	for i := 1; i < iterations; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return sum[:]
+}
+
+// fillBlocks repeats data to fill a whole number of blocks of blockSize bytes; empty data stays empty
+func fillBlocks(data []byte, blockSize int) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	filled := make([]byte, blockSize*((len(data)+blockSize-1)/blockSize))
+	for i := range filled {
+		filled[i] = data[i%len(data)]
+	}
+	return filled
+}
+
+// bmpString encodes s as big-endian UTF-16, the encoding of ASN.1 BMPString and PKCS#12 passwords
+func bmpString(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	encoded := make([]byte, 0, 2*len(units))
+	for _, unit := range units {
+		encoded = append(encoded, byte(unit>>8), byte(unit))
+	}
+	return encoded
+}
+
+// explicitContent wraps DER in a [0] EXPLICIT tag, which asn1.Marshal does not apply to RawValue fields
+func explicitContent(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// randomBytes returns n bytes from the system's secure random source
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return b, nil
+}
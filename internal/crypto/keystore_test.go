@@ -0,0 +1,183 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"certificate-monkey/internal/models"
+)
+
+// keystoreFixture returns a private key and a PEM bundle of its certificate followed by a two-certificate chain
+func (suite *CryptoTestSuite) keystoreFixture() (privateKeyPEM, bundlePEM string, chain []*x509.Certificate) {
+	privateKeyPEM, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+		CommonName: "keystore.example.com",
+		KeyType:    models.KeyTypeECDSAP256,
+	})
+	require.NoError(suite.T(), err)
+
+	root, intermediate, _ := suite.createTestChain()
+	bundlePEM = suite.createMatchingCertificate(privateKeyPEM, csrPEM)
+	for _, cert := range []*x509.Certificate{intermediate, root} {
+		bundlePEM += string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	}
+	return privateKeyPEM, bundlePEM, []*x509.Certificate{intermediate, root}
+}
+
+// Test GenerateKeystore stores the key and chain under the alias so a PKCS#12 reader finds them
+func (suite *CryptoTestSuite) TestGenerateKeystore() {
+	privateKeyPEM, bundlePEM, chain := suite.keystoreFixture()
+
+	keystore, err := suite.cryptoService.GenerateKeystore(privateKeyPEM, bundlePEM, "tomcat", "changeit", "changeit", 0)
+	require.NoError(suite.T(), err)
+
+	privateKey, leaf, caCerts, err := pkcs12.DecodeChain(keystore, "changeit")
+	require.NoError(suite.T(), err)
+	match, err := suite.cryptoService.MatchPrivateKeyAndCertificate(privateKeyPEM, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})))
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), match)
+	assert.NotNil(suite.T(), privateKey)
+	assert.Equal(suite.T(), "keystore.example.com", leaf.Subject.CommonName)
+	require.Len(suite.T(), caCerts, 2)
+	assert.Equal(suite.T(), chain[0].Raw, caCerts[0].Raw)
+	assert.Equal(suite.T(), chain[1].Raw, caCerts[1].Raw)
+
+	// The key and the leaf carry the alias and share a local key ID; the chain has neither
+	blocks, err := pkcs12.ToPEM(keystore, "changeit")
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), blocks, 4)
+	var keyBlock *pem.Block
+	var certBlocks []*pem.Block
+	for _, block := range blocks {
+		if block.Type == "PRIVATE KEY" {
+			keyBlock = block
+		} else {
+			certBlocks = append(certBlocks, block)
+		}
+	}
+	require.NotNil(suite.T(), keyBlock)
+	assert.Equal(suite.T(), "tomcat", keyBlock.Headers["friendlyName"])
+	assert.Equal(suite.T(), "tomcat", certBlocks[0].Headers["friendlyName"])
+	assert.NotEmpty(suite.T(), keyBlock.Headers["localKeyId"])
+	assert.Equal(suite.T(), keyBlock.Headers["localKeyId"], certBlocks[0].Headers["localKeyId"])
+	assert.Empty(suite.T(), certBlocks[1].Headers["friendlyName"])
+
+	// The wrong store password fails the MAC check
+	_, _, _, err = pkcs12.DecodeChain(keystore, "wrong")
+	assert.ErrorIs(suite.T(), err, pkcs12.ErrIncorrectPassword)
+}
+
+// Test GenerateKeystore protects the key entry with its own password
+func (suite *CryptoTestSuite) TestGenerateKeystoreSeparateKeyPassword() {
+	privateKeyPEM, bundlePEM, _ := suite.keystoreFixture()
+
+	keystore, err := suite.cryptoService.GenerateKeystore(privateKeyPEM, bundlePEM, "server", "store-secret", "key-secret", 4096)
+	require.NoError(suite.T(), err)
+
+	// The store password verifies the MAC and decrypts the certificates, but not the key
+	_, _, _, err = pkcs12.DecodeChain(keystore, "store-secret")
+	require.Error(suite.T(), err)
+	assert.NotErrorIs(suite.T(), err, pkcs12.ErrIncorrectPassword)
+	_, _, _, err = pkcs12.DecodeChain(keystore, "key-secret")
+	assert.ErrorIs(suite.T(), err, pkcs12.ErrIncorrectPassword)
+
+	// The key password decrypts the key
+	privateKey := decryptKeystoreKey(suite.T(), keystore, "key-secret")
+	pkcs8Key, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(suite.T(), err)
+	expected, err := suite.cryptoService.parsePrivateKeyFromPEM(privateKeyPEM)
+	require.NoError(suite.T(), err)
+	expectedPKCS8, err := x509.MarshalPKCS8PrivateKey(expected)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), expectedPKCS8, pkcs8Key)
+}
+
+// Test GenerateKeystore rejects a missing alias or password and iterations out of range
+func (suite *CryptoTestSuite) TestGenerateKeystoreValidation() {
+	privateKeyPEM, bundlePEM, _ := suite.keystoreFixture()
+
+	_, err := suite.cryptoService.GenerateKeystore(privateKeyPEM, bundlePEM, "", "changeit", "changeit", 0)
+	assert.Error(suite.T(), err)
+	_, err = suite.cryptoService.GenerateKeystore(privateKeyPEM, bundlePEM, "tomcat", "changeit", "", 0)
+	assert.Error(suite.T(), err)
+	_, err = suite.cryptoService.GenerateKeystore(privateKeyPEM, bundlePEM, "tomcat", "changeit", "changeit", 1000)
+	assert.Error(suite.T(), err)
+}
+
+// TestPKCS12MACKey tests the MAC key derivation against the RFC 7292 KDF as implemented by go-pkcs12,
+// whose decoder accepts the keystore only when both derive the same key
+func TestPKCS12MACKey(t *testing.T) {
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	key := pkcs12MACKey("changeit", salt, 2048)
+	assert.Len(t, key, sha256.Size)
+	assert.Equal(t, key, pkcs12MACKey("changeit", salt, 2048))
+	assert.NotEqual(t, key, pkcs12MACKey("changeit", salt, 2049))
+	assert.NotEqual(t, key, pkcs12MACKey("changeiT", salt, 2048))
+
+	assert.Equal(t, []byte{0, 'a', 0xd8, 0x3d, 0xde, 0x00}, bmpString("a😀"))
+}
+
+// decryptKeystoreKey decrypts the shrouded key bag of a keystore built by GenerateKeystore with password
+func decryptKeystoreKey(t *testing.T, keystore []byte, password string) interface{} {
+	t.Helper()
+	var pfx pkcs12PFX
+	_, err := asn1.Unmarshal(keystore, &pfx)
+	require.NoError(t, err)
+	var authSafe []byte
+	_, err = asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe)
+	require.NoError(t, err)
+	var contentInfos []pkcs7ContentInfo
+	_, err = asn1.Unmarshal(authSafe, &contentInfos)
+	require.NoError(t, err)
+
+	for _, contentInfo := range contentInfos {
+		if !contentInfo.ContentType.Equal(oidData) {
+			continue
+		}
+		var safeContents []byte
+		_, err = asn1.Unmarshal(contentInfo.Content.Bytes, &safeContents)
+		require.NoError(t, err)
+		var bags []pkcs12SafeBag
+		_, err = asn1.Unmarshal(safeContents, &bags)
+		require.NoError(t, err)
+		require.Len(t, bags, 1)
+		require.True(t, bags[0].ID.Equal(oidPKCS8ShroudedKeyBag))
+
+		var keyInfo pkcs12EncryptedPrivateKeyInfo
+		_, err = asn1.Unmarshal(bags[0].Value.Bytes, &keyInfo)
+		require.NoError(t, err)
+		var params pbes2Params
+		_, err = asn1.Unmarshal(keyInfo.Algorithm.Parameters.FullBytes, &params)
+		require.NoError(t, err)
+		var kdfParams pbkdf2Params
+		_, err = asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams)
+		require.NoError(t, err)
+		var iv []byte
+		_, err = asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv)
+		require.NoError(t, err)
+
+		block, err := aes.NewCipher(pbkdf2.Key([]byte(password), kdfParams.Salt, kdfParams.Iterations, 32, sha256.New))
+		require.NoError(t, err)
+		decrypted := make([]byte, len(keyInfo.EncryptedData))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, keyInfo.EncryptedData)
+		padding := int(decrypted[len(decrypted)-1])
+		require.True(t, padding > 0 && padding <= aes.BlockSize)
+		require.True(t, bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)))
+
+		privateKey, err := x509.ParsePKCS8PrivateKey(decrypted[:len(decrypted)-padding])
+		require.NoError(t, err)
+		return privateKey
+	}
+	t.Fatal("keystore has no key bag")
+	return nil
+}
@@ -1,16 +1,22 @@
 package crypto
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha1" // #nosec G505 -- SHA-1 is only used for legacy certificate fingerprints
 	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"math/big"
 	"net"
 	"net/url"
 	"strings"
@@ -20,23 +26,133 @@ import (
 	"certificate-monkey/internal/models"
 )
 
-// CryptoService handles all cryptographic operations
-type CryptoService struct{}
+// FingerprintAlgorithm selects the hash used for certificate fingerprints
+type FingerprintAlgorithm string
+
+const (
+	FingerprintSHA256 FingerprintAlgorithm = "sha256"
+	FingerprintSHA1   FingerprintAlgorithm = "sha1"
+)
+
+// PFX key derivation iteration bounds. The minimum is the encoder's default, so a
+// request can only strengthen the KDF; the maximum keeps a single export affordable.
+const (
+	PFXMinIterations = 2048
+	PFXMaxIterations = 600000
+)
+
+// DefaultMaxSANs is the most subject alternative names a CSR may carry unless SetMaxSANs changes it
+const DefaultMaxSANs = 100
+
+// ErrTooManySANs is returned when a CSR would carry more subject alternative names than allowed
+var ErrTooManySANs = errors.New("too many subject alternative names")
+
+// CryptoService handles all cryptographic operations.
+// rsaPools holds pre-generated RSA keys by bit length; without a pool keys are generated on demand.
+// lowercaseCommonNames lowercases hostname common names in the CSRs it builds.
+// maxSANs caps the subject alternative names of CSRs it builds or imports.
+type CryptoService struct {
+	rsaPools             map[int]*RSAKeyPool
+	lowercaseCommonNames bool
+	maxSANs              int
+}
 
 // NewCryptoService creates a new instance of CryptoService
 func NewCryptoService() *CryptoService {
-	return &CryptoService{}
+	return &CryptoService{maxSANs: DefaultMaxSANs}
+}
+
+// SetMaxSANs sets the most subject alternative names a CSR may carry; values below one keep the
+// current limit, so the cap always applies. Call it before the service is shared.
+func (cs *CryptoService) SetMaxSANs(maxSANs int) {
+	if maxSANs > 0 {
+		cs.maxSANs = maxSANs
+	}
+}
+
+// CheckSANCount returns ErrTooManySANs, with the count and the limit, when count exceeds the limit
+func (cs *CryptoService) CheckSANCount(count int) error {
+	if count > cs.maxSANs {
+		return fmt.Errorf("%w: %d given, at most %d allowed", ErrTooManySANs, count, cs.maxSANs)
+	}
+	return nil
+}
+
+// SetLowercaseCommonNames sets whether CSRs are built with hostname common names lowercased, for CAs
+// that treat the common name case-sensitively. Common names that are not hostnames, such as a person's
+// name, are kept as given. Call it before the service is shared.
+func (cs *CryptoService) SetLowercaseCommonNames(enabled bool) {
+	cs.lowercaseCommonNames = enabled
+}
+
+// NormalizeCommonName returns the common name as a CSR built by the service carries it: lowercased
+// when lowercasing is enabled and the name is a dotted hostname, unchanged otherwise
+func (cs *CryptoService) NormalizeCommonName(commonName string) string {
+	if !cs.lowercaseCommonNames || !isDNSName(commonName) {
+		return commonName
+	}
+	return strings.ToLower(commonName)
+}
+
+// isDNSName reports whether name is a hostname of at least two labels. A single label is not taken
+// as a hostname, as it cannot be told apart from a name.
+func isDNSName(name string) bool {
+	return models.IsHostname(name) && strings.Contains(strings.TrimPrefix(name, "*."), ".")
+}
+
+// EnableRSAKeyPools pre-generates up to size keys in the background for each RSA key size among
+// keyTypes, so new RSA keys do not block the request that asks for them; no CPU is spent on a size
+// that cannot be requested. Call it before the service is shared; zero or less leaves generation
+// on demand.
+func (cs *CryptoService) EnableRSAKeyPools(size int, keyTypes []models.KeyType) {
+	if size <= 0 {
+		return
+	}
+	rsaBits := map[models.KeyType]int{models.KeyTypeRSA2048: 2048, models.KeyTypeRSA4096: 4096}
+	for _, keyType := range keyTypes {
+		bits, ok := rsaBits[keyType]
+		if !ok || cs.rsaPools[bits] != nil {
+			continue
+		}
+		if cs.rsaPools == nil {
+			cs.rsaPools = make(map[int]*RSAKeyPool)
+		}
+		cs.rsaPools[bits] = NewRSAKeyPool(bits, size)
+	}
+}
+
+// Close stops refilling the RSA key pools
+func (cs *CryptoService) Close() {
+	for _, pool := range cs.rsaPools {
+		pool.Close()
+	}
+}
+
+// generateRSAKey takes an RSA key of the given size from its pool, or generates it on demand
+func (cs *CryptoService) generateRSAKey(bits int) (*rsa.PrivateKey, error) {
+	if pool, ok := cs.rsaPools[bits]; ok {
+		return pool.Get()
+	}
+	return rsa.GenerateKey(rand.Reader, bits)
 }
 
 // GenerateKeyAndCSR generates a private key and certificate signing request
 func (cs *CryptoService) GenerateKeyAndCSR(req models.CreateKeyRequest) (privateKeyPEM, csrPEM string, err error) {
+	// Reject an incompatible signature algorithm before spending time on the key
+	if req.SignatureAlgorithm != "" && req.KeyType.IsSupported() && !req.KeyType.SupportsSignatureAlgorithm(req.SignatureAlgorithm) {
+		return "", "", fmt.Errorf("%w: %s with %s", ErrIncompatibleSignatureAlgorithm, req.SignatureAlgorithm, req.KeyType)
+	}
+	if err := cs.CheckSANCount(len(req.SubjectAlternativeNames)); err != nil {
+		return "", "", err
+	}
+
 	// Generate the private key based on the key type
 	var privateKey interface{}
 	switch req.KeyType {
 	case models.KeyTypeRSA2048:
-		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		privateKey, err = cs.generateRSAKey(2048)
 	case models.KeyTypeRSA4096:
-		privateKey, err = rsa.GenerateKey(rand.Reader, 4096)
+		privateKey, err = cs.generateRSAKey(4096)
 	case models.KeyTypeECDSAP256:
 		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	case models.KeyTypeECDSAP384:
@@ -55,10 +171,67 @@ func (cs *CryptoService) GenerateKeyAndCSR(req models.CreateKeyRequest) (private
 		return "", "", fmt.Errorf("failed to encode private key: %w", err)
 	}
 
+	csrPEM, err = cs.createCSR(privateKey, req)
+	if err != nil {
+		return "", "", err
+	}
+
+	return privateKeyPEM, csrPEM, nil
+}
+
+// GenerateCSRFromKey creates a certificate signing request for an existing PEM-encoded private key.
+// The subject and SANs are taken from req; req.KeyType is ignored since the key already exists.
+func (cs *CryptoService) GenerateCSRFromKey(privateKeyPEM string, req models.CreateKeyRequest) (string, error) {
+	if err := cs.CheckSANCount(len(req.SubjectAlternativeNames)); err != nil {
+		return "", err
+	}
+
+	privateKey, err := cs.parsePrivateKeyFromPEM(privateKeyPEM)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	return cs.createCSR(privateKey, req)
+}
+
+// ErrIncompatibleSignatureAlgorithm is returned when a CSR signature algorithm is unknown or does not suit the key
+var ErrIncompatibleSignatureAlgorithm = errors.New("signature algorithm is not compatible with the key type")
+
+// x509SignatureAlgorithms are the algorithms a CSR can be signed with, looked up by their String() name
+var x509SignatureAlgorithms = []x509.SignatureAlgorithm{
+	x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+	x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS,
+	x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512,
+}
+
+// csrSignatureAlgorithm returns the x509 algorithm named name if it is compatible with the private key
+func csrSignatureAlgorithm(privateKey interface{}, name string) (x509.SignatureAlgorithm, error) {
+	var compatible []string
+	switch privateKey.(type) {
+	case *rsa.PrivateKey:
+		compatible = models.RSASignatureAlgorithms
+	case *ecdsa.PrivateKey:
+		compatible = models.ECDSASignatureAlgorithms
+	}
+	for _, compatibleName := range compatible {
+		if compatibleName != name {
+			continue
+		}
+		for _, algorithm := range x509SignatureAlgorithms {
+			if algorithm.String() == name {
+				return algorithm, nil
+			}
+		}
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("%w: %s", ErrIncompatibleSignatureAlgorithm, name)
+}
+
+// createCSR builds and signs a PEM-encoded CSR for the subject and SANs in req
+func (cs *CryptoService) createCSR(privateKey interface{}, req models.CreateKeyRequest) (string, error) {
 	// Create certificate signing request template
 	template := x509.CertificateRequest{
 		Subject: pkix.Name{
-			CommonName: req.CommonName,
+			CommonName: cs.NormalizeCommonName(req.CommonName),
 		},
 		EmailAddresses: []string{},
 	}
@@ -94,19 +267,25 @@ func (cs *CryptoService) GenerateKeyAndCSR(req models.CreateKeyRequest) (private
 		}
 	}
 
+	if req.SignatureAlgorithm != "" {
+		algorithm, err := csrSignatureAlgorithm(privateKey, req.SignatureAlgorithm)
+		if err != nil {
+			return "", err
+		}
+		template.SignatureAlgorithm = algorithm
+	}
+
 	// Create CSR
 	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &template, privateKey)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create certificate request: %w", err)
+		return "", fmt.Errorf("failed to create certificate request: %w", err)
 	}
 
 	// Encode CSR to PEM format
-	csrPEM = string(pem.EncodeToMemory(&pem.Block{
+	return string(pem.EncodeToMemory(&pem.Block{
 		Type:  "CERTIFICATE REQUEST",
 		Bytes: csrDER,
-	}))
-
-	return privateKeyPEM, csrPEM, nil
+	})), nil
 }
 
 // encodePrivateKeyToPEM encodes a private key to PEM format
@@ -154,72 +333,253 @@ func (cs *CryptoService) ParseCertificate(certPEM string) (*x509.Certificate, er
 	return cert, nil
 }
 
-// GenerateCertificateFingerprint generates SHA256 fingerprint of a certificate
-func (cs *CryptoService) GenerateCertificateFingerprint(certPEM string) (string, error) {
+// GenerateCertificateFingerprint generates a fingerprint of a certificate using the given algorithm.
+// SHA-1 is only offered for legacy systems and CA portals that still identify certificates by it.
+func (cs *CryptoService) GenerateCertificateFingerprint(certPEM string, algorithm FingerprintAlgorithm) (string, error) {
 	cert, err := cs.ParseCertificate(certPEM)
 	if err != nil {
 		return "", err
 	}
 
-	hash := sha256.Sum256(cert.Raw)
-	fingerprint := fmt.Sprintf("%x", hash)
+	var hash []byte
+	switch algorithm {
+	case FingerprintSHA256:
+		sum := sha256.Sum256(cert.Raw)
+		hash = sum[:]
+	case FingerprintSHA1:
+		sum := sha1.Sum(cert.Raw) // #nosec G401 -- fingerprint only, not used for security decisions
+		hash = sum[:]
+	default:
+		return "", fmt.Errorf("unsupported fingerprint algorithm: %s", algorithm)
+	}
+
+	return formatHexBytes(hash), nil
+}
+
+// FormatSerialNumberHex formats a certificate serial number as colon-separated hex bytes,
+// the form shown by openssl and most certificate tooling (e.g. 0F:A1:...)
+func (cs *CryptoService) FormatSerialNumberHex(serial *big.Int) string {
+	serialBytes := serial.Bytes()
+	if len(serialBytes) == 0 {
+		serialBytes = []byte{0}
+	}
+	return formatHexBytes(serialBytes)
+}
+
+// formatHexBytes formats bytes as uppercase XX:XX:XX... for readability
+func formatHexBytes(data []byte) string {
+	hexString := strings.ToUpper(hex.EncodeToString(data))
 
-	// Format as XX:XX:XX... for readability
 	var formatted strings.Builder
-	for i, b := range fingerprint {
+	for i, b := range hexString {
 		if i > 0 && i%2 == 0 {
 			formatted.WriteString(":")
 		}
-		formatted.WriteString(string(b))
+		formatted.WriteRune(b)
 	}
 
-	return strings.ToUpper(formatted.String()), nil
+	return formatted.String()
 }
 
-// ValidateCertificateWithCSR validates that a certificate matches the CSR
+// Errors returned by ValidateCertificateWithCSR, distinguishing malformed input from a certificate
+// that is well-formed but was not issued for the CSR
+var (
+	ErrInvalidCertificate  = errors.New("failed to parse certificate")
+	ErrCertificateMismatch = errors.New("certificate does not match the CSR")
+)
+
+// ValidateCertificateWithCSR validates that a certificate matches the CSR.
+// It returns an error wrapping ErrInvalidCertificate or ErrCertificateMismatch when the certificate is at fault.
 func (cs *CryptoService) ValidateCertificateWithCSR(certPEM, csrPEM string) error {
 	// Parse certificate
 	cert, err := cs.ParseCertificate(certPEM)
 	if err != nil {
-		return fmt.Errorf("failed to parse certificate: %w", err)
+		return fmt.Errorf("%w: %w", ErrInvalidCertificate, err)
 	}
 
 	// Parse CSR
-	csrBlock, _ := pem.Decode([]byte(csrPEM))
-	if csrBlock == nil {
-		return fmt.Errorf("failed to decode CSR PEM block")
+	csr, err := cs.ParseCSR(csrPEM)
+	if err != nil {
+		return err
 	}
 
-	csr, err := x509.ParseCertificateRequest(csrBlock.Bytes)
+	// Verify that the certificate's public key matches the CSR's public key
+	match, err := publicKeysMatch(cert.PublicKey, csr.PublicKey)
 	if err != nil {
-		return fmt.Errorf("failed to parse CSR: %w", err)
+		return err
+	}
+	if !match {
+		return fmt.Errorf("%w: certificate public key does not match CSR public key", ErrCertificateMismatch)
 	}
 
-	// Verify that the certificate's public key matches the CSR's public key
-	certPubKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	// Verify that the subject matches
+	if cert.Subject.CommonName != csr.Subject.CommonName {
+		return fmt.Errorf("%w: certificate CommonName does not match CSR CommonName", ErrCertificateMismatch)
+	}
+
+	return nil
+}
+
+// Errors returned by CheckSubjectAlternativeNames for certificates that browsers, which ignore the
+// common name, cannot match by name
+var (
+	ErrNoDNSSAN            = errors.New("certificate has no DNS subject alternative name")
+	ErrCommonNameNotInSANs = errors.New("certificate common name is not among its DNS subject alternative names")
+)
+
+// CheckSubjectAlternativeNames checks that a certificate carries at least one DNS SAN and, when
+// requireCNInSANs is set, that its common name is one of them. Names are compared case-insensitively.
+func (cs *CryptoService) CheckSubjectAlternativeNames(cert *x509.Certificate, requireCNInSANs bool) error {
+	if len(cert.DNSNames) == 0 {
+		return ErrNoDNSSAN
+	}
+	commonName := cert.Subject.CommonName
+	if !requireCNInSANs || commonName == "" {
+		return nil
+	}
+	for _, name := range cert.DNSNames {
+		if strings.EqualFold(name, commonName) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrCommonNameNotInSANs, commonName)
+}
+
+// MatchPrivateKeyAndCertificate reports whether a PEM private key belongs to a PEM certificate
+func (cs *CryptoService) MatchPrivateKeyAndCertificate(privateKeyPEM, certPEM string) (bool, error) {
+	privateKey, err := cs.parsePrivateKeyFromPEM(privateKeyPEM)
 	if err != nil {
-		return fmt.Errorf("failed to marshal certificate public key: %w", err)
+		return false, fmt.Errorf("failed to parse private key: %w", err)
 	}
 
-	csrPubKey, err := x509.MarshalPKIXPublicKey(csr.PublicKey)
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return false, fmt.Errorf("unsupported private key type")
+	}
+
+	cert, err := cs.ParseCertificate(certPEM)
 	if err != nil {
-		return fmt.Errorf("failed to marshal CSR public key: %w", err)
+		return false, err
 	}
 
-	if string(certPubKey) != string(csrPubKey) {
-		return fmt.Errorf("certificate public key does not match CSR public key")
+	return publicKeysMatch(cert.PublicKey, signer.Public())
+}
+
+// publicKeysMatch compares two public keys by their PKIX encoding
+func publicKeysMatch(a, b interface{}) (bool, error) {
+	aDER, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal certificate public key: %w", err)
 	}
 
-	// Verify that the subject matches
-	if cert.Subject.CommonName != csr.Subject.CommonName {
-		return fmt.Errorf("certificate CommonName does not match CSR CommonName")
+	bDER, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal public key: %w", err)
 	}
 
+	return bytes.Equal(aDER, bDER), nil
+}
+
+// PublicKeyPin returns the base64 SHA-256 of the SubjectPublicKeyInfo in a PEM certificate, CSR,
+// private key or public key, the pin format used by HPKP. A certificate and its CSR share a pin,
+// and so does every renewal that keeps the same key.
+func (cs *CryptoService) PublicKeyPin(pemData string) (string, error) {
+	publicKey, err := cs.publicKeyFromPEM(pemData)
+	if err != nil {
+		return "", err
+	}
+
+	spki, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	sum := sha256.Sum256(spki)
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// KeyTypeMismatchError reports key material whose actual key type differs from the one it was labeled with
+type KeyTypeMismatchError struct {
+	Requested models.KeyType
+	Inferred  models.KeyType
+}
+
+func (e *KeyTypeMismatchError) Error() string {
+	return fmt.Sprintf("key_type %s does not match the %s key", e.Requested, e.Inferred)
+}
+
+// InferKeyType returns the key type of a PEM certificate, CSR, private key or public key.
+// Keys Certificate Monkey cannot generate, such as RSA 3072 or Ed25519, are reported as errors.
+func (cs *CryptoService) InferKeyType(pemData string) (models.KeyType, error) {
+	publicKey, err := cs.publicKeyFromPEM(pemData)
+	if err != nil {
+		return "", err
+	}
+	return keyTypeOf(publicKey)
+}
+
+// CheckKeyType returns a *KeyTypeMismatchError when the PEM key material is not of the requested key type
+func (cs *CryptoService) CheckKeyType(pemData string, requested models.KeyType) error {
+	inferred, err := cs.InferKeyType(pemData)
+	if err != nil {
+		return err
+	}
+	if inferred != requested {
+		return &KeyTypeMismatchError{Requested: requested, Inferred: inferred}
+	}
 	return nil
 }
 
-// GeneratePFX creates a PFX (PKCS#12) file from private key and certificate
-func (cs *CryptoService) GeneratePFX(privateKeyPEM, certificatePEM, password string) ([]byte, error) {
+// publicKeyFromPEM returns the public key of a PEM certificate, CSR, private key or public key
+func (cs *CryptoService) publicKeyFromPEM(pemData string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		return cert.PublicKey, nil
+	case "CERTIFICATE REQUEST", "NEW CERTIFICATE REQUEST":
+		csr, err := x509.ParseCertificateRequest(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSR: %w", err)
+		}
+		return csr.PublicKey, nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		return key, nil
+	}
+
+	privateKey, err := cs.parsePrivateKeyFromPEM(pemData)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type")
+	}
+	return signer.Public(), nil
+}
+
+// GeneratePFX creates a PFX (PKCS#12) file from private key and certificate.
+// An iterations value of 0 uses the encoder default; otherwise it sets the KDF iteration
+// count for both the MAC and the encryption keys.
+func (cs *CryptoService) GeneratePFX(privateKeyPEM, certificatePEM, password string, iterations int) ([]byte, error) {
+	encoder := pkcs12.Modern
+	if iterations != 0 {
+		if iterations < PFXMinIterations || iterations > PFXMaxIterations {
+			return nil, fmt.Errorf("iterations must be between %d and %d", PFXMinIterations, PFXMaxIterations)
+		}
+		encoder = encoder.WithIterations(iterations)
+	}
+
 	// Parse the private key
 	privateKey, err := cs.parsePrivateKeyFromPEM(privateKeyPEM)
 	if err != nil {
@@ -234,7 +594,7 @@ func (cs *CryptoService) GeneratePFX(privateKeyPEM, certificatePEM, password str
 
 	// Create PKCS#12 bundle
 	// Using Modern.Encode for better security instead of the deprecated Encode method
-	pfxData, err := pkcs12.Modern.Encode(privateKey, cert, nil, password)
+	pfxData, err := encoder.Encode(privateKey, cert, nil, password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode PKCS#12: %w", err)
 	}
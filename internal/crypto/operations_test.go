@@ -7,7 +7,11 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"math/big"
 	"strings"
 	"testing"
@@ -212,6 +216,128 @@ func (suite *CryptoTestSuite) TestGenerateKeyAndCSR() {
 	}
 }
 
+// Test CSRs keep the common name as given by default and lowercase only hostname common names when enabled
+func (suite *CryptoTestSuite) TestGenerateKeyAndCSRLowercaseCommonNames() {
+	commonNameOf := func(cs *CryptoService, commonName string) string {
+		_, csrPEM, err := cs.GenerateKeyAndCSR(models.CreateKeyRequest{CommonName: commonName, KeyType: models.KeyTypeECDSAP256})
+		require.NoError(suite.T(), err)
+		csr, err := cs.ParseCSR(csrPEM)
+		require.NoError(suite.T(), err)
+		return csr.Subject.CommonName
+	}
+
+	assert.Equal(suite.T(), "API.Example.COM", commonNameOf(suite.cryptoService, "API.Example.COM"))
+	assert.Equal(suite.T(), "API.Example.COM", suite.cryptoService.NormalizeCommonName("API.Example.COM"))
+
+	cs := NewCryptoService()
+	cs.SetLowercaseCommonNames(true)
+	assert.Equal(suite.T(), "api.example.com", commonNameOf(cs, "API.Example.COM"))
+	assert.Equal(suite.T(), "*.example.com", commonNameOf(cs, "*.EXAMPLE.com"))
+	for _, name := range []string{"Jane Doe", "Alice", "O'Brien.Smith", "Jane Doe (Ops)", "Müller.Example.com"} {
+		assert.Equal(suite.T(), name, commonNameOf(cs, name), "%q is not a hostname", name)
+	}
+}
+
+// Test CSRs may carry exactly the SAN limit and one more is rejected, when generated, regenerated or imported
+func (suite *CryptoTestSuite) TestMaxSANs() {
+	sans := func(n int) []string {
+		names := make([]string, n)
+		for i := range names {
+			names[i] = fmt.Sprintf("host%d.example.com", i)
+		}
+		return names
+	}
+	request := func(n int) models.CreateKeyRequest {
+		return models.CreateKeyRequest{CommonName: "sans.example.com", SubjectAlternativeNames: sans(n), KeyType: models.KeyTypeECDSAP256}
+	}
+
+	privateKeyPEM, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(request(DefaultMaxSANs))
+	require.NoError(suite.T(), err)
+	_, encoded, err := suite.cryptoService.CSRNames(csrPEM)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), encoded, DefaultMaxSANs)
+
+	_, _, err = suite.cryptoService.GenerateKeyAndCSR(request(DefaultMaxSANs + 1))
+	assert.ErrorIs(suite.T(), err, ErrTooManySANs)
+	assert.ErrorContains(suite.T(), err, "101 given, at most 100 allowed")
+
+	cs := NewCryptoService()
+	cs.SetMaxSANs(3)
+	_, _, err = cs.GenerateKeyAndCSR(request(3))
+	assert.NoError(suite.T(), err)
+	_, _, err = cs.GenerateKeyAndCSR(request(4))
+	assert.ErrorIs(suite.T(), err, ErrTooManySANs)
+
+	_, err = cs.GenerateCSRFromKey(privateKeyPEM, request(3))
+	assert.NoError(suite.T(), err)
+	_, err = cs.GenerateCSRFromKey(privateKeyPEM, request(4))
+	assert.ErrorIs(suite.T(), err, ErrTooManySANs)
+
+	_, importedPEM, err := suite.cryptoService.GenerateKeyAndCSR(request(4))
+	require.NoError(suite.T(), err)
+	_, err = cs.RequestFromCSR(importedPEM)
+	assert.ErrorIs(suite.T(), err, ErrTooManySANs)
+
+	cs.SetMaxSANs(0)
+	_, _, err = cs.GenerateKeyAndCSR(request(4))
+	assert.ErrorIs(suite.T(), err, ErrTooManySANs, "The limit cannot be switched off")
+}
+
+// Test GenerateKeyAndCSR signs the CSR with the requested signature algorithm and rejects one that does not suit the key
+func (suite *CryptoTestSuite) TestGenerateKeyAndCSRSignatureAlgorithm() {
+	tests := []struct {
+		keyType   models.KeyType
+		algorithm string
+		expected  x509.SignatureAlgorithm
+	}{
+		{models.KeyTypeRSA2048, "", x509.SHA256WithRSA},
+		{models.KeyTypeRSA2048, "SHA384-RSA", x509.SHA384WithRSA},
+		{models.KeyTypeRSA2048, "SHA256-RSAPSS", x509.SHA256WithRSAPSS},
+		{models.KeyTypeECDSAP256, "", x509.ECDSAWithSHA256},
+		{models.KeyTypeECDSAP256, "ECDSA-SHA512", x509.ECDSAWithSHA512},
+		{models.KeyTypeECDSAP384, "ECDSA-SHA384", x509.ECDSAWithSHA384},
+	}
+	for _, tt := range tests {
+		suite.Run(string(tt.keyType)+" "+tt.algorithm, func() {
+			_, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+				CommonName:         "signed.example.com",
+				KeyType:            tt.keyType,
+				SignatureAlgorithm: tt.algorithm,
+			})
+			require.NoError(suite.T(), err)
+
+			block, _ := pem.Decode([]byte(csrPEM))
+			require.NotNil(suite.T(), block)
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			require.NoError(suite.T(), err)
+			assert.Equal(suite.T(), tt.expected, csr.SignatureAlgorithm)
+			assert.NoError(suite.T(), csr.CheckSignature())
+		})
+	}
+
+	for _, tt := range []struct {
+		keyType   models.KeyType
+		algorithm string
+	}{
+		{models.KeyTypeRSA2048, "ECDSA-SHA384"},
+		{models.KeyTypeECDSAP384, "SHA384-RSA"},
+		{models.KeyTypeECDSAP256, "MD5-RSA"},
+	} {
+		_, _, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+			CommonName:         "signed.example.com",
+			KeyType:            tt.keyType,
+			SignatureAlgorithm: tt.algorithm,
+		})
+		assert.ErrorIs(suite.T(), err, ErrIncompatibleSignatureAlgorithm, "%s with %s", tt.algorithm, tt.keyType)
+	}
+
+	// A CSR for an existing key is checked against the key itself
+	privateKeyPEM, _, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{CommonName: "signed.example.com", KeyType: models.KeyTypeECDSAP256})
+	require.NoError(suite.T(), err)
+	_, err = suite.cryptoService.GenerateCSRFromKey(privateKeyPEM, models.CreateKeyRequest{CommonName: "signed.example.com", SignatureAlgorithm: "SHA256-RSA"})
+	assert.ErrorIs(suite.T(), err, ErrIncompatibleSignatureAlgorithm)
+}
+
 // Test ParseCertificate
 func (suite *CryptoTestSuite) TestParseCertificate() {
 	// Create a test certificate
@@ -269,6 +395,20 @@ invaliddata
 	}
 }
 
+// fixedFingerprintCertPEM is a fixed certificate whose fingerprints were computed with
+// `openssl x509 -noout -fingerprint -sha1|-sha256`
+const fixedFingerprintCertPEM = `-----BEGIN CERTIFICATE-----
+MIIBmTCCAT+gAwIBAgIUZn/fnRNUwq6VZMnJa5YuAf7iYlYwCgYIKoZIzj0EAwIw
+IjEgMB4GA1UEAwwXZmluZ2VycHJpbnQuZXhhbXBsZS5jb20wHhcNMjYxMDE2MDE0
+MjEyWhcNMzYxMDEzMDE0MjEyWjAiMSAwHgYDVQQDDBdmaW5nZXJwcmludC5leGFt
+cGxlLmNvbTBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABK3CFNqonCcp0wsBjwaw
+oGrWvzhTdDI+dF1bg+2j/4SNHthfGYPxXoxZ/JID3ldna1NwJxcncY0Um+svgGSl
+fISjUzBRMB0GA1UdDgQWBBSfvjt0imvP8RHZVLmF7irsJT9HajAfBgNVHSMEGDAW
+gBSfvjt0imvP8RHZVLmF7irsJT9HajAPBgNVHRMBAf8EBTADAQH/MAoGCCqGSM49
+BAMCA0gAMEUCIQDZLKJlWUiOIu/q96tOy8i17ZjOBVpf9ybN1piuEgQ0tAIgPgpS
+zmpzv8qOlZEHGUQTJCLFoR6UZLpLHgaYMOJVx5U=
+-----END CERTIFICATE-----`
+
 // Test GenerateCertificateFingerprint
 func (suite *CryptoTestSuite) TestGenerateCertificateFingerprint() {
 	testCert := suite.createTestCertificate()
@@ -276,25 +416,41 @@ func (suite *CryptoTestSuite) TestGenerateCertificateFingerprint() {
 	tests := []struct {
 		name        string
 		certPEM     string
+		algorithm   FingerprintAlgorithm
 		expectError bool
 		errorMsg    string
 	}{
 		{
-			name:        "Valid certificate",
+			name:        "Valid certificate SHA-256",
+			certPEM:     testCert,
+			algorithm:   FingerprintSHA256,
+			expectError: false,
+		},
+		{
+			name:        "Valid certificate SHA-1",
 			certPEM:     testCert,
+			algorithm:   FingerprintSHA1,
 			expectError: false,
 		},
 		{
 			name:        "Invalid certificate",
 			certPEM:     "invalid",
+			algorithm:   FingerprintSHA256,
 			expectError: true,
 			errorMsg:    "failed to decode PEM block",
 		},
+		{
+			name:        "Unsupported algorithm",
+			certPEM:     testCert,
+			algorithm:   "md5",
+			expectError: true,
+			errorMsg:    "unsupported fingerprint algorithm",
+		},
 	}
 
 	for _, tt := range tests {
 		suite.Run(tt.name, func() {
-			fingerprint, err := suite.cryptoService.GenerateCertificateFingerprint(tt.certPEM)
+			fingerprint, err := suite.cryptoService.GenerateCertificateFingerprint(tt.certPEM, tt.algorithm)
 
 			if tt.expectError {
 				assert.Error(suite.T(), err)
@@ -309,7 +465,7 @@ func (suite *CryptoTestSuite) TestGenerateCertificateFingerprint() {
 				assert.Contains(suite.T(), fingerprint, ":")
 
 				// Should be consistent
-				fingerprint2, err := suite.cryptoService.GenerateCertificateFingerprint(tt.certPEM)
+				fingerprint2, err := suite.cryptoService.GenerateCertificateFingerprint(tt.certPEM, tt.algorithm)
 				assert.NoError(suite.T(), err)
 				assert.Equal(suite.T(), fingerprint, fingerprint2)
 			}
@@ -317,6 +473,114 @@ func (suite *CryptoTestSuite) TestGenerateCertificateFingerprint() {
 	}
 }
 
+// Test GenerateCertificateFingerprint against known fingerprints of a fixed certificate
+func (suite *CryptoTestSuite) TestGenerateCertificateFingerprintKnownValues() {
+	sha256Fingerprint, err := suite.cryptoService.GenerateCertificateFingerprint(fixedFingerprintCertPEM, FingerprintSHA256)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "29:54:E8:C7:8C:96:24:96:66:5B:A0:2D:88:57:5F:93:68:8D:13:ED:17:4F:75:EC:50:72:1F:2E:6B:ED:61:7D", sha256Fingerprint)
+
+	sha1Fingerprint, err := suite.cryptoService.GenerateCertificateFingerprint(fixedFingerprintCertPEM, FingerprintSHA1)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "F1:C4:95:26:78:8A:A1:7E:7C:B7:1C:C4:66:34:E3:67:66:6F:38:40", sha1Fingerprint)
+}
+
+// fixedSPKIPin is the pin of fixedFingerprintCertPEM, computed with
+// `openssl x509 -pubkey | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64`
+const fixedSPKIPin = "+UFEEa14Iv/u5pfh6IwG0Mp8rK2/D47CKIdIbjzhpHg="
+
+// Test PublicKeyPin against the known pin of a fixed certificate and its public key
+func (suite *CryptoTestSuite) TestPublicKeyPinKnownValue() {
+	pin, err := suite.cryptoService.PublicKeyPin(fixedFingerprintCertPEM)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), fixedSPKIPin, pin)
+
+	cert, err := suite.cryptoService.ParseCertificate(fixedFingerprintCertPEM)
+	require.NoError(suite.T(), err)
+	spki, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	require.NoError(suite.T(), err)
+	publicKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: spki}))
+
+	pin, err = suite.cryptoService.PublicKeyPin(publicKeyPEM)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), fixedSPKIPin, pin)
+}
+
+// Test that a key, its CSR and its certificate share a pin for both key families
+func (suite *CryptoTestSuite) TestPublicKeyPin() {
+	for _, keyType := range []models.KeyType{models.KeyTypeRSA2048, models.KeyTypeECDSAP256} {
+		suite.Run(string(keyType), func() {
+			privateKeyPEM, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+				CommonName: "pin.example.com",
+				KeyType:    keyType,
+			})
+			require.NoError(suite.T(), err)
+			certPEM := suite.createMatchingCertificate(privateKeyPEM, csrPEM)
+
+			keyPin, err := suite.cryptoService.PublicKeyPin(privateKeyPEM)
+			require.NoError(suite.T(), err)
+			csrPin, err := suite.cryptoService.PublicKeyPin(csrPEM)
+			require.NoError(suite.T(), err)
+			certPin, err := suite.cryptoService.PublicKeyPin(certPEM)
+			require.NoError(suite.T(), err)
+
+			assert.Equal(suite.T(), keyPin, csrPin)
+			assert.Equal(suite.T(), keyPin, certPin)
+			assert.Len(suite.T(), keyPin, 44)
+			assert.NotEqual(suite.T(), fixedSPKIPin, keyPin)
+		})
+	}
+
+	_, err := suite.cryptoService.PublicKeyPin("invalid")
+	assert.ErrorContains(suite.T(), err, "failed to decode PEM block")
+}
+
+// Test InferKeyType and CheckKeyType for every supported key type and for mislabeled keys
+func (suite *CryptoTestSuite) TestInferKeyType() {
+	for _, keyType := range []models.KeyType{models.KeyTypeRSA2048, models.KeyTypeRSA4096, models.KeyTypeECDSAP256, models.KeyTypeECDSAP384} {
+		suite.Run(string(keyType), func() {
+			privateKeyPEM, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+				CommonName: "infer.example.com",
+				KeyType:    keyType,
+			})
+			require.NoError(suite.T(), err)
+
+			for _, material := range []string{privateKeyPEM, csrPEM} {
+				inferred, err := suite.cryptoService.InferKeyType(material)
+				require.NoError(suite.T(), err)
+				assert.Equal(suite.T(), keyType, inferred)
+				assert.NoError(suite.T(), suite.cryptoService.CheckKeyType(material, keyType))
+			}
+		})
+	}
+
+	privateKeyPEM, _, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+		CommonName: "infer.example.com",
+		KeyType:    models.KeyTypeRSA2048,
+	})
+	require.NoError(suite.T(), err)
+
+	for _, requested := range []models.KeyType{models.KeyTypeECDSAP256, models.KeyTypeRSA4096} {
+		suite.Run("RSA2048 labeled "+string(requested), func() {
+			err := suite.cryptoService.CheckKeyType(privateKeyPEM, requested)
+			var mismatch *KeyTypeMismatchError
+			require.ErrorAs(suite.T(), err, &mismatch)
+			assert.Equal(suite.T(), requested, mismatch.Requested)
+			assert.Equal(suite.T(), models.KeyTypeRSA2048, mismatch.Inferred)
+			assert.Equal(suite.T(), fmt.Sprintf("key_type %s does not match the RSA2048 key", requested), err.Error())
+		})
+	}
+
+	suite.Run("unsupported curve", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+		require.NoError(suite.T(), err)
+		der, err := x509.MarshalECPrivateKey(key)
+		require.NoError(suite.T(), err)
+
+		_, err = suite.cryptoService.InferKeyType(string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})))
+		assert.ErrorContains(suite.T(), err, "unsupported")
+	})
+}
+
 // Test ValidateCertificateWithCSR
 func (suite *CryptoTestSuite) TestValidateCertificateWithCSR() {
 	// Generate a key and CSR
@@ -339,6 +603,7 @@ func (suite *CryptoTestSuite) TestValidateCertificateWithCSR() {
 		csrPEM      string
 		expectError bool
 		errorMsg    string
+		sentinel    error
 	}{
 		{
 			name:        "Matching certificate and CSR",
@@ -352,6 +617,7 @@ func (suite *CryptoTestSuite) TestValidateCertificateWithCSR() {
 			csrPEM:      csrPEM,
 			expectError: true,
 			errorMsg:    "certificate public key does not match CSR public key",
+			sentinel:    ErrCertificateMismatch,
 		},
 		{
 			name:        "Invalid certificate",
@@ -359,6 +625,7 @@ func (suite *CryptoTestSuite) TestValidateCertificateWithCSR() {
 			csrPEM:      csrPEM,
 			expectError: true,
 			errorMsg:    "failed to parse certificate",
+			sentinel:    ErrInvalidCertificate,
 		},
 		{
 			name:        "Invalid CSR",
@@ -376,6 +643,8 @@ func (suite *CryptoTestSuite) TestValidateCertificateWithCSR() {
 			if tt.expectError {
 				assert.Error(suite.T(), err)
 				assert.Contains(suite.T(), err.Error(), tt.errorMsg)
+				assert.Equal(suite.T(), tt.sentinel == ErrInvalidCertificate, errors.Is(err, ErrInvalidCertificate))
+				assert.Equal(suite.T(), tt.sentinel == ErrCertificateMismatch, errors.Is(err, ErrCertificateMismatch))
 			} else {
 				assert.NoError(suite.T(), err)
 			}
@@ -383,6 +652,44 @@ func (suite *CryptoTestSuite) TestValidateCertificateWithCSR() {
 	}
 }
 
+// Test GenerateCSRFromKey keeps the key and applies the new subject and SANs
+func (suite *CryptoTestSuite) TestGenerateCSRFromKey() {
+	for _, keyType := range []models.KeyType{models.KeyTypeRSA2048, models.KeyTypeECDSAP256} {
+		suite.Run(string(keyType), func() {
+			privateKeyPEM, originalCSRPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+				CommonName:              "old.example.com",
+				SubjectAlternativeNames: []string{"www.old.example.com"},
+				KeyType:                 keyType,
+			})
+			require.NoError(suite.T(), err)
+
+			csrPEM, err := suite.cryptoService.GenerateCSRFromKey(privateKeyPEM, models.CreateKeyRequest{
+				CommonName:              "new.example.com",
+				SubjectAlternativeNames: []string{"new.example.com", "api.example.com", "10.0.0.1"},
+				Organization:            "ACME Corp",
+			})
+			require.NoError(suite.T(), err)
+
+			original := parseTestCSR(suite.T(), originalCSRPEM)
+			regenerated := parseTestCSR(suite.T(), csrPEM)
+			require.NoError(suite.T(), regenerated.CheckSignature())
+
+			assert.Equal(suite.T(), original.PublicKey, regenerated.PublicKey, "The CSR must be for the same key")
+			assert.Equal(suite.T(), "new.example.com", regenerated.Subject.CommonName)
+			assert.Equal(suite.T(), []string{"ACME Corp"}, regenerated.Subject.Organization)
+			assert.Equal(suite.T(), []string{"new.example.com", "api.example.com"}, regenerated.DNSNames)
+			require.Len(suite.T(), regenerated.IPAddresses, 1)
+			assert.Equal(suite.T(), "10.0.0.1", regenerated.IPAddresses[0].String())
+
+			// A certificate issued for the regenerated CSR matches the stored key
+			assert.NoError(suite.T(), suite.cryptoService.ValidateCertificateWithCSR(suite.createMatchingCertificate(privateKeyPEM, csrPEM), csrPEM))
+		})
+	}
+
+	_, err := suite.cryptoService.GenerateCSRFromKey("not a key", models.CreateKeyRequest{CommonName: "example.com"})
+	assert.Error(suite.T(), err)
+}
+
 // Test Base64 encoding/decoding
 func (suite *CryptoTestSuite) TestBase64Operations() {
 	testData := []byte("Hello, Certificate Monkey!")
@@ -457,7 +764,7 @@ func (suite *CryptoTestSuite) TestGeneratePFX() {
 			certificatePEM := suite.createMatchingCertificate(privateKeyPEM, csrPEM)
 
 			// Generate PFX
-			pfxData, err := suite.cryptoService.GeneratePFX(privateKeyPEM, certificatePEM, tt.password)
+			pfxData, err := suite.cryptoService.GeneratePFX(privateKeyPEM, certificatePEM, tt.password, 0)
 
 			if tt.expectError {
 				assert.Error(suite.T(), err)
@@ -488,7 +795,7 @@ func (suite *CryptoTestSuite) TestGeneratePFX() {
 	// Test error cases
 	suite.Run("Invalid private key", func() {
 		certificatePEM := suite.createTestCertificate()
-		_, err := suite.cryptoService.GeneratePFX("invalid-private-key", certificatePEM, "password")
+		_, err := suite.cryptoService.GeneratePFX("invalid-private-key", certificatePEM, "password", 0)
 		assert.Error(suite.T(), err)
 		assert.Contains(suite.T(), err.Error(), "failed to parse private key")
 	})
@@ -501,12 +808,63 @@ func (suite *CryptoTestSuite) TestGeneratePFX() {
 		privateKeyPEM, _, err := suite.cryptoService.GenerateKeyAndCSR(req)
 		require.NoError(suite.T(), err)
 
-		_, err = suite.cryptoService.GeneratePFX(privateKeyPEM, "invalid-certificate", "password")
+		_, err = suite.cryptoService.GeneratePFX(privateKeyPEM, "invalid-certificate", "password", 0)
 		assert.Error(suite.T(), err)
 		assert.Contains(suite.T(), err.Error(), "failed to parse certificate")
 	})
 }
 
+// Test GeneratePFX applies the requested KDF iteration count
+func (suite *CryptoTestSuite) TestGeneratePFXIterations() {
+	privateKeyPEM, csrPEM, err := suite.cryptoService.GenerateKeyAndCSR(models.CreateKeyRequest{
+		CommonName: "pfx-iterations.example.com",
+		KeyType:    models.KeyTypeECDSAP256,
+	})
+	require.NoError(suite.T(), err)
+	certificatePEM := suite.createMatchingCertificate(privateKeyPEM, csrPEM)
+
+	tests := []struct {
+		iterations int
+		expected   int
+	}{
+		{iterations: 0, expected: PFXMinIterations},
+		{iterations: PFXMinIterations, expected: PFXMinIterations},
+		{iterations: 100000, expected: 100000},
+	}
+
+	for _, tt := range tests {
+		suite.Run(fmt.Sprintf("iterations %d", tt.iterations), func() {
+			pfxData, err := suite.cryptoService.GeneratePFX(privateKeyPEM, certificatePEM, "pfx-password", tt.iterations)
+			require.NoError(suite.T(), err)
+
+			_, _, err = pkcs12.Decode(pfxData, "pfx-password")
+			require.NoError(suite.T(), err, "The PFX must still decode")
+			assert.Equal(suite.T(), tt.expected, pfxMACIterations(suite.T(), pfxData))
+		})
+	}
+
+	for _, iterations := range []int{-1, 1, PFXMinIterations - 1, PFXMaxIterations + 1} {
+		_, err := suite.cryptoService.GeneratePFX(privateKeyPEM, certificatePEM, "pfx-password", iterations)
+		assert.Error(suite.T(), err, "iterations %d", iterations)
+	}
+}
+
+// pfxMACIterations reads the MAC KDF iteration count from a PKCS#12 file (RFC 7292 MacData)
+func pfxMACIterations(t *testing.T, pfxData []byte) int {
+	var pfx struct {
+		Version  int
+		AuthSafe asn1.RawValue
+		MacData  struct {
+			Mac        asn1.RawValue
+			MacSalt    []byte
+			Iterations int `asn1:"optional,default:1"`
+		}
+	}
+	_, err := asn1.Unmarshal(pfxData, &pfx)
+	require.NoError(t, err)
+	return pfx.MacData.Iterations
+}
+
 // Test private key parsing with different formats
 func (suite *CryptoTestSuite) TestParsePrivateKeyFromPEM() {
 	// Generate test keys for each supported type
@@ -575,6 +933,84 @@ MIIEvQIBADANBgkqhkiG9w0BAQEFAASCBKcwggSjAgEAAoIBAQC7
 	assert.Contains(suite.T(), err.Error(), "unsupported private key type")
 }
 
+// Test serial numbers keep full precision in decimal and hex form
+func (suite *CryptoTestSuite) TestSerialNumberRepresentations() {
+	// 20 bytes is the largest serial RFC 5280 allows and far beyond int64/float64 precision
+	serial, ok := new(big.Int).SetString("7FEDCBA9876543210FEDCBA9876543210FEDCBA9", 16)
+	require.True(suite.T(), ok)
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(suite.T(), err)
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "serial.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	require.NoError(suite.T(), err)
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))
+
+	cert, err := suite.cryptoService.ParseCertificate(certPEM)
+	require.NoError(suite.T(), err)
+
+	decimal := cert.SerialNumber.String()
+	hexSerial := suite.cryptoService.FormatSerialNumberHex(cert.SerialNumber)
+	assert.Equal(suite.T(), "730344845988415097185401048072226831495695551401", decimal)
+	assert.Equal(suite.T(), "7F:ED:CB:A9:87:65:43:21:0F:ED:CB:A9:87:65:43:21:0F:ED:CB:A9", hexSerial)
+
+	// Both representations survive a JSON round trip unchanged
+	data, err := json.Marshal(models.CertificateEntity{SerialNumber: decimal, SerialNumberHex: hexSerial})
+	require.NoError(suite.T(), err)
+	var restored models.CertificateEntity
+	require.NoError(suite.T(), json.Unmarshal(data, &restored))
+	roundTripped, ok := new(big.Int).SetString(restored.SerialNumber, 10)
+	require.True(suite.T(), ok)
+	assert.Equal(suite.T(), 0, serial.Cmp(roundTripped))
+	assert.Equal(suite.T(), hexSerial, restored.SerialNumberHex)
+
+	// Small serials are zero-padded to whole bytes
+	assert.Equal(suite.T(), "01", suite.cryptoService.FormatSerialNumberHex(big.NewInt(1)))
+	assert.Equal(suite.T(), "01:00", suite.cryptoService.FormatSerialNumberHex(big.NewInt(256)))
+	assert.Equal(suite.T(), "00", suite.cryptoService.FormatSerialNumberHex(big.NewInt(0)))
+}
+
+// Test CheckSubjectAlternativeNames flags CN-only certificates and, optionally, a CN missing from the SANs
+func (suite *CryptoTestSuite) TestCheckSubjectAlternativeNames() {
+	newCertificate := func(commonName string, dnsNames ...string) *x509.Certificate {
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(suite.T(), err)
+		template := x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: commonName},
+			DNSNames:     dnsNames,
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(24 * time.Hour),
+		}
+		certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+		require.NoError(suite.T(), err)
+		cert, err := x509.ParseCertificate(certDER)
+		require.NoError(suite.T(), err)
+		return cert
+	}
+
+	// A CN-only certificate fails whether or not the CN must be among the SANs
+	sanLess := newCertificate("legacy.example.com")
+	assert.ErrorIs(suite.T(), suite.cryptoService.CheckSubjectAlternativeNames(sanLess, false), ErrNoDNSSAN)
+	assert.ErrorIs(suite.T(), suite.cryptoService.CheckSubjectAlternativeNames(sanLess, true), ErrNoDNSSAN)
+
+	// A CN missing from the SANs only fails when the CN is required among them
+	cnMissing := newCertificate("www.example.com", "example.com", "api.example.com")
+	assert.NoError(suite.T(), suite.cryptoService.CheckSubjectAlternativeNames(cnMissing, false))
+	err := suite.cryptoService.CheckSubjectAlternativeNames(cnMissing, true)
+	assert.ErrorIs(suite.T(), err, ErrCommonNameNotInSANs)
+	assert.Contains(suite.T(), err.Error(), "www.example.com")
+
+	// The CN matches a SAN regardless of case, and a certificate without a CN only needs a SAN
+	assert.NoError(suite.T(), suite.cryptoService.CheckSubjectAlternativeNames(newCertificate("WWW.Example.com", "www.example.com"), true))
+	assert.NoError(suite.T(), suite.cryptoService.CheckSubjectAlternativeNames(newCertificate("", "www.example.com"), true))
+}
+
 // Helper function to create a test certificate
 func (suite *CryptoTestSuite) createTestCertificate() string {
 	// Generate a private key
@@ -641,3 +1077,12 @@ func (suite *CryptoTestSuite) createMatchingCertificate(privateKeyPEM, csrPEM st
 		Bytes: certDER,
 	}))
 }
+
+// parseTestCSR decodes a PEM-encoded CSR
+func parseTestCSR(t *testing.T, csrPEM string) *x509.CertificateRequest {
+	block, _ := pem.Decode([]byte(csrPEM))
+	require.NotNil(t, block)
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	require.NoError(t, err)
+	return csr
+}
@@ -0,0 +1,180 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"certificate-monkey/internal/models"
+)
+
+// oidSignedData identifies PKCS#7 SignedData content (RFC 2315)
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// pkcs7ContentInfo is the outer PKCS#7 ContentInfo structure
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	// Content is [0] EXPLICIT; its Bytes hold the encoded SignedData
+	Content asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// pkcs7SignedData is the SignedData structure; only the certificates are used, so the
+// remaining fields are kept raw
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// ParsePKCS7 extracts the certificates of a PKCS#7 (.p7b) bundle given as DER or as a PEM
+// PKCS7 block, as Windows CAs return them. The certificates are ordered leaf first, followed
+// by its issuers up the chain; certificates outside that chain come last in bundle order.
+// Only DER encoding is supported, not BER with indefinite lengths.
+func (cs *CryptoService) ParsePKCS7(data []byte) ([]*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "PKCS7" && block.Type != "CMS" {
+			return nil, fmt.Errorf("invalid PKCS#7 PEM block type: %s", block.Type)
+		}
+		data = block.Bytes
+	}
+
+	var contentInfo pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(data, &contentInfo); err != nil {
+		return nil, fmt.Errorf("failed to parse PKCS#7 content info: %w", err)
+	}
+	if !contentInfo.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("unsupported PKCS#7 content type %s", contentInfo.ContentType)
+	}
+
+	var signedData pkcs7SignedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, fmt.Errorf("failed to parse PKCS#7 signed data: %w", err)
+	}
+
+	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PKCS#7 certificates: %w", err)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("PKCS#7 bundle contains no certificates")
+	}
+	return orderChain(certs), nil
+}
+
+// CertificateBundlePEM returns an uploaded certificate as a PEM bundle, detecting its encoding
+// as DecodeCertificateUpload does
+func (cs *CryptoService) CertificateBundlePEM(data string) (string, error) {
+	return cs.DecodeCertificateUpload(data, "")
+}
+
+// DecodeCertificateUpload returns an uploaded certificate in the given encoding as a PEM bundle.
+// PEM certificates are returned unchanged; a PKCS#7 bundle, as a PEM PKCS7 block or base64 DER, is
+// converted to its certificates in PEM, leaf first. DER certificates, given in base64, are converted
+// to PEM in the order given. An empty encoding takes data without PEM headers to be base64 DER.
+func (cs *CryptoService) DecodeCertificateUpload(data string, encoding models.CertificateFormat) (string, error) {
+	trimmed := strings.TrimSpace(data)
+	isPEM := strings.Contains(trimmed, "-----BEGIN")
+	switch encoding {
+	case "":
+	case models.CertificateFormatPEM:
+		if !isPEM {
+			return "", fmt.Errorf("certificate is not PEM encoded")
+		}
+	case models.CertificateFormatDER:
+		if isPEM {
+			return "", fmt.Errorf("certificate is PEM encoded, not base64 DER")
+		}
+	default:
+		return "", fmt.Errorf("unsupported certificate encoding %q", encoding)
+	}
+
+	var certs []*x509.Certificate
+	switch {
+	case strings.Contains(trimmed, "-----BEGIN PKCS7-----"), strings.Contains(trimmed, "-----BEGIN CMS-----"):
+		var err error
+		if certs, err = cs.ParsePKCS7([]byte(trimmed)); err != nil {
+			return "", err
+		}
+	case isPEM:
+		return data, nil
+	default:
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(trimmed), ""))
+		if err != nil {
+			return "", fmt.Errorf("certificate is neither PEM nor base64 DER")
+		}
+		// DER is either the certificates themselves or a PKCS#7 bundle of them
+		if certs, err = x509.ParseCertificates(der); err != nil || len(certs) == 0 {
+			if certs, err = cs.ParsePKCS7(der); err != nil {
+				return "", fmt.Errorf("base64 DER is neither a certificate nor a PKCS#7 bundle: %w", err)
+			}
+		}
+	}
+
+	var bundle bytes.Buffer
+	for _, cert := range certs {
+		if err := pem.Encode(&bundle, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return "", fmt.Errorf("failed to encode certificate: %w", err)
+		}
+	}
+	return bundle.String(), nil
+}
+
+// orderChain orders certificates leaf first, then each certificate's issuer in turn. The leaf is
+// the first certificate that issued none of the others; a PKCS#7 certificate set has no order of its own.
+func orderChain(certs []*x509.Certificate) []*x509.Certificate {
+	if len(certs) < 2 {
+		return certs
+	}
+
+	issued := func(issuer, cert *x509.Certificate) bool {
+		return issuer != cert && bytes.Equal(issuer.RawSubject, cert.RawIssuer)
+	}
+
+	leaf := 0
+	for i, candidate := range certs {
+		isIssuer := false
+		for _, cert := range certs {
+			if issued(candidate, cert) {
+				isIssuer = true
+				break
+			}
+		}
+		if !isIssuer {
+			leaf = i
+			break
+		}
+	}
+
+	used := make([]bool, len(certs))
+	ordered := []*x509.Certificate{certs[leaf]}
+	used[leaf] = true
+	for current := certs[leaf]; ; {
+		next := -1
+		for i, cert := range certs {
+			if !used[i] && issued(cert, current) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		used[next] = true
+		current = certs[next]
+		ordered = append(ordered, current)
+	}
+
+	for i, cert := range certs {
+		if !used[i] {
+			ordered = append(ordered, cert)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,218 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/models"
+)
+
+// createTestChain issues a leaf certificate through an intermediate from a self-signed root
+func (suite *CryptoTestSuite) createTestChain() (root, intermediate, leaf *x509.Certificate) {
+	issue := func(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(suite.T(), err)
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		require.NoError(suite.T(), err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(suite.T(), err)
+		return cert, key
+	}
+	caTemplate := func(serial int64, commonName string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: commonName},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(24 * time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+	}
+
+	root, rootKey := issue(caTemplate(1, "Test Root CA"), nil, nil)
+	intermediate, intermediateKey := issue(caTemplate(2, "Test Issuing CA"), root, rootKey)
+	leaf, _ = issue(&x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "windows.example.com"},
+		DNSNames:     []string{"windows.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}, intermediate, intermediateKey)
+	return root, intermediate, leaf
+}
+
+// buildPKCS7 encodes certificates as a degenerate, certificates-only PKCS#7 SignedData bundle
+// in the given order, as `openssl crl2pkcs7 -nocrl` and Windows CAs produce them
+func (suite *CryptoTestSuite) buildPKCS7(certs ...*x509.Certificate) []byte {
+	var raw []byte
+	for _, cert := range certs {
+		raw = append(raw, cert.Raw...)
+	}
+	emptySet := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}
+
+	dataContentInfo, err := asn1.Marshal(struct{ ContentType asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}})
+	require.NoError(suite.T(), err)
+	signedData, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue
+		SignerInfos      asn1.RawValue
+	}{
+		Version:          1,
+		DigestAlgorithms: emptySet,
+		ContentInfo:      asn1.RawValue{FullBytes: dataContentInfo},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+		SignerInfos:      emptySet,
+	})
+	require.NoError(suite.T(), err)
+
+	contentInfo, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+	require.NoError(suite.T(), err)
+	return contentInfo
+}
+
+// Test ParsePKCS7 decodes a bundle in DER and PEM into its certificates, leaf first
+func (suite *CryptoTestSuite) TestParsePKCS7() {
+	root, intermediate, leaf := suite.createTestChain()
+
+	// The certificate set is unordered; the chain is put back in order
+	bundle := suite.buildPKCS7(root, leaf, intermediate)
+
+	certs, err := suite.cryptoService.ParsePKCS7(bundle)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), certs, 3)
+	assert.True(suite.T(), certs[0].Equal(leaf))
+	assert.True(suite.T(), certs[1].Equal(intermediate))
+	assert.True(suite.T(), certs[2].Equal(root))
+
+	pemBundle := pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: bundle})
+	fromPEM, err := suite.cryptoService.ParsePKCS7(pemBundle)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), fromPEM, 3)
+	assert.True(suite.T(), fromPEM[0].Equal(leaf))
+
+	// A bundle holding only the leaf
+	certs, err = suite.cryptoService.ParsePKCS7(suite.buildPKCS7(leaf))
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), certs, 1)
+	assert.True(suite.T(), certs[0].Equal(leaf))
+
+	_, err = suite.cryptoService.ParsePKCS7(suite.buildPKCS7())
+	assert.ErrorContains(suite.T(), err, "no certificates")
+
+	_, err = suite.cryptoService.ParsePKCS7([]byte("not a bundle"))
+	assert.Error(suite.T(), err)
+
+	_, err = suite.cryptoService.ParsePKCS7(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}))
+	assert.ErrorContains(suite.T(), err, "PEM block type")
+}
+
+// Test CertificateBundlePEM passes PEM through and converts PKCS#7 bundles to PEM
+func (suite *CryptoTestSuite) TestCertificateBundlePEM() {
+	root, intermediate, leaf := suite.createTestChain()
+	bundle := suite.buildPKCS7(intermediate, root, leaf)
+
+	leafPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}))
+	unchanged, err := suite.cryptoService.CertificateBundlePEM(leafPEM)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), leafPEM, unchanged)
+
+	for name, upload := range map[string]string{
+		"pem":    string(pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: bundle})),
+		"base64": base64.StdEncoding.EncodeToString(bundle),
+		// Base64 copied from a file keeps its line breaks
+		"wrapped base64": wrap(base64.StdEncoding.EncodeToString(bundle), 64),
+	} {
+		suite.Run(name, func() {
+			converted, err := suite.cryptoService.CertificateBundlePEM(upload)
+			require.NoError(suite.T(), err)
+
+			certs, err := suite.cryptoService.ParseCertificates(converted)
+			require.NoError(suite.T(), err)
+			require.Len(suite.T(), certs, 3)
+			assert.True(suite.T(), certs[0].Equal(leaf))
+			assert.True(suite.T(), certs[1].Equal(intermediate))
+			assert.True(suite.T(), certs[2].Equal(root))
+
+			// The leaf is what gets parsed and matched against the CSR
+			first, err := suite.cryptoService.ParseCertificate(converted)
+			require.NoError(suite.T(), err)
+			assert.True(suite.T(), first.Equal(leaf))
+		})
+	}
+
+	_, err = suite.cryptoService.CertificateBundlePEM("not a certificate!")
+	assert.Error(suite.T(), err)
+}
+
+// Test DecodeCertificateUpload converts base64 DER certificates to PEM and holds uploads to a requested encoding
+func (suite *CryptoTestSuite) TestDecodeCertificateUpload() {
+	_, intermediate, leaf := suite.createTestChain()
+	leafPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}))
+	chainPEM := leafPEM + string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediate.Raw}))
+	leafDER := base64.StdEncoding.EncodeToString(leaf.Raw)
+
+	for name, tt := range map[string]struct {
+		upload   string
+		encoding models.CertificateFormat
+		expected string
+	}{
+		"detected DER":   {leafDER, "", leafPEM},
+		"explicit DER":   {leafDER, models.CertificateFormatDER, leafPEM},
+		"wrapped DER":    {wrap(leafDER, 64), models.CertificateFormatDER, leafPEM},
+		"DER with chain": {base64.StdEncoding.EncodeToString(append(append([]byte{}, leaf.Raw...), intermediate.Raw...)), "", chainPEM},
+		"explicit PEM":   {leafPEM, models.CertificateFormatPEM, leafPEM},
+		"detected PEM":   {chainPEM, "", chainPEM},
+		"PKCS#7 as DER":  {base64.StdEncoding.EncodeToString(suite.buildPKCS7(intermediate, leaf)), models.CertificateFormatDER, chainPEM},
+	} {
+		suite.Run(name, func() {
+			converted, err := suite.cryptoService.DecodeCertificateUpload(tt.upload, tt.encoding)
+			require.NoError(suite.T(), err)
+			assert.Equal(suite.T(), tt.expected, converted)
+		})
+	}
+
+	// An explicit encoding must match the upload
+	_, err := suite.cryptoService.DecodeCertificateUpload(leafDER, models.CertificateFormatPEM)
+	assert.ErrorContains(suite.T(), err, "not PEM")
+	_, err = suite.cryptoService.DecodeCertificateUpload(leafPEM, models.CertificateFormatDER)
+	assert.ErrorContains(suite.T(), err, "not base64 DER")
+	_, err = suite.cryptoService.DecodeCertificateUpload(leafDER, "p12")
+	assert.ErrorContains(suite.T(), err, "unsupported certificate encoding")
+
+	// Base64 that is no DER certificate is rejected
+	_, err = suite.cryptoService.DecodeCertificateUpload(base64.StdEncoding.EncodeToString([]byte("not a certificate")), models.CertificateFormatDER)
+	assert.Error(suite.T(), err)
+}
+
+// wrap breaks s into lines of at most width characters
+func wrap(s string, width int) string {
+	var wrapped string
+	for len(s) > width {
+		wrapped += s[:width] + "\n"
+		s = s[width:]
+	}
+	return wrapped + s
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EMF metric units, as defined by CloudWatch
+const (
+	unitCount        = "Count"
+	unitMilliseconds = "Milliseconds"
+)
+
+// EMFRecorder writes each metric as a CloudWatch Embedded Metric Format log line.
+// CloudWatch Logs extracts the metrics, so no agent or scrape endpoint is needed.
+type EMFRecorder struct {
+	namespace string
+	now       func() time.Time
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewEMFRecorder creates a recorder writing EMF lines to out under the CloudWatch namespace
+func NewEMFRecorder(out io.Writer, namespace string) *EMFRecorder {
+	return &EMFRecorder{
+		namespace: namespace,
+		now:       time.Now,
+		out:       out,
+	}
+}
+
+// emfMetric declares one metric of an EMF document
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+// emfDirective tells CloudWatch which members of an EMF document are metrics and dimensions
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+// emfMetadata is the _aws member of an EMF document
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+// KeyCreated emits KeysCreated with the KeyType dimension
+func (r *EMFRecorder) KeyCreated(keyType string) {
+	r.emit("KeysCreated", unitCount, 1, map[string]string{"KeyType": keyType})
+}
+
+// KMSLatency emits KMSLatency in milliseconds with the Operation and Outcome dimensions
+func (r *EMFRecorder) KMSLatency(operation string, duration time.Duration, err error) {
+	outcome := "Success"
+	if err != nil {
+		outcome = "Error"
+	}
+	r.emit("KMSLatency", unitMilliseconds, float64(duration.Microseconds())/1000,
+		map[string]string{"Operation": operation, "Outcome": outcome})
+}
+
+// PFXGenerated emits PFXGenerated without dimensions
+func (r *EMFRecorder) PFXGenerated() {
+	r.emit("PFXGenerated", unitCount, 1, nil)
+}
+
+// emit writes one EMF document holding a single metric value
+func (r *EMFRecorder) emit(name, unit string, value float64, dimensions map[string]string) {
+	dimensionNames := make([]string, 0, len(dimensions))
+	document := make(map[string]interface{}, len(dimensions)+2)
+	for key, dimension := range dimensions {
+		dimensionNames = append(dimensionNames, key)
+		document[key] = dimension
+	}
+	sort.Strings(dimensionNames)
+
+	document[name] = value
+	document["_aws"] = emfMetadata{
+		Timestamp: r.now().UnixMilli(),
+		CloudWatchMetrics: []emfDirective{{
+			Namespace:  r.namespace,
+			Dimensions: [][]string{dimensionNames},
+			Metrics:    []emfMetric{{Name: name, Unit: unit}},
+		}},
+	}
+
+	line, err := json.Marshal(document)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = r.out.Write(append(line, '\n'))
+}
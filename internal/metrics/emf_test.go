@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEMFRecorder() (*EMFRecorder, *bytes.Buffer) {
+	var out bytes.Buffer
+	recorder := NewEMFRecorder(&out, "CertificateMonkey")
+	recorder.now = func() time.Time { return time.UnixMilli(1700000000123) }
+	return recorder, &out
+}
+
+// TestEMFKeyCreated tests the full EMF document emitted for a counted metric
+func TestEMFKeyCreated(t *testing.T) {
+	recorder, out := newTestEMFRecorder()
+	recorder.KeyCreated("ECDSA-P256")
+
+	assert.JSONEq(t, `{
+		"_aws": {
+			"Timestamp": 1700000000123,
+			"CloudWatchMetrics": [{
+				"Namespace": "CertificateMonkey",
+				"Dimensions": [["KeyType"]],
+				"Metrics": [{"Name": "KeysCreated", "Unit": "Count"}]
+			}]
+		},
+		"KeyType": "ECDSA-P256",
+		"KeysCreated": 1
+	}`, out.String())
+	assert.True(t, strings.HasSuffix(out.String(), "}\n"), "Each document is a single line")
+}
+
+// TestEMFKMSLatency tests latency is reported in milliseconds with sorted dimensions
+func TestEMFKMSLatency(t *testing.T) {
+	recorder, out := newTestEMFRecorder()
+	recorder.KMSLatency("Decrypt", 12500*time.Microsecond, errors.New("throttled"))
+
+	var document map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &document))
+	assert.Equal(t, 12.5, document["KMSLatency"])
+	assert.Equal(t, "Decrypt", document["Operation"])
+	assert.Equal(t, "Error", document["Outcome"])
+
+	directive := document["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{[]interface{}{"Operation", "Outcome"}}, directive["Dimensions"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"Name": "KMSLatency", "Unit": "Milliseconds"}}, directive["Metrics"])
+}
+
+// TestEMFPFXGenerated tests a metric without dimensions declares an empty dimension set
+func TestEMFPFXGenerated(t *testing.T) {
+	recorder, out := newTestEMFRecorder()
+	recorder.PFXGenerated()
+	recorder.PFXGenerated()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		assert.Contains(t, line, `"Dimensions":[[]]`)
+		assert.Contains(t, line, `"PFXGenerated":1`)
+	}
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Supported metrics backends, selected with METRICS_BACKEND
+const (
+	BackendNoop = "noop"
+	BackendEMF  = "emf"
+)
+
+// Recorder receives the service's operational metrics
+type Recorder interface {
+	// KeyCreated counts a private key created through the API
+	KeyCreated(keyType string)
+	// KMSLatency records the duration of a KMS call; operation is "Encrypt" or "Decrypt"
+	KMSLatency(operation string, duration time.Duration, err error)
+	// PFXGenerated counts a PKCS#12 file generated for download
+	PFXGenerated()
+}
+
+var (
+	mu       sync.RWMutex
+	recorder Recorder = noopRecorder{}
+)
+
+// NewRecorder creates the recorder for a backend; EMF metrics are written to stdout under namespace
+func NewRecorder(backend, namespace string) (Recorder, error) {
+	switch backend {
+	case BackendNoop:
+		return noopRecorder{}, nil
+	case BackendEMF:
+		return NewEMFRecorder(os.Stdout, namespace), nil
+	default:
+		return nil, fmt.Errorf("unsupported metrics backend %q", backend)
+	}
+}
+
+// SetRecorder installs the recorder that receives all metrics; nil disables metrics
+func SetRecorder(r Recorder) {
+	if r == nil {
+		r = noopRecorder{}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	recorder = r
+}
+
+func current() Recorder {
+	mu.RLock()
+	defer mu.RUnlock()
+	return recorder
+}
+
+// KeyCreated counts a private key created through the API
+func KeyCreated(keyType string) {
+	current().KeyCreated(keyType)
+}
+
+// KMSLatency records the duration of a KMS call
+func KMSLatency(operation string, duration time.Duration, err error) {
+	current().KMSLatency(operation, duration, err)
+}
+
+// PFXGenerated counts a PKCS#12 file generated for download
+func PFXGenerated() {
+	current().PFXGenerated()
+}
+
+// noopRecorder discards every metric
+type noopRecorder struct{}
+
+func (noopRecorder) KeyCreated(string)                       {}
+func (noopRecorder) KMSLatency(string, time.Duration, error) {}
+func (noopRecorder) PFXGenerated()                           {}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRecorder counts the metrics it receives
+type countingRecorder struct {
+	keys, kms, pfx int
+}
+
+func (r *countingRecorder) KeyCreated(string)                       { r.keys++ }
+func (r *countingRecorder) KMSLatency(string, time.Duration, error) { r.kms++ }
+func (r *countingRecorder) PFXGenerated()                           { r.pfx++ }
+
+// TestNewRecorder tests backends are selected by name
+func TestNewRecorder(t *testing.T) {
+	recorder, err := NewRecorder(BackendNoop, "CertificateMonkey")
+	require.NoError(t, err)
+	assert.IsType(t, noopRecorder{}, recorder)
+
+	recorder, err = NewRecorder(BackendEMF, "CertificateMonkey")
+	require.NoError(t, err)
+	assert.IsType(t, &EMFRecorder{}, recorder)
+
+	_, err = NewRecorder("prometheus", "CertificateMonkey")
+	assert.Error(t, err)
+}
+
+// TestSetRecorder tests the package functions forward to the installed recorder
+func TestSetRecorder(t *testing.T) {
+	defer SetRecorder(nil)
+
+	counting := &countingRecorder{}
+	SetRecorder(counting)
+	KeyCreated("RSA2048")
+	KMSLatency("Encrypt", time.Millisecond, nil)
+	PFXGenerated()
+	assert.Equal(t, &countingRecorder{keys: 1, kms: 1, pfx: 1}, counting)
+
+	SetRecorder(nil)
+	KeyCreated("RSA2048")
+	assert.Equal(t, 1, counting.keys, "A nil recorder disables metrics")
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
@@ -53,6 +54,8 @@ func TestCertificateEntityJSONSerialization(t *testing.T) {
 		ValidTo:                 &validTo,
 		SerialNumber:            "123456789",
 		Fingerprint:             "AA:BB:CC:DD:EE:FF",
+		FingerprintSHA1:         "11:22:33:44",
+		FingerprintSHA256:       "AA:BB:CC:DD:EE:FF",
 	}
 
 	// Test marshaling
@@ -83,6 +86,8 @@ func TestCertificateEntityJSONSerialization(t *testing.T) {
 	assert.Equal(t, entity.Tags, unmarshaled.Tags)
 	assert.Equal(t, entity.SerialNumber, unmarshaled.SerialNumber)
 	assert.Equal(t, entity.Fingerprint, unmarshaled.Fingerprint)
+	assert.Equal(t, entity.FingerprintSHA1, unmarshaled.FingerprintSHA1)
+	assert.Equal(t, entity.FingerprintSHA256, unmarshaled.FingerprintSHA256)
 
 	// Time fields require special handling due to precision
 	assert.WithinDuration(t, entity.CreatedAt, unmarshaled.CreatedAt, time.Second)
@@ -290,12 +295,37 @@ func TestGeneratePFXRequest(t *testing.T) {
 	jsonData, err := json.Marshal(request)
 	require.NoError(t, err)
 	assert.Contains(t, string(jsonData), "password")
+	assert.NotContains(t, string(jsonData), "secure-password-123")
 
+	// Incoming requests must still bind the real password
 	var unmarshaled GeneratePFXRequest
-	err = json.Unmarshal(jsonData, &unmarshaled)
+	err = json.Unmarshal([]byte(`{"password":"secure-password-123"}`), &unmarshaled)
+	require.NoError(t, err)
+
+	assert.Equal(t, "secure-password-123", unmarshaled.Password.Reveal())
+}
+
+// Test SecretString never exposes its value when printed or serialized
+func TestSecretStringMasking(t *testing.T) {
+	secret := SecretString("super-secret-value")
+
+	assert.Equal(t, "***", secret.String())
+	assert.Equal(t, "***", fmt.Sprintf("%v", secret))
+	assert.Equal(t, "***", fmt.Sprintf("%s", secret))
+	assert.Equal(t, "***", fmt.Sprintf("%#v", secret))
+
+	jsonData, err := json.Marshal(secret)
+	require.NoError(t, err)
+	assert.Equal(t, `"***"`, string(jsonData))
+
+	// Nested in a struct and a map the value must stay masked as well
+	jsonData, err = json.Marshal(map[string]interface{}{
+		"request": GeneratePFXRequest{Password: secret},
+	})
 	require.NoError(t, err)
+	assert.NotContains(t, string(jsonData), "super-secret-value")
 
-	assert.Equal(t, request.Password, unmarshaled.Password)
+	assert.Equal(t, "super-secret-value", secret.Reveal())
 }
 
 // Test GeneratePFXResponse
@@ -461,3 +491,77 @@ func TestJSONOmitEmpty(t *testing.T) {
 	assert.Contains(t, jsonStr, "created_at")
 	assert.Contains(t, jsonStr, "updated_at")
 }
+
+// TestSanitizeNotes tests notes keep line breaks and tabs but lose other control characters and surrounding whitespace
+func TestSanitizeNotes(t *testing.T) {
+	tests := map[string]string{
+		"issued for Q3 migration, owner @jdoe": "issued for Q3 migration, owner @jdoe",
+		"  padded\n":                           "padded",
+		"line one\r\nline two":                 "line one\nline two",
+		"col\tumn":                             "col\tumn",
+		"bell\a and \x1b[31mescape\x00":        "bell and [31mescape",
+		"zero width\u200bjoiner":               "zero width\u200bjoiner",
+		"":                                     "",
+	}
+	for input, expected := range tests {
+		assert.Equal(t, expected, SanitizeNotes(input), "%q", input)
+	}
+}
+
+// TestCertificateEntityNotes tests notes are serialized when set and omitted otherwise
+func TestCertificateEntityNotes(t *testing.T) {
+	data, err := json.Marshal(CertificateEntity{ID: "entity-1", Notes: "owner @jdoe"})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"notes":"owner @jdoe"`)
+
+	data, err = json.Marshal(CertificateEntity{ID: "entity-1"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "notes")
+
+	fields, err := ParseEntityFields("id,notes")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "notes"}, fields)
+}
+
+// TestEntityTTL tests ttl_days is turned into the epoch seconds DynamoDB's TTL expects
+func TestEntityTTL(t *testing.T) {
+	createdAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2024, 1, 22, 10, 30, 0, 0, time.UTC).Unix(), EntityTTL(createdAt, 7))
+	assert.Equal(t, time.Date(2025, 1, 14, 10, 30, 0, 0, time.UTC).Unix(), EntityTTL(createdAt, 365))
+	assert.Zero(t, EntityTTL(createdAt, 0))
+
+	data, err := json.Marshal(CertificateEntity{ID: "entity-1", ExpiresTTL: EntityTTL(createdAt, 7)})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"expires_ttl":1705919400`)
+
+	data, err = json.Marshal(CertificateEntity{ID: "entity-1"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "expires_ttl")
+}
+
+// TestSerialNumberIsJSONString tests serial numbers are emitted as strings so 20-byte serials keep full precision
+func TestSerialNumberIsJSONString(t *testing.T) {
+	const decimal = "730344845988415097185401048072226831495695551401"
+	const hexSerial = "7F:ED:CB:A9:87:65:43:21:0F:ED:CB:A9:87:65:43:21:0F:ED:CB:A9"
+
+	responses := map[string]interface{}{
+		"entity":  CertificateEntity{ID: "entity-1", SerialNumber: decimal, SerialNumberHex: hexSerial},
+		"upload":  UploadCertificateResponse{ID: "entity-1", SerialNumber: decimal, SerialNumberHex: hexSerial},
+		"inspect": CertificateDetails{SerialNumber: decimal, SerialNumberHex: hexSerial},
+	}
+
+	for name, response := range responses {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.Marshal(response)
+			require.NoError(t, err)
+
+			var decoded map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.IsType(t, "", decoded["serial_number"])
+			assert.IsType(t, "", decoded["serial_number_hex"])
+			assert.Equal(t, decimal, decoded["serial_number"])
+			assert.Equal(t, hexSerial, decoded["serial_number_hex"])
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// entityFieldNames holds the JSON names of the CertificateEntity fields a client may select, in declaration order
+var entityFieldNames = jsonFieldNames(reflect.TypeOf(CertificateEntity{}))
+
+// entityFields is the set of entityFieldNames
+var entityFields = func() map[string]bool {
+	set := make(map[string]bool, len(entityFieldNames))
+	for _, name := range entityFieldNames {
+		set[name] = true
+	}
+	return set
+}()
+
+// summaryExcludedFields are the bulky PEM fields left out of summary list results
+var summaryExcludedFields = map[string]bool{"csr": true, "certificate": true}
+
+// ParseEntityFields parses a comma-separated list of CertificateEntity JSON field names.
+// An empty value selects every field and yields nil.
+func ParseEntityFields(value string) ([]string, error) {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || seen[field] {
+			continue
+		}
+		if !entityFields[field] {
+			return nil, fmt.Errorf("unknown field '%s'", field)
+		}
+		seen[field] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// SummaryEntityFields returns every entity field except the CSR and certificate
+func SummaryEntityFields() []string {
+	fields := make([]string, 0, len(entityFieldNames))
+	for _, name := range entityFieldNames {
+		if !summaryExcludedFields[name] {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// ProjectEntity returns the JSON encoding of the entity reduced to the given fields.
+// Fields that are empty and omitted from the full encoding are omitted here as well.
+func ProjectEntity(entity *CertificateEntity, fields []string) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal entity: %w", err)
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal entity: %w", err)
+	}
+
+	projected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			projected[field] = value
+		}
+	}
+	return projected, nil
+}
+
+// CanonicalJSON returns a canonical JSON encoding of v for clients that sign response bodies:
+// object keys are sorted at every level, including struct fields, there is no insignificant
+// whitespace and HTML characters are not escaped. Equal values always encode to the same bytes.
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	// Decoding into generic values turns every object into a map, which encoding/json writes
+	// in sorted key order; numbers stay as written
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("failed to decode value: %w", err)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(generic); err != nil {
+		return nil, fmt.Errorf("failed to encode value: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// jsonFieldNames returns the JSON names of a struct type's exported fields in declaration order
+func jsonFieldNames(t reflect.Type) []string {
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
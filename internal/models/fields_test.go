@@ -0,0 +1,105 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test parsing of the fields query parameter
+func TestParseEntityFields(t *testing.T) {
+	fields, err := ParseEntityFields("")
+	require.NoError(t, err)
+	assert.Nil(t, fields, "An empty value selects every field")
+
+	fields, err = ParseEntityFields(" id, common_name,,status,id ")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "common_name", "status"}, fields)
+
+	_, err = ParseEntityFields("id,CommonName")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CommonName")
+}
+
+// Test that a projected entity contains only the requested fields
+func TestProjectEntity(t *testing.T) {
+	validTo := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	entity := &CertificateEntity{
+		ID:                  "entity-1",
+		CommonName:          "example.com",
+		EncryptedPrivateKey: "[REDACTED]",
+		CSR:                 "-----BEGIN CERTIFICATE REQUEST-----",
+		Certificate:         "-----BEGIN CERTIFICATE-----",
+		Status:              StatusCertUploaded,
+		ValidTo:             &validTo,
+	}
+
+	projected, err := ProjectEntity(entity, []string{"id", "status", "valid_to"})
+	require.NoError(t, err)
+
+	data, err := json.Marshal(projected)
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, map[string]interface{}{
+		"id":       "entity-1",
+		"status":   "CERT_UPLOADED",
+		"valid_to": "2030-01-01T00:00:00Z",
+	}, decoded)
+
+	// Omitted empty fields stay omitted
+	projected, err = ProjectEntity(entity, []string{"id", "organization"})
+	require.NoError(t, err)
+	assert.Len(t, projected, 1)
+	assert.Contains(t, projected, "id")
+}
+
+// Test that summary fields cover the whole entity except the PEM bodies
+func TestSummaryEntityFields(t *testing.T) {
+	fields := SummaryEntityFields()
+	assert.Equal(t, "id", fields[0], "Fields keep their declaration order")
+	assert.NotContains(t, fields, "csr")
+	assert.NotContains(t, fields, "certificate")
+	assert.Contains(t, fields, "certificate_chain")
+	assert.Len(t, fields, len(entityFieldNames)-2)
+}
+
+// Test that the canonical encoding is byte-identical across marshals and sorts every key
+func TestCanonicalJSON(t *testing.T) {
+	entity := &CertificateEntity{
+		ID:         "entity-1",
+		CommonName: "a<b>&c.example.com",
+		Status:     StatusCSRCreated,
+		Tags: map[string]string{
+			"zone": "eu", "app": "web", "team": "platform", "env": "prod", "owner": "ops",
+			"cost_center": "42", "build": "1", "region": "eu-central-1",
+		},
+		ExternalKey: true,
+	}
+
+	first, err := CanonicalJSON(entity)
+	require.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		again, err := CanonicalJSON(entity)
+		require.NoError(t, err)
+		require.Equal(t, first, again, "Repeated marshals must produce identical bytes")
+	}
+
+	s := string(first)
+	assert.NotContains(t, s, "\n")
+	assert.NotContains(t, s, `\u003c`, "HTML characters are not escaped")
+	assert.Contains(t, s, `"common_name":"a<b>&c.example.com"`)
+	assert.Contains(t, s, `"tags":{"app":"web","build":"1","cost_center":"42","env":"prod","owner":"ops","region":"eu-central-1","team":"platform","zone":"eu"}`)
+	assert.Less(t, strings.Index(s, `"common_name"`), strings.Index(s, `"id"`), "Struct fields are sorted too")
+	assert.Contains(t, s, `"external_key":true`)
+
+	// The canonical form decodes to the same value as the plain encoding
+	plain, err := json.Marshal(entity)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(plain), s)
+}
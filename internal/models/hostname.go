@@ -0,0 +1,26 @@
+package models
+
+import "strings"
+
+// IsHostname reports whether name is an RFC 1123 hostname, optionally led by a single wildcard
+// label such as *.example.com. It is the one definition of a hostname shared by request
+// validation and CSR building, so both agree on which names are hostnames.
+func IsHostname(name string) bool {
+	hostname := strings.TrimPrefix(name, "*.")
+	if hostname == "" || len(hostname) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(hostname, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test which names are accepted as hostnames
+func TestIsHostname(t *testing.T) {
+	for _, name := range []string{"example.com", "WWW.Example.com", "*.example.com", "localhost", "xn--bcher-kva.example", strings.Repeat("a", 63) + ".com"} {
+		assert.True(t, IsHostname(name), name)
+	}
+	for _, name := range []string{"", "*.", "*", "*.*.example.com", "bad host", "-start.example.com", "end-.example.com", "a..b", "example.com.", "under_score.example.com", strings.Repeat("a", 64) + ".com", strings.Repeat("a.", 127) + "com"} {
+		assert.False(t, IsHostname(name), name)
+	}
+}
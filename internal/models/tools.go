@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// InspectCertificateRequest represents a PEM certificate, or bundle, to inspect
+type InspectCertificateRequest struct {
+	Certificate string `json:"certificate" binding:"required"`
+}
+
+// CertificateDetails describes a parsed certificate.
+// SerialNumber is always a JSON string so 20-byte serials keep full precision.
+type CertificateDetails struct {
+	Subject            string    `json:"subject" example:"CN=example.com,O=Example Corp,C=US"`
+	Issuer             string    `json:"issuer" example:"CN=Example Issuing CA,O=Example Corp,C=US"`
+	SerialNumber       string    `json:"serial_number" example:"123456789"`
+	SerialNumberHex    string    `json:"serial_number_hex" example:"07:5B:CD:15"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	Expired            bool      `json:"expired"`
+	NotYetValid        bool      `json:"not_yet_valid"`
+	DNSNames           []string  `json:"dns_names,omitempty"`
+	IPAddresses        []string  `json:"ip_addresses,omitempty"`
+	EmailAddresses     []string  `json:"email_addresses,omitempty"`
+	URIs               []string  `json:"uris,omitempty"`
+	KeyAlgorithm       string    `json:"key_algorithm" example:"RSA"`
+	KeySize            int       `json:"key_size" example:"2048"`
+	KeyUsages          []string  `json:"key_usages,omitempty" example:"digitalSignature,keyEncipherment"`
+	ExtKeyUsages       []string  `json:"ext_key_usages,omitempty" example:"serverAuth,clientAuth"`
+	SignatureAlgorithm string    `json:"signature_algorithm" example:"SHA256-RSA"`
+	IsCA               bool      `json:"is_ca"`
+	SelfSigned         bool      `json:"self_signed"`
+	FingerprintSHA256  string    `json:"fingerprint_sha256"`
+	AuthorityKeyID     string    `json:"authority_key_id,omitempty" example:"B4:2C:5E:..."`
+	SubjectKeyID       string    `json:"subject_key_id,omitempty" example:"3A:1F:9D:..."`
+}
+
+// InspectFormat selects how InspectCertificate renders the certificates
+type InspectFormat string
+
+const (
+	InspectFormatJSON InspectFormat = "json"
+	InspectFormatText InspectFormat = "text"
+)
+
+// InspectCertificateResponse lists the certificates found in the submitted PEM, in order
+type InspectCertificateResponse struct {
+	Count        int                  `json:"count" example:"1"`
+	Certificates []CertificateDetails `json:"certificates"`
+}
+
+// InspectCSRRequest represents a PEM certificate signing request to inspect
+type InspectCSRRequest struct {
+	CSR string `json:"csr" binding:"required"`
+}
+
+// CSRDetails describes a parsed certificate signing request.
+// SignatureError explains why SignatureValid is false.
+type CSRDetails struct {
+	Subject            string   `json:"subject" example:"CN=example.com,O=Example Corp,C=US"`
+	CommonName         string   `json:"common_name" example:"example.com"`
+	DNSNames           []string `json:"dns_names,omitempty"`
+	IPAddresses        []string `json:"ip_addresses,omitempty"`
+	EmailAddresses     []string `json:"email_addresses,omitempty"`
+	URIs               []string `json:"uris,omitempty"`
+	KeyAlgorithm       string   `json:"key_algorithm" example:"ECDSA"`
+	KeySize            int      `json:"key_size" example:"256"`
+	SignatureAlgorithm string   `json:"signature_algorithm" example:"ECDSA-SHA256"`
+	SignatureValid     bool     `json:"signature_valid"`
+	SignatureError     string   `json:"signature_error,omitempty"`
+}
+
+// MatchKeyRequest represents a private key and certificate to compare.
+// The private key is a SecretString so it is masked if the request is ever logged.
+type MatchKeyRequest struct {
+	PrivateKey  SecretString `json:"private_key" binding:"required" swaggertype:"string"`
+	Certificate string       `json:"certificate" binding:"required"`
+}
+
+// MatchKeyResponse reports whether a private key belongs to a certificate
+type MatchKeyResponse struct {
+	Match              bool   `json:"match" example:"true"`
+	CertificateSubject string `json:"certificate_subject" example:"CN=example.com"`
+}
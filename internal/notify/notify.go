@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+// Notification events, selected with NOTIFY_EVENTS
+const (
+	// EventIssued is sent when a certificate is attached to an entity and its status becomes CERT_UPLOADED
+	EventIssued = "issued"
+	// EventExpiring is reserved for warnings that a certificate is about to expire, which are not sent yet
+	EventExpiring = "expiring"
+)
+
+// Events lists the notification events the service sends. EventExpiring is left out until expiry
+// warnings are sent, so it cannot be selected and silently never arrive.
+var Events = []string{EventIssued}
+
+// IsEvent reports whether name is a notification event
+func IsEvent(name string) bool {
+	return slices.Contains(Events, name)
+}
+
+// Notification tells downstream automation, such as a deployment pipeline, about a certificate.
+// Fingerprint is the SHA-256 fingerprint of the certificate.
+type Notification struct {
+	Event       string     `json:"event"`
+	EntityID    string     `json:"entity_id"`
+	CommonName  string     `json:"common_name"`
+	Fingerprint string     `json:"fingerprint"`
+	ValidFrom   *time.Time `json:"valid_from,omitempty"`
+	ValidTo     *time.Time `json:"valid_to,omitempty"`
+	Timestamp   time.Time  `json:"timestamp"`
+}
+
+// Notifier delivers notifications about certificate entities. WebhookNotifier is the HTTP notifier
+// and SNSNotifier publishes to an SNS topic.
+type Notifier interface {
+	Notify(ctx context.Context, notification *Notification) error
+}
+
+// filteredNotifier passes on the notifications of the enabled events and drops the others
+type filteredNotifier struct {
+	next   Notifier
+	events []string
+}
+
+// Filter returns a notifier that only passes notifications of the listed events on to next
+func Filter(next Notifier, events []string) Notifier {
+	return &filteredNotifier{next: next, events: events}
+}
+
+// Notify passes the notification on when its event is enabled
+func (n *filteredNotifier) Notify(ctx context.Context, notification *Notification) error {
+	if !slices.Contains(n.events, notification.Event) {
+		return nil
+	}
+	return n.next.Notify(ctx, notification)
+}
+
+// multiNotifier passes every notification on to each of its notifiers
+type multiNotifier []Notifier
+
+// Multi returns a notifier delivering each notification to all notifiers
+func Multi(notifiers ...Notifier) Notifier {
+	if len(notifiers) == 1 {
+		return notifiers[0]
+	}
+	return multiNotifier(notifiers)
+}
+
+// Notify delivers the notification to every notifier, even when an earlier one fails
+func (n multiNotifier) Notify(ctx context.Context, notification *Notification) error {
+	var errs []error
+	for _, notifier := range n {
+		if err := notifier.Notify(ctx, notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
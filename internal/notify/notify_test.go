@@ -0,0 +1,210 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNotifier keeps every notification it is given
+type recordingNotifier struct {
+	notifications []*Notification
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, notification *Notification) error {
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+// TestFilter tests only notifications of the enabled events are passed on
+func TestFilter(t *testing.T) {
+	next := &recordingNotifier{}
+	notifier := Filter(next, []string{EventIssued})
+
+	require.NoError(t, notifier.Notify(context.Background(), &Notification{Event: EventExpiring, EntityID: "entity-1"}))
+	require.NoError(t, notifier.Notify(context.Background(), &Notification{Event: EventIssued, EntityID: "entity-2"}))
+	require.Len(t, next.notifications, 1)
+	assert.Equal(t, "entity-2", next.notifications[0].EntityID)
+
+	assert.True(t, IsEvent("issued"))
+	assert.False(t, IsEvent("expiring"), "Expiry warnings are not sent yet")
+	assert.False(t, IsEvent("deleted"))
+}
+
+// TestWebhookNotifier tests notifications are posted as JSON and non-2xx responses are failures
+func TestWebhookNotifier(t *testing.T) {
+	status := http.StatusNoContent
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	validFrom := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	validTo := validFrom.AddDate(0, 3, 0)
+	notifier := NewWebhookNotifier(server.URL, time.Second)
+	err := notifier.Notify(context.Background(), &Notification{
+		Event:       EventIssued,
+		EntityID:    "entity-1",
+		CommonName:  "api.example.com",
+		Fingerprint: "AB:CD",
+		ValidFrom:   &validFrom,
+		ValidTo:     &validTo,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "issued", received["event"])
+	assert.Equal(t, "entity-1", received["entity_id"])
+	assert.Equal(t, "api.example.com", received["common_name"])
+	assert.Equal(t, "AB:CD", received["fingerprint"])
+	assert.Equal(t, "2024-01-15T00:00:00Z", received["valid_from"])
+	assert.Equal(t, "2024-04-15T00:00:00Z", received["valid_to"])
+	assert.NotEmpty(t, received["timestamp"])
+
+	status = http.StatusInternalServerError
+	err = notifier.Notify(context.Background(), &Notification{Event: EventIssued, EntityID: "entity-1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 500")
+}
+
+// failingNotifier fails every notification
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(context.Context, *Notification) error {
+	return errors.New("delivery failed")
+}
+
+// TestMulti tests every notifier gets the notification even when another one fails
+func TestMulti(t *testing.T) {
+	first, second := &recordingNotifier{}, &recordingNotifier{}
+	err := Multi(first, failingNotifier{}, second).Notify(context.Background(), &Notification{Event: EventIssued, EntityID: "entity-1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "delivery failed")
+	assert.Len(t, first.notifications, 1)
+	assert.Len(t, second.notifications, 1)
+
+	assert.Same(t, first, Multi(first))
+}
+
+// recordingPublisher keeps every publish input it is given
+type recordingPublisher struct {
+	inputs []*SNSPublishInput
+	err    error
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, input *SNSPublishInput) error {
+	p.inputs = append(p.inputs, input)
+	return p.err
+}
+
+// TestSNSNotifier tests notifications are published as the webhook's JSON with filterable attributes
+func TestSNSNotifier(t *testing.T) {
+	publisher := &recordingPublisher{}
+	notifier := NewSNSNotifier(publisher, "arn:aws:sns:eu-west-1:123456789012:certificates")
+
+	timestamp := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	validFrom := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	validTo := validFrom.AddDate(0, 3, 0)
+	err := notifier.Notify(context.Background(), &Notification{
+		Event:       EventIssued,
+		EntityID:    "entity-1",
+		CommonName:  "api.example.com",
+		Fingerprint: "AB:CD",
+		ValidFrom:   &validFrom,
+		ValidTo:     &validTo,
+		Timestamp:   timestamp,
+	})
+	require.NoError(t, err)
+	require.Len(t, publisher.inputs, 1)
+	input := publisher.inputs[0]
+	assert.Equal(t, "arn:aws:sns:eu-west-1:123456789012:certificates", input.TopicARN)
+	assert.Equal(t, map[string]SNSMessageAttribute{
+		"entity_id":      {DataType: "String", StringValue: "entity-1"},
+		"common_name":    {DataType: "String", StringValue: "api.example.com"},
+		"event":          {DataType: "String", StringValue: "issued"},
+		"days_remaining": {DataType: "Number", StringValue: "44"},
+	}, input.MessageAttributes)
+
+	var message map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(input.Message), &message))
+	assert.Equal(t, "entity-1", message["entity_id"])
+	assert.Equal(t, "AB:CD", message["fingerprint"])
+	assert.Equal(t, "2024-04-15T00:00:00Z", message["valid_to"])
+	assert.Equal(t, "2024-03-01T12:00:00Z", message["timestamp"])
+
+	// Without a validity or common name those attributes are left out, as SNS rejects empty values
+	require.NoError(t, notifier.Notify(context.Background(), &Notification{Event: EventExpiring, EntityID: "entity-2"}))
+	require.Len(t, publisher.inputs, 2)
+	assert.Equal(t, []string{"entity_id", "event"}, slices.Sorted(maps.Keys(publisher.inputs[1].MessageAttributes)))
+
+	publisher.err = errors.New("throttled")
+	err = notifier.Notify(context.Background(), &Notification{Event: EventIssued, EntityID: "entity-1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to publish issued notification")
+}
+
+// TestSNSClient tests Publish sends a signed Query API request with numbered message attributes
+func TestSNSClient(t *testing.T) {
+	status := http.StatusOK
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/sns/aws4_request")
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			_, _ = io.WriteString(w, `<ErrorResponse><Error><Type>Sender</Type><Code>NotFound</Code><Message>Topic does not exist</Message></Error></ErrorResponse>`)
+		}
+	}))
+	defer server.Close()
+
+	client := NewSNSClient(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+	}), time.Second)
+	client.endpoint = server.URL
+	input := &SNSPublishInput{
+		TopicARN: "arn:aws:sns:eu-west-1:123456789012:certificates",
+		Message:  `{"event":"issued"}`,
+		MessageAttributes: map[string]SNSMessageAttribute{
+			"event":          {DataType: "String", StringValue: "issued"},
+			"days_remaining": {DataType: "Number", StringValue: "44"},
+		},
+	}
+	require.NoError(t, client.Publish(context.Background(), input))
+	assert.Equal(t, "Publish", form.Get("Action"))
+	assert.Equal(t, "2010-03-31", form.Get("Version"))
+	assert.Equal(t, "arn:aws:sns:eu-west-1:123456789012:certificates", form.Get("TopicArn"))
+	assert.Equal(t, `{"event":"issued"}`, form.Get("Message"))
+	assert.Equal(t, "days_remaining", form.Get("MessageAttributes.entry.1.Name"))
+	assert.Equal(t, "Number", form.Get("MessageAttributes.entry.1.Value.DataType"))
+	assert.Equal(t, "44", form.Get("MessageAttributes.entry.1.Value.StringValue"))
+	assert.Equal(t, "event", form.Get("MessageAttributes.entry.2.Name"))
+
+	status = http.StatusNotFound
+	err := client.Publish(context.Background(), input)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NotFound: Topic does not exist")
+
+	input.TopicARN = "arn:aws:sqs:eu-west-1:123456789012:certificates"
+	assert.ErrorContains(t, client.Publish(context.Background(), input), "invalid SNS topic ARN")
+	assert.False(t, IsSNSTopicARN("arn:aws:sns:eu-west-1::certificates"))
+}
@@ -0,0 +1,200 @@
+package notify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// snsVersion is the SNS Query API version
+const snsVersion = "2010-03-31"
+
+// SNSMessageAttribute is a message attribute subscribers can filter on; DataType is String or Number
+type SNSMessageAttribute struct {
+	DataType    string
+	StringValue string
+}
+
+// SNSPublishInput is a message published to a topic
+type SNSPublishInput struct {
+	TopicARN          string
+	Message           string
+	MessageAttributes map[string]SNSMessageAttribute
+}
+
+// SNSPublisher publishes messages to SNS topics. SNSClient is the implementation calling AWS.
+type SNSPublisher interface {
+	Publish(ctx context.Context, input *SNSPublishInput) error
+}
+
+// SNSNotifier publishes each notification as a JSON message to an SNS topic, with the entity ID,
+// common name, event and, once the certificate's validity is known, the days remaining as message
+// attributes for subscription filter policies. Notifications are not retried.
+type SNSNotifier struct {
+	client   SNSPublisher
+	topicARN string
+}
+
+// NewSNSNotifier creates a notifier publishing to the topic through client
+func NewSNSNotifier(client SNSPublisher, topicARN string) *SNSNotifier {
+	return &SNSNotifier{client: client, topicARN: topicARN}
+}
+
+// Notify publishes the notification, stamping it with the current time when it has none
+func (n *SNSNotifier) Notify(ctx context.Context, notification *Notification) error {
+	if notification.Timestamp.IsZero() {
+		notification.Timestamp = time.Now()
+	}
+	notification.Timestamp = notification.Timestamp.UTC()
+
+	message, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	attributes := map[string]SNSMessageAttribute{
+		"entity_id": {DataType: "String", StringValue: notification.EntityID},
+		"event":     {DataType: "String", StringValue: notification.Event},
+	}
+	// SNS rejects attributes with empty values
+	if notification.CommonName != "" {
+		attributes["common_name"] = SNSMessageAttribute{DataType: "String", StringValue: notification.CommonName}
+	}
+	if notification.ValidTo != nil {
+		days := daysRemaining(*notification.ValidTo, notification.Timestamp)
+		attributes["days_remaining"] = SNSMessageAttribute{DataType: "Number", StringValue: strconv.Itoa(days)}
+	}
+
+	err = n.client.Publish(ctx, &SNSPublishInput{
+		TopicARN:          n.topicARN,
+		Message:           string(message),
+		MessageAttributes: attributes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish %s notification: %w", notification.Event, err)
+	}
+	return nil
+}
+
+// daysRemaining returns the whole days from now until validTo, negative once it has passed
+func daysRemaining(validTo, now time.Time) int {
+	remaining := validTo.Sub(now)
+	days := int(remaining / (24 * time.Hour))
+	if remaining < 0 && remaining%(24*time.Hour) != 0 {
+		days--
+	}
+	return days
+}
+
+// IsSNSTopicARN reports whether arn has the form arn:partition:sns:region:account:topic
+func IsSNSTopicARN(arn string) bool {
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" {
+		return false
+	}
+	return !slices.Contains(parts, "")
+}
+
+// SNSClient publishes to SNS through the Query API, signing requests with the service's AWS credentials
+type SNSClient struct {
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	httpClient  *http.Client
+	// endpoint overrides the regional endpoint derived from the topic ARN
+	endpoint string
+}
+
+// NewSNSClient creates an SNS client, giving up on a request after timeout
+func NewSNSClient(credentials aws.CredentialsProvider, timeout time.Duration) *SNSClient {
+	return &SNSClient{
+		credentials: credentials,
+		signer:      v4.NewSigner(),
+		httpClient:  &http.Client{Timeout: timeout},
+	}
+}
+
+// snsError is the error document SNS returns with a non-2xx status
+type snsError struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// Publish sends a Publish request to the region and partition of the topic
+func (c *SNSClient) Publish(ctx context.Context, input *SNSPublishInput) error {
+	if !IsSNSTopicARN(input.TopicARN) {
+		return fmt.Errorf("invalid SNS topic ARN %q", input.TopicARN)
+	}
+	arn := strings.Split(input.TopicARN, ":")
+	region := arn[3]
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = "https://sns." + region + ".amazonaws.com"
+		if arn[1] == "aws-cn" {
+			endpoint += ".cn"
+		}
+	}
+
+	form := url.Values{}
+	form.Set("Action", "Publish")
+	form.Set("Version", snsVersion)
+	form.Set("TopicArn", input.TopicARN)
+	form.Set("Message", input.Message)
+	entry := 0
+	// Attributes are numbered in name order so requests are deterministic
+	for _, name := range slices.Sorted(maps.Keys(input.MessageAttributes)) {
+		entry++
+		prefix := fmt.Sprintf("MessageAttributes.entry.%d.", entry)
+		form.Set(prefix+"Name", name)
+		form.Set(prefix+"Value.DataType", input.MessageAttributes[name].DataType)
+		form.Set(prefix+"Value.StringValue", input.MessageAttributes[name].StringValue)
+	}
+	body := form.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/", strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create SNS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	credentials, err := c.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	payloadHash := sha256.Sum256([]byte(body))
+	if err := c.signer.SignHTTP(ctx, credentials, req, hex.EncodeToString(payloadHash[:]), "sns", region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var snsErr snsError
+		if xml.Unmarshal(data, &snsErr) == nil && snsErr.Code != "" {
+			return fmt.Errorf("sns returned %s: %s: %s", res.Status, snsErr.Code, snsErr.Message)
+		}
+		return fmt.Errorf("sns returned %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+)
+
+// maxDecryptCacheEntries bounds the plaintexts one request keeps; later decrypts go to KMS uncached
+const maxDecryptCacheEntries = 64
+
+// decryptCacheKey is the context key of a request's decrypt cache
+type decryptCacheKey struct{}
+
+// decryptCache holds KMS plaintexts for the lifetime of one request, keyed by the SHA-256 of the
+// KMS key ID, the encryption context and the ciphertext, so a hit only ever returns what KMS would
+// have decrypted for the same call. It lives only in memory and is emptied when the request ends.
+type decryptCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]string
+}
+
+// WithDecryptCache returns a context in which repeated KMS decrypts of the same ciphertext are
+// served from memory, and the function that empties the cache. Call it as soon as the operation
+// ends, so plaintext keys are not kept for longer than the request that needed them.
+func WithDecryptCache(ctx context.Context) (context.Context, func()) {
+	cache := &decryptCache{entries: make(map[[sha256.Size]byte]string)}
+	return context.WithValue(ctx, decryptCacheKey{}, cache), cache.clear
+}
+
+// decryptCacheFrom returns the decrypt cache of ctx, or nil when it has none
+func decryptCacheFrom(ctx context.Context) *decryptCache {
+	cache, _ := ctx.Value(decryptCacheKey{}).(*decryptCache)
+	return cache
+}
+
+// decryptCacheEntry returns the cache key of a decrypt call. The encryption context is encoded
+// with its keys sorted, so equal contexts give the same key whatever their map order.
+func decryptCacheEntry(keyID string, encryptionContext map[string]string, ciphertext string) [sha256.Size]byte {
+	// Marshalling strings and a string map cannot fail
+	data, _ := json.Marshal([]interface{}{keyID, encryptionContext, ciphertext})
+	return sha256.Sum256(data)
+}
+
+// get returns the cached plaintext of ciphertext decrypted under keyID and encryptionContext;
+// a nil cache holds nothing
+func (c *decryptCache) get(keyID string, encryptionContext map[string]string, ciphertext string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	plaintext, ok := c.entries[decryptCacheEntry(keyID, encryptionContext, ciphertext)]
+	return plaintext, ok
+}
+
+// put caches the plaintext of ciphertext decrypted under keyID and encryptionContext; a nil cache
+// ignores it
+func (c *decryptCache) put(keyID string, encryptionContext map[string]string, ciphertext, plaintext string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A cleared cache stays empty, even if a decrypt was still in flight
+	if c.entries == nil || len(c.entries) >= maxDecryptCacheEntries {
+		return
+	}
+	c.entries[decryptCacheEntry(keyID, encryptionContext, ciphertext)] = plaintext
+}
+
+// clear drops every cached plaintext; the cache stores nothing afterwards
+func (c *decryptCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
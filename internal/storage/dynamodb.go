@@ -2,7 +2,13 @@ package storage
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -10,46 +16,160 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 
 	"certificate-monkey/internal/config"
+	"certificate-monkey/internal/metrics"
 	"certificate-monkey/internal/models"
 )
 
+// ErrEntityExists is returned when an entity with the same ID is already stored
+var ErrEntityExists = errors.New("certificate entity already exists")
+
+// ErrEntityNotFound is returned when no entity with the requested ID is stored
+var ErrEntityNotFound = errors.New("certificate entity not found")
+
+// ErrEntityProtected is returned when a protected entity would be deleted or overwritten without force
+var ErrEntityProtected = errors.New("certificate entity is protected")
+
+// ErrEntityModified is returned when an entity kept changing while an update based on its
+// current state was being applied
+var ErrEntityModified = errors.New("certificate entity was modified concurrently")
+
+// ErrContextTagBound is returned when a metadata change would alter or remove a tag that is bound
+// into the KMS encryption context of the entity's private key, which would leave the key undecryptable
+var ErrContextTagBound = errors.New("tag is bound to the private key's encryption context")
+
+// ErrEntityClaimed is returned when an entity is claimed by another holder whose claim has not expired
+var ErrEntityClaimed = errors.New("certificate entity is claimed")
+
+// ClaimError reports the live claim that prevented a claim or release; it wraps ErrEntityClaimed
+type ClaimError struct {
+	ID        string
+	ClaimedBy string
+	ExpiresAt *time.Time
+}
+
+func (e *ClaimError) Error() string {
+	if e.ExpiresAt == nil {
+		return fmt.Sprintf("%s: %s by %s", ErrEntityClaimed, e.ID, e.ClaimedBy)
+	}
+	return fmt.Sprintf("%s: %s by %s until %s", ErrEntityClaimed, e.ID, e.ClaimedBy, e.ExpiresAt.Format(time.RFC3339))
+}
+
+func (e *ClaimError) Unwrap() error {
+	return ErrEntityClaimed
+}
+
+// errEntityChanged is returned when an entity was modified while it was being re-encrypted
+var errEntityChanged = errors.New("entity changed during rekey; run rekey again")
+
+// DynamoDBAPI defines the DynamoDB operations used by the storage layer
+type DynamoDBAPI interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// KMSAPI defines the KMS operations used by the storage layer
+type KMSAPI interface {
+	Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+	DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error)
+}
+
 // DynamoDBStorage handles all DynamoDB operations
 type DynamoDBStorage struct {
-	client    *dynamodb.Client
-	kmsClient *kms.Client
+	client    DynamoDBAPI
+	kmsClient KMSAPI
 	tableName string
 	kmsKeyID  string
+	rekeyRate int
 	logger    *logrus.Logger
+
+	// encryptAllSensitive also KMS-encrypts CSRs and certificates at rest
+	encryptAllSensitive bool
+	// contextTags names the entity tags bound into the encryption context of new private key ciphertexts
+	contextTags []string
 }
 
-// NewDynamoDBStorage creates a new DynamoDB storage instance
-func NewDynamoDBStorage(client *dynamodb.Client, kmsClient *kms.Client, cfg *config.Config, logger *logrus.Logger) *DynamoDBStorage {
+// NewDynamoDBStorage creates a new DynamoDB storage instance. KMS encrypt and decrypt calls that are
+// throttled or fail transiently are retried up to the configured KMSMaxAttempts.
+func NewDynamoDBStorage(client DynamoDBAPI, kmsClient KMSAPI, cfg *config.Config, logger *logrus.Logger) *DynamoDBStorage {
 	return &DynamoDBStorage{
 		client:    client,
-		kmsClient: kmsClient,
+		kmsClient: withKMSRetry(kmsClient, cfg.AWS.KMSMaxAttempts, logger),
 		tableName: cfg.AWS.DynamoDBTable,
 		kmsKeyID:  cfg.AWS.KMSKeyID,
+		rekeyRate: cfg.AWS.KMSRekeyRate,
 		logger:    logger,
+
+		encryptAllSensitive: cfg.AWS.EncryptAllSensitive,
+		contextTags:         cfg.AWS.KMSContextTags,
 	}
 }
 
-// CreateCertificateEntity stores a new certificate entity in DynamoDB
+// CreateCertificateEntity stores a new certificate entity in DynamoDB.
+// If the entity has no ID a UUID is generated for it; should that ID already exist
+// the write is retried once with a fresh UUID. Caller-supplied IDs that already
+// exist are reported as ErrEntityExists. Entities with an external key hold no private key, so
+// KMS is not called for them.
 func (d *DynamoDBStorage) CreateCertificateEntity(ctx context.Context, entity *models.CertificateEntity) error {
-	// Encrypt the private key using KMS
-	encryptedPrivateKey, err := d.encryptData(ctx, entity.EncryptedPrivateKey)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt private key: %w", err)
+	generatedID := entity.ID == ""
+	if generatedID {
+		entity.ID = uuid.New().String()
 	}
 
-	// Create a copy with encrypted private key
+	// Store a copy with the encrypted private key
 	entityToStore := *entity
-	entityToStore.EncryptedPrivateKey = encryptedPrivateKey
+	if !entity.ExternalKey {
+		entity.KMSContextTags = d.boundContextTags(entity.Tags)
+		entityToStore.KMSContextTags = entity.KMSContextTags
+		encryptedPrivateKey, keyID, err := d.encryptData(ctx, entity.EncryptedPrivateKey, keyEncryptionContext(entity))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt private key: %w", err)
+		}
+		entityToStore.EncryptedPrivateKey = encryptedPrivateKey
+		entityToStore.KMSKeyID = keyID
+		entity.KMSKeyID = keyID
+	}
+	if err := d.sealFields(ctx, &entityToStore); err != nil {
+		return err
+	}
+	entity.KMSKeyID = entityToStore.KMSKeyID
+
+	err := d.putNewEntity(ctx, &entityToStore)
+	if errors.Is(err, ErrEntityExists) && generatedID {
+		d.logger.WithField("entity_id", entity.ID).Warn("Generated entity ID already exists, retrying with a new ID")
+		entity.ID = uuid.New().String()
+		entityToStore.ID = entity.ID
+		err = d.putNewEntity(ctx, &entityToStore)
+	}
+	if err != nil {
+		return err
+	}
+
+	d.logger.WithFields(logrus.Fields{
+		"entity_id":   entity.ID,
+		"common_name": entity.CommonName,
+		"key_type":    entity.KeyType,
+	}).Info("Certificate entity created successfully")
+
+	return nil
+}
 
+// putNewEntity writes an entity only if no item with the same ID exists yet
+func (d *DynamoDBStorage) putNewEntity(ctx context.Context, entity *models.CertificateEntity) error {
 	// Convert to DynamoDB attribute value
-	av, err := attributevalue.MarshalMap(entityToStore)
+	av, err := attributevalue.MarshalMap(entity)
 	if err != nil {
 		return fmt.Errorf("failed to marshal entity: %w", err)
 	}
@@ -63,20 +183,91 @@ func (d *DynamoDBStorage) CreateCertificateEntity(ctx context.Context, entity *m
 
 	_, err = d.client.PutItem(ctx, input)
 	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return fmt.Errorf("%w: %s", ErrEntityExists, entity.ID)
+		}
 		return fmt.Errorf("failed to put item in DynamoDB: %w", err)
 	}
 
-	d.logger.WithFields(logrus.Fields{
-		"entity_id":   entity.ID,
-		"common_name": entity.CommonName,
-		"key_type":    entity.KeyType,
-	}).Info("Certificate entity created successfully")
-
 	return nil
 }
 
-// GetCertificateEntity retrieves a certificate entity by ID
-func (d *DynamoDBStorage) GetCertificateEntity(ctx context.Context, id string) (*models.CertificateEntity, error) {
+// RestoreCertificateEntity writes an entity from a backup archive, preserving its ID and timestamps.
+// The private key arrives KMS-encrypted and is re-encrypted with the current KMS key, so archives
+// taken before a key rotation can still be restored. Unless overwrite is set an existing entity is
+// left untouched and ErrEntityExists is returned. A protected entity is only overwritten with force,
+// otherwise ErrEntityProtected is returned. The result reports whether an entity was replaced.
+// Entities with an external key are restored without calling KMS.
+func (d *DynamoDBStorage) RestoreCertificateEntity(ctx context.Context, entity *models.CertificateEntity, overwrite, force bool) (bool, error) {
+	entityToStore := *entity
+	// Sealed fields are opened under the archived key ID before it is replaced below
+	if err := d.openSealedFields(ctx, &entityToStore); err != nil {
+		return false, err
+	}
+	if !entity.ExternalKey {
+		// The archive records the original key and bound tags, so keys rotated away from still decrypt
+		privateKey, err := d.decryptData(ctx, entity.EncryptedPrivateKey, entity.KMSKeyID, keyEncryptionContext(entity))
+		if err != nil {
+			return false, fmt.Errorf("failed to decrypt private key: %w", err)
+		}
+
+		// The key is bound to the tags configured now, not those it was archived with
+		entityToStore.KMSContextTags = d.boundContextTags(entity.Tags)
+		encryptedPrivateKey, keyID, err := d.encryptData(ctx, privateKey, keyEncryptionContext(&entityToStore))
+		if err != nil {
+			return false, fmt.Errorf("failed to encrypt private key: %w", err)
+		}
+
+		entityToStore.EncryptedPrivateKey = encryptedPrivateKey
+		entityToStore.KMSKeyID = keyID
+	} else {
+		// sealFields records the key the sealed fields are re-encrypted under
+		entityToStore.KMSKeyID = ""
+		entityToStore.KMSContextTags = nil
+	}
+	// Sealed fields are re-encrypted too, or stored in plaintext if encryption is now disabled
+	if err := d.sealFields(ctx, &entityToStore); err != nil {
+		return false, err
+	}
+
+	if !overwrite {
+		return false, d.putNewEntity(ctx, &entityToStore)
+	}
+
+	av, err := attributevalue.MarshalMap(&entityToStore)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal entity: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:    aws.String(d.tableName),
+		Item:         av,
+		ReturnValues: types.ReturnValueAllOld,
+	}
+	if !force {
+		input.ConditionExpression = aws.String("attribute_not_exists(id) OR " + notProtectedCondition)
+		input.ExpressionAttributeNames = notProtectedNames()
+		input.ExpressionAttributeValues = notProtectedValues()
+	}
+
+	result, err := d.client.PutItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return false, fmt.Errorf("%w: %s", ErrEntityProtected, entity.ID)
+		}
+		return false, fmt.Errorf("failed to put item in DynamoDB: %w", err)
+	}
+
+	return len(result.Attributes) > 0, nil
+}
+
+// GetCertificateEntity retrieves a certificate entity by ID.
+// With decrypt the private key is decrypted with KMS; otherwise KMS is not called and the key is
+// left empty, so callers that only need metadata keep working while KMS is unavailable. Entities
+// with an external key have no private key to decrypt.
+func (d *DynamoDBStorage) GetCertificateEntity(ctx context.Context, id string, decrypt bool) (*models.CertificateEntity, error) {
 	input := &dynamodb.GetItemInput{
 		TableName: aws.String(d.tableName),
 		Key: map[string]types.AttributeValue{
@@ -100,8 +291,18 @@ func (d *DynamoDBStorage) GetCertificateEntity(ctx context.Context, id string) (
 		return nil, fmt.Errorf("failed to unmarshal entity: %w", err)
 	}
 
+	if err := d.openSealedFields(ctx, &entity); err != nil {
+		return nil, err
+	}
+
+	// An empty key is never written back by UpdateCertificateEntity, so the stored key stays intact
+	if !decrypt || entity.ExternalKey {
+		entity.EncryptedPrivateKey = ""
+		return &entity, nil
+	}
+
 	// Decrypt the private key
-	decryptedPrivateKey, err := d.decryptData(ctx, entity.EncryptedPrivateKey)
+	decryptedPrivateKey, err := d.decryptData(ctx, entity.EncryptedPrivateKey, entity.KMSKeyID, keyEncryptionContext(&entity))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt private key: %w", err)
 	}
@@ -110,13 +311,16 @@ func (d *DynamoDBStorage) GetCertificateEntity(ctx context.Context, id string) (
 	return &entity, nil
 }
 
-// UpdateCertificateEntity updates an existing certificate entity
-func (d *DynamoDBStorage) UpdateCertificateEntity(ctx context.Context, entity *models.CertificateEntity) error {
+// UpdateCertificateEntity updates an existing certificate entity.
+// A non-nil change is appended to the entity's status history in the same write. A private key
+// is re-encrypted bound to the entity's current values of the configured context tags.
+func (d *DynamoDBStorage) UpdateCertificateEntity(ctx context.Context, entity *models.CertificateEntity, change *models.StatusChange) error {
 	// Encrypt the private key if it's not already encrypted
 	encryptedPrivateKey := entity.EncryptedPrivateKey
 	if entity.EncryptedPrivateKey != "" {
 		var err error
-		encryptedPrivateKey, err = d.encryptData(ctx, entity.EncryptedPrivateKey)
+		entity.KMSContextTags = d.boundContextTags(entity.Tags)
+		encryptedPrivateKey, entity.KMSKeyID, err = d.encryptData(ctx, entity.EncryptedPrivateKey, keyEncryptionContext(entity))
 		if err != nil {
 			return fmt.Errorf("failed to encrypt private key: %w", err)
 		}
@@ -138,9 +342,19 @@ func (d *DynamoDBStorage) UpdateCertificateEntity(ctx context.Context, entity *m
 
 	// Add certificate fields if present
 	if entity.Certificate != "" {
+		certificate, err := d.sealField(ctx, entity.Certificate)
+		if err != nil {
+			return err
+		}
 		updateExpression += ", #certificate = :certificate"
 		expressionAttributeNames["#certificate"] = "certificate"
-		expressionAttributeValues[":certificate"] = &types.AttributeValueMemberS{Value: entity.Certificate}
+		expressionAttributeValues[":certificate"] = &types.AttributeValueMemberS{Value: certificate}
+	}
+
+	if entity.CertificateChain != "" {
+		updateExpression += ", #certificate_chain = :certificate_chain"
+		expressionAttributeNames["#certificate_chain"] = "certificate_chain"
+		expressionAttributeValues[":certificate_chain"] = &types.AttributeValueMemberS{Value: entity.CertificateChain}
 	}
 
 	if entity.ValidFrom != nil {
@@ -155,23 +369,105 @@ func (d *DynamoDBStorage) UpdateCertificateEntity(ctx context.Context, entity *m
 		expressionAttributeValues[":valid_to"] = &types.AttributeValueMemberS{Value: entity.ValidTo.Format(time.RFC3339)}
 	}
 
+	if entity.Issuer != "" {
+		updateExpression += ", #issuer = :issuer"
+		expressionAttributeNames["#issuer"] = "issuer"
+		expressionAttributeValues[":issuer"] = &types.AttributeValueMemberS{Value: entity.Issuer}
+	}
+
 	if entity.SerialNumber != "" {
 		updateExpression += ", #serial_number = :serial_number"
 		expressionAttributeNames["#serial_number"] = "serial_number"
 		expressionAttributeValues[":serial_number"] = &types.AttributeValueMemberS{Value: entity.SerialNumber}
 	}
 
+	if entity.SerialNumberHex != "" {
+		updateExpression += ", #serial_number_hex = :serial_number_hex"
+		expressionAttributeNames["#serial_number_hex"] = "serial_number_hex"
+		expressionAttributeValues[":serial_number_hex"] = &types.AttributeValueMemberS{Value: entity.SerialNumberHex}
+	}
+
 	if entity.Fingerprint != "" {
 		updateExpression += ", #fingerprint = :fingerprint"
 		expressionAttributeNames["#fingerprint"] = "fingerprint"
 		expressionAttributeValues[":fingerprint"] = &types.AttributeValueMemberS{Value: entity.Fingerprint}
 	}
 
+	if entity.FingerprintSHA1 != "" {
+		updateExpression += ", #fingerprint_sha1 = :fingerprint_sha1"
+		expressionAttributeNames["#fingerprint_sha1"] = "fingerprint_sha1"
+		expressionAttributeValues[":fingerprint_sha1"] = &types.AttributeValueMemberS{Value: entity.FingerprintSHA1}
+	}
+
+	if entity.FingerprintSHA256 != "" {
+		updateExpression += ", #fingerprint_sha256 = :fingerprint_sha256"
+		expressionAttributeNames["#fingerprint_sha256"] = "fingerprint_sha256"
+		expressionAttributeValues[":fingerprint_sha256"] = &types.AttributeValueMemberS{Value: entity.FingerprintSHA256}
+	}
+
+	if entity.SPKIPin != "" {
+		updateExpression += ", #spki_pin = :spki_pin"
+		expressionAttributeNames["#spki_pin"] = "spki_pin"
+		expressionAttributeValues[":spki_pin"] = &types.AttributeValueMemberS{Value: entity.SPKIPin}
+	}
+
+	// Usages and key identifiers are written with every certificate so a replacement certificate clears stale ones
+	if entity.Certificate != "" {
+		keyUsages, err := attributevalue.Marshal(entity.KeyUsages)
+		if err != nil {
+			return fmt.Errorf("failed to marshal key usages: %w", err)
+		}
+		extKeyUsages, err := attributevalue.Marshal(entity.ExtKeyUsages)
+		if err != nil {
+			return fmt.Errorf("failed to marshal extended key usages: %w", err)
+		}
+		updateExpression += ", #key_usages = :key_usages, #ext_key_usages = :ext_key_usages"
+		expressionAttributeNames["#key_usages"] = "key_usages"
+		expressionAttributeNames["#ext_key_usages"] = "ext_key_usages"
+		expressionAttributeValues[":key_usages"] = keyUsages
+		expressionAttributeValues[":ext_key_usages"] = extKeyUsages
+
+		updateExpression += ", #authority_key_id = :authority_key_id, #subject_key_id = :subject_key_id"
+		expressionAttributeNames["#authority_key_id"] = "authority_key_id"
+		expressionAttributeNames["#subject_key_id"] = "subject_key_id"
+		expressionAttributeValues[":authority_key_id"] = &types.AttributeValueMemberS{Value: entity.AuthorityKeyID}
+		expressionAttributeValues[":subject_key_id"] = &types.AttributeValueMemberS{Value: entity.SubjectKeyID}
+	}
+
+	// The bound tags are replaced with the key, so they always describe the stored ciphertext
+	var removeExpression string
 	if encryptedPrivateKey != "" {
 		updateExpression += ", #encrypted_private_key = :encrypted_private_key"
 		expressionAttributeNames["#encrypted_private_key"] = "encrypted_private_key"
 		expressionAttributeValues[":encrypted_private_key"] = &types.AttributeValueMemberS{Value: encryptedPrivateKey}
+
+		expressionAttributeNames["#kms_context_tags"] = "kms_context_tags"
+		if len(entity.KMSContextTags) == 0 {
+			removeExpression = " REMOVE #kms_context_tags"
+		} else {
+			contextTags, err := attributevalue.Marshal(entity.KMSContextTags)
+			if err != nil {
+				return fmt.Errorf("failed to marshal context tags: %w", err)
+			}
+			updateExpression += ", #kms_context_tags = :kms_context_tags"
+			expressionAttributeValues[":kms_context_tags"] = contextTags
+		}
+	}
+
+	if entity.KMSKeyID != "" {
+		updateExpression += ", #kms_key_id = :kms_key_id"
+		expressionAttributeNames["#kms_key_id"] = "kms_key_id"
+		expressionAttributeValues[":kms_key_id"] = &types.AttributeValueMemberS{Value: entity.KMSKeyID}
+	}
+
+	if change != nil {
+		expression, err := appendStatusChange(change, entity.UpdatedAt, expressionAttributeNames, expressionAttributeValues)
+		if err != nil {
+			return err
+		}
+		updateExpression += ", " + expression
 	}
+	updateExpression += removeExpression
 
 	// Perform the update
 	input := &dynamodb.UpdateItemInput{
@@ -198,90 +494,234 @@ func (d *DynamoDBStorage) UpdateCertificateEntity(ctx context.Context, entity *m
 	return nil
 }
 
-// ListCertificateEntities retrieves certificate entities with optional filtering
-func (d *DynamoDBStorage) ListCertificateEntities(ctx context.Context, filters models.SearchFilters) ([]models.CertificateEntity, error) {
-	input := &dynamodb.ScanInput{
-		TableName: aws.String(d.tableName),
+// appendStatusChange stamps a status change with the update time and returns the SET action
+// that appends it to the entity's status history, creating the list if needed
+func appendStatusChange(change *models.StatusChange, at time.Time, names map[string]string, values map[string]types.AttributeValue) (string, error) {
+	change.Timestamp = at
+
+	entry, err := attributevalue.Marshal(change)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal status change: %w", err)
 	}
 
-	// Apply filters if provided
-	var filterExpressions []string
-	expressionAttributeNames := make(map[string]string)
-	expressionAttributeValues := make(map[string]types.AttributeValue)
+	names["#status_history"] = "status_history"
+	values[":status_change"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{entry}}
+	values[":empty_list"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{}}
+	return "#status_history = list_append(if_not_exists(#status_history, :empty_list), :status_change)", nil
+}
 
-	if filters.Status != "" {
-		filterExpressions = append(filterExpressions, "#status = :status")
-		expressionAttributeNames["#status"] = "status"
-		expressionAttributeValues[":status"] = &types.AttributeValueMemberS{Value: string(filters.Status)}
+// GetStatusHistory returns a page of the status transitions of an entity, most recent first unless
+// page.SortOrder is "asc", with the cursor of the next page, which is empty on the last one. Only
+// the history is read, so the private key is never decrypted. ErrInvalidCursor is returned for a
+// malformed cursor or one issued for the other order.
+func (d *DynamoDBStorage) GetStatusHistory(ctx context.Context, id string, page models.HistoryPage) ([]models.StatusChange, string, error) {
+	page = normalizeHistoryPage(page)
+	var after historyCursor
+	if page.Cursor != "" {
+		var err error
+		if after, err = decodeHistoryCursor(page.Cursor, page.SortOrder); err != nil {
+			return nil, "", err
+		}
 	}
 
-	if filters.KeyType != "" {
-		filterExpressions = append(filterExpressions, "#key_type = :key_type")
-		expressionAttributeNames["#key_type"] = "key_type"
-		expressionAttributeValues[":key_type"] = &types.AttributeValueMemberS{Value: string(filters.KeyType)}
+	entity, err := d.GetCertificateEntityFields(ctx, id, []string{"status_history"})
+	if err != nil {
+		return nil, "", err
 	}
 
-	if filters.DateFrom != nil {
-		filterExpressions = append(filterExpressions, "#created_at >= :date_from")
-		expressionAttributeNames["#created_at"] = "created_at"
-		expressionAttributeValues[":date_from"] = &types.AttributeValueMemberS{Value: filters.DateFrom.Format(time.RFC3339)}
+	// The history is stored oldest first and only ever appended to, so a position in it is stable
+	// and pages are cut by position rather than timestamp, which transitions can share
+	history := entity.StatusHistory
+	first, last := 0, len(history)-1
+	if page.Cursor != "" {
+		position := historyCursorPosition(history, after)
+		if page.SortOrder == "asc" {
+			first = position + 1
+		} else {
+			last = position - 1
+		}
 	}
 
-	if filters.DateTo != nil {
-		filterExpressions = append(filterExpressions, "#created_at <= :date_to")
-		expressionAttributeNames["#created_at"] = "created_at"
-		expressionAttributeValues[":date_to"] = &types.AttributeValueMemberS{Value: filters.DateTo.Format(time.RFC3339)}
+	var next string
+	if page.SortOrder == "asc" {
+		if last-first+1 > page.PageSize {
+			last = first + page.PageSize - 1
+			next = historyCursor{SortOrder: page.SortOrder, Timestamp: history[last].Timestamp, Position: &last}.encode()
+		}
+	} else if last-first+1 > page.PageSize {
+		first = last - page.PageSize + 1
+		next = historyCursor{SortOrder: page.SortOrder, Timestamp: history[first].Timestamp, Position: &first}.encode()
 	}
 
-	// Add tag filters
-	if len(filters.Tags) > 0 {
-		// Define #tags attribute name once for all tag filters
-		expressionAttributeNames["#tags"] = "tags"
+	if first > last {
+		return []models.StatusChange{}, "", nil
+	}
+	result := slices.Clone(history[first : last+1])
+	if page.SortOrder == "desc" {
+		slices.Reverse(result)
 	}
+	return result, next, nil
+}
 
-	tagIndex := 0
-	for tagKey, tagValue := range filters.Tags {
-		filterExpressions = append(filterExpressions, fmt.Sprintf("#tags.#tag_key_%d = :tag_value_%d", tagIndex, tagIndex))
-		expressionAttributeNames[fmt.Sprintf("#tag_key_%d", tagIndex)] = tagKey
-		expressionAttributeValues[fmt.Sprintf(":tag_value_%d", tagIndex)] = &types.AttributeValueMemberS{Value: tagValue}
-		tagIndex++
+// historyCursorPosition returns the position in the stored history of the last entry of the
+// previous page. A cursor whose position no longer holds its entry, as after the entity was
+// restored from a backup, is placed by timestamp instead.
+func historyCursorPosition(history []models.StatusChange, cursor historyCursor) int {
+	if position := cursor.Position; position != nil && *position < len(history) && history[*position].Timestamp.Equal(cursor.Timestamp) {
+		return *position
+	}
+	if cursor.SortOrder == "asc" {
+		return sort.Search(len(history), func(i int) bool { return history[i].Timestamp.After(cursor.Timestamp) }) - 1
 	}
+	return sort.Search(len(history), func(i int) bool { return !history[i].Timestamp.Before(cursor.Timestamp) })
+}
 
-	if len(filterExpressions) > 0 {
-		filterExpression := ""
-		for i, expr := range filterExpressions {
-			if i > 0 {
-				filterExpression += " AND "
-			}
-			filterExpression += expr
+// GetCertificateEntityFields retrieves an entity with only the given attributes and its ID populated.
+// The private key is never read, which avoids a KMS call for metadata lookups unless an encrypted
+// CSR or certificate is requested.
+func (d *DynamoDBStorage) GetCertificateEntityFields(ctx context.Context, id string, attributes []string) (*models.CertificateEntity, error) {
+	names := make(map[string]string)
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ProjectionExpression:     aws.String(projectionExpression(append([]string{"id"}, attributes...), names)),
+		ExpressionAttributeNames: names,
+	}
+
+	result, err := d.client.GetItem(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item from DynamoDB: %w", err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("%w: %s", ErrEntityNotFound, id)
+	}
+
+	var entity models.CertificateEntity
+	if err := attributevalue.UnmarshalMap(result.Item, &entity); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal entity: %w", err)
+	}
+	if err := d.openSealedFields(ctx, &entity); err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+// VerifyPrivateKey checks that KMS can still decrypt an entity's private key. The plaintext is
+// discarded at once, and the returned entity holds only its ID, common name, key ID and external
+// key flag. The reason is empty when the key decrypts and otherwise carries only the AWS error code,
+// since KMS messages can contain key ARNs. An error means the entity could not be read at all.
+func (d *DynamoDBStorage) VerifyPrivateKey(ctx context.Context, id string) (*models.CertificateEntity, string, error) {
+	entity, err := d.GetCertificateEntityFields(ctx, id, []string{"common_name", "external_key", "encrypted_private_key", "kms_key_id", "tags", "kms_context_tags"})
+	if err != nil {
+		return nil, "", err
+	}
+	// The tags are only read to rebuild the encryption context
+	encryptedPrivateKey, encryptionContext := entity.EncryptedPrivateKey, keyEncryptionContext(entity)
+	entity.EncryptedPrivateKey, entity.Tags, entity.KMSContextTags = "", nil, nil
+	if entity.ExternalKey {
+		return entity, "", nil
+	}
+	if encryptedPrivateKey == "" {
+		return entity, "no private key stored", nil
+	}
+
+	if _, err := d.decryptData(ctx, encryptedPrivateKey, entity.KMSKeyID, encryptionContext); err != nil {
+		d.logger.WithError(err).WithField("entity_id", id).Warn("Private key failed decryption check")
+		return entity, decryptFailureReason("private key", err), nil
+	}
+	return entity, "", nil
+}
+
+// DefaultListPageSize is the page size ListCertificateEntities applies when none is given
+const DefaultListPageSize = 50
+
+// ListCertificateEntities retrieves certificate entities with optional filtering.
+// Entities that cannot be decoded or decrypted are left out of the result and reported
+// separately, so callers can tell an incomplete list from an empty one. With filters.Cursor
+// the page starts after the entity the cursor was encoded from and filters.Page is ignored;
+// ErrInvalidCursor is returned for a malformed cursor or one issued for another sort.
+func (d *DynamoDBStorage) ListCertificateEntities(ctx context.Context, filters models.SearchFilters) ([]models.CertificateEntity, []models.EntityError, error) {
+	// Reject a bad cursor before paying for the scan
+	var after models.CertificateEntity
+	if filters.Cursor != "" {
+		var err error
+		after, err = decodeCursor(filters.Cursor, filters.SortBy, filters.SortOrder)
+		if err != nil {
+			return nil, nil, err
 		}
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(d.tableName),
+	}
+
+	// Apply filters if provided
+	filterExpression, expressionAttributeNames, expressionAttributeValues := searchFilterExpression(filters)
+	if filterExpression != "" {
 		input.FilterExpression = aws.String(filterExpression)
 		input.ExpressionAttributeNames = expressionAttributeNames
 		input.ExpressionAttributeValues = expressionAttributeValues
 	}
 
+	// Read only the requested attributes, plus those needed to identify and sort entities and
+	// to rebuild the private key's encryption context
+	if len(filters.Projection) > 0 {
+		attributes := append([]string{"id", filters.SortBy}, filters.Projection...)
+		if slices.Contains(filters.Projection, "encrypted_private_key") {
+			attributes = append(attributes, "tags", "kms_context_tags")
+		}
+		input.ProjectionExpression = aws.String(projectionExpression(attributes, expressionAttributeNames))
+		input.ExpressionAttributeNames = expressionAttributeNames
+	}
+
 	// Note: We'll retrieve all matching items first, then sort and paginate in memory
-	// This is because DynamoDB Scan doesn't support sorting by arbitrary fields
-	result, err := d.client.Scan(ctx, input)
+	// This is because DynamoDB Scan doesn't support sorting by arbitrary fields.
+	// Every page is read so that sorting and pagination see the whole result set.
+	var items []map[string]types.AttributeValue
+	err := d.scanPages(ctx, input, func(page *dynamodb.ScanOutput) error {
+		items = append(items, page.Items...)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan DynamoDB table: %w", err)
+		return nil, nil, err
 	}
 
 	// Unmarshal results
 	var entities []models.CertificateEntity
-	for _, item := range result.Items {
+	var entityErrors []models.EntityError
+	for _, item := range items {
 		var entity models.CertificateEntity
 		err = attributevalue.UnmarshalMap(item, &entity)
 		if err != nil {
 			d.logger.WithError(err).Error("Failed to unmarshal certificate entity")
+			entityErrors = append(entityErrors, models.EntityError{
+				ID:    itemID(item),
+				Error: "failed to decode stored entity",
+			})
+			continue
+		}
+
+		if err := d.openSealedFields(ctx, &entity); err != nil {
+			d.logger.WithError(err).WithField("entity_id", entity.ID).Error("Failed to decrypt CSR or certificate")
+			entityErrors = append(entityErrors, models.EntityError{
+				ID:    entity.ID,
+				Error: decryptFailureReason("CSR or certificate", err),
+			})
 			continue
 		}
 
 		// Decrypt the private key
 		if entity.EncryptedPrivateKey != "" {
-			decryptedPrivateKey, err := d.decryptData(ctx, entity.EncryptedPrivateKey)
+			decryptedPrivateKey, err := d.decryptData(ctx, entity.EncryptedPrivateKey, entity.KMSKeyID, keyEncryptionContext(&entity))
 			if err != nil {
 				d.logger.WithError(err).WithField("entity_id", entity.ID).Error("Failed to decrypt private key")
+				entityErrors = append(entityErrors, models.EntityError{
+					ID:    entity.ID,
+					Error: decryptFailureReason("private key", err),
+				})
 				continue
 			}
 			entity.EncryptedPrivateKey = decryptedPrivateKey
@@ -294,39 +734,135 @@ func (d *DynamoDBStorage) ListCertificateEntities(ctx context.Context, filters m
 	d.sortEntities(entities, filters.SortBy, filters.SortOrder)
 
 	// Apply pagination after sorting
-	totalCount := len(entities)
 	page := filters.Page
 	pageSize := filters.PageSize
 
+	// A cursor continues right after the last entity of the previous page, so entities created or
+	// deleted in between cannot shift the window and repeat or skip entities already listed
+	if filters.Cursor != "" {
+		start := sort.Search(len(entities), func(i int) bool {
+			return d.compareEntities(entities[i], after, filters.SortBy, filters.SortOrder)
+		})
+		entities = entities[start:]
+		page = 1
+	}
+
+	totalCount := len(entities)
 	if page <= 0 {
 		page = 1
 	}
 	if pageSize <= 0 {
-		pageSize = 50
+		pageSize = DefaultListPageSize
 	}
 
 	startIndex := (page - 1) * pageSize
 	endIndex := startIndex + pageSize
 
 	if startIndex >= totalCount {
-		return []models.CertificateEntity{}, nil
+		return []models.CertificateEntity{}, entityErrors, nil
 	}
 
 	if endIndex > totalCount {
 		endIndex = totalCount
 	}
 
-	return entities[startIndex:endIndex], nil
+	return entities[startIndex:endIndex], entityErrors, nil
 }
 
-// GetCertificateEntityCount returns the total count of entities matching the filters
-func (d *DynamoDBStorage) GetCertificateEntityCount(ctx context.Context, filters models.SearchFilters) (int, error) {
+// ListCSREntities returns every entity that has a CSR with only its ID, status, CSR and SPKI pin
+// populated, so certificates can be matched to the entity holding their key. The whole table is
+// scanned and the private key is never read. Entities whose CSR cannot be read are reported separately.
+func (d *DynamoDBStorage) ListCSREntities(ctx context.Context) ([]models.CertificateEntity, []models.EntityError, error) {
+	names := map[string]string{"#csr": "csr"}
 	input := &dynamodb.ScanInput{
-		TableName: aws.String(d.tableName),
-		Select:    types.SelectCount, // Only count, don't return items
+		TableName:                aws.String(d.tableName),
+		FilterExpression:         aws.String("attribute_exists(#csr)"),
+		ProjectionExpression:     aws.String(projectionExpression([]string{"id", "status", "csr", "spki_pin"}, names)),
+		ExpressionAttributeNames: names,
 	}
 
-	// Apply the same filters as in ListCertificateEntities
+	var entities []models.CertificateEntity
+	var entityErrors []models.EntityError
+	err := d.scanPages(ctx, input, func(page *dynamodb.ScanOutput) error {
+		for _, item := range page.Items {
+			var entity models.CertificateEntity
+			if err := attributevalue.UnmarshalMap(item, &entity); err != nil {
+				d.logger.WithError(err).Error("Failed to unmarshal certificate entity")
+				entityErrors = append(entityErrors, models.EntityError{
+					ID:    itemID(item),
+					Error: "failed to decode stored entity",
+				})
+				continue
+			}
+			if err := d.openSealedFields(ctx, &entity); err != nil {
+				d.logger.WithError(err).WithField("entity_id", entity.ID).Error("Failed to decrypt CSR")
+				entityErrors = append(entityErrors, models.EntityError{
+					ID:    entity.ID,
+					Error: decryptFailureReason("CSR", err),
+				})
+				continue
+			}
+			entities = append(entities, entity)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return entities, entityErrors, nil
+}
+
+// staleCondition matches entities whose updated_at and, when set, last_seen_at lie before :cutoff.
+// Timestamps are RFC3339 strings, so they compare in time order.
+const staleCondition = "#updated_at < :cutoff AND (attribute_not_exists(#last_seen_at) OR #last_seen_at < :cutoff)"
+
+// ListStaleEntities returns the entities neither touched nor updated since cutoff, with only their
+// ID, subject, status, tags, timestamps and expiry populated. The whole table is scanned and no
+// key material is read. Entities that cannot be decoded are reported separately.
+func (d *DynamoDBStorage) ListStaleEntities(ctx context.Context, cutoff time.Time) ([]models.CertificateEntity, []models.EntityError, error) {
+	names := map[string]string{
+		"#updated_at":   "updated_at",
+		"#last_seen_at": "last_seen_at",
+	}
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(d.tableName),
+		FilterExpression: aws.String(staleCondition),
+		ProjectionExpression: aws.String(projectionExpression(
+			[]string{"id", "common_name", "status", "tags", "updated_at", "last_seen_at", "valid_to"}, names)),
+		ExpressionAttributeNames: names,
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":cutoff": &types.AttributeValueMemberS{Value: cutoff.UTC().Format(time.RFC3339)},
+		},
+	}
+
+	var entities []models.CertificateEntity
+	var entityErrors []models.EntityError
+	err := d.scanPages(ctx, input, func(page *dynamodb.ScanOutput) error {
+		for _, item := range page.Items {
+			var entity models.CertificateEntity
+			if err := attributevalue.UnmarshalMap(item, &entity); err != nil {
+				d.logger.WithError(err).Error("Failed to unmarshal certificate entity")
+				entityErrors = append(entityErrors, models.EntityError{
+					ID:    itemID(item),
+					Error: "failed to decode stored entity",
+				})
+				continue
+			}
+			entities = append(entities, entity)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return entities, entityErrors, nil
+}
+
+// searchFilterExpression builds the scan FilterExpression for the status, key type, creation
+// date, certificate presence and tag filters, with its placeholders. The expression is empty
+// when no filter is set, and the values are nil when no filter needs one, as DynamoDB rejects
+// an empty ExpressionAttributeValues.
+func searchFilterExpression(filters models.SearchFilters) (string, map[string]string, map[string]types.AttributeValue) {
 	var filterExpressions []string
 	expressionAttributeNames := make(map[string]string)
 	expressionAttributeValues := make(map[string]types.AttributeValue)
@@ -355,8 +891,18 @@ func (d *DynamoDBStorage) GetCertificateEntityCount(ctx context.Context, filters
 		expressionAttributeValues[":date_to"] = &types.AttributeValueMemberS{Value: filters.DateTo.Format(time.RFC3339)}
 	}
 
+	if filters.HasCertificate != nil {
+		if *filters.HasCertificate {
+			filterExpressions = append(filterExpressions, "attribute_exists(#certificate)")
+		} else {
+			filterExpressions = append(filterExpressions, "attribute_not_exists(#certificate)")
+		}
+		expressionAttributeNames["#certificate"] = "certificate"
+	}
+
 	// Add tag filters
 	if len(filters.Tags) > 0 {
+		// Define #tags attribute name once for all tag filters
 		expressionAttributeNames["#tags"] = "tags"
 	}
 
@@ -368,51 +914,791 @@ func (d *DynamoDBStorage) GetCertificateEntityCount(ctx context.Context, filters
 		tagIndex++
 	}
 
-	if len(filterExpressions) > 0 {
-		filterExpression := ""
-		for i, expr := range filterExpressions {
-			if i > 0 {
-				filterExpression += " AND "
-			}
-			filterExpression += expr
-		}
-		input.FilterExpression = aws.String(filterExpression)
-		input.ExpressionAttributeNames = expressionAttributeNames
-		input.ExpressionAttributeValues = expressionAttributeValues
+	if len(expressionAttributeValues) == 0 {
+		expressionAttributeValues = nil
 	}
+	return strings.Join(filterExpressions, " AND "), expressionAttributeNames, expressionAttributeValues
+}
 
-	result, err := d.client.Scan(ctx, input)
-	if err != nil {
-		return 0, fmt.Errorf("failed to count items in DynamoDB table: %w", err)
+// projectionExpression builds a ProjectionExpression for the given attributes, skipping
+// empty and repeated names. Placeholders are added to names so reserved words are safe.
+func projectionExpression(attributes []string, names map[string]string) string {
+	var placeholders []string
+	seen := make(map[string]bool)
+	for _, attribute := range attributes {
+		if attribute == "" || seen[attribute] {
+			continue
+		}
+		seen[attribute] = true
+		placeholder := fmt.Sprintf("#proj_%d", len(placeholders))
+		names[placeholder] = attribute
+		placeholders = append(placeholders, placeholder)
 	}
-
-	return int(result.Count), nil
+	return strings.Join(placeholders, ", ")
 }
 
-// sortEntities sorts the entities slice in-place based on the specified field and order
-func (d *DynamoDBStorage) sortEntities(entities []models.CertificateEntity, sortBy, sortOrder string) {
-	if len(entities) <= 1 {
-		return
+// itemID returns the ID of a raw DynamoDB item, even if the item cannot be unmarshaled
+func itemID(item map[string]types.AttributeValue) string {
+	if id, ok := item["id"].(*types.AttributeValueMemberS); ok {
+		return id.Value
 	}
+	return ""
+}
 
-	// Import sort package at the top of the file
-	// sort.Slice(entities, func(i, j int) bool {
-	// 	return d.compareEntities(entities[i], entities[j], sortBy, sortOrder)
-	// })
-
-	// Implement sorting using a simple approach
-	for i := 0; i < len(entities)-1; i++ {
-		for j := i + 1; j < len(entities); j++ {
-			shouldSwap := d.compareEntities(entities[i], entities[j], sortBy, sortOrder)
-			if shouldSwap {
-				entities[i], entities[j] = entities[j], entities[i]
-			}
-		}
+// decryptFailureReason describes why a field could not be decrypted. Only the AWS
+// error code is exposed, since KMS error messages can contain key ARNs.
+func decryptFailureReason(field string, err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("failed to decrypt %s: %s", field, apiErr.ErrorCode())
 	}
+	return "failed to decrypt " + field
 }
 
-// compareEntities compares two entities based on the sort field and order
-// Returns true if entity i should come after entity j in the sorted order
+// ForEachEncryptedEntity walks the whole table page by page and calls fn for every entity.
+// Private keys are passed through exactly as stored, i.e. still KMS-encrypted.
+func (d *DynamoDBStorage) ForEachEncryptedEntity(ctx context.Context, fn func(entity *models.CertificateEntity) error) error {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(d.tableName),
+	}
+
+	return d.scanPages(ctx, input, func(page *dynamodb.ScanOutput) error {
+		for _, item := range page.Items {
+			var entity models.CertificateEntity
+			if err := attributevalue.UnmarshalMap(item, &entity); err != nil {
+				return fmt.Errorf("failed to unmarshal entity: %w", err)
+			}
+			if err := fn(&entity); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateCSR replaces an entity's CSR and subject fields and resets its status to CSR_CREATED.
+// The private key is left untouched; empty optional subject fields are removed.
+// A non-nil change is appended to the entity's status history in the same write.
+func (d *DynamoDBStorage) UpdateCSR(ctx context.Context, entity *models.CertificateEntity, change *models.StatusChange) error {
+	entity.Status = models.StatusCSRCreated
+	entity.UpdatedAt = time.Now()
+
+	csr, err := d.sealField(ctx, entity.CSR)
+	if err != nil {
+		return err
+	}
+
+	setExpressions := []string{"#csr = :csr", "#common_name = :common_name", "#status = :status", "#updated_at = :updated_at"}
+	var removeExpressions []string
+	expressionAttributeNames := map[string]string{
+		"#csr":         "csr",
+		"#common_name": "common_name",
+		"#status":      "status",
+		"#updated_at":  "updated_at",
+	}
+	expressionAttributeValues := map[string]types.AttributeValue{
+		":csr":         &types.AttributeValueMemberS{Value: csr},
+		":common_name": &types.AttributeValueMemberS{Value: entity.CommonName},
+		":status":      &types.AttributeValueMemberS{Value: string(entity.Status)},
+		":updated_at":  &types.AttributeValueMemberS{Value: entity.UpdatedAt.Format(time.RFC3339)},
+	}
+
+	optionalFields := []struct {
+		name  string
+		value string
+	}{
+		{"organization", entity.Organization},
+		{"organizational_unit", entity.OrganizationalUnit},
+		{"country", entity.Country},
+		{"state", entity.State},
+		{"city", entity.City},
+		{"email_address", entity.EmailAddress},
+	}
+	for _, field := range optionalFields {
+		expressionAttributeNames["#"+field.name] = field.name
+		if field.value == "" {
+			removeExpressions = append(removeExpressions, "#"+field.name)
+			continue
+		}
+		setExpressions = append(setExpressions, fmt.Sprintf("#%s = :%s", field.name, field.name))
+		expressionAttributeValues[":"+field.name] = &types.AttributeValueMemberS{Value: field.value}
+	}
+
+	expressionAttributeNames["#subject_alternative_names"] = "subject_alternative_names"
+	if len(entity.SubjectAlternativeNames) == 0 {
+		removeExpressions = append(removeExpressions, "#subject_alternative_names")
+	} else {
+		sans, err := attributevalue.Marshal(entity.SubjectAlternativeNames)
+		if err != nil {
+			return fmt.Errorf("failed to marshal subject alternative names: %w", err)
+		}
+		setExpressions = append(setExpressions, "#subject_alternative_names = :subject_alternative_names")
+		expressionAttributeValues[":subject_alternative_names"] = sans
+	}
+
+	if change != nil {
+		expression, err := appendStatusChange(change, entity.UpdatedAt, expressionAttributeNames, expressionAttributeValues)
+		if err != nil {
+			return err
+		}
+		setExpressions = append(setExpressions, expression)
+	}
+
+	updateExpression := "SET " + strings.Join(setExpressions, ", ")
+	if len(removeExpressions) > 0 {
+		updateExpression += " REMOVE " + strings.Join(removeExpressions, ", ")
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: entity.ID},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ExpressionAttributeNames:  expressionAttributeNames,
+		ExpressionAttributeValues: expressionAttributeValues,
+		ConditionExpression:       aws.String("attribute_exists(id)"),
+	}
+
+	if _, err := d.client.UpdateItem(ctx, input); err != nil {
+		return fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+
+	d.logger.WithFields(logrus.Fields{
+		"entity_id":   entity.ID,
+		"common_name": entity.CommonName,
+	}).Info("Certificate signing request regenerated")
+
+	return nil
+}
+
+// UpdateMetadata sets an entity's notes and replaces its tags, leaving a nil argument unchanged;
+// empty notes or tags are removed. Unless force is set, tags are not changed in a way that
+// drops protected=true from a protected entity. Tags bound into the private key's encryption
+// context are never changed or removed, even with force; ErrContextTagBound is returned instead.
+// The updated entity is returned without its private key being decrypted.
+func (d *DynamoDBStorage) UpdateMetadata(ctx context.Context, id string, notes *string, tags map[string]string, force bool) (*models.CertificateEntity, error) {
+	if tags != nil {
+		current, err := d.GetCertificateEntityFields(ctx, id, []string{"tags", "kms_context_tags"})
+		// A missing entity is reported by the conditional write below
+		if err != nil && !errors.Is(err, ErrEntityNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			if err := changedContextTag(current, tags); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	setExpressions := []string{"#updated_at = :updated_at"}
+	var removeExpressions []string
+	expressionAttributeNames := map[string]string{
+		"#updated_at": "updated_at",
+	}
+	expressionAttributeValues := map[string]types.AttributeValue{
+		":updated_at": &types.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339)},
+	}
+	condition := "attribute_exists(id)"
+
+	if notes != nil {
+		expressionAttributeNames["#notes"] = "notes"
+		if *notes == "" {
+			removeExpressions = append(removeExpressions, "#notes")
+		} else {
+			setExpressions = append(setExpressions, "#notes = :notes")
+			expressionAttributeValues[":notes"] = &types.AttributeValueMemberS{Value: *notes}
+		}
+	}
+
+	if tags != nil {
+		expressionAttributeNames["#tags"] = "tags"
+		if len(tags) == 0 {
+			removeExpressions = append(removeExpressions, "#tags")
+		} else {
+			value, err := attributevalue.Marshal(tags)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal tags: %w", err)
+			}
+			setExpressions = append(setExpressions, "#tags = :tags")
+			expressionAttributeValues[":tags"] = value
+		}
+		// DynamoDB rejects unused placeholders, so the protection check adds its own only when applied
+		if !force && tags[models.ProtectedTag] != "true" {
+			condition += " AND (" + notProtectedCondition + ")"
+			for name, value := range notProtectedNames() {
+				expressionAttributeNames[name] = value
+			}
+			for name, value := range notProtectedValues() {
+				expressionAttributeValues[name] = value
+			}
+		}
+	}
+
+	updateExpression := "SET " + strings.Join(setExpressions, ", ")
+	if len(removeExpressions) > 0 {
+		updateExpression += " REMOVE " + strings.Join(removeExpressions, ", ")
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ExpressionAttributeNames:  expressionAttributeNames,
+		ExpressionAttributeValues: expressionAttributeValues,
+		ConditionExpression:       aws.String(condition),
+		ReturnValues:              types.ReturnValueAllNew,
+		// The old item tells a protected entity apart from a missing one when the condition fails
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+
+	result, err := d.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			if len(conditionErr.Item) > 0 {
+				return nil, fmt.Errorf("%w: %s", ErrEntityProtected, id)
+			}
+			return nil, fmt.Errorf("%w: %s", ErrEntityNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+
+	var entity models.CertificateEntity
+	if err := attributevalue.UnmarshalMap(result.Attributes, &entity); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal entity: %w", err)
+	}
+	if err := d.openSealedFields(ctx, &entity); err != nil {
+		return nil, err
+	}
+
+	d.logger.WithFields(logrus.Fields{
+		"entity_id":     id,
+		"notes_changed": notes != nil,
+		"tags_changed":  tags != nil,
+	}).Info("Certificate entity metadata updated")
+
+	return &entity, nil
+}
+
+// maxTransferAttempts bounds how often a transfer is retried when the tags change underneath it
+const maxTransferAttempts = 3
+
+// TransferOwnership merges the given owner tags into an entity's tags and, when notes is not nil,
+// sets its notes, all in one conditional write. The write only succeeds if the tags are still the
+// ones the merge was based on, so concurrent tag changes are never lost, and a protected entity is
+// never transferred. Owner tags that would change a tag bound into the private key's encryption
+// context are rejected with ErrContextTagBound. It returns the updated entity, without its private
+// key being decrypted, and the previous values of those owner tags that were already set.
+func (d *DynamoDBStorage) TransferOwnership(ctx context.Context, id string, ownerTags map[string]string, notes *string) (*models.CertificateEntity, map[string]string, error) {
+	for attempt := 1; attempt <= maxTransferAttempts; attempt++ {
+		current, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(d.tableName),
+			Key: map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberS{Value: id},
+			},
+			ProjectionExpression:     aws.String("#id, #tags, #kms_context_tags"),
+			ExpressionAttributeNames: map[string]string{"#id": "id", "#tags": "tags", "#kms_context_tags": "kms_context_tags"},
+			ConsistentRead:           aws.Bool(true),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get item from DynamoDB: %w", err)
+		}
+		if current.Item == nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrEntityNotFound, id)
+		}
+		if isProtectedItem(current.Item) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrEntityProtected, id)
+		}
+
+		var stored models.CertificateEntity
+		if err := attributevalue.UnmarshalMap(current.Item, &stored); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+		}
+		tags := stored.Tags
+		previous := make(map[string]string, len(ownerTags))
+		merged := make(map[string]string, len(tags)+len(ownerTags))
+		for key, value := range tags {
+			merged[key] = value
+		}
+		for key, value := range ownerTags {
+			if old, ok := tags[key]; ok {
+				previous[key] = old
+			}
+			merged[key] = value
+		}
+		if err := changedContextTag(&stored, merged); err != nil {
+			return nil, nil, err
+		}
+
+		entity, err := d.putTransferredTags(ctx, id, current.Item["tags"], merged, notes)
+		if errors.Is(err, errEntityChanged) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		d.logger.WithFields(logrus.Fields{
+			"entity_id":     id,
+			"notes_changed": notes != nil,
+		}).Info("Certificate entity ownership transferred")
+
+		return entity, previous, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: %s", ErrEntityModified, id)
+}
+
+// putTransferredTags writes the merged tags of a transfer on condition that the stored tags
+// still equal oldTags, where nil means the entity had none
+func (d *DynamoDBStorage) putTransferredTags(ctx context.Context, id string, oldTags types.AttributeValue, tags map[string]string, notes *string) (*models.CertificateEntity, error) {
+	tagsValue, err := attributevalue.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	setExpressions := []string{"#tags = :tags", "#updated_at = :updated_at"}
+	var removeExpressions []string
+	expressionAttributeNames := map[string]string{
+		"#tags":       "tags",
+		"#updated_at": "updated_at",
+	}
+	expressionAttributeValues := map[string]types.AttributeValue{
+		":tags":       tagsValue,
+		":updated_at": &types.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339)},
+	}
+
+	condition := "attribute_exists(id) AND attribute_not_exists(#tags)"
+	if oldTags != nil {
+		condition = "#tags = :old_tags"
+		expressionAttributeValues[":old_tags"] = oldTags
+	}
+
+	if notes != nil {
+		expressionAttributeNames["#notes"] = "notes"
+		if *notes == "" {
+			removeExpressions = append(removeExpressions, "#notes")
+		} else {
+			setExpressions = append(setExpressions, "#notes = :notes")
+			expressionAttributeValues[":notes"] = &types.AttributeValueMemberS{Value: *notes}
+		}
+	}
+
+	updateExpression := "SET " + strings.Join(setExpressions, ", ")
+	if len(removeExpressions) > 0 {
+		updateExpression += " REMOVE " + strings.Join(removeExpressions, ", ")
+	}
+
+	result, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ExpressionAttributeNames:  expressionAttributeNames,
+		ExpressionAttributeValues: expressionAttributeValues,
+		ConditionExpression:       aws.String(condition),
+		ReturnValues:              types.ReturnValueAllNew,
+		// The old item tells changed tags apart from a deleted entity when the condition fails
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			if len(conditionErr.Item) > 0 {
+				return nil, errEntityChanged
+			}
+			return nil, fmt.Errorf("%w: %s", ErrEntityNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+
+	var entity models.CertificateEntity
+	if err := attributevalue.UnmarshalMap(result.Attributes, &entity); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal entity: %w", err)
+	}
+	if err := d.openSealedFields(ctx, &entity); err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// claimCondition lets a claim or release through when the entity is unclaimed, already held by
+// :holder or its claim has expired. Expiry times are written as second-precision UTC RFC3339, so
+// comparing them as strings orders them in time.
+const claimCondition = "attribute_exists(id) AND (attribute_not_exists(#claimed_by) OR #claimed_by = :holder OR #claim_expires_at < :now)"
+
+// ClaimEntity claims an entity for holder until ttl from now, so that concurrent renewers can agree
+// on which of them renews it. The claim is a single conditional write that succeeds only when the
+// entity is unclaimed, its claim has expired or holder already holds it, in which case the claim is
+// extended. A live claim of another holder is reported as a ClaimError. The updated entity is
+// returned without its private key being decrypted.
+func (d *DynamoDBStorage) ClaimEntity(ctx context.Context, id, holder string, ttl time.Duration) (*models.CertificateEntity, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	entity, err := d.updateClaim(ctx, id, holder, now, &dynamodb.UpdateItemInput{
+		UpdateExpression: aws.String("SET #claimed_by = :holder, #claim_expires_at = :expires_at"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":expires_at": &types.AttributeValueMemberS{Value: now.Add(ttl).Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.logger.WithFields(logrus.Fields{
+		"entity_id":  id,
+		"claimed_by": holder,
+		"expires_at": entity.ClaimExpiresAt,
+	}).Info("Certificate entity claimed")
+
+	return entity, nil
+}
+
+// ReleaseClaim clears holder's claim on an entity. Releasing an unclaimed entity or an expired
+// claim succeeds; a live claim of another holder is reported as a ClaimError. The updated entity
+// is returned without its private key being decrypted.
+func (d *DynamoDBStorage) ReleaseClaim(ctx context.Context, id, holder string) (*models.CertificateEntity, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	entity, err := d.updateClaim(ctx, id, holder, now, &dynamodb.UpdateItemInput{
+		UpdateExpression:          aws.String("REMOVE #claimed_by, #claim_expires_at"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.logger.WithFields(logrus.Fields{
+		"entity_id":   id,
+		"released_by": holder,
+	}).Info("Certificate entity claim released")
+
+	return entity, nil
+}
+
+// TouchCertificateEntity records that an entity is still in use by setting its updated_at and
+// last_seen_at to the current time. No other attribute is written. The time set is returned.
+func (d *DynamoDBStorage) TouchCertificateEntity(ctx context.Context, id string) (time.Time, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:    aws.String("SET #updated_at = :now, #last_seen_at = :now"),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ExpressionAttributeNames: map[string]string{
+			"#updated_at":   "updated_at",
+			"#last_seen_at": "last_seen_at",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberS{Value: now.Format(time.RFC3339)},
+		},
+	}
+
+	if _, err := d.client.UpdateItem(ctx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return time.Time{}, fmt.Errorf("%w: %s", ErrEntityNotFound, id)
+		}
+		return time.Time{}, fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+
+	return now, nil
+}
+
+// updateClaim applies a claim update on condition of claimCondition and decodes the updated entity
+func (d *DynamoDBStorage) updateClaim(ctx context.Context, id, holder string, now time.Time, input *dynamodb.UpdateItemInput) (*models.CertificateEntity, error) {
+	input.TableName = aws.String(d.tableName)
+	input.Key = map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: id},
+	}
+	input.ConditionExpression = aws.String(claimCondition)
+	input.ExpressionAttributeNames = map[string]string{
+		"#claimed_by":       "claimed_by",
+		"#claim_expires_at": "claim_expires_at",
+	}
+	input.ExpressionAttributeValues[":holder"] = &types.AttributeValueMemberS{Value: holder}
+	input.ExpressionAttributeValues[":now"] = &types.AttributeValueMemberS{Value: now.Format(time.RFC3339)}
+	input.ReturnValues = types.ReturnValueAllNew
+	// The old item tells a live claim apart from a missing entity when the condition fails
+	input.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+
+	result, err := d.client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			if len(conditionErr.Item) == 0 {
+				return nil, fmt.Errorf("%w: %s", ErrEntityNotFound, id)
+			}
+			var current models.CertificateEntity
+			if err := attributevalue.UnmarshalMap(conditionErr.Item, &current); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal entity: %w", err)
+			}
+			return nil, &ClaimError{ID: id, ClaimedBy: current.ClaimedBy, ExpiresAt: current.ClaimExpiresAt}
+		}
+		return nil, fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+
+	var entity models.CertificateEntity
+	if err := attributevalue.UnmarshalMap(result.Attributes, &entity); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal entity: %w", err)
+	}
+	if err := d.openSealedFields(ctx, &entity); err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// RekeyEntities re-encrypts stored private keys under the currently configured KMS key.
+// Entities already encrypted under that key are skipped, so the operation is idempotent and
+// can be resumed by running it again. At most limit entities are re-encrypted per call and KMS
+// calls are paced at the configured rate to avoid throttling. Plaintext keys never leave the service.
+func (d *DynamoDBStorage) RekeyEntities(ctx context.Context, limit int) (*models.RekeyResponse, error) {
+	// Resolve aliases so entities are compared against the key the alias currently points to
+	keyInfo, err := d.kmsClient.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(d.kmsKeyID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe KMS key: %w", err)
+	}
+	if keyInfo.KeyMetadata == nil || aws.ToString(keyInfo.KeyMetadata.Arn) == "" {
+		return nil, fmt.Errorf("KMS key %s has no ARN", d.kmsKeyID)
+	}
+	currentKeyARN := aws.ToString(keyInfo.KeyMetadata.Arn)
+
+	result := &models.RekeyResponse{KMSKeyID: currentKeyARN}
+
+	// Each re-encryption costs a Decrypt and an Encrypt call
+	ticker := time.NewTicker(time.Second / time.Duration(d.rekeyRate))
+	defer ticker.Stop()
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(d.tableName),
+	}
+
+	err = d.scanPages(ctx, input, func(page *dynamodb.ScanOutput) error {
+		for _, item := range page.Items {
+			result.Scanned++
+
+			var entity models.CertificateEntity
+			if err := attributevalue.UnmarshalMap(item, &entity); err != nil {
+				d.logger.WithError(err).Error("Failed to unmarshal certificate entity")
+				result.Failed++
+				result.Errors = append(result.Errors, models.EntityError{ID: itemID(item), Error: "failed to decode stored entity"})
+				continue
+			}
+
+			if !hasEncryptedData(&entity) || entity.KMSKeyID == currentKeyARN {
+				result.AlreadyCurrent++
+				continue
+			}
+			if result.Rekeyed+result.Failed >= limit {
+				result.Pending++
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+
+			if err := d.rekeyEntity(ctx, &entity); err != nil {
+				d.logger.WithError(err).WithField("entity_id", entity.ID).Error("Failed to re-encrypt private key")
+				result.Failed++
+				result.Errors = append(result.Errors, models.EntityError{ID: entity.ID, Error: rekeyFailureReason(err)})
+				continue
+			}
+			result.Rekeyed++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Complete = result.Pending == 0 && result.Failed == 0
+
+	d.logger.WithFields(logrus.Fields{
+		"kms_key_id":      currentKeyARN,
+		"scanned":         result.Scanned,
+		"rekeyed":         result.Rekeyed,
+		"already_current": result.AlreadyCurrent,
+		"pending":         result.Pending,
+		"failed":          result.Failed,
+	}).Info("Rekey run finished")
+
+	return result, nil
+}
+
+// rekeyEntity re-encrypts one entity's private key and any encrypted CSR or certificate. The
+// write only succeeds if the stored ciphertexts are still the ones that were decrypted, so
+// concurrent updates are never overwritten.
+func (d *DynamoDBStorage) rekeyEntity(ctx context.Context, entity *models.CertificateEntity) error {
+	var setExpressions, conditions []string
+	expressionAttributeNames := map[string]string{
+		"#kms_key_id": "kms_key_id",
+	}
+	expressionAttributeValues := map[string]types.AttributeValue{}
+
+	// Entities with an external private key only hold an encrypted CSR or certificate
+	var keyID string
+	if entity.EncryptedPrivateKey != "" {
+		// The key stays bound to the same tags, so the context tags are left as stored
+		encryptionContext := keyEncryptionContext(entity)
+		privateKey, err := d.decryptData(ctx, entity.EncryptedPrivateKey, entity.KMSKeyID, encryptionContext)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt private key: %w", err)
+		}
+
+		var encryptedPrivateKey string
+		encryptedPrivateKey, keyID, err = d.encryptData(ctx, privateKey, encryptionContext)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt private key: %w", err)
+		}
+
+		expressionAttributeNames["#encrypted_private_key"] = "encrypted_private_key"
+		expressionAttributeValues[":old_key"] = &types.AttributeValueMemberS{Value: entity.EncryptedPrivateKey}
+		expressionAttributeValues[":new_key"] = &types.AttributeValueMemberS{Value: encryptedPrivateKey}
+		setExpressions = append(setExpressions, "#encrypted_private_key = :new_key")
+		conditions = append(conditions, "#encrypted_private_key = :old_key")
+	}
+
+	sealedFields := []struct {
+		name  string
+		value string
+	}{
+		{"csr", entity.CSR},
+		{"certificate", entity.Certificate},
+	}
+	for _, field := range sealedFields {
+		if !strings.HasPrefix(field.value, sealedPrefix) {
+			continue
+		}
+		plaintext, err := d.openField(ctx, field.value, entity.KMSKeyID)
+		if err != nil {
+			return err
+		}
+		ciphertext, fieldKeyID, err := d.encryptData(ctx, plaintext, nil)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", field.name, err)
+		}
+		if keyID == "" {
+			keyID = fieldKeyID
+		}
+
+		expressionAttributeNames["#"+field.name] = field.name
+		expressionAttributeValues[":new_"+field.name] = &types.AttributeValueMemberS{Value: sealedPrefix + ciphertext}
+		expressionAttributeValues[":old_"+field.name] = &types.AttributeValueMemberS{Value: field.value}
+		setExpressions = append(setExpressions, fmt.Sprintf("#%s = :new_%s", field.name, field.name))
+		conditions = append(conditions, fmt.Sprintf("#%s = :old_%s", field.name, field.name))
+	}
+	setExpressions = append(setExpressions, "#kms_key_id = :kms_key_id")
+	expressionAttributeValues[":kms_key_id"] = &types.AttributeValueMemberS{Value: keyID}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: entity.ID},
+		},
+		UpdateExpression:          aws.String("SET " + strings.Join(setExpressions, ", ")),
+		ExpressionAttributeNames:  expressionAttributeNames,
+		ExpressionAttributeValues: expressionAttributeValues,
+		ConditionExpression:       aws.String(strings.Join(conditions, " AND ")),
+	}
+
+	if _, err := d.client.UpdateItem(ctx, input); err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return errEntityChanged
+		}
+		return fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+
+	return nil
+}
+
+// rekeyFailureReason describes why an entity could not be re-encrypted without exposing KMS messages
+func rekeyFailureReason(err error) string {
+	if errors.Is(err, errEntityChanged) {
+		return errEntityChanged.Error()
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("failed to re-encrypt private key: %s", apiErr.ErrorCode())
+	}
+	return "failed to re-encrypt private key"
+}
+
+// scanPages runs a Scan and follows LastEvaluatedKey until every page has been processed
+func (d *DynamoDBStorage) scanPages(ctx context.Context, input *dynamodb.ScanInput, fn func(page *dynamodb.ScanOutput) error) error {
+	for {
+		result, err := d.client.Scan(ctx, input)
+		if err != nil {
+			return fmt.Errorf("failed to scan DynamoDB table: %w", err)
+		}
+
+		if err := fn(result); err != nil {
+			return err
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// GetCertificateEntityCount returns the total count of entities matching the filters.
+// filters.Projection is ignored since a COUNT scan returns no attributes.
+func (d *DynamoDBStorage) GetCertificateEntityCount(ctx context.Context, filters models.SearchFilters) (int, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(d.tableName),
+		Select:    types.SelectCount, // Only count, don't return items
+	}
+
+	// Apply the same filters as in ListCertificateEntities
+	if filterExpression, names, values := searchFilterExpression(filters); filterExpression != "" {
+		input.FilterExpression = aws.String(filterExpression)
+		input.ExpressionAttributeNames = names
+		input.ExpressionAttributeValues = values
+	}
+
+	// Scan pages are capped at 1MB of evaluated data, so follow LastEvaluatedKey to count every match
+	var count, scannedCount int
+	err := d.scanPages(ctx, input, func(page *dynamodb.ScanOutput) error {
+		count += int(page.Count)
+		scannedCount += int(page.ScannedCount)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count items in DynamoDB table: %w", err)
+	}
+
+	d.logger.WithFields(logrus.Fields{
+		"count":         count,
+		"scanned_count": scannedCount,
+	}).Debug("Counted certificate entities")
+
+	return count, nil
+}
+
+// sortEntities sorts the entities slice in-place based on the specified field and order
+func (d *DynamoDBStorage) sortEntities(entities []models.CertificateEntity, sortBy, sortOrder string) {
+	if len(entities) <= 1 {
+		return
+	}
+
+	sort.SliceStable(entities, func(i, j int) bool {
+		return d.compareEntities(entities[j], entities[i], sortBy, sortOrder)
+	})
+}
+
+// compareEntities compares two entities based on the sort field and order
+// Returns true if entity i should come after entity j in the sorted order; entities equal on the
+// sort field are ordered by ID
 func (d *DynamoDBStorage) compareEntities(entityI, entityJ models.CertificateEntity, sortBy, sortOrder string) bool {
 	var comparison int
 
@@ -502,6 +1788,11 @@ func (d *DynamoDBStorage) compareEntities(entityI, entityJ models.CertificateEnt
 		}
 	}
 
+	// Break ties on the ID so pages are cut at the same place on every request
+	if comparison == 0 {
+		comparison = strings.Compare(entityI.ID, entityJ.ID)
+	}
+
 	// Apply sort order
 	if sortOrder == "desc" {
 		comparison = -comparison
@@ -510,51 +1801,313 @@ func (d *DynamoDBStorage) compareEntities(entityI, entityJ models.CertificateEnt
 	return comparison > 0
 }
 
-// DeleteCertificateEntity deletes a certificate entity by ID
-func (d *DynamoDBStorage) DeleteCertificateEntity(ctx context.Context, id string) error {
+// ErrInvalidCursor is returned for a list, event or history cursor that cannot be decoded or was
+// issued for another sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// listCursor marks the last entity of a page by its sort key and ID. Times are RFC3339Nano and
+// an unset valid_from or valid_to is empty.
+type listCursor struct {
+	SortBy    string `json:"s"`
+	SortOrder string `json:"o"`
+	Key       string `json:"k"`
+	ID        string `json:"id"`
+}
+
+// EncodeCursor returns the cursor for the list page following entity under the given sort
+func EncodeCursor(entity models.CertificateEntity, sortBy, sortOrder string) string {
+	cursor := listCursor{SortBy: sortBy, SortOrder: sortOrder, ID: entity.ID}
+	switch sortBy {
+	case "updated_at":
+		cursor.Key = entity.UpdatedAt.Format(time.RFC3339Nano)
+	case "common_name":
+		cursor.Key = entity.CommonName
+	case "status":
+		cursor.Key = string(entity.Status)
+	case "key_type":
+		cursor.Key = string(entity.KeyType)
+	case "valid_to":
+		if entity.ValidTo != nil {
+			cursor.Key = entity.ValidTo.Format(time.RFC3339Nano)
+		}
+	case "valid_from":
+		if entity.ValidFrom != nil {
+			cursor.Key = entity.ValidFrom.Format(time.RFC3339Nano)
+		}
+	default:
+		cursor.Key = entity.CreatedAt.Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns an entity carrying only the ID and sort key of a cursor issued for the same sort
+func decodeCursor(encoded, sortBy, sortOrder string) (models.CertificateEntity, error) {
+	var entity models.CertificateEntity
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return entity, ErrInvalidCursor
+	}
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return entity, ErrInvalidCursor
+	}
+	if cursor.SortBy != sortBy || cursor.SortOrder != sortOrder {
+		return entity, fmt.Errorf("%w: issued for sort_by=%s and sort_order=%s", ErrInvalidCursor, cursor.SortBy, cursor.SortOrder)
+	}
+
+	entity.ID = cursor.ID
+	parseTime := func() (time.Time, error) {
+		return time.Parse(time.RFC3339Nano, cursor.Key)
+	}
+	parseOptionalTime := func() (*time.Time, error) {
+		if cursor.Key == "" {
+			return nil, nil
+		}
+		t, err := parseTime()
+		return &t, err
+	}
+	switch sortBy {
+	case "updated_at":
+		entity.UpdatedAt, err = parseTime()
+	case "common_name":
+		entity.CommonName = cursor.Key
+	case "status":
+		entity.Status = models.CertificateStatus(cursor.Key)
+	case "key_type":
+		entity.KeyType = models.KeyType(cursor.Key)
+	case "valid_to":
+		entity.ValidTo, err = parseOptionalTime()
+	case "valid_from":
+		entity.ValidFrom, err = parseOptionalTime()
+	default:
+		entity.CreatedAt, err = parseTime()
+	}
+	if err != nil {
+		return entity, ErrInvalidCursor
+	}
+	return entity, nil
+}
+
+// DeleteCertificateEntity deletes a certificate entity by ID.
+// A protected entity is only deleted with force, otherwise ErrEntityProtected is returned.
+func (d *DynamoDBStorage) DeleteCertificateEntity(ctx context.Context, id string, force bool) error {
 	input := &dynamodb.DeleteItemInput{
 		TableName: aws.String(d.tableName),
 		Key: map[string]types.AttributeValue{
 			"id": &types.AttributeValueMemberS{Value: id},
 		},
 		ConditionExpression: aws.String("attribute_exists(id)"),
+		// The old item tells a protected entity apart from a missing one when the condition fails
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	if !force {
+		input.ConditionExpression = aws.String("attribute_exists(id) AND (" + notProtectedCondition + ")")
+		input.ExpressionAttributeNames = notProtectedNames()
+		input.ExpressionAttributeValues = notProtectedValues()
 	}
 
 	_, err := d.client.DeleteItem(ctx, input)
 	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			if len(conditionErr.Item) > 0 {
+				return fmt.Errorf("%w: %s", ErrEntityProtected, id)
+			}
+			return fmt.Errorf("%w: %s", ErrEntityNotFound, id)
+		}
 		return fmt.Errorf("failed to delete item from DynamoDB: %w", err)
 	}
 
-	d.logger.WithField("entity_id", id).Info("Certificate entity deleted successfully")
+	d.logger.WithFields(logrus.Fields{
+		"entity_id": id,
+		"force":     force,
+	}).Info("Certificate entity deleted successfully")
 	return nil
 }
 
-// encryptData encrypts data using AWS KMS
-func (d *DynamoDBStorage) encryptData(ctx context.Context, plaintext string) (string, error) {
+// maxTransactWriteItems is the most actions DynamoDB accepts in one TransactWriteItems call
+const maxTransactWriteItems = 100
+
+// maxTransactWriteAttempts bounds how often a cancelled transaction is retried
+const maxTransactWriteAttempts = 5
+
+// transactWriteBackoff is the delay before the first retry of a cancelled transaction; it doubles per attempt
+var transactWriteBackoff = 50 * time.Millisecond
+
+// FindDeletableEntities returns the IDs of entities matching the status, key type, creation date
+// and tag filters, split into those that may be deleted and those tagged protected=true. Both
+// lists are sorted. Only IDs and tags are read, so no private key is decrypted.
+func (d *DynamoDBStorage) FindDeletableEntities(ctx context.Context, filters models.SearchFilters) ([]string, []string, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(d.tableName),
+	}
+
+	filterExpression, expressionAttributeNames, expressionAttributeValues := searchFilterExpression(filters)
+	if filterExpression != "" {
+		input.FilterExpression = aws.String(filterExpression)
+		input.ExpressionAttributeValues = expressionAttributeValues
+	}
+	input.ProjectionExpression = aws.String(projectionExpression([]string{"id", "tags"}, expressionAttributeNames))
+	input.ExpressionAttributeNames = expressionAttributeNames
+
+	var deletable, protected []string
+	err := d.scanPages(ctx, input, func(page *dynamodb.ScanOutput) error {
+		for _, item := range page.Items {
+			id := itemID(item)
+			if id == "" {
+				continue
+			}
+			if isProtectedItem(item) {
+				protected = append(protected, id)
+			} else {
+				deletable = append(deletable, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(deletable)
+	sort.Strings(protected)
+	return deletable, protected, nil
+}
+
+// isProtectedItem reports whether a raw DynamoDB item is tagged protected=true
+func isProtectedItem(item map[string]types.AttributeValue) bool {
+	tags, ok := item["tags"].(*types.AttributeValueMemberM)
+	if !ok {
+		return false
+	}
+	protected, ok := tags.Value[models.ProtectedTag].(*types.AttributeValueMemberS)
+	return ok && protected.Value == "true"
+}
+
+// DeleteCertificateEntities deletes entities in transactions of up to 100. Each delete has the same
+// condition as DeleteCertificateEntity without force, so an entity tagged protected, or deleted, after
+// it was selected is kept and the rest of its transaction retried without it. Transactions cancelled
+// for other reasons, such as a conflicting write, are retried with exponential backoff. It returns the
+// IDs of the entities deleted, including when an error stops it part way.
+func (d *DynamoDBStorage) DeleteCertificateEntities(ctx context.Context, ids []string) ([]string, error) {
+	var deleted []string
+	for start := 0; start < len(ids); start += maxTransactWriteItems {
+		batch := ids[start:min(start+maxTransactWriteItems, len(ids))]
+
+		for attempt := 0; len(batch) > 0; attempt++ {
+			if attempt > 0 {
+				if attempt == maxTransactWriteAttempts {
+					return deleted, fmt.Errorf("failed to delete %d items from DynamoDB after %d attempts", len(batch), attempt)
+				}
+				select {
+				case <-ctx.Done():
+					return deleted, ctx.Err()
+				case <-time.After(transactWriteBackoff << (attempt - 1)):
+				}
+			}
+
+			items := make([]types.TransactWriteItem, 0, len(batch))
+			for _, id := range batch {
+				items = append(items, types.TransactWriteItem{
+					Delete: &types.Delete{
+						TableName: aws.String(d.tableName),
+						Key: map[string]types.AttributeValue{
+							"id": &types.AttributeValueMemberS{Value: id},
+						},
+						ConditionExpression:       aws.String("attribute_exists(id) AND (" + notProtectedCondition + ")"),
+						ExpressionAttributeNames:  notProtectedNames(),
+						ExpressionAttributeValues: notProtectedValues(),
+					},
+				})
+			}
+
+			_, err := d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+			if err == nil {
+				deleted = append(deleted, batch...)
+				break
+			}
+			var canceled *types.TransactionCanceledException
+			if !errors.As(err, &canceled) {
+				return deleted, fmt.Errorf("failed to delete items from DynamoDB: %w", err)
+			}
+
+			// Reasons are listed in the order of the transaction's items
+			var remaining []string
+			for i, id := range batch {
+				if i < len(canceled.CancellationReasons) && aws.ToString(canceled.CancellationReasons[i].Code) == "ConditionalCheckFailed" {
+					d.logger.WithField("entity_id", id).Warn("Certificate entity was protected or deleted since it was selected; not deleting it")
+					continue
+				}
+				remaining = append(remaining, id)
+			}
+			batch = remaining
+		}
+	}
+
+	d.logger.WithField("deleted_count", len(deleted)).Info("Certificate entities deleted in bulk")
+	return deleted, nil
+}
+
+// notProtectedCondition matches items without the protected tag set to "true"
+const notProtectedCondition = "attribute_not_exists(#tags.#protected) OR #tags.#protected <> :protected"
+
+func notProtectedNames() map[string]string {
+	return map[string]string{
+		"#tags":      "tags",
+		"#protected": models.ProtectedTag,
+	}
+}
+
+func notProtectedValues() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		":protected": &types.AttributeValueMemberS{Value: "true"},
+	}
+}
+
+// encryptData encrypts data using AWS KMS and returns the ciphertext with the ARN of the key used.
+// A non-nil encryptionContext must be passed unchanged to decryptData to decrypt the ciphertext.
+func (d *DynamoDBStorage) encryptData(ctx context.Context, plaintext string, encryptionContext map[string]string) (string, string, error) {
 	if plaintext == "" {
-		return "", nil
+		return "", "", nil
 	}
 
 	input := &kms.EncryptInput{
-		KeyId:     aws.String(d.kmsKeyID),
-		Plaintext: []byte(plaintext),
+		KeyId:             aws.String(d.kmsKeyID),
+		Plaintext:         []byte(plaintext),
+		EncryptionContext: encryptionContext,
 	}
 
+	start := time.Now()
 	result, err := d.kmsClient.Encrypt(ctx, input)
+	metrics.KMSLatency("Encrypt", time.Since(start), err)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	// Encode the encrypted data as base64
-	return fmt.Sprintf("%x", result.CiphertextBlob), nil
+	// Encode the encrypted data as hex
+	return fmt.Sprintf("%x", result.CiphertextBlob), aws.ToString(result.KeyId), nil
 }
 
-// decryptData decrypts data using AWS KMS
-func (d *DynamoDBStorage) decryptData(ctx context.Context, encryptedData string) (string, error) {
+// decryptData decrypts data using AWS KMS. keyID is the key recorded on the entity; records
+// written before key IDs were stored fall back to the configured key. Should KMS reject that
+// key, for example for a sealed field that is still under an older key, the decrypt is retried
+// without a key ID so KMS selects the key from the ciphertext. encryptionContext must match the
+// one the data was encrypted with. Within a context from WithDecryptCache each ciphertext is sent
+// to KMS only once per key ID and encryption context.
+func (d *DynamoDBStorage) decryptData(ctx context.Context, encryptedData, keyID string, encryptionContext map[string]string) (string, error) {
 	if encryptedData == "" {
 		return "", nil
 	}
 
+	if keyID == "" {
+		keyID = d.kmsKeyID
+	}
+	cache := decryptCacheFrom(ctx)
+	if plaintext, ok := cache.get(keyID, encryptionContext, encryptedData); ok {
+		return plaintext, nil
+	}
+
 	// Decode from hex
 	ciphertext := make([]byte, len(encryptedData)/2)
 	_, err := fmt.Sscanf(encryptedData, "%x", &ciphertext)
@@ -563,43 +2116,204 @@ func (d *DynamoDBStorage) decryptData(ctx context.Context, encryptedData string)
 	}
 
 	input := &kms.DecryptInput{
-		CiphertextBlob: ciphertext,
+		CiphertextBlob:    ciphertext,
+		KeyId:             aws.String(keyID),
+		EncryptionContext: encryptionContext,
 	}
 
+	start := time.Now()
 	result, err := d.kmsClient.Decrypt(ctx, input)
+	metrics.KMSLatency("Decrypt", time.Since(start), err)
+	var incorrectKey *kmstypes.IncorrectKeyException
+	if errors.As(err, &incorrectKey) {
+		d.logger.WithField("kms_key_id", keyID).Debug("Ciphertext is not under the recorded KMS key, letting KMS select the key")
+		input.KeyId = nil
+		start = time.Now()
+		result, err = d.kmsClient.Decrypt(ctx, input)
+		metrics.KMSLatency("Decrypt", time.Since(start), err)
+	}
 	if err != nil {
 		return "", err
 	}
 
-	return string(result.Plaintext), nil
+	plaintext := string(result.Plaintext)
+	cache.put(keyID, encryptionContext, encryptedData, plaintext)
+	return plaintext, nil
+}
+
+// contextTagPrefix namespaces bound tags in a KMS encryption context
+const contextTagPrefix = "tag:"
+
+// boundContextTags returns the configured context tags that tags sets, in configuration order.
+// Tags the entity does not have are left out so they can still be added later.
+func (d *DynamoDBStorage) boundContextTags(tags map[string]string) []string {
+	var bound []string
+	for _, name := range d.contextTags {
+		if _, ok := tags[name]; ok {
+			bound = append(bound, name)
+		}
+	}
+	return bound
+}
+
+// keyEncryptionContext builds the KMS encryption context of an entity's private key from the
+// current values of its bound tags. It is nil when no tags are bound, as for keys written before
+// KMS_CONTEXT_TAGS was set, so those keys keep decrypting.
+func keyEncryptionContext(entity *models.CertificateEntity) map[string]string {
+	if len(entity.KMSContextTags) == 0 {
+		return nil
+	}
+	encryptionContext := make(map[string]string, len(entity.KMSContextTags))
+	for _, name := range entity.KMSContextTags {
+		encryptionContext[contextTagPrefix+name] = entity.Tags[name]
+	}
+	return encryptionContext
+}
+
+// changedContextTag returns ErrContextTagBound when tags, the tags an entity is about to be given,
+// would change or drop the value of a tag bound into the entity's encryption context
+func changedContextTag(entity *models.CertificateEntity, tags map[string]string) error {
+	for _, name := range entity.KMSContextTags {
+		current, had := entity.Tags[name]
+		if value, has := tags[name]; has != had || value != current {
+			return fmt.Errorf("%w: %s", ErrContextTagBound, name)
+		}
+	}
+	return nil
+}
+
+// sealedPrefix marks a CSR or certificate stored KMS-encrypted; a PEM value never starts with it
+const sealedPrefix = "kms:"
+
+// hasEncryptedData reports whether an entity holds a KMS-encrypted private key, CSR or certificate
+func hasEncryptedData(entity *models.CertificateEntity) bool {
+	return entity.EncryptedPrivateKey != "" ||
+		strings.HasPrefix(entity.CSR, sealedPrefix) ||
+		strings.HasPrefix(entity.Certificate, sealedPrefix)
+}
+
+// sealField KMS-encrypts a CSR or certificate for storage when ENCRYPT_ALL_SENSITIVE is enabled.
+// Otherwise, and for empty or already encrypted values, the value is returned unchanged.
+func (d *DynamoDBStorage) sealField(ctx context.Context, value string) (string, error) {
+	if !d.encryptAllSensitive || value == "" || strings.HasPrefix(value, sealedPrefix) {
+		return value, nil
+	}
+	ciphertext, _, err := d.encryptData(ctx, value, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt sensitive field: %w", err)
+	}
+	return sealedPrefix + ciphertext, nil
+}
+
+// openField decrypts a value sealed by sealField under the entity's recorded keyID. Plaintext
+// values, such as those written before ENCRYPT_ALL_SENSITIVE was enabled, are returned unchanged.
+func (d *DynamoDBStorage) openField(ctx context.Context, value, keyID string) (string, error) {
+	ciphertext, sealed := strings.CutPrefix(value, sealedPrefix)
+	if !sealed {
+		return value, nil
+	}
+	plaintext, err := d.decryptData(ctx, ciphertext, keyID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt sensitive field: %w", err)
+	}
+	return plaintext, nil
+}
+
+// sealFields encrypts an entity's CSR and certificate in place for storage. An entity without a
+// private key takes its KMS key ID from the sealed fields, so it is included in rekey runs.
+func (d *DynamoDBStorage) sealFields(ctx context.Context, entity *models.CertificateEntity) error {
+	if !d.encryptAllSensitive {
+		return nil
+	}
+	for _, field := range []*string{&entity.CSR, &entity.Certificate} {
+		if *field == "" || strings.HasPrefix(*field, sealedPrefix) {
+			continue
+		}
+		ciphertext, keyID, err := d.encryptData(ctx, *field, nil)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt sensitive field: %w", err)
+		}
+		*field = sealedPrefix + ciphertext
+		if entity.KMSKeyID == "" {
+			entity.KMSKeyID = keyID
+		}
+	}
+	return nil
+}
+
+// openSealedFields decrypts an entity's sealed CSR and certificate in place
+func (d *DynamoDBStorage) openSealedFields(ctx context.Context, entity *models.CertificateEntity) error {
+	for _, field := range []*string{&entity.CSR, &entity.Certificate} {
+		plaintext, err := d.openField(ctx, *field, entity.KMSKeyID)
+		if err != nil {
+			return err
+		}
+		*field = plaintext
+	}
+	return nil
 }
 
-// CheckDynamoDBHealth verifies DynamoDB table accessibility
+// CheckDynamoDBHealth verifies the configured table exists and is ACTIVE. A table that is
+// still being created or updated, or is being deleted, is reported as unhealthy.
 func (d *DynamoDBStorage) CheckDynamoDBHealth(ctx context.Context) error {
-	// Try to describe the table to verify access
+	_, err := d.DynamoDBTableStatus(ctx)
+	return err
+}
+
+// DynamoDBTableStatus returns the status of the configured table, such as ACTIVE. It fails like
+// CheckDynamoDBHealth, but still returns the status when the table exists and is not ACTIVE.
+func (d *DynamoDBStorage) DynamoDBTableStatus(ctx context.Context) (string, error) {
 	input := &dynamodb.DescribeTableInput{
 		TableName: aws.String(d.tableName),
 	}
 
-	_, err := d.client.DescribeTable(ctx, input)
+	result, err := d.client.DescribeTable(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to describe DynamoDB table: %w", err)
+		return "", fmt.Errorf("failed to describe DynamoDB table %s: %w", d.tableName, err)
+	}
+	if result.Table == nil {
+		return "", fmt.Errorf("DynamoDB table %s was not described", d.tableName)
+	}
+	if result.Table.TableStatus != types.TableStatusActive {
+		return string(result.Table.TableStatus), fmt.Errorf("DynamoDB table %s is %s, not ACTIVE", d.tableName, result.Table.TableStatus)
 	}
 
-	return nil
+	return string(result.Table.TableStatus), nil
 }
 
-// CheckKMSHealth verifies KMS key accessibility
+// CheckKMSHealth verifies the configured KMS key exists and is enabled, without encrypting anything
 func (d *DynamoDBStorage) CheckKMSHealth(ctx context.Context) error {
-	// Try to describe the key to verify access
+	_, err := d.KMSKeyState(ctx)
+	return err
+}
+
+// KMSKeyState returns the state of the configured KMS key, such as Enabled. It fails like
+// CheckKMSHealth, but still returns the state when the key exists and is not enabled.
+func (d *DynamoDBStorage) KMSKeyState(ctx context.Context) (string, error) {
 	input := &kms.DescribeKeyInput{
 		KeyId: aws.String(d.kmsKeyID),
 	}
 
-	_, err := d.kmsClient.DescribeKey(ctx, input)
+	result, err := d.kmsClient.DescribeKey(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to describe KMS key: %w", err)
+		return "", fmt.Errorf("failed to describe KMS key %s: %w", d.kmsKeyID, err)
+	}
+	if result.KeyMetadata == nil {
+		return "", fmt.Errorf("KMS key %s was not described", d.kmsKeyID)
+	}
+	if result.KeyMetadata.KeyState != kmstypes.KeyStateEnabled {
+		return string(result.KeyMetadata.KeyState), fmt.Errorf("KMS key %s is %s, not Enabled", d.kmsKeyID, result.KeyMetadata.KeyState)
 	}
 
-	return nil
+	return string(result.KeyMetadata.KeyState), nil
+}
+
+// TableName returns the name of the configured entity table
+func (d *DynamoDBStorage) TableName() string {
+	return d.tableName
+}
+
+// KMSKeyID returns the configured KMS key ID, alias or ARN
+func (d *DynamoDBStorage) KMSKeyID() string {
+	return d.kmsKeyID
 }
@@ -2,13 +2,28 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"certificate-monkey/internal/config"
 	"certificate-monkey/internal/models"
+	"certificate-monkey/internal/storage/storagetest"
 )
 
 // TestNewDynamoDBStorage tests the constructor
@@ -138,6 +153,38 @@ func TestCompareEntitiesDescendingOrder(t *testing.T) {
 	assert.True(t, result, "Descending order should flip comparison result")
 }
 
+// TestSortEntitiesTiebreaker tests entities sharing the sort value are ordered by ID, whatever their input order
+func TestSortEntitiesTiebreaker(t *testing.T) {
+	storage := &DynamoDBStorage{}
+
+	var entities []models.CertificateEntity
+	for i := 0; i < 50; i++ {
+		status := models.StatusCSRCreated
+		if i%5 == 0 {
+			status = models.StatusCertUploaded
+		}
+		entities = append(entities, models.CertificateEntity{ID: fmt.Sprintf("entity-%02d", (i*37)%50), Status: status})
+	}
+
+	reversed := make([]models.CertificateEntity, len(entities))
+	for i := range entities {
+		reversed[len(entities)-1-i] = entities[i]
+	}
+
+	for _, order := range []string{"asc", "desc"} {
+		first := append([]models.CertificateEntity(nil), entities...)
+		second := append([]models.CertificateEntity(nil), reversed...)
+		storage.sortEntities(first, "status", order)
+		storage.sortEntities(second, "status", order)
+
+		assert.Equal(t, first, second, "Sorting by %s must give the same total order for any input order", order)
+		for i := 1; i < len(first); i++ {
+			assert.True(t, storage.compareEntities(first[i], first[i-1], "status", order),
+				"Entities must be strictly ordered, %s before %s", first[i-1].ID, first[i].ID)
+		}
+	}
+}
+
 // TestHealthCheckMethodSignatures verifies the health check methods have correct signatures
 func TestHealthCheckMethodSignatures(t *testing.T) {
 	logger := logrus.New()
@@ -163,3 +210,2151 @@ func TestHealthCheckMethodSignatures(t *testing.T) {
 	assert.NotNil(t, dynamoHealthCheck)
 	assert.NotNil(t, kmsHealthCheck)
 }
+
+// TestCheckDynamoDBHealth tests the table must exist and be ACTIVE to be healthy
+func TestCheckDynamoDBHealth(t *testing.T) {
+	var status types.TableStatus
+	client := &storagetest.DynamoDBClient{
+		DescribeTableFn: func(ctx context.Context, params *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+			assert.Equal(t, "test-table", aws.ToString(params.TableName))
+			return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: status}}, nil
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	status = types.TableStatusActive
+	assert.NoError(t, storage.CheckDynamoDBHealth(context.Background()))
+
+	status = types.TableStatusCreating
+	err := storage.CheckDynamoDBHealth(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "test-table is CREATING, not ACTIVE")
+
+	tableStatus, err := storage.DynamoDBTableStatus(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "CREATING", tableStatus, "The status is reported even when it is not healthy")
+
+	client.DescribeTableFn = func(ctx context.Context, params *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found")}
+	}
+	err = storage.CheckDynamoDBHealth(context.Background())
+	require.Error(t, err)
+	var notFound *types.ResourceNotFoundException
+	assert.ErrorAs(t, err, &notFound)
+}
+
+// TestCheckKMSHealth tests the key must exist and be enabled to be healthy
+func TestCheckKMSHealth(t *testing.T) {
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(&storagetest.DynamoDBClient{}, kmsClient)
+
+	assert.NoError(t, storage.CheckKMSHealth(context.Background()))
+	assert.Zero(t, kmsClient.EncryptCalls, "The health check must not encrypt anything")
+
+	kmsClient.DescribeKeyFn = func(ctx context.Context, params *kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error) {
+		return &kms.DescribeKeyOutput{KeyMetadata: &kmstypes.KeyMetadata{KeyId: params.KeyId, KeyState: kmstypes.KeyStatePendingDeletion}}, nil
+	}
+	err := storage.CheckKMSHealth(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is PendingDeletion, not Enabled")
+
+	keyState, err := storage.KMSKeyState(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "PendingDeletion", keyState, "The state is reported even when it is not healthy")
+
+	kmsClient.DescribeKeyFn = func(ctx context.Context, params *kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error) {
+		return nil, &kmstypes.NotFoundException{Message: aws.String("Key does not exist")}
+	}
+	err = storage.CheckKMSHealth(context.Background())
+	require.Error(t, err)
+	var notFound *kmstypes.NotFoundException
+	assert.ErrorAs(t, err, &notFound)
+}
+
+// TestCreateCertificateEntityRetriesGeneratedIDCollision tests that a colliding generated ID is replaced once
+func TestCreateCertificateEntityRetriesGeneratedIDCollision(t *testing.T) {
+	calls := 0
+	client := &storagetest.DynamoDBClient{
+		PutItemFn: func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			calls++
+			if calls == 1 {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+			}
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	entity := &models.CertificateEntity{
+		CommonName:          "example.com",
+		KeyType:             models.KeyTypeRSA2048,
+		EncryptedPrivateKey: "private-key-pem",
+	}
+
+	err := storage.CreateCertificateEntity(context.Background(), entity)
+	require.NoError(t, err)
+	require.Len(t, client.PutItemInputs, 2)
+
+	firstID := client.PutItemInputs[0].Item["id"].(*types.AttributeValueMemberS).Value
+	secondID := client.PutItemInputs[1].Item["id"].(*types.AttributeValueMemberS).Value
+	assert.NotEqual(t, firstID, secondID, "Retry should use a freshly generated ID")
+	assert.Equal(t, secondID, entity.ID, "Entity should carry the ID that was stored")
+
+	// The private key must only ever be written encrypted
+	storedKey := client.PutItemInputs[1].Item["encrypted_private_key"].(*types.AttributeValueMemberS).Value
+	assert.NotEqual(t, "private-key-pem", storedKey)
+}
+
+// TestCreateCertificateEntityClientIDConflict tests that supplied IDs are never silently replaced
+func TestCreateCertificateEntityClientIDConflict(t *testing.T) {
+	client := &storagetest.DynamoDBClient{
+		PutItemFn: func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	entity := &models.CertificateEntity{
+		ID:         "client-supplied-id",
+		CommonName: "example.com",
+	}
+
+	err := storage.CreateCertificateEntity(context.Background(), entity)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrEntityExists)
+	assert.Len(t, client.PutItemInputs, 1, "Client supplied IDs must not be retried")
+	assert.Equal(t, "client-supplied-id", entity.ID)
+}
+
+// TestCreateCertificateEntityOtherErrors tests that unrelated DynamoDB errors are not treated as conflicts
+func TestCreateCertificateEntityOtherErrors(t *testing.T) {
+	client := &storagetest.DynamoDBClient{
+		PutItemFn: func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			return nil, errors.New("throttled")
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	err := storage.CreateCertificateEntity(context.Background(), &models.CertificateEntity{CommonName: "example.com"})
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrEntityExists)
+	assert.Len(t, client.PutItemInputs, 1)
+}
+
+// TestForEachEncryptedEntity tests that every scan page is visited and keys are never decrypted
+func TestForEachEncryptedEntity(t *testing.T) {
+	pages := []*dynamodb.ScanOutput{
+		{
+			Items: []map[string]types.AttributeValue{
+				{"id": &types.AttributeValueMemberS{Value: "entity-1"}, "encrypted_private_key": &types.AttributeValueMemberS{Value: "test-key|key-1"}},
+				{"id": &types.AttributeValueMemberS{Value: "entity-2"}, "encrypted_private_key": &types.AttributeValueMemberS{Value: "test-key|key-2"}},
+			},
+			LastEvaluatedKey: map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "entity-2"}},
+		},
+		{
+			Items: []map[string]types.AttributeValue{
+				{"id": &types.AttributeValueMemberS{Value: "entity-3"}, "encrypted_private_key": &types.AttributeValueMemberS{Value: "test-key|key-3"}},
+			},
+		},
+	}
+
+	var scanCalls int
+	client := &storagetest.DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			if scanCalls > 0 {
+				require.NotNil(t, params.ExclusiveStartKey, "Follow-up scans must continue from the last key")
+			}
+			page := pages[scanCalls]
+			scanCalls++
+			return page, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	var visited []string
+	err := storage.ForEachEncryptedEntity(context.Background(), func(entity *models.CertificateEntity) error {
+		visited = append(visited, entity.ID)
+		assert.Equal(t, "test-key|"+"key-"+entity.ID[len("entity-"):], entity.EncryptedPrivateKey)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"entity-1", "entity-2", "entity-3"}, visited)
+	assert.Equal(t, 2, scanCalls)
+	assert.Equal(t, 0, kmsClient.DecryptCalls, "Export must never decrypt private keys")
+
+	// Callback errors stop the walk
+	scanCalls = 0
+	stop := errors.New("stop")
+	err = storage.ForEachEncryptedEntity(context.Background(), func(entity *models.CertificateEntity) error {
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, scanCalls)
+}
+
+// TestRestoreCertificateEntity tests that restored keys are re-encrypted with the current KMS key
+func TestRestoreCertificateEntity(t *testing.T) {
+	// Ciphertext produced under a key that has since been rotated out
+	oldCiphertext := fmt.Sprintf("%x", "old-key|private-key-pem")
+
+	t.Run("new entity", func(t *testing.T) {
+		client := &storagetest.DynamoDBClient{}
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		entity := &models.CertificateEntity{ID: "restored-id", CommonName: "example.com", EncryptedPrivateKey: oldCiphertext}
+		replaced, err := storage.RestoreCertificateEntity(context.Background(), entity, false, false)
+		require.NoError(t, err)
+		assert.False(t, replaced)
+
+		require.Len(t, client.PutItemInputs, 1)
+		input := client.PutItemInputs[0]
+		assert.Equal(t, "attribute_not_exists(id)", aws.ToString(input.ConditionExpression))
+		assert.Equal(t, "restored-id", input.Item["id"].(*types.AttributeValueMemberS).Value)
+		storedKey := input.Item["encrypted_private_key"].(*types.AttributeValueMemberS).Value
+		assert.Equal(t, fmt.Sprintf("%x", "test-key|private-key-pem"), storedKey)
+		assert.Equal(t, oldCiphertext, entity.EncryptedPrivateKey, "The caller's entity must not be modified")
+	})
+
+	t.Run("existing entity without overwrite", func(t *testing.T) {
+		client := &storagetest.DynamoDBClient{
+			PutItemFn: func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+			},
+		}
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		_, err := storage.RestoreCertificateEntity(context.Background(), &models.CertificateEntity{ID: "restored-id", EncryptedPrivateKey: oldCiphertext}, false, false)
+		assert.ErrorIs(t, err, ErrEntityExists)
+	})
+
+	t.Run("existing entity with overwrite", func(t *testing.T) {
+		client := &storagetest.DynamoDBClient{
+			PutItemFn: func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+				return &dynamodb.PutItemOutput{Attributes: params.Item}, nil
+			},
+		}
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		replaced, err := storage.RestoreCertificateEntity(context.Background(), &models.CertificateEntity{ID: "restored-id", EncryptedPrivateKey: oldCiphertext}, true, false)
+		require.NoError(t, err)
+		assert.True(t, replaced)
+		assert.Contains(t, aws.ToString(client.PutItemInputs[0].ConditionExpression), "#tags.#protected <> :protected")
+	})
+
+	t.Run("protected entity with overwrite", func(t *testing.T) {
+		client := &storagetest.DynamoDBClient{
+			PutItemFn: func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+			},
+		}
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		_, err := storage.RestoreCertificateEntity(context.Background(), &models.CertificateEntity{ID: "restored-id", EncryptedPrivateKey: oldCiphertext}, true, false)
+		assert.ErrorIs(t, err, ErrEntityProtected)
+	})
+
+	t.Run("protected entity with force", func(t *testing.T) {
+		client := &storagetest.DynamoDBClient{
+			PutItemFn: func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+				return &dynamodb.PutItemOutput{Attributes: params.Item}, nil
+			},
+		}
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		replaced, err := storage.RestoreCertificateEntity(context.Background(), &models.CertificateEntity{ID: "restored-id", EncryptedPrivateKey: oldCiphertext}, true, true)
+		require.NoError(t, err)
+		assert.True(t, replaced)
+		assert.Nil(t, client.PutItemInputs[0].ConditionExpression)
+	})
+
+	t.Run("undecryptable key", func(t *testing.T) {
+		client := &storagetest.DynamoDBClient{}
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		_, err := storage.RestoreCertificateEntity(context.Background(), &models.CertificateEntity{ID: "restored-id", EncryptedPrivateKey: fmt.Sprintf("%x", "garbage")}, false, false)
+		require.Error(t, err)
+		assert.Empty(t, client.PutItemInputs)
+	})
+}
+
+// TestListCertificateEntitiesReportsDecryptFailures tests that undecryptable entities are reported instead of silently dropped
+func TestListCertificateEntitiesReportsDecryptFailures(t *testing.T) {
+	item := func(id, ciphertext string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"id":                    &types.AttributeValueMemberS{Value: id},
+			"common_name":           &types.AttributeValueMemberS{Value: id + ".example.com"},
+			"encrypted_private_key": &types.AttributeValueMemberS{Value: fmt.Sprintf("%x", ciphertext)},
+		}
+	}
+
+	client := &storagetest.DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			return &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{
+				item("ok-1", "test-key|key-1"),
+				item("rotated", "retired-key|key-2"),
+				item("ok-2", "test-key|key-3"),
+				item("denied", "locked-key|key-4"),
+				{"id": &types.AttributeValueMemberS{Value: "corrupt"}, "tags": &types.AttributeValueMemberN{Value: "1"}},
+			}}, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{
+		DecryptFn: func(ctx context.Context, params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+			keyID, plaintext, _ := strings.Cut(string(params.CiphertextBlob), "|")
+			switch keyID {
+			case "retired-key":
+				return nil, &kmstypes.IncorrectKeyException{Message: aws.String("arn:aws:kms:eu-central-1:123456789012:key/retired")}
+			case "locked-key":
+				return nil, errors.New("connection reset")
+			}
+			return &kms.DecryptOutput{Plaintext: []byte(plaintext), KeyId: aws.String(keyID)}, nil
+		},
+	}
+	storage := newMockStorage(client, kmsClient)
+
+	entities, entityErrors, err := storage.ListCertificateEntities(context.Background(), models.SearchFilters{SortBy: "common_name", SortOrder: "asc"})
+	require.NoError(t, err)
+
+	require.Len(t, entities, 2, "Decryptable entities must still be returned")
+	assert.Equal(t, "ok-1", entities[0].ID)
+	assert.Equal(t, "key-1", entities[0].EncryptedPrivateKey)
+	assert.Equal(t, "ok-2", entities[1].ID)
+
+	assert.Equal(t, []models.EntityError{
+		{ID: "rotated", Error: "failed to decrypt private key: IncorrectKeyException"},
+		{ID: "denied", Error: "failed to decrypt private key"},
+		{ID: "corrupt", Error: "failed to decode stored entity"},
+	}, entityErrors)
+	for _, entityErr := range entityErrors {
+		assert.NotContains(t, entityErr.Error, "arn:aws:kms", "Key ARNs must not leak into responses")
+	}
+}
+
+// TestListCertificateEntitiesHasCertificate tests that the has_certificate filter separates
+// CSR-only entities from those with a certificate and combines with the age filter
+func TestListCertificateEntitiesHasCertificate(t *testing.T) {
+	item := func(id string, withCertificate bool) map[string]types.AttributeValue {
+		item := map[string]types.AttributeValue{
+			"id":         &types.AttributeValueMemberS{Value: id},
+			"created_at": &types.AttributeValueMemberS{Value: "2024-01-01T00:00:00Z"},
+			"csr":        &types.AttributeValueMemberS{Value: "csr"},
+		}
+		if withCertificate {
+			item["certificate"] = &types.AttributeValueMemberS{Value: "certificate"}
+		}
+		return item
+	}
+	items := []map[string]types.AttributeValue{item("csr-only", false), item("issued", true)}
+
+	var inputs []*dynamodb.ScanInput
+	client := &storagetest.DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			inputs = append(inputs, params)
+			expression := aws.ToString(params.FilterExpression)
+			var page []map[string]types.AttributeValue
+			for _, item := range items {
+				_, hasCertificate := item["certificate"]
+				if strings.Contains(expression, "attribute_not_exists(#certificate)") && hasCertificate {
+					continue
+				}
+				if strings.Contains(expression, "attribute_exists(#certificate)") && !hasCertificate {
+					continue
+				}
+				page = append(page, item)
+			}
+			return &dynamodb.ScanOutput{Items: page}, nil
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	for _, tt := range []struct {
+		hasCertificate bool
+		want           string
+		expression     string
+	}{
+		{false, "csr-only", "attribute_not_exists(#certificate)"},
+		{true, "issued", "attribute_exists(#certificate)"},
+	} {
+		t.Run(tt.want, func(t *testing.T) {
+			inputs = nil
+			entities, _, err := storage.ListCertificateEntities(context.Background(), models.SearchFilters{HasCertificate: &tt.hasCertificate})
+			require.NoError(t, err)
+			require.Len(t, entities, 1)
+			assert.Equal(t, tt.want, entities[0].ID)
+
+			require.Len(t, inputs, 1)
+			assert.Equal(t, tt.expression, aws.ToString(inputs[0].FilterExpression))
+			assert.Equal(t, "certificate", inputs[0].ExpressionAttributeNames["#certificate"])
+			assert.Nil(t, inputs[0].ExpressionAttributeValues, "DynamoDB rejects empty expression attribute values")
+		})
+	}
+
+	t.Run("combined with age filter", func(t *testing.T) {
+		inputs = nil
+		hasCertificate := false
+		dateTo := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		_, _, err := storage.ListCertificateEntities(context.Background(), models.SearchFilters{HasCertificate: &hasCertificate, DateTo: &dateTo})
+		require.NoError(t, err)
+
+		require.Len(t, inputs, 1)
+		assert.Equal(t, "#created_at <= :date_to AND attribute_not_exists(#certificate)", aws.ToString(inputs[0].FilterExpression))
+		assert.Contains(t, inputs[0].ExpressionAttributeValues, ":date_to")
+	})
+}
+
+// newRekeyTable returns a mock client backed by an in-memory table supporting
+// the scan, conditional update and get operations used by a rekey
+func newRekeyTable(items map[string]map[string]types.AttributeValue) *storagetest.DynamoDBClient {
+	return &storagetest.DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			var page []map[string]types.AttributeValue
+			for _, id := range []string{"a", "b", "c", "d"} {
+				if item, ok := items[id]; ok {
+					page = append(page, item)
+				}
+			}
+			return &dynamodb.ScanOutput{Items: page}, nil
+		},
+		UpdateItemFn: func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			id := params.Key["id"].(*types.AttributeValueMemberS).Value
+			item := items[id]
+			fields := map[string]string{"encrypted_private_key": "key", "csr": "csr", "certificate": "certificate"}
+			for attribute, placeholder := range fields {
+				old, ok := params.ExpressionAttributeValues[":old_"+placeholder]
+				if !ok {
+					continue
+				}
+				if item[attribute].(*types.AttributeValueMemberS).Value != old.(*types.AttributeValueMemberS).Value {
+					return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+				}
+			}
+			for attribute, placeholder := range fields {
+				if value, ok := params.ExpressionAttributeValues[":new_"+placeholder]; ok {
+					item[attribute] = value
+				}
+			}
+			item["kms_key_id"] = params.ExpressionAttributeValues[":kms_key_id"]
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+		GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: items[params.Key["id"].(*types.AttributeValueMemberS).Value]}, nil
+		},
+	}
+}
+
+// TestRekeyEntities tests that private keys are re-encrypted under the current key and stay decryptable
+func TestRekeyEntities(t *testing.T) {
+	item := func(id, keyID string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"id":                    &types.AttributeValueMemberS{Value: id},
+			"common_name":           &types.AttributeValueMemberS{Value: id + ".example.com"},
+			"encrypted_private_key": &types.AttributeValueMemberS{Value: fmt.Sprintf("%x", keyID+"|private-key-"+id)},
+			"kms_key_id":            &types.AttributeValueMemberS{Value: keyID},
+		}
+	}
+
+	t.Run("re-encrypts and is idempotent", func(t *testing.T) {
+		items := map[string]map[string]types.AttributeValue{
+			"a": item("a", "old-key"),
+			"b": item("b", "test-key"),
+			"c": item("c", "old-key"),
+		}
+		kmsClient := &storagetest.KMSClient{}
+		storage := newMockStorage(newRekeyTable(items), kmsClient)
+
+		result, err := storage.RekeyEntities(context.Background(), 100)
+		require.NoError(t, err)
+		assert.Equal(t, "test-key", result.KMSKeyID)
+		assert.Equal(t, 3, result.Scanned)
+		assert.Equal(t, 2, result.Rekeyed)
+		assert.Equal(t, 1, result.AlreadyCurrent)
+		assert.Zero(t, result.Pending)
+		assert.Zero(t, result.Failed)
+		assert.True(t, result.Complete)
+
+		for _, id := range []string{"a", "b", "c"} {
+			entity, err := storage.GetCertificateEntity(context.Background(), id, true)
+			require.NoError(t, err)
+			assert.Equal(t, "private-key-"+id, entity.EncryptedPrivateKey, "Rekeyed keys must decrypt to the original")
+			assert.Equal(t, "test-key", entity.KMSKeyID)
+		}
+
+		// A second run has nothing left to do
+		kmsClient.EncryptCalls = 0
+		result, err = storage.RekeyEntities(context.Background(), 100)
+		require.NoError(t, err)
+		assert.Zero(t, result.Rekeyed)
+		assert.Equal(t, 3, result.AlreadyCurrent)
+		assert.True(t, result.Complete)
+		assert.Zero(t, kmsClient.EncryptCalls)
+	})
+
+	t.Run("limit leaves the rest pending", func(t *testing.T) {
+		items := map[string]map[string]types.AttributeValue{
+			"a": item("a", "old-key"),
+			"b": item("b", "old-key"),
+			"c": item("c", "old-key"),
+		}
+		storage := newMockStorage(newRekeyTable(items), &storagetest.KMSClient{})
+
+		result, err := storage.RekeyEntities(context.Background(), 2)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Rekeyed)
+		assert.Equal(t, 1, result.Pending)
+		assert.False(t, result.Complete)
+
+		// Resuming finishes the remaining entity
+		result, err = storage.RekeyEntities(context.Background(), 2)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Rekeyed)
+		assert.Equal(t, 2, result.AlreadyCurrent)
+		assert.True(t, result.Complete)
+	})
+
+	t.Run("failures are reported per entity", func(t *testing.T) {
+		items := map[string]map[string]types.AttributeValue{
+			"a": item("a", "old-key"),
+			"b": item("b", "retired-key"),
+		}
+		kmsClient := &storagetest.KMSClient{
+			DecryptFn: func(ctx context.Context, params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+				keyID, plaintext, _ := strings.Cut(string(params.CiphertextBlob), "|")
+				if keyID == "retired-key" {
+					return nil, &kmstypes.IncorrectKeyException{Message: aws.String("arn:aws:kms:eu-central-1:123456789012:key/retired")}
+				}
+				return &kms.DecryptOutput{Plaintext: []byte(plaintext), KeyId: aws.String(keyID)}, nil
+			},
+		}
+		storage := newMockStorage(newRekeyTable(items), kmsClient)
+
+		result, err := storage.RekeyEntities(context.Background(), 100)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Rekeyed)
+		assert.Equal(t, 1, result.Failed)
+		assert.False(t, result.Complete)
+		assert.Equal(t, []models.EntityError{
+			{ID: "b", Error: "failed to re-encrypt private key: IncorrectKeyException"},
+		}, result.Errors)
+	})
+
+	t.Run("concurrent modification is not overwritten", func(t *testing.T) {
+		items := map[string]map[string]types.AttributeValue{
+			"a": item("a", "old-key"),
+		}
+		client := newRekeyTable(items)
+		client.UpdateItemFn = func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+		}
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		result, err := storage.RekeyEntities(context.Background(), 100)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Failed)
+		assert.Equal(t, errEntityChanged.Error(), result.Errors[0].Error)
+	})
+
+	t.Run("describe key failure", func(t *testing.T) {
+		kmsClient := &storagetest.KMSClient{
+			DescribeKeyFn: func(ctx context.Context, params *kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error) {
+				return nil, errors.New("access denied")
+			},
+		}
+		storage := newMockStorage(newRekeyTable(nil), kmsClient)
+
+		_, err := storage.RekeyEntities(context.Background(), 100)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to describe KMS key")
+	})
+}
+
+// TestGetCertificateEntityCountPaginates tests that counts are summed across all Scan pages
+func TestGetCertificateEntityCountPaginates(t *testing.T) {
+	pages := []*dynamodb.ScanOutput{
+		{Count: 40, ScannedCount: 100, LastEvaluatedKey: map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "page-1"}}},
+		{Count: 25, ScannedCount: 100, LastEvaluatedKey: map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "page-2"}}},
+		{Count: 3, ScannedCount: 12},
+	}
+
+	var startKeys []string
+	client := &storagetest.DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			assert.Equal(t, types.SelectCount, params.Select)
+			startKey := ""
+			if params.ExclusiveStartKey != nil {
+				startKey = params.ExclusiveStartKey["id"].(*types.AttributeValueMemberS).Value
+			}
+			startKeys = append(startKeys, startKey)
+			return pages[len(startKeys)-1], nil
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	count, err := storage.GetCertificateEntityCount(context.Background(), models.SearchFilters{Status: models.StatusCSRCreated})
+	require.NoError(t, err)
+	assert.Equal(t, 68, count)
+	assert.Equal(t, []string{"", "page-1", "page-2"}, startKeys)
+
+	t.Run("scan error", func(t *testing.T) {
+		client := &storagetest.DynamoDBClient{
+			ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+				return nil, errors.New("throttled")
+			},
+		}
+		_, err := newMockStorage(client, &storagetest.KMSClient{}).GetCertificateEntityCount(context.Background(), models.SearchFilters{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to count items")
+	})
+}
+
+// TestListCertificateEntitiesPaginatesScan tests that items from every Scan page are listed
+func TestListCertificateEntitiesPaginatesScan(t *testing.T) {
+	item := func(id string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"id":                    &types.AttributeValueMemberS{Value: id},
+			"common_name":           &types.AttributeValueMemberS{Value: id + ".example.com"},
+			"encrypted_private_key": &types.AttributeValueMemberS{Value: fmt.Sprintf("%x", "test-key|key-"+id)},
+		}
+	}
+	pages := []*dynamodb.ScanOutput{
+		{Items: []map[string]types.AttributeValue{item("c"), item("a")}, LastEvaluatedKey: item("a")},
+		{Items: []map[string]types.AttributeValue{item("e")}, LastEvaluatedKey: item("e")},
+		{Items: []map[string]types.AttributeValue{item("b"), item("d")}},
+	}
+
+	calls := 0
+	client := &storagetest.DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			calls++
+			return pages[calls-1], nil
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	entities, entityErrors, err := storage.ListCertificateEntities(context.Background(), models.SearchFilters{SortBy: "common_name", SortOrder: "asc"})
+	require.NoError(t, err)
+	assert.Empty(t, entityErrors)
+	assert.Equal(t, 3, calls)
+
+	var ids []string
+	for _, entity := range entities {
+		ids = append(ids, entity.ID)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, ids, "Items from later pages must be sorted with the rest")
+
+	// Pagination is applied to the full, sorted result set
+	calls = 0
+	entities, _, err = storage.ListCertificateEntities(context.Background(), models.SearchFilters{SortBy: "common_name", SortOrder: "asc", Page: 2, PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, entities, 2)
+	assert.Equal(t, "c", entities[0].ID)
+	assert.Equal(t, "d", entities[1].ID)
+}
+
+// TestListCertificateEntitiesCursor tests that a cursor continues after the previous page even when
+// entities are created or deleted between the two requests
+func TestListCertificateEntitiesCursor(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	item := func(id string, created time.Time) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"id":         &types.AttributeValueMemberS{Value: id},
+			"created_at": &types.AttributeValueMemberS{Value: created.Format(time.RFC3339Nano)},
+		}
+	}
+	items := []map[string]types.AttributeValue{
+		item("a", base),
+		item("b", base.Add(time.Hour)),
+		item("c", base.Add(2*time.Hour)),
+		item("d", base.Add(2*time.Hour)),
+		item("e", base.Add(3*time.Hour)),
+	}
+	client := &storagetest.DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			return &dynamodb.ScanOutput{Items: items}, nil
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+	ids := func(entities []models.CertificateEntity) []string {
+		ids := []string{}
+		for _, entity := range entities {
+			ids = append(ids, entity.ID)
+		}
+		return ids
+	}
+
+	filters := models.SearchFilters{SortBy: "created_at", SortOrder: "desc", PageSize: 2}
+	first, _, err := storage.ListCertificateEntities(context.Background(), filters)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e", "d"}, ids(first))
+
+	// A newer entity now sorts first, which would push "d" onto an offset-based second page
+	items = append(items, item("new", base.Add(4*time.Hour)))
+	offsetPage, _, err := storage.ListCertificateEntities(context.Background(), models.SearchFilters{SortBy: "created_at", SortOrder: "desc", PageSize: 2, Page: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d", "c"}, ids(offsetPage))
+
+	filters.Cursor = EncodeCursor(first[1], filters.SortBy, filters.SortOrder)
+	second, _, err := storage.ListCertificateEntities(context.Background(), filters)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "b"}, ids(second), "Entities equal on the sort key continue by ID")
+
+	// Deleting an already listed entity does not skip anything either
+	items = items[1:]
+	filters.Cursor = EncodeCursor(second[1], filters.SortBy, filters.SortOrder)
+	third, _, err := storage.ListCertificateEntities(context.Background(), filters)
+	require.NoError(t, err)
+	assert.Empty(t, ids(third), "Only the deleted entity came after b")
+
+	t.Run("every sort field round-trips", func(t *testing.T) {
+		validTo := base.Add(time.Nanosecond)
+		entity := models.CertificateEntity{
+			ID: "x", CommonName: "x.example.com", Status: models.StatusCertUploaded, KeyType: models.KeyTypeRSA2048,
+			CreatedAt: base, UpdatedAt: base.Add(time.Minute), ValidTo: &validTo,
+		}
+		for _, sortBy := range []string{"created_at", "updated_at", "common_name", "status", "key_type", "valid_to", "valid_from"} {
+			anchor, err := decodeCursor(EncodeCursor(entity, sortBy, "asc"), sortBy, "asc")
+			require.NoError(t, err, sortBy)
+			assert.False(t, storage.compareEntities(entity, anchor, sortBy, "asc"), sortBy)
+			assert.False(t, storage.compareEntities(anchor, entity, sortBy, "asc"), sortBy)
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		for _, cursor := range []string{"not base64!", "bm90IGpzb24", EncodeCursor(first[1], "common_name", "desc"), EncodeCursor(first[1], "created_at", "asc")} {
+			_, _, err := storage.ListCertificateEntities(context.Background(), models.SearchFilters{SortBy: "created_at", SortOrder: "desc", Cursor: cursor})
+			assert.ErrorIs(t, err, ErrInvalidCursor, cursor)
+		}
+	})
+}
+
+// TestListCertificateEntitiesProjection tests that only the projected attributes are read from DynamoDB
+func TestListCertificateEntitiesProjection(t *testing.T) {
+	var input *dynamodb.ScanInput
+	client := &storagetest.DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			input = params
+			return &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{{
+				"id":          &types.AttributeValueMemberS{Value: "entity-1"},
+				"common_name": &types.AttributeValueMemberS{Value: "example.com"},
+				"status":      &types.AttributeValueMemberS{Value: "CSR_CREATED"},
+			}}}, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	entities, _, err := storage.ListCertificateEntities(context.Background(), models.SearchFilters{
+		Status:     models.StatusCSRCreated,
+		SortBy:     "valid_to",
+		Projection: []string{"common_name", "status", "id"},
+	})
+	require.NoError(t, err)
+	require.Len(t, entities, 1)
+	assert.Zero(t, kmsClient.DecryptCalls, "No private key was read, so none must be decrypted")
+
+	require.NotNil(t, input.ProjectionExpression)
+	var projected []string
+	for _, placeholder := range strings.Split(aws.ToString(input.ProjectionExpression), ", ") {
+		projected = append(projected, input.ExpressionAttributeNames[placeholder])
+	}
+	assert.ElementsMatch(t, []string{"id", "valid_to", "common_name", "status"}, projected)
+	for _, large := range []string{"encrypted_private_key", "csr", "certificate"} {
+		assert.NotContains(t, projected, large)
+	}
+
+	// The filter still resolves its own placeholders
+	assert.Equal(t, "status", input.ExpressionAttributeNames["#status"])
+
+	t.Run("no projection reads whole items", func(t *testing.T) {
+		_, _, err := storage.ListCertificateEntities(context.Background(), models.SearchFilters{})
+		require.NoError(t, err)
+		assert.Nil(t, input.ProjectionExpression)
+	})
+}
+
+// TestListCSREntities tests that only entities with a CSR are read, without their private keys
+func TestListCSREntities(t *testing.T) {
+	var input *dynamodb.ScanInput
+	client := &storagetest.DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			input = params
+			page := []map[string]types.AttributeValue{{
+				"id":       &types.AttributeValueMemberS{Value: "entity-1"},
+				"status":   &types.AttributeValueMemberS{Value: "CSR_CREATED"},
+				"csr":      &types.AttributeValueMemberS{Value: "-----BEGIN CERTIFICATE REQUEST-----"},
+				"spki_pin": &types.AttributeValueMemberS{Value: "pin-1"},
+			}, {
+				"id": &types.AttributeValueMemberS{Value: "corrupt"}, "spki_pin": &types.AttributeValueMemberL{},
+			}}
+			if params.ExclusiveStartKey == nil {
+				return &dynamodb.ScanOutput{Items: page[:1], LastEvaluatedKey: page[0]}, nil
+			}
+			return &dynamodb.ScanOutput{Items: page[1:]}, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	entities, entityErrors, err := storage.ListCSREntities(context.Background())
+	require.NoError(t, err)
+	require.Len(t, entities, 1, "Every page is read")
+	assert.Equal(t, "entity-1", entities[0].ID)
+	assert.Equal(t, models.StatusCSRCreated, entities[0].Status)
+	assert.Equal(t, "pin-1", entities[0].SPKIPin)
+	assert.Equal(t, []models.EntityError{{ID: "corrupt", Error: "failed to decode stored entity"}}, entityErrors)
+	assert.Zero(t, kmsClient.DecryptCalls)
+
+	assert.Equal(t, "attribute_exists(#csr)", aws.ToString(input.FilterExpression))
+	var projected []string
+	for _, placeholder := range strings.Split(aws.ToString(input.ProjectionExpression), ", ") {
+		projected = append(projected, input.ExpressionAttributeNames[placeholder])
+	}
+	assert.Equal(t, []string{"id", "status", "csr", "spki_pin"}, projected)
+}
+
+// TestListStaleEntities tests only entities neither touched nor updated since the cutoff are listed
+func TestListStaleEntities(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	cutoff := now.AddDate(0, 0, -90)
+	daysAgo := func(days int) string {
+		return now.AddDate(0, 0, -days).Format(time.RFC3339)
+	}
+	fixture := func(id, updatedAt, lastSeenAt string) map[string]types.AttributeValue {
+		item := map[string]types.AttributeValue{
+			"id":         &types.AttributeValueMemberS{Value: id},
+			"status":     &types.AttributeValueMemberS{Value: string(models.StatusCertUploaded)},
+			"updated_at": &types.AttributeValueMemberS{Value: updatedAt},
+		}
+		if lastSeenAt != "" {
+			item["last_seen_at"] = &types.AttributeValueMemberS{Value: lastSeenAt}
+		}
+		return item
+	}
+	fixtures := []map[string]types.AttributeValue{
+		fixture("fresh", daysAgo(5), ""),
+		fixture("abandoned", daysAgo(400), ""),
+		fixture("recently-seen", daysAgo(200), daysAgo(10)),
+		fixture("seen-long-ago", daysAgo(120), daysAgo(120)),
+		fixture("just-inside", daysAgo(89), ""),
+	}
+
+	var input *dynamodb.ScanInput
+	client := &storagetest.DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			input = params
+			// Evaluate staleCondition the way DynamoDB compares strings
+			cutoff := params.ExpressionAttributeValues[":cutoff"].(*types.AttributeValueMemberS).Value
+			var items []map[string]types.AttributeValue
+			for _, item := range fixtures {
+				updatedAt := item["updated_at"].(*types.AttributeValueMemberS).Value
+				lastSeen, seen := item["last_seen_at"].(*types.AttributeValueMemberS)
+				if updatedAt < cutoff && (!seen || lastSeen.Value < cutoff) {
+					items = append(items, item)
+				}
+			}
+			return &dynamodb.ScanOutput{Items: items}, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	entities, entityErrors, err := storage.ListStaleEntities(context.Background(), cutoff)
+	require.NoError(t, err)
+	assert.Empty(t, entityErrors)
+	var ids []string
+	for _, entity := range entities {
+		ids = append(ids, entity.ID)
+	}
+	assert.Equal(t, []string{"abandoned", "seen-long-ago"}, ids)
+	require.NotNil(t, entities[1].LastSeenAt)
+	assert.Zero(t, kmsClient.DecryptCalls)
+
+	assert.Equal(t, staleCondition, aws.ToString(input.FilterExpression))
+	assert.Equal(t, cutoff.Format(time.RFC3339), input.ExpressionAttributeValues[":cutoff"].(*types.AttributeValueMemberS).Value)
+	var projected []string
+	for _, placeholder := range strings.Split(aws.ToString(input.ProjectionExpression), ", ") {
+		projected = append(projected, input.ExpressionAttributeNames[placeholder])
+	}
+	assert.NotContains(t, projected, "encrypted_private_key")
+	assert.Contains(t, projected, "last_seen_at")
+}
+
+// TestUpdateCSR tests that the CSR and subject are replaced without touching the private key
+func TestUpdateCSR(t *testing.T) {
+	var input *dynamodb.UpdateItemInput
+	client := &storagetest.DynamoDBClient{
+		UpdateItemFn: func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			input = params
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	entity := &models.CertificateEntity{
+		ID:                      "entity-id",
+		CommonName:              "new.example.com",
+		SubjectAlternativeNames: []string{"new.example.com", "api.example.com"},
+		Organization:            "ACME Corp",
+		EncryptedPrivateKey:     "private-key-pem",
+		CSR:                     "new-csr",
+		Status:                  models.StatusCertUploaded,
+	}
+	require.NoError(t, storage.UpdateCSR(context.Background(), entity, nil))
+	require.NotNil(t, input)
+
+	assert.Equal(t, models.StatusCSRCreated, entity.Status)
+	assert.Zero(t, kmsClient.EncryptCalls, "The private key must not be rewritten")
+	assert.Equal(t, "attribute_exists(id)", aws.ToString(input.ConditionExpression))
+
+	expression := aws.ToString(input.UpdateExpression)
+	assert.NotContains(t, input.ExpressionAttributeNames, "#encrypted_private_key")
+	assert.Contains(t, expression, "#subject_alternative_names = :subject_alternative_names")
+	assert.Contains(t, expression, "#organization = :organization")
+	assert.Contains(t, expression, " REMOVE ")
+	assert.Contains(t, expression, "#organizational_unit")
+
+	assert.Equal(t, "new-csr", input.ExpressionAttributeValues[":csr"].(*types.AttributeValueMemberS).Value)
+	assert.Equal(t, string(models.StatusCSRCreated), input.ExpressionAttributeValues[":status"].(*types.AttributeValueMemberS).Value)
+	sans := input.ExpressionAttributeValues[":subject_alternative_names"].(*types.AttributeValueMemberL).Value
+	require.Len(t, sans, 2)
+	assert.Equal(t, "api.example.com", sans[1].(*types.AttributeValueMemberS).Value)
+}
+
+// TestDeleteCertificateEntity tests that protected entities are only deleted with force
+func TestDeleteCertificateEntity(t *testing.T) {
+	// deleteItemFn emulates DynamoDB evaluating the delete condition against a stored item
+	deleteItemFn := func(stored map[string]types.AttributeValue) func(ctx context.Context, params *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+		return func(ctx context.Context, params *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+			conditionFailed := &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+			if stored == nil {
+				return nil, conditionFailed
+			}
+			tags, _ := stored["tags"].(*types.AttributeValueMemberM)
+			protected := tags != nil && tags.Value["protected"] != nil &&
+				tags.Value["protected"].(*types.AttributeValueMemberS).Value == "true"
+			if protected && strings.Contains(aws.ToString(params.ConditionExpression), ":protected") {
+				conditionFailed.Item = stored
+				return nil, conditionFailed
+			}
+			return &dynamodb.DeleteItemOutput{}, nil
+		}
+	}
+
+	protectedItem := map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: "entity-1"},
+		"tags": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"protected": &types.AttributeValueMemberS{Value: "true"},
+		}},
+	}
+	unprotectedItem := map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: "entity-1"},
+	}
+
+	tests := []struct {
+		name        string
+		stored      map[string]types.AttributeValue
+		force       bool
+		expectedErr error
+	}{
+		{name: "unprotected entity", stored: unprotectedItem},
+		{name: "protected entity blocks delete", stored: protectedItem, expectedErr: ErrEntityProtected},
+		{name: "force overrides protection", stored: protectedItem, force: true},
+		{name: "missing entity", stored: nil, expectedErr: ErrEntityNotFound},
+		{name: "missing entity with force", stored: nil, force: true, expectedErr: ErrEntityNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &storagetest.DynamoDBClient{DeleteItemFn: deleteItemFn(tt.stored)}
+			storage := newMockStorage(client, &storagetest.KMSClient{})
+
+			err := storage.DeleteCertificateEntity(context.Background(), "entity-1", tt.force)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestUpdateMetadata tests notes and tags are set or removed in one conditional write and the new entity is returned
+func TestUpdateMetadata(t *testing.T) {
+	var input *dynamodb.UpdateItemInput
+	client := &storagetest.DynamoDBClient{
+		UpdateItemFn: func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			input = params
+			return &dynamodb.UpdateItemOutput{Attributes: map[string]types.AttributeValue{
+				"id":                    &types.AttributeValueMemberS{Value: "entity-1"},
+				"notes":                 &types.AttributeValueMemberS{Value: "owner @jdoe"},
+				"encrypted_private_key": &types.AttributeValueMemberS{Value: "ciphertext"},
+			}}, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	notes := "owner @jdoe"
+	entity, err := storage.UpdateMetadata(context.Background(), "entity-1", &notes, map[string]string{"env": "prod"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "owner @jdoe", entity.Notes)
+	assert.Zero(t, kmsClient.DecryptCalls, "The private key is not decrypted")
+
+	assert.Equal(t, "SET #updated_at = :updated_at, #notes = :notes, #tags = :tags", aws.ToString(input.UpdateExpression))
+	assert.Equal(t, "attribute_exists(id) AND ("+notProtectedCondition+")", aws.ToString(input.ConditionExpression))
+	assert.Equal(t, types.ReturnValueAllNew, input.ReturnValues)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "owner @jdoe"}, input.ExpressionAttributeValues[":notes"])
+
+	// Clearing notes leaves tags alone and needs no protection check
+	empty := ""
+	_, err = storage.UpdateMetadata(context.Background(), "entity-1", &empty, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, "SET #updated_at = :updated_at REMOVE #notes", aws.ToString(input.UpdateExpression))
+	assert.Equal(t, "attribute_exists(id)", aws.ToString(input.ConditionExpression))
+	assert.NotContains(t, input.ExpressionAttributeNames, "#tags")
+
+	// Keeping protected=true or forcing skips the protection check
+	_, err = storage.UpdateMetadata(context.Background(), "entity-1", nil, map[string]string{models.ProtectedTag: "true"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "attribute_exists(id)", aws.ToString(input.ConditionExpression))
+	assert.NotContains(t, input.ExpressionAttributeNames, "#protected", "Unused placeholders are rejected by DynamoDB")
+
+	_, err = storage.UpdateMetadata(context.Background(), "entity-1", nil, map[string]string{}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "SET #updated_at = :updated_at REMOVE #tags", aws.ToString(input.UpdateExpression))
+	assert.Equal(t, "attribute_exists(id)", aws.ToString(input.ConditionExpression))
+}
+
+// TestUpdateMetadataConditionFailures tests a failed condition is reported as a protected or missing entity
+func TestUpdateMetadataConditionFailures(t *testing.T) {
+	var stored map[string]types.AttributeValue
+	client := &storagetest.DynamoDBClient{
+		UpdateItemFn: func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed"), Item: stored}
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	_, err := storage.UpdateMetadata(context.Background(), "entity-1", nil, map[string]string{}, false)
+	assert.ErrorIs(t, err, ErrEntityNotFound)
+
+	stored = map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "entity-1"}}
+	_, err = storage.UpdateMetadata(context.Background(), "entity-1", nil, map[string]string{}, false)
+	assert.ErrorIs(t, err, ErrEntityProtected)
+}
+
+// TestTransferOwnership tests owner tags are merged in one write conditioned on the tags read
+func TestTransferOwnership(t *testing.T) {
+	tagsValue := func(tags map[string]string) types.AttributeValue {
+		value, err := attributevalue.Marshal(tags)
+		require.NoError(t, err)
+		return value
+	}
+	newTable := func(item map[string]types.AttributeValue) (*storagetest.DynamoDBClient, *[]*dynamodb.UpdateItemInput) {
+		var updates []*dynamodb.UpdateItemInput
+		client := &storagetest.DynamoDBClient{
+			GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+				return &dynamodb.GetItemOutput{Item: item}, nil
+			},
+			UpdateItemFn: func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+				updates = append(updates, params)
+				updated := map[string]types.AttributeValue{"id": item["id"], "tags": params.ExpressionAttributeValues[":tags"]}
+				if notes, ok := params.ExpressionAttributeValues[":notes"]; ok {
+					updated["notes"] = notes
+				}
+				return &dynamodb.UpdateItemOutput{Attributes: updated}, nil
+			},
+		}
+		return client, &updates
+	}
+
+	t.Run("merges owner tags", func(t *testing.T) {
+		oldTags := tagsValue(map[string]string{"team": "web", "env": "prod"})
+		client, updates := newTable(map[string]types.AttributeValue{
+			"id":   &types.AttributeValueMemberS{Value: "entity-1"},
+			"tags": oldTags,
+		})
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		notes := "handed over"
+		entity, previous, err := storage.TransferOwnership(context.Background(), "entity-1", map[string]string{"team": "platform", "owner": "jdoe"}, &notes)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"team": "platform", "owner": "jdoe", "env": "prod"}, entity.Tags)
+		assert.Equal(t, "handed over", entity.Notes)
+		assert.Equal(t, map[string]string{"team": "web"}, previous, "Only owner tags that were set have a previous value")
+
+		require.Len(t, *updates, 1)
+		input := (*updates)[0]
+		assert.Equal(t, "#tags = :old_tags", aws.ToString(input.ConditionExpression))
+		assert.Equal(t, oldTags, input.ExpressionAttributeValues[":old_tags"])
+		assert.Equal(t, "SET #tags = :tags, #updated_at = :updated_at, #notes = :notes", aws.ToString(input.UpdateExpression))
+	})
+
+	t.Run("entity without tags", func(t *testing.T) {
+		client, updates := newTable(map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "entity-1"}})
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		entity, previous, err := storage.TransferOwnership(context.Background(), "entity-1", map[string]string{"team": "platform"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"team": "platform"}, entity.Tags)
+		assert.Empty(t, previous)
+		assert.Equal(t, "attribute_exists(id) AND attribute_not_exists(#tags)", aws.ToString((*updates)[0].ConditionExpression))
+		assert.NotContains(t, aws.ToString((*updates)[0].UpdateExpression), "#notes")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		client, updates := newTable(nil)
+		_, _, err := newMockStorage(client, &storagetest.KMSClient{}).TransferOwnership(context.Background(), "missing", map[string]string{"team": "platform"}, nil)
+		assert.ErrorIs(t, err, ErrEntityNotFound)
+		assert.Empty(t, *updates)
+	})
+
+	t.Run("protected", func(t *testing.T) {
+		client, updates := newTable(map[string]types.AttributeValue{
+			"id":   &types.AttributeValueMemberS{Value: "entity-1"},
+			"tags": tagsValue(map[string]string{models.ProtectedTag: "true"}),
+		})
+		_, _, err := newMockStorage(client, &storagetest.KMSClient{}).TransferOwnership(context.Background(), "entity-1", map[string]string{"team": "platform"}, nil)
+		assert.ErrorIs(t, err, ErrEntityProtected)
+		assert.Empty(t, *updates)
+	})
+
+	t.Run("concurrent tag changes are retried", func(t *testing.T) {
+		client, updates := newTable(map[string]types.AttributeValue{
+			"id":   &types.AttributeValueMemberS{Value: "entity-1"},
+			"tags": tagsValue(map[string]string{"team": "web"}),
+		})
+		succeedOn := 2
+		update := client.UpdateItemFn
+		client.UpdateItemFn = func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			if len(*updates)+1 < succeedOn {
+				*updates = append(*updates, params)
+				return nil, &types.ConditionalCheckFailedException{
+					Message: aws.String("The conditional request failed"),
+					Item:    map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "entity-1"}},
+				}
+			}
+			return update(ctx, params)
+		}
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		_, _, err := storage.TransferOwnership(context.Background(), "entity-1", map[string]string{"team": "platform"}, nil)
+		require.NoError(t, err)
+		assert.Len(t, *updates, 2)
+
+		// An entity that keeps changing is reported rather than retried forever
+		*updates = nil
+		succeedOn = maxTransferAttempts + 1
+		_, _, err = storage.TransferOwnership(context.Background(), "entity-1", map[string]string{"team": "platform"}, nil)
+		assert.ErrorIs(t, err, ErrEntityModified)
+		assert.Len(t, *updates, maxTransferAttempts)
+	})
+}
+
+// claimTable is a single-item table that applies claim updates atomically, evaluating claimCondition
+// the way DynamoDB would, so concurrent claims contend as they would against the real table
+type claimTable struct {
+	mu   sync.Mutex
+	item map[string]types.AttributeValue
+}
+
+func (t *claimTable) updateItem(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if aws.ToString(params.ConditionExpression) != claimCondition {
+		return nil, fmt.Errorf("unexpected condition %q", aws.ToString(params.ConditionExpression))
+	}
+	if t.item == nil {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+
+	stringValue := func(v types.AttributeValue) string {
+		if s, ok := v.(*types.AttributeValueMemberS); ok {
+			return s.Value
+		}
+		return ""
+	}
+	holder := stringValue(params.ExpressionAttributeValues[":holder"])
+	now := stringValue(params.ExpressionAttributeValues[":now"])
+	claimedBy, claimed := t.item["claimed_by"]
+	if claimed && stringValue(claimedBy) != holder && !(stringValue(t.item["claim_expires_at"]) < now) {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed"), Item: t.item}
+	}
+
+	updated := make(map[string]types.AttributeValue, len(t.item)+2)
+	for name, value := range t.item {
+		updated[name] = value
+	}
+	if strings.HasPrefix(aws.ToString(params.UpdateExpression), "REMOVE") {
+		delete(updated, "claimed_by")
+		delete(updated, "claim_expires_at")
+	} else {
+		updated["claimed_by"] = params.ExpressionAttributeValues[":holder"]
+		updated["claim_expires_at"] = params.ExpressionAttributeValues[":expires_at"]
+	}
+	t.item = updated
+	return &dynamodb.UpdateItemOutput{Attributes: updated}, nil
+}
+
+// TestClaimEntity tests a claim is a single conditional write that sets the holder and expiry
+func TestClaimEntity(t *testing.T) {
+	var input *dynamodb.UpdateItemInput
+	table := &claimTable{item: map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "entity-1"}}}
+	client := &storagetest.DynamoDBClient{
+		UpdateItemFn: func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			input = params
+			return table.updateItem(ctx, params)
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	before := time.Now().UTC().Truncate(time.Second)
+	entity, err := storage.ClaimEntity(context.Background(), "entity-1", "renewer-a", 5*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "renewer-a", entity.ClaimedBy)
+	require.NotNil(t, entity.ClaimExpiresAt)
+	assert.WithinDuration(t, before.Add(5*time.Minute), *entity.ClaimExpiresAt, 2*time.Second)
+	assert.Zero(t, kmsClient.DecryptCalls, "The private key is not decrypted")
+
+	assert.Equal(t, "SET #claimed_by = :holder, #claim_expires_at = :expires_at", aws.ToString(input.UpdateExpression))
+	assert.Equal(t, claimCondition, aws.ToString(input.ConditionExpression))
+	assert.Equal(t, types.ReturnValuesOnConditionCheckFailureAllOld, input.ReturnValuesOnConditionCheckFailure)
+	expiresAt := input.ExpressionAttributeValues[":expires_at"].(*types.AttributeValueMemberS).Value
+	assert.Regexp(t, `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`, expiresAt, "Expiry is second-precision UTC so string comparison orders it")
+
+	entity, err = storage.ReleaseClaim(context.Background(), "entity-1", "renewer-a")
+	require.NoError(t, err)
+	assert.Empty(t, entity.ClaimedBy)
+	assert.Nil(t, entity.ClaimExpiresAt)
+	assert.Equal(t, "REMOVE #claimed_by, #claim_expires_at", aws.ToString(input.UpdateExpression))
+	assert.Equal(t, claimCondition, aws.ToString(input.ConditionExpression))
+}
+
+// TestClaimEntityContention tests only one of several concurrent renewers wins a claim and that
+// the others see who holds it until it is released or expires
+func TestClaimEntityContention(t *testing.T) {
+	table := &claimTable{item: map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "entity-1"}}}
+	storage := newMockStorage(&storagetest.DynamoDBClient{UpdateItemFn: table.updateItem}, &storagetest.KMSClient{})
+	ctx := context.Background()
+
+	const renewers = 20
+	var wg sync.WaitGroup
+	errs := make([]error, renewers)
+	for i := 0; i < renewers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = storage.ClaimEntity(ctx, "entity-1", fmt.Sprintf("renewer-%d", i), time.Minute)
+		}(i)
+	}
+	wg.Wait()
+
+	winner := ""
+	for i, err := range errs {
+		if err == nil {
+			require.Empty(t, winner, "Only one renewer may win the claim")
+			winner = fmt.Sprintf("renewer-%d", i)
+		}
+	}
+	require.NotEmpty(t, winner, "One renewer wins the claim")
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		require.ErrorIs(t, err, ErrEntityClaimed)
+		var claimErr *ClaimError
+		require.ErrorAs(t, err, &claimErr)
+		assert.Equal(t, winner, claimErr.ClaimedBy)
+		assert.NotNil(t, claimErr.ExpiresAt)
+	}
+
+	// The holder may extend its claim, but nobody else may take or release it
+	_, err := storage.ClaimEntity(ctx, "entity-1", winner, 2*time.Minute)
+	require.NoError(t, err)
+	_, err = storage.ReleaseClaim(ctx, "entity-1", "intruder")
+	assert.ErrorIs(t, err, ErrEntityClaimed)
+
+	// Once released, another renewer can claim it
+	_, err = storage.ReleaseClaim(ctx, "entity-1", winner)
+	require.NoError(t, err)
+	entity, err := storage.ClaimEntity(ctx, "entity-1", "renewer-next", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "renewer-next", entity.ClaimedBy)
+
+	// An expired claim no longer blocks anyone
+	table.item["claim_expires_at"] = &types.AttributeValueMemberS{Value: time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)}
+	entity, err = storage.ClaimEntity(ctx, "entity-1", "renewer-late", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "renewer-late", entity.ClaimedBy)
+
+	// A missing entity is not mistaken for a claimed one
+	table.item = nil
+	_, err = storage.ClaimEntity(ctx, "entity-1", "renewer-a", time.Minute)
+	assert.ErrorIs(t, err, ErrEntityNotFound)
+	_, err = storage.ReleaseClaim(ctx, "entity-1", "renewer-a")
+	assert.ErrorIs(t, err, ErrEntityNotFound)
+}
+
+// TestFindDeletableEntities tests matching entities are counted across pages with protected ones set aside
+func TestFindDeletableEntities(t *testing.T) {
+	item := func(id string, tags map[string]string) map[string]types.AttributeValue {
+		item := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}}
+		if tags != nil {
+			value, err := attributevalue.Marshal(tags)
+			require.NoError(t, err)
+			item["tags"] = value
+		}
+		return item
+	}
+	pages := []*dynamodb.ScanOutput{
+		{
+			Items: []map[string]types.AttributeValue{
+				item("entity-c", map[string]string{"env": "dev"}),
+				item("entity-a", map[string]string{"env": "dev", models.ProtectedTag: "true"}),
+			},
+			LastEvaluatedKey: map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "entity-a"}},
+		},
+		{
+			Items: []map[string]types.AttributeValue{
+				item("entity-b", map[string]string{"env": "dev", models.ProtectedTag: "false"}),
+				item("entity-d", nil),
+			},
+		},
+	}
+
+	var inputs []dynamodb.ScanInput
+	client := &storagetest.DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			inputs = append(inputs, *params)
+			return pages[len(inputs)-1], nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	deletable, protected, err := storage.FindDeletableEntities(context.Background(), models.SearchFilters{
+		Status: models.StatusCSRCreated,
+		Tags:   map[string]string{"env": "dev"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"entity-b", "entity-c", "entity-d"}, deletable)
+	assert.Equal(t, []string{"entity-a"}, protected)
+	assert.Zero(t, kmsClient.DecryptCalls)
+
+	require.Len(t, inputs, 2)
+	assert.Equal(t, "#status = :status AND #tags.#tag_key_0 = :tag_value_0", aws.ToString(inputs[0].FilterExpression))
+	assert.Equal(t, "#proj_0, #proj_1", aws.ToString(inputs[0].ProjectionExpression))
+	assert.Equal(t, "id", inputs[0].ExpressionAttributeNames["#proj_0"])
+	assert.Equal(t, "tags", inputs[0].ExpressionAttributeNames["#proj_1"])
+}
+
+// TestDeleteCertificateEntities tests entities are deleted in conditional transactions of up to 100,
+// entities protected since they were selected are kept and cancelled transactions are retried
+func TestDeleteCertificateEntities(t *testing.T) {
+	defer func(backoff time.Duration) { transactWriteBackoff = backoff }(transactWriteBackoff)
+	transactWriteBackoff = time.Millisecond
+
+	ids := make([]string, 150)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("entity-%03d", i)
+	}
+
+	var transactionSizes []int
+	var deleted []string
+	client := &storagetest.DynamoDBClient{
+		TransactWriteFn: func(ctx context.Context, params *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			transactionSizes = append(transactionSizes, len(params.TransactItems))
+			var batch []string
+			reasons := make([]types.CancellationReason, len(params.TransactItems))
+			canceled := false
+			for i, item := range params.TransactItems {
+				require.NotNil(t, item.Delete)
+				assert.Equal(t, "attribute_exists(id) AND ("+notProtectedCondition+")", aws.ToString(item.Delete.ConditionExpression))
+				id := item.Delete.Key["id"].(*types.AttributeValueMemberS).Value
+				reasons[i].Code = aws.String("None")
+				// entity-010 was tagged protected after it was selected
+				if id == "entity-010" {
+					reasons[i].Code = aws.String("ConditionalCheckFailed")
+					canceled = true
+				}
+				batch = append(batch, id)
+			}
+			if canceled {
+				return nil, &types.TransactionCanceledException{CancellationReasons: reasons}
+			}
+			deleted = append(deleted, batch...)
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	deletedIDs, err := storage.DeleteCertificateEntities(context.Background(), ids)
+	require.NoError(t, err)
+	expected := append(slices.Clone(ids[:10]), ids[11:]...)
+	assert.Equal(t, expected, deletedIDs)
+	assert.Equal(t, []int{100, 99, 50}, transactionSizes)
+	assert.Equal(t, expected, deleted)
+
+	// Transactions that keep being cancelled fail the delete after a bounded number of attempts
+	client.TransactWriteFn = func(ctx context.Context, params *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+		reasons := make([]types.CancellationReason, len(params.TransactItems))
+		for i := range reasons {
+			reasons[i].Code = aws.String("TransactionConflict")
+		}
+		return nil, &types.TransactionCanceledException{CancellationReasons: reasons}
+	}
+	deletedIDs, err = storage.DeleteCertificateEntities(context.Background(), ids[:3])
+	require.Error(t, err)
+	assert.Empty(t, deletedIDs)
+}
+
+// TestStatusHistoryIsAppendedOnUpdate tests that a status change is appended in the same write as the update
+func TestStatusHistoryIsAppendedOnUpdate(t *testing.T) {
+	var inputs []*dynamodb.UpdateItemInput
+	client := &storagetest.DynamoDBClient{
+		UpdateItemFn: func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			inputs = append(inputs, params)
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	change := &models.StatusChange{
+		FromStatus: models.StatusCSRCreated,
+		ToStatus:   models.StatusCertUploaded,
+		Actor:      "ci-pipeline",
+		RequestID:  "req_12345678",
+	}
+	entity := &models.CertificateEntity{ID: "entity-id", Status: models.StatusCertUploaded, Certificate: "cert"}
+	require.NoError(t, storage.UpdateCertificateEntity(context.Background(), entity, change))
+
+	require.Len(t, inputs, 1)
+	assert.Contains(t, aws.ToString(inputs[0].UpdateExpression), "#status_history = list_append(if_not_exists(#status_history, :empty_list), :status_change)")
+	assert.Equal(t, entity.UpdatedAt, change.Timestamp, "The change is stamped with the update time")
+
+	appended := inputs[0].ExpressionAttributeValues[":status_change"].(*types.AttributeValueMemberL).Value
+	require.Len(t, appended, 1)
+	var recorded models.StatusChange
+	require.NoError(t, attributevalue.Unmarshal(appended[0], &recorded))
+	assert.Equal(t, models.StatusCSRCreated, recorded.FromStatus)
+	assert.Equal(t, models.StatusCertUploaded, recorded.ToStatus)
+	assert.Equal(t, "ci-pipeline", recorded.Actor)
+	assert.Equal(t, "req_12345678", recorded.RequestID)
+
+	// Usages are written with the certificate, even when empty, so stale usages are cleared
+	assert.Contains(t, aws.ToString(inputs[0].UpdateExpression), "#key_usages = :key_usages, #ext_key_usages = :ext_key_usages")
+
+	// Without a change the history is left alone
+	require.NoError(t, storage.UpdateCertificateEntity(context.Background(), entity, nil))
+	assert.NotContains(t, aws.ToString(inputs[1].UpdateExpression), "status_history")
+
+	// Regenerating a CSR records its transition too
+	change = &models.StatusChange{FromStatus: models.StatusCertUploaded, ToStatus: models.StatusCSRCreated}
+	require.NoError(t, storage.UpdateCSR(context.Background(), &models.CertificateEntity{ID: "entity-id", CSR: "csr"}, change))
+	assert.Contains(t, aws.ToString(inputs[2].UpdateExpression), "list_append(if_not_exists(#status_history, :empty_list), :status_change)")
+	assert.False(t, change.Timestamp.IsZero())
+}
+
+// TestGetStatusHistory tests that only the history is read and missing entities are reported
+func TestGetStatusHistory(t *testing.T) {
+	history := []models.StatusChange{
+		{ToStatus: models.StatusCSRCreated, Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Actor: "ci-pipeline"},
+		{FromStatus: models.StatusCSRCreated, ToStatus: models.StatusCertUploaded, Timestamp: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	historyAV, err := attributevalue.Marshal(history)
+	require.NoError(t, err)
+
+	var input *dynamodb.GetItemInput
+	client := &storagetest.DynamoDBClient{
+		GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			input = params
+			if params.Key["id"].(*types.AttributeValueMemberS).Value != "entity-id" {
+				return &dynamodb.GetItemOutput{}, nil
+			}
+			return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+				"id":             &types.AttributeValueMemberS{Value: "entity-id"},
+				"status_history": historyAV,
+			}}, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	got, nextCursor, err := storage.GetStatusHistory(context.Background(), "entity-id", models.HistoryPage{SortOrder: "asc"})
+	require.NoError(t, err)
+	assert.Equal(t, history, got)
+	assert.Empty(t, nextCursor)
+	assert.Equal(t, "#proj_0, #proj_1", aws.ToString(input.ProjectionExpression))
+	assert.Equal(t, map[string]string{"#proj_0": "id", "#proj_1": "status_history"}, input.ExpressionAttributeNames)
+	assert.Zero(t, kmsClient.DecryptCalls)
+
+	_, _, err = storage.GetStatusHistory(context.Background(), "missing-id", models.HistoryPage{})
+	assert.ErrorIs(t, err, ErrEntityNotFound)
+}
+
+// TestGetStatusHistoryPagination tests transitions are paged most recent first by default and a
+// cursor continues right after the last transition of the previous page in either order
+func TestGetStatusHistoryPagination(t *testing.T) {
+	var history []models.StatusChange
+	for day := 1; day <= 5; day++ {
+		history = append(history, models.StatusChange{ToStatus: models.StatusCertUploaded, Timestamp: time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)})
+	}
+	historyAV, err := attributevalue.Marshal(history)
+	require.NoError(t, err)
+	client := &storagetest.DynamoDBClient{
+		GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+				"id":             &types.AttributeValueMemberS{Value: "entity-id"},
+				"status_history": historyAV,
+			}}, nil
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	days := func(page []models.StatusChange) []int {
+		var result []int
+		for _, change := range page {
+			result = append(result, change.Timestamp.Day())
+		}
+		return result
+	}
+	readAll := func(sortOrder string) [][]int {
+		var pages [][]int
+		cursor := ""
+		for {
+			page, next, err := storage.GetStatusHistory(context.Background(), "entity-id", models.HistoryPage{PageSize: 2, SortOrder: sortOrder, Cursor: cursor})
+			require.NoError(t, err)
+			pages = append(pages, days(page))
+			if next == "" {
+				return pages
+			}
+			cursor = next
+		}
+	}
+
+	assert.Equal(t, [][]int{{5, 4}, {3, 2}, {1}}, readAll(""), "Most recent first by default")
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, readAll("asc"))
+
+	// A full last page has no cursor
+	page, next, err := storage.GetStatusHistory(context.Background(), "entity-id", models.HistoryPage{PageSize: 5})
+	require.NoError(t, err)
+	assert.Len(t, page, 5)
+	assert.Empty(t, next)
+
+	// A cursor is only valid for the order it was issued for
+	_, next, err = storage.GetStatusHistory(context.Background(), "entity-id", models.HistoryPage{PageSize: 2})
+	require.NoError(t, err)
+	_, _, err = storage.GetStatusHistory(context.Background(), "entity-id", models.HistoryPage{PageSize: 2, SortOrder: "asc", Cursor: next})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, _, err = storage.GetStatusHistory(context.Background(), "entity-id", models.HistoryPage{Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+// TestGetStatusHistoryEqualTimestamps tests transitions recorded at the same instant are neither
+// skipped nor repeated when a page boundary falls between them, in either order
+func TestGetStatusHistoryEqualTimestamps(t *testing.T) {
+	instant := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	statuses := []models.CertificateStatus{models.StatusCSRCreated, models.StatusCertUploaded, models.StatusCompleted, models.StatusExpired}
+	var history []models.StatusChange
+	for _, status := range statuses {
+		history = append(history, models.StatusChange{ToStatus: status, Timestamp: instant})
+	}
+	historyAV, err := attributevalue.Marshal(history)
+	require.NoError(t, err)
+	client := &storagetest.DynamoDBClient{
+		GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+				"id":             &types.AttributeValueMemberS{Value: "entity-id"},
+				"status_history": historyAV,
+			}}, nil
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	readAll := func(sortOrder string) []models.CertificateStatus {
+		var result []models.CertificateStatus
+		cursor := ""
+		for {
+			page, next, err := storage.GetStatusHistory(context.Background(), "entity-id", models.HistoryPage{PageSize: 3, SortOrder: sortOrder, Cursor: cursor})
+			require.NoError(t, err)
+			for _, change := range page {
+				result = append(result, change.ToStatus)
+			}
+			if next == "" {
+				return result
+			}
+			cursor = next
+		}
+	}
+
+	assert.Equal(t, statuses, readAll("asc"))
+	reversed := slices.Clone(statuses)
+	slices.Reverse(reversed)
+	assert.Equal(t, reversed, readAll("desc"))
+}
+
+// TestGetCertificateEntityWithoutDecrypt tests metadata stays readable without calling KMS, even when KMS is down
+func TestGetCertificateEntityWithoutDecrypt(t *testing.T) {
+	validTo := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := &storagetest.DynamoDBClient{
+		GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+				"id":                    &types.AttributeValueMemberS{Value: "entity-id"},
+				"status":                &types.AttributeValueMemberS{Value: string(models.StatusCertUploaded)},
+				"valid_to":              &types.AttributeValueMemberS{Value: validTo.Format(time.RFC3339)},
+				"encrypted_private_key": &types.AttributeValueMemberS{Value: fmt.Sprintf("%x", "test-key|private-key")},
+			}}, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{
+		DecryptFn: func(ctx context.Context, params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+			return nil, fmt.Errorf("KMS is unavailable")
+		},
+	}
+	storage := newMockStorage(client, kmsClient)
+
+	entity, err := storage.GetCertificateEntity(context.Background(), "entity-id", false)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCertUploaded, entity.Status)
+	assert.Equal(t, validTo, entity.ValidTo.UTC())
+	assert.Empty(t, entity.EncryptedPrivateKey, "The key is withheld rather than returned encrypted")
+	assert.Zero(t, kmsClient.DecryptCalls)
+
+	// Callers that need the key still see the KMS failure
+	_, err = storage.GetCertificateEntity(context.Background(), "entity-id", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "KMS is unavailable")
+	assert.Equal(t, 1, kmsClient.DecryptCalls)
+
+	// Once KMS recovers the key is decrypted
+	kmsClient.DecryptFn = nil
+	entity, err = storage.GetCertificateEntity(context.Background(), "entity-id", true)
+	require.NoError(t, err)
+	assert.Equal(t, "private-key", entity.EncryptedPrivateKey)
+}
+
+// TestExternalKeyEntityBypassesKMS tests entities created from an external CSR are stored, read and restored without a private key
+func TestExternalKeyEntityBypassesKMS(t *testing.T) {
+	var stored map[string]types.AttributeValue
+	client := &storagetest.DynamoDBClient{
+		PutItemFn: func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			stored = params.Item
+			return &dynamodb.PutItemOutput{}, nil
+		},
+		GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: stored}, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	entity := &models.CertificateEntity{
+		CommonName:  "airgap.example.com",
+		KeyType:     models.KeyTypeECDSAP256,
+		ExternalKey: true,
+		CSR:         "csr-pem",
+		Status:      models.StatusCSRCreated,
+	}
+	require.NoError(t, storage.CreateCertificateEntity(context.Background(), entity))
+	assert.Equal(t, &types.AttributeValueMemberBOOL{Value: true}, stored["external_key"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: ""}, stored["encrypted_private_key"])
+	assert.NotContains(t, stored, "kms_key_id")
+
+	loaded, err := storage.GetCertificateEntity(context.Background(), entity.ID, true)
+	require.NoError(t, err)
+	assert.True(t, loaded.ExternalKey)
+	assert.Empty(t, loaded.EncryptedPrivateKey)
+	assert.Equal(t, "csr-pem", loaded.CSR)
+
+	replaced, err := storage.RestoreCertificateEntity(context.Background(), loaded, true, false)
+	require.NoError(t, err)
+	assert.False(t, replaced)
+
+	assert.Zero(t, kmsClient.EncryptCalls, "There is no private key to encrypt")
+	assert.Zero(t, kmsClient.DecryptCalls, "There is no private key to decrypt")
+}
+
+// TestEncryptAllSensitive tests that CSRs and certificates are KMS-encrypted at rest when enabled
+func TestEncryptAllSensitive(t *testing.T) {
+	newTable := func() (*storagetest.DynamoDBClient, map[string]types.AttributeValue) {
+		stored := map[string]types.AttributeValue{}
+		client := &storagetest.DynamoDBClient{
+			PutItemFn: func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+				for name, value := range params.Item {
+					stored[name] = value
+				}
+				return &dynamodb.PutItemOutput{}, nil
+			},
+			GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+				return &dynamodb.GetItemOutput{Item: stored}, nil
+			},
+		}
+		return client, stored
+	}
+	storedString := func(item map[string]types.AttributeValue, name string) string {
+		return item[name].(*types.AttributeValueMemberS).Value
+	}
+
+	t.Run("create and get round trip", func(t *testing.T) {
+		client, stored := newTable()
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+		storage.encryptAllSensitive = true
+
+		entity := &models.CertificateEntity{
+			CommonName:          "example.com",
+			KeyType:             models.KeyTypeRSA2048,
+			EncryptedPrivateKey: "private-key-pem",
+			CSR:                 "csr-pem",
+			Status:              models.StatusCSRCreated,
+		}
+		require.NoError(t, storage.CreateCertificateEntity(context.Background(), entity))
+		assert.Equal(t, "kms:"+fmt.Sprintf("%x", "test-key|csr-pem"), storedString(stored, "csr"))
+		assert.Equal(t, "example.com", storedString(stored, "common_name"), "Subject fields stay searchable")
+		assert.Equal(t, "csr-pem", entity.CSR, "The caller's entity must not be modified")
+
+		loaded, err := storage.GetCertificateEntity(context.Background(), entity.ID, false)
+		require.NoError(t, err)
+		assert.Equal(t, "csr-pem", loaded.CSR)
+
+		fields, err := storage.GetCertificateEntityFields(context.Background(), entity.ID, []string{"csr"})
+		require.NoError(t, err)
+		assert.Equal(t, "csr-pem", fields.CSR)
+	})
+
+	t.Run("external key entity records the KMS key", func(t *testing.T) {
+		client, stored := newTable()
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+		storage.encryptAllSensitive = true
+
+		entity := &models.CertificateEntity{CommonName: "airgap.example.com", ExternalKey: true, CSR: "csr-pem"}
+		require.NoError(t, storage.CreateCertificateEntity(context.Background(), entity))
+		assert.Equal(t, "test-key", storedString(stored, "kms_key_id"))
+		assert.Equal(t, "test-key", entity.KMSKeyID)
+	})
+
+	t.Run("plaintext entities stay readable", func(t *testing.T) {
+		client, stored := newTable()
+		stored["id"] = &types.AttributeValueMemberS{Value: "legacy"}
+		stored["csr"] = &types.AttributeValueMemberS{Value: "csr-pem"}
+		stored["certificate"] = &types.AttributeValueMemberS{Value: "certificate-pem"}
+		kmsClient := &storagetest.KMSClient{}
+		storage := newMockStorage(client, kmsClient)
+		storage.encryptAllSensitive = true
+
+		loaded, err := storage.GetCertificateEntity(context.Background(), "legacy", false)
+		require.NoError(t, err)
+		assert.Equal(t, "csr-pem", loaded.CSR)
+		assert.Equal(t, "certificate-pem", loaded.Certificate)
+		assert.Zero(t, kmsClient.DecryptCalls)
+	})
+
+	t.Run("sealed entities stay readable when disabled", func(t *testing.T) {
+		client, stored := newTable()
+		stored["id"] = &types.AttributeValueMemberS{Value: "sealed"}
+		stored["csr"] = &types.AttributeValueMemberS{Value: "kms:" + fmt.Sprintf("%x", "old-key|csr-pem")}
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		loaded, err := storage.GetCertificateEntity(context.Background(), "sealed", false)
+		require.NoError(t, err)
+		assert.Equal(t, "csr-pem", loaded.CSR)
+	})
+
+	t.Run("uploaded certificate is encrypted", func(t *testing.T) {
+		var input *dynamodb.UpdateItemInput
+		client := &storagetest.DynamoDBClient{
+			UpdateItemFn: func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+				input = params
+				return &dynamodb.UpdateItemOutput{}, nil
+			},
+		}
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+		storage.encryptAllSensitive = true
+
+		entity := &models.CertificateEntity{ID: "a", Certificate: "certificate-pem", Status: models.StatusCertUploaded}
+		require.NoError(t, storage.UpdateCertificateEntity(context.Background(), entity, nil))
+		assert.Equal(t, "kms:"+fmt.Sprintf("%x", "test-key|certificate-pem"), storedString(input.ExpressionAttributeValues, ":certificate"))
+		assert.Equal(t, "certificate-pem", entity.Certificate)
+	})
+
+	t.Run("restore re-encrypts under the current key", func(t *testing.T) {
+		client, stored := newTable()
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+		storage.encryptAllSensitive = true
+
+		entity := &models.CertificateEntity{
+			ID:          "restored-id",
+			ExternalKey: true,
+			CSR:         "kms:" + fmt.Sprintf("%x", "old-key|csr-pem"),
+			Certificate: "certificate-pem",
+		}
+		_, err := storage.RestoreCertificateEntity(context.Background(), entity, false, false)
+		require.NoError(t, err)
+		assert.Equal(t, "kms:"+fmt.Sprintf("%x", "test-key|csr-pem"), storedString(stored, "csr"))
+		assert.Equal(t, "kms:"+fmt.Sprintf("%x", "test-key|certificate-pem"), storedString(stored, "certificate"))
+	})
+
+	t.Run("rekey re-encrypts sealed fields", func(t *testing.T) {
+		items := map[string]map[string]types.AttributeValue{
+			"a": {
+				"id":                    &types.AttributeValueMemberS{Value: "a"},
+				"encrypted_private_key": &types.AttributeValueMemberS{Value: fmt.Sprintf("%x", "old-key|private-key-a")},
+				"csr":                   &types.AttributeValueMemberS{Value: "kms:" + fmt.Sprintf("%x", "old-key|csr-a")},
+				"certificate":           &types.AttributeValueMemberS{Value: "certificate-a"},
+				"kms_key_id":            &types.AttributeValueMemberS{Value: "old-key"},
+			},
+			"b": {
+				"id":           &types.AttributeValueMemberS{Value: "b"},
+				"external_key": &types.AttributeValueMemberBOOL{Value: true},
+				"csr":          &types.AttributeValueMemberS{Value: "kms:" + fmt.Sprintf("%x", "old-key|csr-b")},
+				"certificate":  &types.AttributeValueMemberS{Value: "kms:" + fmt.Sprintf("%x", "old-key|certificate-b")},
+				"kms_key_id":   &types.AttributeValueMemberS{Value: "old-key"},
+			},
+		}
+		storage := newMockStorage(newRekeyTable(items), &storagetest.KMSClient{})
+
+		result, err := storage.RekeyEntities(context.Background(), 100)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Rekeyed)
+		assert.True(t, result.Complete)
+
+		assert.Equal(t, "kms:"+fmt.Sprintf("%x", "test-key|csr-a"), storedString(items["a"], "csr"))
+		assert.Equal(t, "certificate-a", storedString(items["a"], "certificate"), "Plaintext fields are left alone")
+		assert.Equal(t, "kms:"+fmt.Sprintf("%x", "test-key|certificate-b"), storedString(items["b"], "certificate"))
+		assert.Equal(t, "test-key", storedString(items["b"], "kms_key_id"))
+
+		entity, err := storage.GetCertificateEntity(context.Background(), "b", false)
+		require.NoError(t, err)
+		assert.Equal(t, "csr-b", entity.CSR)
+		assert.Equal(t, "certificate-b", entity.Certificate)
+	})
+}
+
+// TestDecryptCache tests repeated reads of an entity within one request decrypt its key once
+func TestDecryptCache(t *testing.T) {
+	client := &storagetest.DynamoDBClient{
+		GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+				"id":                    &types.AttributeValueMemberS{Value: "entity-id"},
+				"encrypted_private_key": &types.AttributeValueMemberS{Value: fmt.Sprintf("%x", "test-key|private-key-pem")},
+			}}, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	ctx, clearCache := WithDecryptCache(context.Background())
+	for i := 0; i < 2; i++ {
+		entity, err := storage.GetCertificateEntity(ctx, "entity-id", true)
+		require.NoError(t, err)
+		assert.Equal(t, "private-key-pem", entity.EncryptedPrivateKey)
+	}
+	assert.Equal(t, 1, kmsClient.DecryptCalls, "The second read is served from the cache")
+
+	// Once cleared, nothing is cached any more
+	clearCache()
+	_, err := storage.GetCertificateEntity(ctx, "entity-id", true)
+	require.NoError(t, err)
+	_, err = storage.GetCertificateEntity(ctx, "entity-id", true)
+	require.NoError(t, err)
+	assert.Equal(t, 3, kmsClient.DecryptCalls)
+
+	t.Run("without a cache every read decrypts", func(t *testing.T) {
+		kmsClient := &storagetest.KMSClient{}
+		storage := newMockStorage(client, kmsClient)
+		for i := 0; i < 2; i++ {
+			_, err := storage.GetCertificateEntity(context.Background(), "entity-id", true)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, kmsClient.DecryptCalls)
+	})
+
+	t.Run("failed decrypts are not cached", func(t *testing.T) {
+		kmsClient := &storagetest.KMSClient{DecryptFn: func(ctx context.Context, params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+			return nil, errors.New("throttled")
+		}}
+		storage := newMockStorage(client, kmsClient)
+		ctx, clearCache := WithDecryptCache(context.Background())
+		defer clearCache()
+		for i := 0; i < 2; i++ {
+			_, err := storage.GetCertificateEntity(ctx, "entity-id", true)
+			require.Error(t, err)
+		}
+		assert.Equal(t, 2, kmsClient.DecryptCalls)
+	})
+
+	t.Run("size bound", func(t *testing.T) {
+		cache := &decryptCache{entries: make(map[[sha256.Size]byte]string)}
+		for i := 0; i < maxDecryptCacheEntries+10; i++ {
+			cache.put("test-key", nil, fmt.Sprintf("ciphertext-%d", i), "plaintext")
+		}
+		assert.Len(t, cache.entries, maxDecryptCacheEntries)
+		_, ok := cache.get("test-key", nil, fmt.Sprintf("ciphertext-%d", maxDecryptCacheEntries))
+		assert.False(t, ok)
+	})
+
+	t.Run("hits require the same key and encryption context", func(t *testing.T) {
+		kmsClient := &storagetest.KMSClient{}
+		storage := newMockStorage(client, kmsClient)
+		ctx, clearCache := WithDecryptCache(context.Background())
+		defer clearCache()
+
+		bound := map[string]string{"entity_id": "entity-id", "tag:data_class": "restricted"}
+		ciphertext, keyID, err := storage.encryptData(ctx, "private-key-pem", bound)
+		require.NoError(t, err)
+		_, err = storage.decryptData(ctx, ciphertext, keyID, bound)
+		require.NoError(t, err)
+		_, err = storage.decryptData(ctx, ciphertext, keyID, map[string]string{"tag:data_class": "restricted", "entity_id": "entity-id"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, kmsClient.DecryptCalls, "An equal context is a hit")
+
+		// The bound tag changed since the key was encrypted: KMS must see the new context and refuse it
+		_, err = storage.decryptData(ctx, ciphertext, keyID, map[string]string{"entity_id": "entity-id", "tag:data_class": "public"})
+		assert.Error(t, err)
+		assert.Equal(t, 2, kmsClient.DecryptCalls, "A different context is a miss")
+
+		_, err = storage.decryptData(ctx, ciphertext, "other-key", bound)
+		require.NoError(t, err, "KMS selects the key from the ciphertext")
+		assert.Equal(t, 4, kmsClient.DecryptCalls, "Another key ID is a miss")
+	})
+}
+
+// TestDecryptSelectsEntityKey tests that entities encrypted under different KMS keys each decrypt
+// with the key they record, and that legacy entities without a key ID use the configured key
+func TestDecryptSelectsEntityKey(t *testing.T) {
+	item := func(id, keyID, recordedKeyID string) map[string]types.AttributeValue {
+		item := map[string]types.AttributeValue{
+			"id":                    &types.AttributeValueMemberS{Value: id},
+			"common_name":           &types.AttributeValueMemberS{Value: id + ".example.com"},
+			"encrypted_private_key": &types.AttributeValueMemberS{Value: fmt.Sprintf("%x", keyID+"|private-key-"+id)},
+		}
+		if recordedKeyID != "" {
+			item["kms_key_id"] = &types.AttributeValueMemberS{Value: recordedKeyID}
+		}
+		return item
+	}
+	items := map[string]map[string]types.AttributeValue{
+		"a":      item("a", "key-a", "key-a"),
+		"b":      item("b", "key-b", "key-b"),
+		"legacy": item("legacy", "test-key", ""),
+	}
+	client := &storagetest.DynamoDBClient{
+		GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: items[params.Key["id"].(*types.AttributeValueMemberS).Value]}, nil
+		},
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			return &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{items["a"], items["b"], items["legacy"]}}, nil
+		},
+	}
+
+	for id, keyID := range map[string]string{"a": "key-a", "b": "key-b", "legacy": "test-key"} {
+		kmsClient := &storagetest.KMSClient{}
+		storage := newMockStorage(client, kmsClient)
+		entity, err := storage.GetCertificateEntity(context.Background(), id, true)
+		require.NoError(t, err)
+		assert.Equal(t, "private-key-"+id, entity.EncryptedPrivateKey)
+		assert.Equal(t, []string{keyID}, kmsClient.DecryptKeyIDs, "Entity %s must decrypt with its own key on the first call", id)
+	}
+
+	t.Run("list decrypts every key", func(t *testing.T) {
+		kmsClient := &storagetest.KMSClient{}
+		storage := newMockStorage(client, kmsClient)
+		entities, entityErrors, err := storage.ListCertificateEntities(context.Background(), models.SearchFilters{SortBy: "common_name", SortOrder: "asc"})
+		require.NoError(t, err)
+		assert.Empty(t, entityErrors)
+		require.Len(t, entities, 3)
+		for _, entity := range entities {
+			assert.Equal(t, "private-key-"+entity.ID, entity.EncryptedPrivateKey)
+		}
+		assert.ElementsMatch(t, []string{"key-a", "key-b", "test-key"}, kmsClient.DecryptKeyIDs)
+	})
+
+	t.Run("legacy entity under a rotated key", func(t *testing.T) {
+		items["rotated"] = item("rotated", "old-key", "")
+		defer delete(items, "rotated")
+		kmsClient := &storagetest.KMSClient{}
+		storage := newMockStorage(client, kmsClient)
+
+		entity, err := storage.GetCertificateEntity(context.Background(), "rotated", true)
+		require.NoError(t, err)
+		assert.Equal(t, "private-key-rotated", entity.EncryptedPrivateKey)
+		assert.Equal(t, []string{"test-key", ""}, kmsClient.DecryptKeyIDs, "KMS selects the key once the configured key is rejected")
+	})
+
+	t.Run("sealed field under an older key", func(t *testing.T) {
+		items["mixed"] = map[string]types.AttributeValue{
+			"id":                    &types.AttributeValueMemberS{Value: "mixed"},
+			"encrypted_private_key": &types.AttributeValueMemberS{Value: fmt.Sprintf("%x", "key-b|private-key-mixed")},
+			"csr":                   &types.AttributeValueMemberS{Value: "kms:" + fmt.Sprintf("%x", "key-a|csr-mixed")},
+			"kms_key_id":            &types.AttributeValueMemberS{Value: "key-b"},
+		}
+		defer delete(items, "mixed")
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		entity, err := storage.GetCertificateEntity(context.Background(), "mixed", true)
+		require.NoError(t, err)
+		assert.Equal(t, "csr-mixed", entity.CSR)
+		assert.Equal(t, "private-key-mixed", entity.EncryptedPrivateKey)
+	})
+}
+
+// TestTouchCertificateEntity tests a touch writes only the timestamps of an existing entity
+func TestTouchCertificateEntity(t *testing.T) {
+	var input *dynamodb.UpdateItemInput
+	client := &storagetest.DynamoDBClient{
+		UpdateItemFn: func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			input = params
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+
+	before := time.Now().UTC().Truncate(time.Second)
+	touchedAt, err := storage.TouchCertificateEntity(context.Background(), "entity-1")
+	require.NoError(t, err)
+	assert.WithinDuration(t, before, touchedAt, 2*time.Second)
+
+	assert.Equal(t, "entity-1", input.Key["id"].(*types.AttributeValueMemberS).Value)
+	assert.Equal(t, "SET #updated_at = :now, #last_seen_at = :now", aws.ToString(input.UpdateExpression))
+	assert.Equal(t, "attribute_exists(id)", aws.ToString(input.ConditionExpression))
+	assert.Equal(t, map[string]string{"#updated_at": "updated_at", "#last_seen_at": "last_seen_at"}, input.ExpressionAttributeNames)
+	assert.Equal(t, map[string]types.AttributeValue{
+		":now": &types.AttributeValueMemberS{Value: touchedAt.Format(time.RFC3339)},
+	}, input.ExpressionAttributeValues, "Only the timestamp is written")
+	assert.Zero(t, kmsClient.DecryptCalls+kmsClient.EncryptCalls, "KMS is not involved")
+
+	t.Run("missing entity", func(t *testing.T) {
+		client := &storagetest.DynamoDBClient{
+			UpdateItemFn: func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+				return nil, &types.ConditionalCheckFailedException{}
+			},
+		}
+		_, err := newMockStorage(client, &storagetest.KMSClient{}).TouchCertificateEntity(context.Background(), "missing")
+		assert.ErrorIs(t, err, ErrEntityNotFound)
+	})
+}
+
+// TestCreateCertificateEntityTTL tests the TTL is written as the numeric attribute DynamoDB's TTL reads, and only when set
+func TestCreateCertificateEntityTTL(t *testing.T) {
+	client := &storagetest.DynamoDBClient{
+		PutItemFn: func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+	storage := newMockStorage(client, &storagetest.KMSClient{})
+
+	createdAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	entity := &models.CertificateEntity{
+		CommonName: "dev.example.com",
+		ExpiresTTL: models.EntityTTL(createdAt, 7),
+		CreatedAt:  createdAt,
+	}
+	require.NoError(t, storage.CreateCertificateEntity(context.Background(), entity))
+
+	err := storage.CreateCertificateEntity(context.Background(), &models.CertificateEntity{CommonName: "prod.example.com"})
+	require.NoError(t, err)
+	require.Len(t, client.PutItemInputs, 2)
+
+	ttl, ok := client.PutItemInputs[0].Item["expires_ttl"].(*types.AttributeValueMemberN)
+	require.True(t, ok, "expires_ttl must be a Number attribute")
+	assert.Equal(t, "1705919400", ttl.Value)
+
+	assert.NotContains(t, client.PutItemInputs[1].Item, "expires_ttl")
+}
+
+// TestKMSContextTags tests configured tags are bound into the private key's encryption context,
+// so the key no longer decrypts once a bound tag's value changes, and that updates refuse to change them
+func TestKMSContextTags(t *testing.T) {
+	client := &storagetest.DynamoDBClient{}
+	kmsClient := &storagetest.KMSClient{}
+	storage := newMockStorage(client, kmsClient)
+	storage.contextTags = []string{"data_class", "env"}
+
+	entity := &models.CertificateEntity{
+		ID:                  "entity-1",
+		CommonName:          "pci.example.com",
+		EncryptedPrivateKey: "private-key-pem",
+		Tags:                map[string]string{"data_class": "pci", "team": "payments"},
+	}
+	require.NoError(t, storage.CreateCertificateEntity(context.Background(), entity))
+	assert.Equal(t, []string{"data_class"}, entity.KMSContextTags, "Only configured tags the entity has are bound")
+
+	require.Len(t, client.PutItemInputs, 1)
+	stored := client.PutItemInputs[0].Item
+	storedKey := stored["encrypted_private_key"].(*types.AttributeValueMemberS).Value
+	assert.Equal(t, fmt.Sprintf("%x", "test-key#tag:data_class=pci|private-key-pem"), storedKey)
+
+	client.GetItemFn = func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{Item: stored}, nil
+	}
+	got, err := storage.GetCertificateEntity(context.Background(), "entity-1", true)
+	require.NoError(t, err)
+	assert.Equal(t, "private-key-pem", got.EncryptedPrivateKey)
+
+	t.Run("changed tag value fails decryption", func(t *testing.T) {
+		tampered := make(map[string]types.AttributeValue, len(stored))
+		for name, value := range stored {
+			tampered[name] = value
+		}
+		tags, err := attributevalue.Marshal(map[string]string{"data_class": "public", "team": "payments"})
+		require.NoError(t, err)
+		tampered["tags"] = tags
+		client := &storagetest.DynamoDBClient{
+			GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+				return &dynamodb.GetItemOutput{Item: tampered}, nil
+			},
+		}
+		storage := newMockStorage(client, &storagetest.KMSClient{})
+
+		_, err = storage.GetCertificateEntity(context.Background(), "entity-1", true)
+		var invalidCiphertext *kmstypes.InvalidCiphertextException
+		assert.ErrorAs(t, err, &invalidCiphertext)
+
+		_, reason, err := storage.VerifyPrivateKey(context.Background(), "entity-1")
+		require.NoError(t, err)
+		assert.Equal(t, "failed to decrypt private key: InvalidCiphertextException", reason)
+	})
+
+	t.Run("metadata updates keep bound tags", func(t *testing.T) {
+		var updates int
+		client.UpdateItemFn = func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			updates++
+			return &dynamodb.UpdateItemOutput{Attributes: stored}, nil
+		}
+
+		_, err := storage.UpdateMetadata(context.Background(), "entity-1", nil, map[string]string{"data_class": "public"}, true)
+		assert.ErrorIs(t, err, ErrContextTagBound)
+		_, err = storage.UpdateMetadata(context.Background(), "entity-1", nil, map[string]string{}, true)
+		assert.ErrorIs(t, err, ErrContextTagBound, "Removing a bound tag is refused too")
+		_, _, err = storage.TransferOwnership(context.Background(), "entity-1", map[string]string{"data_class": "public"}, nil)
+		assert.ErrorIs(t, err, ErrContextTagBound)
+		assert.Zero(t, updates)
+
+		_, err = storage.UpdateMetadata(context.Background(), "entity-1", nil, map[string]string{"data_class": "pci", "env": "prod"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, updates)
+	})
+
+	t.Run("re-encryption rebinds the current tags", func(t *testing.T) {
+		var input *dynamodb.UpdateItemInput
+		client.UpdateItemFn = func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			input = params
+			return &dynamodb.UpdateItemOutput{}, nil
+		}
+
+		entity := &models.CertificateEntity{ID: "entity-1", EncryptedPrivateKey: "private-key-pem", Status: models.StatusCSRCreated}
+		require.NoError(t, storage.UpdateCertificateEntity(context.Background(), entity, nil))
+		assert.True(t, strings.HasSuffix(aws.ToString(input.UpdateExpression), " REMOVE #kms_context_tags"))
+		assert.Equal(t, fmt.Sprintf("%x", "test-key|private-key-pem"), input.ExpressionAttributeValues[":encrypted_private_key"].(*types.AttributeValueMemberS).Value)
+
+		entity = &models.CertificateEntity{ID: "entity-1", EncryptedPrivateKey: "private-key-pem", Status: models.StatusCSRCreated, Tags: map[string]string{"env": "prod"}}
+		require.NoError(t, storage.UpdateCertificateEntity(context.Background(), entity, nil))
+		assert.Contains(t, aws.ToString(input.UpdateExpression), "#kms_context_tags = :kms_context_tags")
+		assert.NotContains(t, aws.ToString(input.UpdateExpression), "REMOVE")
+		assert.Equal(t, fmt.Sprintf("%x", "test-key#tag:env=prod|private-key-pem"), input.ExpressionAttributeValues[":encrypted_private_key"].(*types.AttributeValueMemberS).Value)
+	})
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/sirupsen/logrus"
+
+	"certificate-monkey/internal/audit"
+	"certificate-monkey/internal/models"
+)
+
+// eventTimestampFormat is a fixed-width RFC 3339 layout, so the timestamp sort key orders
+// lexically in time order; RFC3339Nano drops trailing zeros and does not.
+const eventTimestampFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// EventStore persists the certificate event log in its own DynamoDB table, partitioned by
+// entity_id with the event timestamp as sort key
+type EventStore struct {
+	client    DynamoDBAPI
+	tableName string
+	logger    *logrus.Logger
+}
+
+// The event store is the DynamoDB audit sink
+var _ audit.Sink = (*EventStore)(nil)
+
+// NewEventStore creates an event store writing to tableName
+func NewEventStore(client DynamoDBAPI, tableName string, logger *logrus.Logger) *EventStore {
+	return &EventStore{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}
+}
+
+// RecordEvent appends an event to the log, stamping it with the current time when it has none.
+// An existing event is never overwritten.
+func (e *EventStore) RecordEvent(ctx context.Context, event *models.Event) error {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	event.Timestamp = event.Timestamp.UTC()
+
+	item, err := attributevalue.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	item["timestamp"] = &types.AttributeValueMemberS{Value: event.Timestamp.Format(eventTimestampFormat)}
+
+	_, err = e.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(e.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#timestamp)"),
+		ExpressionAttributeNames: map[string]string{
+			"#timestamp": "timestamp",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put event in DynamoDB: %w", err)
+	}
+
+	e.logger.WithFields(logrus.Fields{
+		"entity_id":  event.EntityID,
+		"event_type": event.Type,
+	}).Debug("Event recorded")
+
+	return nil
+}
+
+// DefaultHistoryPageSize is the page size ListEvents and GetStatusHistory apply when none is given
+const DefaultHistoryPageSize = 50
+
+// ListEvents returns a page of the logged events of an entity, most recent first unless
+// page.SortOrder is "asc", with the cursor of the next page, which is empty on the last one.
+// Events are kept after the entity is deleted, and an entity without events yields an empty list.
+// ErrInvalidCursor is returned for a malformed cursor or one issued for the other order.
+func (e *EventStore) ListEvents(ctx context.Context, entityID string, page models.HistoryPage) ([]models.Event, string, error) {
+	page = normalizeHistoryPage(page)
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(e.tableName),
+		KeyConditionExpression: aws.String("entity_id = :entity_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":entity_id": &types.AttributeValueMemberS{Value: entityID},
+		},
+		ScanIndexForward: aws.Bool(page.SortOrder == "asc"),
+		// One event more than the page tells whether another page follows
+		Limit: aws.Int32(int32(page.PageSize + 1)),
+	}
+	if page.Cursor != "" {
+		after, err := decodeHistoryCursor(page.Cursor, page.SortOrder)
+		if err != nil {
+			return nil, "", err
+		}
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			"entity_id": &types.AttributeValueMemberS{Value: entityID},
+			"timestamp": &types.AttributeValueMemberS{Value: after.Timestamp.Format(eventTimestampFormat)},
+		}
+	}
+
+	events := []models.Event{}
+	for len(events) <= page.PageSize {
+		result, err := e.client.Query(ctx, input)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to query events from DynamoDB: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var event models.Event
+			if err := attributevalue.UnmarshalMap(item, &event); err != nil {
+				return nil, "", fmt.Errorf("failed to unmarshal event: %w", err)
+			}
+			timestamp, ok := item["timestamp"].(*types.AttributeValueMemberS)
+			if !ok {
+				return nil, "", fmt.Errorf("event of entity %s has no timestamp", entityID)
+			}
+			if event.Timestamp, err = time.Parse(eventTimestampFormat, timestamp.Value); err != nil {
+				return nil, "", fmt.Errorf("failed to parse event timestamp: %w", err)
+			}
+			events = append(events, event)
+		}
+
+		// A query page can end early at 1 MB, so keep reading until the page is full
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+		input.Limit = aws.Int32(int32(page.PageSize + 1 - len(events)))
+	}
+
+	if len(events) > page.PageSize {
+		events = events[:page.PageSize]
+		return events, historyCursor{SortOrder: page.SortOrder, Timestamp: events[len(events)-1].Timestamp}.encode(), nil
+	}
+	return events, "", nil
+}
+
+// normalizeHistoryPage applies the default page size and the most recent first order
+func normalizeHistoryPage(page models.HistoryPage) models.HistoryPage {
+	if page.PageSize <= 0 {
+		page.PageSize = DefaultHistoryPageSize
+	}
+	if page.SortOrder != "asc" {
+		page.SortOrder = "desc"
+	}
+	return page
+}
+
+// historyCursor marks the last entry of an event log or status history page by its timestamp
+type historyCursor struct {
+	SortOrder string    `json:"o"`
+	Timestamp time.Time `json:"t"`
+	// Position is the index of the entry in the stored status history, which tells apart transitions
+	// recorded at the same instant; event log cursors have none, as events are keyed by timestamp
+	Position *int `json:"p,omitempty"`
+}
+
+// encode returns the opaque form of the cursor handed to clients
+func (c historyCursor) encode() string {
+	c.Timestamp = c.Timestamp.UTC()
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeHistoryCursor returns a cursor issued for the same order
+func decodeHistoryCursor(encoded, sortOrder string) (historyCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return historyCursor{}, ErrInvalidCursor
+	}
+	var cursor historyCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Timestamp.IsZero() {
+		return historyCursor{}, ErrInvalidCursor
+	}
+	if cursor.Position != nil && *cursor.Position < 0 {
+		return historyCursor{}, ErrInvalidCursor
+	}
+	if cursor.SortOrder != sortOrder {
+		return historyCursor{}, fmt.Errorf("%w: issued for sort_order=%s", ErrInvalidCursor, cursor.SortOrder)
+	}
+	cursor.Timestamp = cursor.Timestamp.UTC()
+	return cursor, nil
+}
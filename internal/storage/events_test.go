@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/audit"
+	"certificate-monkey/internal/models"
+	"certificate-monkey/internal/storage/storagetest"
+)
+
+func newMockEventStore(client *storagetest.DynamoDBClient) *EventStore {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	return NewEventStore(client, "test-events", logger)
+}
+
+// TestRecordEvent tests events are written to the events table with a sortable timestamp and never overwritten
+func TestRecordEvent(t *testing.T) {
+	client := &storagetest.DynamoDBClient{}
+	events := newMockEventStore(client)
+
+	event := &models.Event{
+		EntityID:  "entity-1",
+		Timestamp: time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600)),
+		Type:      models.EventCertUploaded,
+		Actor:     "ci-pipeline",
+		RequestID: "req_1",
+	}
+	require.NoError(t, events.RecordEvent(context.Background(), event))
+
+	require.Len(t, client.PutItemInputs, 1)
+	input := client.PutItemInputs[0]
+	assert.Equal(t, "test-events", aws.ToString(input.TableName))
+	assert.Equal(t, "attribute_not_exists(#timestamp)", aws.ToString(input.ConditionExpression))
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "entity-1"}, input.Item["entity_id"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "CERT_UPLOADED"}, input.Item["type"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "ci-pipeline"}, input.Item["actor"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "2026-03-01T11:00:00.000000000Z"}, input.Item["timestamp"],
+		"Timestamps are stored in UTC at a fixed width so they sort in time order")
+
+	// An event without a timestamp is stamped with the current time
+	unstamped := &models.Event{EntityID: "entity-1", Type: models.EventDeleted}
+	require.NoError(t, events.RecordEvent(context.Background(), unstamped))
+	assert.WithinDuration(t, time.Now(), unstamped.Timestamp, time.Minute)
+
+	client.PutItemFn = func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+		return nil, errors.New("throttled")
+	}
+	err := events.RecordEvent(context.Background(), &models.Event{EntityID: "entity-1", Type: models.EventDeleted})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "throttled")
+}
+
+// TestEventStoreAuditSink tests the event store records a sample event when used as the DynamoDB audit sink
+func TestEventStoreAuditSink(t *testing.T) {
+	client := &storagetest.DynamoDBClient{}
+	var sink audit.Sink = newMockEventStore(client)
+
+	require.NoError(t, sink.RecordEvent(context.Background(), &models.Event{
+		EntityID: "entity-1",
+		Type:     models.EventPrivateKeyExported,
+		Actor:    "ci-pipeline",
+	}))
+
+	require.Len(t, client.PutItemInputs, 1)
+	assert.Equal(t, "test-events", aws.ToString(client.PutItemInputs[0].TableName))
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "PRIVATE_KEY_EXPORTED"}, client.PutItemInputs[0].Item["type"])
+}
+
+// TestListEvents tests an entity's events are queried in the requested order across query pages
+func TestListEvents(t *testing.T) {
+	pages := []*dynamodb.QueryOutput{
+		{
+			Items: []map[string]types.AttributeValue{{
+				"entity_id": &types.AttributeValueMemberS{Value: "entity-1"},
+				"timestamp": &types.AttributeValueMemberS{Value: "2026-03-01T11:00:00.000000000Z"},
+				"type":      &types.AttributeValueMemberS{Value: "KEY_CREATED"},
+				"actor":     &types.AttributeValueMemberS{Value: "ci-pipeline"},
+			}},
+			LastEvaluatedKey: map[string]types.AttributeValue{
+				"entity_id": &types.AttributeValueMemberS{Value: "entity-1"},
+				"timestamp": &types.AttributeValueMemberS{Value: "2026-03-01T11:00:00.000000000Z"},
+			},
+		},
+		{
+			Items: []map[string]types.AttributeValue{{
+				"entity_id":  &types.AttributeValueMemberS{Value: "entity-1"},
+				"timestamp":  &types.AttributeValueMemberS{Value: "2026-03-02T09:30:00.000000000Z"},
+				"type":       &types.AttributeValueMemberS{Value: "PRIVATE_KEY_EXPORTED"},
+				"request_id": &types.AttributeValueMemberS{Value: "req_2"},
+			}},
+		},
+	}
+
+	var inputs []*dynamodb.QueryInput
+	client := &storagetest.DynamoDBClient{
+		QueryFn: func(ctx context.Context, params *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			copied := *params
+			inputs = append(inputs, &copied)
+			return pages[len(inputs)-1], nil
+		},
+	}
+
+	events, nextCursor, err := newMockEventStore(client).ListEvents(context.Background(), "entity-1", models.HistoryPage{SortOrder: "asc"})
+	require.NoError(t, err)
+	assert.Empty(t, nextCursor)
+
+	require.Len(t, inputs, 2)
+	assert.Equal(t, "test-events", aws.ToString(inputs[0].TableName))
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "entity-1"}, inputs[0].ExpressionAttributeValues[":entity_id"])
+	assert.True(t, aws.ToBool(inputs[0].ScanIndexForward))
+	assert.Nil(t, inputs[0].ExclusiveStartKey)
+	assert.Equal(t, int32(DefaultHistoryPageSize+1), aws.ToInt32(inputs[0].Limit))
+	assert.Equal(t, pages[0].LastEvaluatedKey, inputs[1].ExclusiveStartKey)
+	assert.Equal(t, int32(DefaultHistoryPageSize), aws.ToInt32(inputs[1].Limit), "The second query only reads what the page still needs")
+
+	assert.Equal(t, []models.Event{
+		{
+			EntityID:  "entity-1",
+			Timestamp: time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC),
+			Type:      models.EventKeyCreated,
+			Actor:     "ci-pipeline",
+		},
+		{
+			EntityID:  "entity-1",
+			Timestamp: time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC),
+			Type:      models.EventPrivateKeyExported,
+			RequestID: "req_2",
+		},
+	}, events)
+}
+
+// TestListEventsEmpty tests an entity without events, including an unknown or deleted one, yields an empty list
+func TestListEventsEmpty(t *testing.T) {
+	events, _, err := newMockEventStore(&storagetest.DynamoDBClient{}).ListEvents(context.Background(), "unknown", models.HistoryPage{})
+	require.NoError(t, err)
+	assert.NotNil(t, events)
+	assert.Empty(t, events)
+
+	client := &storagetest.DynamoDBClient{
+		QueryFn: func(ctx context.Context, params *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			return nil, errors.New("table not found")
+		},
+	}
+	_, _, err = newMockEventStore(client).ListEvents(context.Background(), "entity-1", models.HistoryPage{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "table not found")
+}
+
+// TestListEventsPagination tests events are paged most recent first by default, with a cursor that
+// resumes the query right after the last event of the page
+func TestListEventsPagination(t *testing.T) {
+	// The table holds five events, one per day of March 2026
+	timestamps := make([]string, 5)
+	for i := range timestamps {
+		timestamps[i] = time.Date(2026, 3, i+1, 10, 0, 0, 0, time.UTC).Format(eventTimestampFormat)
+	}
+
+	var inputs []*dynamodb.QueryInput
+	client := &storagetest.DynamoDBClient{
+		QueryFn: func(ctx context.Context, params *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			copied := *params
+			inputs = append(inputs, &copied)
+
+			// Emulate DynamoDB: order by the sort key, start after ExclusiveStartKey and stop at Limit
+			ordered := append([]string(nil), timestamps...)
+			if !aws.ToBool(params.ScanIndexForward) {
+				slices.Reverse(ordered)
+			}
+			if params.ExclusiveStartKey != nil {
+				start := params.ExclusiveStartKey["timestamp"].(*types.AttributeValueMemberS).Value
+				ordered = ordered[slices.Index(ordered, start)+1:]
+			}
+			output := &dynamodb.QueryOutput{}
+			for _, timestamp := range ordered {
+				if len(output.Items) == int(aws.ToInt32(params.Limit)) {
+					output.LastEvaluatedKey = output.Items[len(output.Items)-1]
+					break
+				}
+				output.Items = append(output.Items, map[string]types.AttributeValue{
+					"entity_id": &types.AttributeValueMemberS{Value: "entity-1"},
+					"timestamp": &types.AttributeValueMemberS{Value: timestamp},
+					"type":      &types.AttributeValueMemberS{Value: "CERT_UPLOADED"},
+				})
+			}
+			return output, nil
+		},
+	}
+	events := newMockEventStore(client)
+
+	readAll := func(sortOrder string) [][]int {
+		var pages [][]int
+		cursor := ""
+		for {
+			page, next, err := events.ListEvents(context.Background(), "entity-1", models.HistoryPage{PageSize: 2, SortOrder: sortOrder, Cursor: cursor})
+			require.NoError(t, err)
+			var days []int
+			for _, event := range page {
+				days = append(days, event.Timestamp.Day())
+			}
+			pages = append(pages, days)
+			if next == "" {
+				return pages
+			}
+			cursor = next
+		}
+	}
+
+	assert.Equal(t, [][]int{{5, 4}, {3, 2}, {1}}, readAll(""), "Most recent first by default")
+	assert.False(t, aws.ToBool(inputs[0].ScanIndexForward))
+	assert.Equal(t, int32(3), aws.ToInt32(inputs[0].Limit), "One event beyond the page tells whether another follows")
+	assert.Equal(t, timestamps[3], inputs[1].ExclusiveStartKey["timestamp"].(*types.AttributeValueMemberS).Value)
+
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, readAll("asc"))
+
+	// A page that ends with the last event has no cursor
+	page, next, err := events.ListEvents(context.Background(), "entity-1", models.HistoryPage{PageSize: 5})
+	require.NoError(t, err)
+	assert.Len(t, page, 5)
+	assert.Empty(t, next)
+
+	// A cursor is only valid for the order it was issued for
+	_, next, err = events.ListEvents(context.Background(), "entity-1", models.HistoryPage{PageSize: 2})
+	require.NoError(t, err)
+	_, _, err = events.ListEvents(context.Background(), "entity-1", models.HistoryPage{PageSize: 2, SortOrder: "asc", Cursor: next})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"certificate-monkey/internal/config"
+	"certificate-monkey/internal/storage/storagetest"
+)
+
+// newMockStorage creates a storage instance backed by the given mock clients
+func newMockStorage(client *storagetest.DynamoDBClient, kmsClient *storagetest.KMSClient) *DynamoDBStorage {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	cfg := &config.Config{
+		AWS: config.AWSConfig{
+			DynamoDBTable: "test-table",
+			KMSKeyID:      "test-key",
+			KMSRekeyRate:  1000,
+		},
+	}
+
+	return NewDynamoDBStorage(client, kmsClient, cfg, logger)
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/smithy-go"
+	"github.com/sirupsen/logrus"
+)
+
+// kmsRetryBackoff is the delay before the first retry of a KMS call; it doubles per attempt
+var kmsRetryBackoff = 100 * time.Millisecond
+
+// retryableKMSErrorCodes are the KMS error codes of throttling and transient service failures.
+// Anything else, such as AccessDeniedException or InvalidCiphertextException, fails the same way
+// on every attempt and is returned at once.
+var retryableKMSErrorCodes = []string{
+	"ThrottlingException",
+	"KMSInternalException",
+	"DependencyTimeoutException",
+	"KeyUnavailableException",
+}
+
+// retryingKMS retries Encrypt and Decrypt calls that fail with a throttling or transient error, up
+// to maxAttempts calls in all with exponential backoff between them. Both calls are idempotent, so
+// a retry has the same effect as the first attempt would have had. Other calls, such as DescribeKey
+// for health checks, are passed through unchanged. The wrapped client is expected to make a single
+// attempt per call, as the server's KMS client does with the SDK retryer disabled, so attempts are
+// not multiplied by a second retry layer.
+type retryingKMS struct {
+	KMSAPI
+	maxAttempts int
+	logger      *logrus.Logger
+}
+
+// withKMSRetry wraps client so its Encrypt and Decrypt calls are retried; with maxAttempts of one
+// or less the client is returned as is
+func withKMSRetry(client KMSAPI, maxAttempts int, logger *logrus.Logger) KMSAPI {
+	if client == nil || maxAttempts <= 1 {
+		return client
+	}
+	return &retryingKMS{KMSAPI: client, maxAttempts: maxAttempts, logger: logger}
+}
+
+// Encrypt calls KMS Encrypt, retrying throttling and transient failures
+func (k *retryingKMS) Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	return retryKMSCall(ctx, k, "Encrypt", func() (*kms.EncryptOutput, error) {
+		return k.KMSAPI.Encrypt(ctx, params, optFns...)
+	})
+}
+
+// Decrypt calls KMS Decrypt, retrying throttling and transient failures
+func (k *retryingKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	return retryKMSCall(ctx, k, "Decrypt", func() (*kms.DecryptOutput, error) {
+		return k.KMSAPI.Decrypt(ctx, params, optFns...)
+	})
+}
+
+// retryKMSCall makes call until it succeeds, fails with an error that is not retryable or has been
+// made maxAttempts times, returning the last result. Waiting between attempts ends early with the
+// context's error when ctx is done.
+func retryKMSCall[T any](ctx context.Context, k *retryingKMS, operation string, call func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := call()
+		if err == nil || attempt == k.maxAttempts || !isRetryableKMSError(err) {
+			return result, err
+		}
+
+		backoff := kmsRetryBackoff << (attempt - 1)
+		k.logger.WithError(err).WithFields(logrus.Fields{
+			"operation": operation,
+			"attempt":   attempt,
+			"backoff":   backoff.String(),
+		}).Warn("KMS call failed with a transient error, retrying")
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// isRetryableKMSError reports whether err is a KMS throttling or transient service error
+func isRetryableKMSError(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && slices.Contains(retryableKMSErrorCodes, apiErr.ErrorCode())
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"certificate-monkey/internal/config"
+	"certificate-monkey/internal/storage/storagetest"
+)
+
+// newRetryingStorage creates a storage instance that tries KMS calls up to maxAttempts times,
+// with the retry backoff shortened for the duration of the test
+func newRetryingStorage(t *testing.T, kmsClient *storagetest.KMSClient, maxAttempts int) *DynamoDBStorage {
+	t.Helper()
+	backoff := kmsRetryBackoff
+	kmsRetryBackoff = time.Millisecond
+	t.Cleanup(func() { kmsRetryBackoff = backoff })
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	cfg := &config.Config{AWS: config.AWSConfig{DynamoDBTable: "test-table", KMSKeyID: "test-key", KMSMaxAttempts: maxAttempts}}
+	return NewDynamoDBStorage(&storagetest.DynamoDBClient{}, kmsClient, cfg, logger)
+}
+
+// failingFirst returns an error for the first n calls and then nil
+func failingFirst(n int, err error) func() error {
+	calls := 0
+	return func() error {
+		calls++
+		if calls <= n {
+			return err
+		}
+		return nil
+	}
+}
+
+// TestKMSRetryTransientErrors tests throttled and transiently failing KMS calls succeed once KMS recovers
+// within the allowed attempts
+func TestKMSRetryTransientErrors(t *testing.T) {
+	for name, transient := range map[string]error{
+		"throttling":       &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"},
+		"internal failure": &kmstypes.KMSInternalException{Message: aws.String("internal error")},
+		"dependency":       &kmstypes.DependencyTimeoutException{Message: aws.String("timed out")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			kmsClient := &storagetest.KMSClient{}
+			failEncrypt := failingFirst(2, transient)
+			kmsClient.EncryptFn = func(ctx context.Context, params *kms.EncryptInput) (*kms.EncryptOutput, error) {
+				if err := failEncrypt(); err != nil {
+					return nil, err
+				}
+				return &kms.EncryptOutput{CiphertextBlob: []byte("test-key|secret"), KeyId: params.KeyId}, nil
+			}
+			failDecrypt := failingFirst(2, transient)
+			kmsClient.DecryptFn = func(ctx context.Context, params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+				if err := failDecrypt(); err != nil {
+					return nil, err
+				}
+				return &kms.DecryptOutput{Plaintext: []byte("secret")}, nil
+			}
+			d := newRetryingStorage(t, kmsClient, 3)
+
+			ciphertext, keyID, err := d.encryptData(context.Background(), "secret", nil)
+			require.NoError(t, err)
+			assert.Equal(t, "test-key", keyID)
+			assert.Equal(t, 3, kmsClient.EncryptCalls)
+
+			plaintext, err := d.decryptData(context.Background(), ciphertext, keyID, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "secret", plaintext)
+			assert.Equal(t, 3, kmsClient.DecryptCalls)
+		})
+	}
+}
+
+// TestKMSRetryGivesUp tests a call still failing after the allowed attempts returns the KMS error
+func TestKMSRetryGivesUp(t *testing.T) {
+	kmsClient := &storagetest.KMSClient{}
+	kmsClient.DecryptFn = func(ctx context.Context, params *kms.DecryptInput) (*kms.DecryptOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	}
+	d := newRetryingStorage(t, kmsClient, 4)
+
+	_, err := d.decryptData(context.Background(), "74657374", "test-key", nil)
+	var apiErr smithy.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "ThrottlingException", apiErr.ErrorCode())
+	assert.Equal(t, 4, kmsClient.DecryptCalls)
+}
+
+// TestKMSRetryPermanentErrors tests errors that would fail the same way again, such as access denied,
+// are returned after a single call
+func TestKMSRetryPermanentErrors(t *testing.T) {
+	for name, permanent := range map[string]error{
+		"access denied":      &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"},
+		"invalid ciphertext": &kmstypes.InvalidCiphertextException{Message: aws.String("invalid")},
+		"disabled key":       &kmstypes.DisabledException{Message: aws.String("disabled")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			kmsClient := &storagetest.KMSClient{}
+			kmsClient.EncryptFn = func(ctx context.Context, params *kms.EncryptInput) (*kms.EncryptOutput, error) {
+				return nil, permanent
+			}
+			d := newRetryingStorage(t, kmsClient, 3)
+
+			_, _, err := d.encryptData(context.Background(), "secret", nil)
+			assert.ErrorIs(t, err, permanent)
+			assert.Equal(t, 1, kmsClient.EncryptCalls)
+		})
+	}
+}
+
+// TestKMSRetryContext tests retries stop once the context is done and one attempt disables them
+func TestKMSRetryContext(t *testing.T) {
+	kmsClient := &storagetest.KMSClient{}
+	ctx, cancel := context.WithCancel(context.Background())
+	kmsClient.EncryptFn = func(_ context.Context, params *kms.EncryptInput) (*kms.EncryptOutput, error) {
+		cancel()
+		return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	}
+	d := newRetryingStorage(t, kmsClient, 3)
+	kmsRetryBackoff = time.Hour
+
+	_, _, err := d.encryptData(ctx, "secret", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, kmsClient.EncryptCalls)
+
+	kmsClient = &storagetest.KMSClient{}
+	kmsClient.EncryptFn = func(_ context.Context, params *kms.EncryptInput) (*kms.EncryptOutput, error) {
+		return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	}
+	d = newRetryingStorage(t, kmsClient, 1)
+	_, _, err = d.encryptData(context.Background(), "secret", nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, kmsClient.EncryptCalls)
+}
@@ -0,0 +1,191 @@
+// Package storagetest provides configurable DynamoDB and KMS clients for tests of code built on storage.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// DynamoDBClient implements storage.DynamoDBAPI with overridable per-operation behaviour.
+// Operations without a configured function succeed with an empty output.
+type DynamoDBClient struct {
+	PutItemFn       func(ctx context.Context, params *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	GetItemFn       func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	UpdateItemFn    func(ctx context.Context, params *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	DeleteItemFn    func(ctx context.Context, params *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	ScanFn          func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	QueryFn         func(ctx context.Context, params *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	DescribeTableFn func(ctx context.Context, params *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error)
+	TransactWriteFn func(ctx context.Context, params *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+
+	mu sync.Mutex
+	// PutItemInputs and UpdateItemInputs record every PutItem and UpdateItem, in call order
+	PutItemInputs    []*dynamodb.PutItemInput
+	UpdateItemInputs []*dynamodb.UpdateItemInput
+}
+
+// NewItemClient returns a client serving item to every GetItem, or no item when item is nil
+func NewItemClient(item map[string]types.AttributeValue) *DynamoDBClient {
+	return &DynamoDBClient{
+		GetItemFn: func(ctx context.Context, params *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: item}, nil
+		},
+	}
+}
+
+// NewScanClient returns a client serving items to every Scan as a single page
+func NewScanClient(items []map[string]types.AttributeValue) *DynamoDBClient {
+	return &DynamoDBClient{
+		ScanFn: func(ctx context.Context, params *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			return &dynamodb.ScanOutput{Items: items}, nil
+		},
+	}
+}
+
+func (m *DynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.mu.Lock()
+	m.PutItemInputs = append(m.PutItemInputs, params)
+	m.mu.Unlock()
+	if m.PutItemFn != nil {
+		return m.PutItemFn(ctx, params)
+	}
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *DynamoDBClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if m.GetItemFn != nil {
+		return m.GetItemFn(ctx, params)
+	}
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (m *DynamoDBClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	m.mu.Lock()
+	m.UpdateItemInputs = append(m.UpdateItemInputs, params)
+	m.mu.Unlock()
+	if m.UpdateItemFn != nil {
+		return m.UpdateItemFn(ctx, params)
+	}
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (m *DynamoDBClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if m.DeleteItemFn != nil {
+		return m.DeleteItemFn(ctx, params)
+	}
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m *DynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if m.ScanFn != nil {
+		return m.ScanFn(ctx, params)
+	}
+	return &dynamodb.ScanOutput{}, nil
+}
+
+func (m *DynamoDBClient) Query(ctx context.Context, params *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if m.QueryFn != nil {
+		return m.QueryFn(ctx, params)
+	}
+	return &dynamodb.QueryOutput{}, nil
+}
+
+func (m *DynamoDBClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	if m.DescribeTableFn != nil {
+		return m.DescribeTableFn(ctx, params)
+	}
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableName: params.TableName, TableStatus: types.TableStatusActive}}, nil
+}
+
+func (m *DynamoDBClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if m.TransactWriteFn != nil {
+		return m.TransactWriteFn(ctx, params)
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// KMSClient implements storage.KMSAPI with a reversible fake cipher.
+// Ciphertexts have the form "<key id>|<plaintext>" so tests can tell which key was used; with an
+// encryption context they have the form "<key id>#<context>|<plaintext>".
+type KMSClient struct {
+	EncryptFn     func(ctx context.Context, params *kms.EncryptInput) (*kms.EncryptOutput, error)
+	DecryptFn     func(ctx context.Context, params *kms.DecryptInput) (*kms.DecryptOutput, error)
+	DescribeKeyFn func(ctx context.Context, params *kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error)
+
+	mu           sync.Mutex
+	EncryptCalls int
+	DecryptCalls int
+	// DecryptKeyIDs records the KeyId sent with each Decrypt call, empty when none was sent
+	DecryptKeyIDs []string
+}
+
+// EncryptedValue returns plaintext as storage holds it once KMSClient has encrypted it under keyID
+// without an encryption context, for seeding stored items
+func EncryptedValue(keyID, plaintext string) string {
+	return fmt.Sprintf("%x", keyID+"|"+plaintext)
+}
+
+func (m *KMSClient) Encrypt(ctx context.Context, params *kms.EncryptInput, _ ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	m.mu.Lock()
+	m.EncryptCalls++
+	m.mu.Unlock()
+	if m.EncryptFn != nil {
+		return m.EncryptFn(ctx, params)
+	}
+	blob := aws.ToString(params.KeyId) + "|" + string(params.Plaintext)
+	if len(params.EncryptionContext) > 0 {
+		blob = aws.ToString(params.KeyId) + "#" + encodeEncryptionContext(params.EncryptionContext) + "|" + string(params.Plaintext)
+	}
+	return &kms.EncryptOutput{CiphertextBlob: []byte(blob), KeyId: params.KeyId}, nil
+}
+
+func (m *KMSClient) Decrypt(ctx context.Context, params *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	m.mu.Lock()
+	m.DecryptCalls++
+	m.DecryptKeyIDs = append(m.DecryptKeyIDs, aws.ToString(params.KeyId))
+	m.mu.Unlock()
+	if m.DecryptFn != nil {
+		return m.DecryptFn(ctx, params)
+	}
+	keyID, plaintext, found := strings.Cut(string(params.CiphertextBlob), "|")
+	if !found {
+		return nil, fmt.Errorf("invalid ciphertext")
+	}
+	// Like KMS, fail unless the request carries the exact encryption context used to encrypt
+	keyID, encryptionContext, _ := strings.Cut(keyID, "#")
+	if encryptionContext != encodeEncryptionContext(params.EncryptionContext) {
+		return nil, &kmstypes.InvalidCiphertextException{Message: aws.String("The ciphertext refers to a customer master key that does not exist, does not exist in this region, or you are not allowed to access")}
+	}
+	// Like KMS, reject a KeyId that does not match the key the ciphertext was encrypted under
+	if params.KeyId != nil && aws.ToString(params.KeyId) != keyID {
+		return nil, &kmstypes.IncorrectKeyException{Message: aws.String("The key ID in the request does not identify a KMS key that can perform this operation")}
+	}
+	return &kms.DecryptOutput{Plaintext: []byte(plaintext), KeyId: aws.String(keyID)}, nil
+}
+
+func (m *KMSClient) DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, _ ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	if m.DescribeKeyFn != nil {
+		return m.DescribeKeyFn(ctx, params)
+	}
+	// Treat the configured key ID as its own ARN
+	return &kms.DescribeKeyOutput{KeyMetadata: &kmstypes.KeyMetadata{KeyId: params.KeyId, Arn: params.KeyId, KeyState: kmstypes.KeyStateEnabled}}, nil
+}
+
+// encodeEncryptionContext renders an encryption context in a canonical form, empty when there is none
+func encodeEncryptionContext(encryptionContext map[string]string) string {
+	pairs := make([]string, 0, len(encryptionContext))
+	for key, value := range encryptionContext {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
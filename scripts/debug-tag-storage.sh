@@ -81,7 +81,7 @@ verify_via_api() {
 
     # Test tag search
     echo -e "${YELLOW}Testing tag search:${NC}"
-    search_response=$(curl -s "$API_BASE_URL/api/v1/keys?environment=debug" \
+    search_response=$(curl -sg "$API_BASE_URL/api/v1/keys?tags[environment]=debug" \
         -H "X-API-Key: $API_KEY")
 
     search_count=$(echo "$search_response" | grep -o '"id"' | wc -l)
@@ -27,9 +27,9 @@ echo "  • The fix is in: internal/storage/dynamodb.go lines 233-236"
 echo ""
 
 echo "🚀 Tag search should now work with:"
-echo "  • GET /api/v1/keys?environment=production"
-echo "  • GET /api/v1/keys?project=web-server"
-echo "  • GET /api/v1/keys?environment=production&team=platform"
+echo "  • GET /api/v1/keys?tags[environment]=production"
+echo "  • GET /api/v1/keys?tags[project]=web-server"
+echo "  • GET /api/v1/keys?tags[environment]=production&tags[team]=platform"
 echo ""
 
 echo "✅ Fix applied successfully!"
@@ -113,8 +113,8 @@ test_tag_searches() {
 
     # Test 1: Search by environment
     echo -e "${YELLOW}Test 1: Search for production certificates${NC}"
-    echo -e "Query: ${BLUE}?environment=production${NC}"
-    result=$(curl -s "$API_BASE_URL/api/v1/keys?environment=production" \
+    echo -e "Query: ${BLUE}?tags[environment]=production${NC}"
+    result=$(curl -sg "$API_BASE_URL/api/v1/keys?tags[environment]=production" \
         -H "X-API-Key: $API_KEY")
 
     count=$(echo "$result" | grep -o '"common_name"' | wc -l)
@@ -126,8 +126,8 @@ test_tag_searches() {
 
     # Test 2: Search by project
     echo -e "${YELLOW}Test 2: Search for api-gateway project${NC}"
-    echo -e "Query: ${BLUE}?project=api-gateway${NC}"
-    result=$(curl -s "$API_BASE_URL/api/v1/keys?project=api-gateway" \
+    echo -e "Query: ${BLUE}?tags[project]=api-gateway${NC}"
+    result=$(curl -sg "$API_BASE_URL/api/v1/keys?tags[project]=api-gateway" \
         -H "X-API-Key: $API_KEY")
 
     count=$(echo "$result" | grep -o '"common_name"' | wc -l)
@@ -139,8 +139,8 @@ test_tag_searches() {
 
     # Test 3: Search by team
     echo -e "${YELLOW}Test 3: Search for platform team certificates${NC}"
-    echo -e "Query: ${BLUE}?team=platform${NC}"
-    result=$(curl -s "$API_BASE_URL/api/v1/keys?team=platform" \
+    echo -e "Query: ${BLUE}?tags[team]=platform${NC}"
+    result=$(curl -sg "$API_BASE_URL/api/v1/keys?tags[team]=platform" \
         -H "X-API-Key: $API_KEY")
 
     count=$(echo "$result" | grep -o '"common_name"' | wc -l)
@@ -152,8 +152,8 @@ test_tag_searches() {
 
     # Test 4: Multiple tag search
     echo -e "${YELLOW}Test 4: Search for production AND web-server project${NC}"
-    echo -e "Query: ${BLUE}?environment=production&project=web-server${NC}"
-    result=$(curl -s "$API_BASE_URL/api/v1/keys?environment=production&project=web-server" \
+    echo -e "Query: ${BLUE}?tags[environment]=production&tags[project]=web-server${NC}"
+    result=$(curl -sg "$API_BASE_URL/api/v1/keys?tags[environment]=production&tags[project]=web-server" \
         -H "X-API-Key: $API_KEY")
 
     count=$(echo "$result" | grep -o '"common_name"' | wc -l)
@@ -165,8 +165,8 @@ test_tag_searches() {
 
     # Test 5: Combined with other filters
     echo -e "${YELLOW}Test 5: Search for production + RSA keys${NC}"
-    echo -e "Query: ${BLUE}?environment=production&key_type=RSA2048${NC}"
-    result=$(curl -s "$API_BASE_URL/api/v1/keys?environment=production&key_type=RSA2048" \
+    echo -e "Query: ${BLUE}?tags[environment]=production&key_type=RSA2048${NC}"
+    result=$(curl -sg "$API_BASE_URL/api/v1/keys?tags[environment]=production&key_type=RSA2048" \
         -H "X-API-Key: $API_KEY")
 
     count=$(echo "$result" | grep -o '"common_name"' | wc -l)
@@ -178,8 +178,8 @@ test_tag_searches() {
 
     # Test 6: Custom tag search
     echo -e "${YELLOW}Test 6: Search for temporary certificates${NC}"
-    echo -e "Query: ${BLUE}?temporary=true${NC}"
-    result=$(curl -s "$API_BASE_URL/api/v1/keys?temporary=true" \
+    echo -e "Query: ${BLUE}?tags[temporary]=true${NC}"
+    result=$(curl -sg "$API_BASE_URL/api/v1/keys?tags[temporary]=true" \
         -H "X-API-Key: $API_KEY")
 
     count=$(echo "$result" | grep -o '"common_name"' | wc -l)
@@ -207,27 +207,27 @@ show_usage_examples() {
     echo -e "${CYAN}💡 Tag Search Usage Examples:${NC}"
     echo ""
     echo -e "${YELLOW}1. Search by single tag:${NC}"
-    echo -e "   curl '${API_BASE_URL}/api/v1/keys?environment=production' \\"
+    echo -e "   curl -g '${API_BASE_URL}/api/v1/keys?tags[environment]=production' \\"
     echo -e "     -H 'X-API-Key: your-api-key'"
     echo ""
 
     echo -e "${YELLOW}2. Search by multiple tags:${NC}"
-    echo -e "   curl '${API_BASE_URL}/api/v1/keys?environment=production&team=platform' \\"
+    echo -e "   curl -g '${API_BASE_URL}/api/v1/keys?tags[environment]=production&tags[team]=platform' \\"
     echo -e "     -H 'X-API-Key: your-api-key'"
     echo ""
 
     echo -e "${YELLOW}3. Combine with other filters:${NC}"
-    echo -e "   curl '${API_BASE_URL}/api/v1/keys?environment=production&status=CERT_UPLOADED&key_type=RSA2048' \\"
+    echo -e "   curl -g '${API_BASE_URL}/api/v1/keys?tags[environment]=production&status=CERT_UPLOADED&key_type=RSA2048' \\"
     echo -e "     -H 'X-API-Key: your-api-key'"
     echo ""
 
     echo -e "${YELLOW}4. Any custom tag:${NC}"
-    echo -e "   curl '${API_BASE_URL}/api/v1/keys?cost-center=IT-001&owner=john.doe' \\"
+    echo -e "   curl -g '${API_BASE_URL}/api/v1/keys?tags[cost-center]=IT-001&tags[owner]=john.doe' \\"
     echo -e "     -H 'X-API-Key: your-api-key'"
     echo ""
 
     echo -e "${YELLOW}5. With pagination:${NC}"
-    echo -e "   curl '${API_BASE_URL}/api/v1/keys?environment=production&page=1&page_size=10' \\"
+    echo -e "   curl -g '${API_BASE_URL}/api/v1/keys?tags[environment]=production&page=1&page_size=10' \\"
     echo -e "     -H 'X-API-Key: your-api-key'"
     echo ""
 }
@@ -238,14 +238,14 @@ test_performance() {
     echo ""
 
     start_time=$(date +%s%N)
-    result=$(curl -s "$API_BASE_URL/api/v1/keys?environment=production" \
+    result=$(curl -sg "$API_BASE_URL/api/v1/keys?tags[environment]=production" \
         -H "X-API-Key: $API_KEY")
     end_time=$(date +%s%N)
 
     duration=$(( (end_time - start_time) / 1000000 )) # Convert to milliseconds
     count=$(echo "$result" | grep -o '"common_name"' | wc -l)
 
-    echo -e "Query: ?environment=production"
+    echo -e "Query: ?tags[environment]=production"
     echo -e "Results: ${GREEN}$count certificates${NC}"
     echo -e "Time: ${YELLOW}${duration}ms${NC}"
     echo ""